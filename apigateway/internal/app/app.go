@@ -27,7 +27,7 @@ func Run(configPath string) {
 		//BackendServiceAddr: "http://" + cfg.BackendService.Host + ":" + strconv.Itoa(cfg.BackendService.Port),
 		AuthServiceAddr: authServiceAddr,
 		DataServiceAddr: dataServiceAddr,
-	})
+	}, permissionRules(cfg.Permissions))
 
 	// TODO: run http server
 	srv := server.NewServer(cfg.HTTPServer, handlers.InitHandle())
@@ -52,3 +52,15 @@ func Run(configPath string) {
 	}
 	logger.Info("HTTP-server has shut down")
 }
+
+func permissionRules(rules []config.PermissionRule) []http.PermissionRule {
+	res := make([]http.PermissionRule, 0, len(rules))
+	for _, rule := range rules {
+		res = append(res, http.PermissionRule{
+			Path:       rule.Path,
+			Methods:    rule.Methods,
+			Permission: rule.Permission,
+		})
+	}
+	return res
+}
@@ -13,6 +13,8 @@ type (
 		HTTPServer  HTTPServer        `yaml:"http"`
 		AuthService AuthServiceConfig `yaml:"auth-service"`
 		DataService DataServiceConfig `yaml:"data-service"`
+		// Permissions are checked in order before proxying, the first rule matching a request applies
+		Permissions []PermissionRule `yaml:"permissions"`
 	}
 
 	HTTPServer struct {
@@ -21,6 +23,8 @@ type (
 		WriteTimeout       time.Duration `yaml:"writeTimeout" env-default:"10s"`
 		ReadTimeout        time.Duration `yaml:"readTimeout" env-default:"10s"`
 		MaxHeaderMegabytes int           `yaml:"maxHeaderMegabytes" env-default:"1"`
+		// MaxRequestBodyMegabytes limits request bodies, uploads included
+		MaxRequestBodyMegabytes int `yaml:"maxRequestBodyMegabytes" env-default:"64"`
 	}
 
 	AuthServiceConfig struct {
@@ -31,6 +35,14 @@ type (
 		Http HTTPConfig `yaml:"http"`
 	}
 
+	// PermissionRule requires a permission for requests to Path and the paths under it.
+	// Empty Methods match every method.
+	PermissionRule struct {
+		Path       string   `yaml:"path"`
+		Methods    []string `yaml:"methods"`
+		Permission string   `yaml:"permission"`
+	}
+
 	HTTPConfig struct {
 		Schema string `yaml:"schema"`
 		Host   string `yaml:"host"`
@@ -11,9 +11,18 @@ import (
 	"net/url"
 )
 
+var errUnauthorized = errors.New("user is not authorized")
+
 type UserData struct {
-	ID   int `json:"id"`
-	Role int `json:"role"`
+	ID          int      `json:"id"`
+	Role        int      `json:"role"`
+	RoleName    string   `json:"role_name"`
+	Permissions []string `json:"permissions"`
+	// OrgID is 0 for users in no organization
+	OrgID   int    `json:"org_id"`
+	OrgRole string `json:"org_role"`
+	// Student is true while the user has a confirmed academic email
+	Student bool `json:"student"`
 }
 
 func (h *Handler) GetUserInfo(ctx *fasthttp.RequestCtx) (UserData, error) {
@@ -55,7 +64,7 @@ func (h *Handler) GetUserInfo(ctx *fasthttp.RequestCtx) (UserData, error) {
 	if resp.StatusCode != http.StatusOK {
 		logger.Warn("user is not authorized")
 
-		return UserData{}, errors.New("user is not authorized")
+		return UserData{}, errUnauthorized
 	}
 
 	var userData UserData
@@ -18,14 +18,16 @@ type Services struct {
 }
 
 type Handler struct {
-	logger   *slog.Logger
-	services Services
+	logger      *slog.Logger
+	services    Services
+	permissions []PermissionRule
 }
 
-func NewHandler(logger *slog.Logger, services Services) *Handler {
+func NewHandler(logger *slog.Logger, services Services, permissions []PermissionRule) *Handler {
 	return &Handler{
-		logger:   logger,
-		services: services,
+		logger:      logger,
+		services:    services,
+		permissions: permissions,
 	}
 }
 
@@ -35,7 +37,21 @@ func (h *Handler) InitHandle() func(ctx *fasthttp.RequestCtx) {
 
 		case strings.HasPrefix(path, "/api/v1/auth") ||
 			strings.HasPrefix(path, "/api/v1/users") ||
-			strings.HasPrefix(path, "/swagger"):
+			strings.HasPrefix(path, "/api/v1/admin") ||
+			strings.HasPrefix(path, "/api/v1/organizations") ||
+			strings.HasPrefix(path, "/api/v1/university-applications") ||
+			strings.HasPrefix(path, "/swagger") ||
+			strings.HasPrefix(path, "/.well-known/jwks.json") ||
+			// only mounted by auth-service in the local environment
+			strings.HasPrefix(path, "/dev/mailbox"):
+
+			// auth-service checks permissions itself, a rule only adds to that
+			if rule, ok := h.permissionRule(ctx); ok {
+				userData, err := h.GetUserInfo(ctx)
+				if !h.authorize(ctx, rule, userData, err) {
+					return
+				}
+			}
 
 			h.proxyRequest(ctx, h.services.AuthServiceAddr)
 
@@ -45,6 +61,15 @@ func (h *Handler) InitHandle() func(ctx *fasthttp.RequestCtx) {
 
 			userData, err := h.GetUserInfo(ctx)
 
+			if rule, ok := h.permissionRule(ctx); ok && !h.authorize(ctx, rule, userData, err) {
+				return
+			}
+
+			// the organization and the student status are set only from the token, never taken from the client
+			ctx.QueryArgs().Del("user_org_id")
+			ctx.QueryArgs().Del("user_org_role")
+			ctx.QueryArgs().Del("user_student")
+
 			if err != nil {
 				ctx.QueryArgs().Del("user_id")
 				ctx.QueryArgs().Del("user_role")
@@ -54,6 +79,15 @@ func (h *Handler) InitHandle() func(ctx *fasthttp.RequestCtx) {
 				ctx.QueryArgs().SetUint("user_id", userData.ID)
 				ctx.QueryArgs().SetUint("user_role", userData.Role)
 				ctx.QueryArgs().SetUint("user_id_to_get", userData.ID)
+
+				if userData.OrgID != 0 {
+					ctx.QueryArgs().SetUint("user_org_id", userData.OrgID)
+					ctx.QueryArgs().Set("user_org_role", userData.OrgRole)
+				}
+
+				if userData.Student {
+					ctx.QueryArgs().SetUint("user_student", 1)
+				}
 			}
 
 			h.proxyRequest(ctx, h.services.DataServiceAddr)
@@ -79,7 +113,7 @@ func (h *Handler) proxyRequest(ctx *fasthttp.RequestCtx, to string) {
 
 	link, err := url.Parse(to)
 	if err != nil {
-		logger.Error("failed on parse url: ", err.Error())
+		logger.Error("failed on parse url", sl.Err(err))
 		return
 	}
 	link.Path = string(ctx.Path())
@@ -0,0 +1,75 @@
+package http
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/valyala/fasthttp"
+	"slices"
+	"strings"
+)
+
+// PermissionRule requires a permission for requests to Path and the paths under it,
+// e.g. /api/v1/tours covers /api/v1/tours/5 but not /api/v1/tours-archive.
+// Empty Methods match every method.
+type PermissionRule struct {
+	Path       string
+	Methods    []string
+	Permission string
+}
+
+func (r PermissionRule) matches(method string, path string) bool {
+	prefix := strings.TrimSuffix(r.Path, "/")
+	if path != prefix && !strings.HasPrefix(path, prefix+"/") {
+		return false
+	}
+
+	if len(r.Methods) == 0 {
+		return true
+	}
+
+	return slices.ContainsFunc(r.Methods, func(m string) bool {
+		return strings.EqualFold(m, method)
+	})
+}
+
+// permissionRule returns the first rule matching the request.
+func (h *Handler) permissionRule(ctx *fasthttp.RequestCtx) (PermissionRule, bool) {
+	method, path := string(ctx.Method()), string(ctx.Path())
+
+	for _, rule := range h.permissions {
+		if rule.matches(method, path) {
+			return rule, true
+		}
+	}
+
+	return PermissionRule{}, false
+}
+
+// authorize checks the user returned by GetUserInfo against the rule. If the request
+// must not be proxied, it responds with 401 or 403 and returns false.
+func (h *Handler) authorize(ctx *fasthttp.RequestCtx, rule PermissionRule, userData UserData, err error) bool {
+	if err != nil {
+		if errors.Is(err, errUnauthorized) {
+			errorResponse(ctx, fasthttp.StatusUnauthorized, err.Error())
+			return false
+		}
+		errorResponse(ctx, fasthttp.StatusBadGateway, "auth-service is unavailable")
+		return false
+	}
+
+	if !slices.Contains(userData.Permissions, rule.Permission) {
+		errorResponse(ctx, fasthttp.StatusForbidden, "permission "+rule.Permission+" is required")
+		return false
+	}
+
+	return true
+}
+
+// errorResponse responds in the format of auth-service errors.
+func errorResponse(ctx *fasthttp.RequestCtx, statusCode int, message string) {
+	body, _ := json.Marshal(map[string]string{"message": message})
+
+	ctx.SetStatusCode(statusCode)
+	ctx.SetContentType("application/json")
+	ctx.SetBody(body)
+}
@@ -19,6 +19,8 @@ func NewServer(httpConfig config.HTTPServer, handler fasthttp.RequestHandler) *S
 			WriteTimeout: httpConfig.WriteTimeout,
 
 			ReadTimeout: httpConfig.ReadTimeout,
+
+			MaxRequestBodySize: httpConfig.MaxRequestBodyMegabytes << 20,
 		},
 	}
 }
@@ -5,6 +5,7 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
+	"github.com/go-webauthn/webauthn/webauthn"
 	"github.com/golang-migrate/migrate/v4"
 	"github.com/golang-migrate/migrate/v4/database/postgres"
 	_ "github.com/golang-migrate/migrate/v4/source/file"
@@ -15,10 +16,14 @@ import (
 	"github.com/shamank/edutour-backend/auth-service/internal/repository"
 	"github.com/shamank/edutour-backend/auth-service/internal/server"
 	"github.com/shamank/edutour-backend/auth-service/internal/service"
+	"github.com/shamank/edutour-backend/auth-service/internal/templates"
 	"github.com/shamank/edutour-backend/auth-service/pkg/auth"
 	"github.com/shamank/edutour-backend/auth-service/pkg/email"
+	"github.com/shamank/edutour-backend/auth-service/pkg/encrypt"
 	"github.com/shamank/edutour-backend/auth-service/pkg/hash"
 	"github.com/shamank/edutour-backend/auth-service/pkg/logger/sl"
+	"github.com/shamank/edutour-backend/auth-service/pkg/sms"
+	"github.com/shamank/edutour-backend/auth-service/pkg/storage"
 	"log/slog"
 	"os"
 	"os/signal"
@@ -73,29 +78,249 @@ func Run(configDir string) {
 
 	JWTConfig := cfg.AuthConfig.JWT
 
-	tokenManager, err := auth.NewManager(JWTConfig.SignedKey, JWTConfig.AccessTokenTTL, JWTConfig.RefreshTokenTTL)
+	keyFiles := make([]auth.KeyFile, 0, len(JWTConfig.Keys))
+	for _, key := range JWTConfig.Keys {
+		keyFiles = append(keyFiles, auth.KeyFile{
+			ID:             key.ID,
+			PrivateKeyPath: key.PrivateKeyPath,
+			PublicKeyPath:  key.PublicKeyPath,
+		})
+	}
+
+	keySet, err := auth.NewKeySet(JWTConfig.SigningKeyID, keyFiles)
+	if err != nil {
+		logger.Error("error occurred loading JWT signing keys", sl.Err(err))
+		return
+	}
+
+	tokenManager, err := auth.NewManager(keySet, auth.ManagerConfig{
+		AccessTokenTTL:  JWTConfig.AccessTokenTTL,
+		RefreshTokenTTL: JWTConfig.RefreshTokenTTL,
+		Issuer:          JWTConfig.Issuer,
+		Audience:        JWTConfig.Audience,
+		Leeway:          JWTConfig.Leeway,
+	})
 	if err != nil {
 		logger.Error("error occurred generate tokenManager", sl.Err(err))
+		return
+	}
+
+	mailFrom := cfg.Mail.From
+	if mailFrom == "" {
+		mailFrom = cfg.SMTP.User
+	}
+
+	var dkimSigner *email.DKIMSigner
+	if DKIMConfig := cfg.Mail.DKIM; DKIMConfig.Domain != "" {
+		dkimKey, err := email.LoadDKIMKey(DKIMConfig.PrivateKeyPath)
+		if err != nil {
+			logger.Error("error occurred loading the DKIM key", sl.Err(err))
+			return
+		}
+
+		dkimSigner, err = email.NewDKIMSigner(DKIMConfig.Domain, DKIMConfig.Selector, dkimKey)
+		if err != nil {
+			logger.Error("error occurred creating the DKIM signer", sl.Err(err))
+			return
+		}
+	}
+
+	var mailer email.Mailer
+	switch cfg.Mail.Driver {
+	case "smtp":
+		mailer, err = email.NewSMTPMailer(email.SMTPConfig{
+			Host:     cfg.SMTP.Host,
+			Port:     cfg.SMTP.Port,
+			User:     cfg.SMTP.User,
+			Password: cfg.SMTP.Password,
+			From:     mailFrom,
+			TLSMode:  cfg.SMTP.TLS,
+			Timeout:  cfg.SMTP.Timeout,
+			DKIM:     dkimSigner,
+		})
+	case "file":
+		mailer, err = email.NewFileMailer(cfg.Mail.Dir, mailFrom)
+	case "memory":
+		mailer = email.NewMemoryMailer(mailFrom)
+	default:
+		err = fmt.Errorf("unknown mail driver %q", cfg.Mail.Driver)
+	}
+	if err != nil {
+		logger.Error("error occurred creating mailer", sl.Err(err))
+		return
+	}
+
+	emailTemplates, err := templates.NewRenderer()
+	if err != nil {
+		logger.Error("error occurred parsing email templates", sl.Err(err))
+		return
+	}
+
+	var smsSender sms.SMSSender
+	switch cfg.SMS.Driver {
+	case "smsru":
+		smsSender, err = sms.NewSMSRuSender(sms.SMSRuConfig{
+			APIID:   cfg.SMS.APIID,
+			From:    cfg.SMS.From,
+			Timeout: cfg.SMS.Timeout,
+		})
+	case "log":
+		// the log would hold sign-in codes of every user with a phone
+		if cfg.Env == envProd {
+			err = fmt.Errorf("sms driver %q is not allowed in %s", cfg.SMS.Driver, envProd)
+			break
+		}
+		smsSender = sms.NewLogSender(logger)
+	default:
+		err = fmt.Errorf("unknown sms driver %q", cfg.SMS.Driver)
+	}
+	if err != nil {
+		logger.Error("error occurred creating sms sender", sl.Err(err))
+		return
+	}
+
+	var blobStorage storage.BlobStorage
+	switch cfg.Storage.Driver {
+	case "local":
+		blobStorage, err = storage.NewLocalStorage(cfg.Storage.Dir)
+		if err != nil {
+			logger.Error("error occurred creating storage", sl.Err(err))
+			return
+		}
+	default:
+		logger.Error("unknown storage driver", slog.String("driver", cfg.Storage.Driver))
+		return
+	}
+
+	hashConfig := cfg.AuthConfig.PasswordHash
+
+	hasher := hash.NewArgon2Hasher(hash.Argon2Params{
+		Time:      hashConfig.Time,
+		MemoryKiB: hashConfig.MemoryKiB,
+		Threads:   hashConfig.Threads,
+	}, hash.NewSHA256Hasher(cfg.AuthConfig.PasswordSalt))
+
+	tokenHasher, err := hash.NewHMACHasher(cfg.AuthConfig.TokenHashKey)
+	if err != nil {
+		logger.Error("error occurred creating token hasher", sl.Err(err))
+		return
 	}
 
-	SMTP := email.NewSMTPServer(cfg.SMTP.Host, cfg.SMTP.Port, cfg.SMTP.User, cfg.SMTP.Password)
-	emailManager := email.NewEmailManager(SMTP, cfg.SMTP.User)
+	encryptor, err := encrypt.NewAESGCM(cfg.AuthConfig.TwoFactor.EncryptionKey)
+	if err != nil {
+		logger.Error("error occurred creating encryptor", sl.Err(err))
+		return
+	}
 
-	hasher := hash.NewSHA256Hasher(cfg.AuthConfig.PasswordSalt)
+	webAuthnConfig := cfg.AuthConfig.WebAuthn
+
+	webAuthn, err := webauthn.New(&webauthn.Config{
+		RPID:          webAuthnConfig.RPID,
+		RPDisplayName: webAuthnConfig.RPDisplayName,
+		RPOrigins:     webAuthnConfig.RPOrigins,
+		Timeouts: webauthn.TimeoutsConfig{
+			Login:        webauthn.TimeoutConfig{Enforce: true, Timeout: webAuthnConfig.Timeout, TimeoutUVD: webAuthnConfig.Timeout},
+			Registration: webauthn.TimeoutConfig{Enforce: true, Timeout: webAuthnConfig.Timeout, TimeoutUVD: webAuthnConfig.Timeout},
+		},
+	})
+	if err != nil {
+		logger.Error("error occurred creating webauthn", sl.Err(err))
+		return
+	}
 
 	deps := service.Dependencies{
-		Cache:        memcache,
-		Hasher:       hasher,
-		TokenManager: tokenManager,
-		EmailManager: emailManager,
+		Cache:         memcache,
+		Hasher:        hasher,
+		TokenHasher:   tokenHasher,
+		TokenManager:  tokenManager,
+		Mailer:        mailer,
+		Templates:     emailTemplates,
+		SMSSender:     smsSender,
+		Encryptor:     encryptor,
+		WebAuthn:      webAuthn,
+		SchedulerTick: cfg.Scheduler.Tick,
+		OrganizationConfig: service.OrganizationConfig{
+			FrontendURL:   cfg.Frontend.BaseURL,
+			InvitationTTL: cfg.Organizations.InvitationTTL,
+		},
+		Storage: blobStorage,
+		UniversityConfig: service.UniversityConfig{
+			FrontendURL:     cfg.Frontend.BaseURL,
+			MaxDocuments:    cfg.Universities.MaxDocuments,
+			MaxDocumentSize: int64(cfg.Universities.MaxDocumentMegabytes) << 20,
+		},
+		StudentConfig: service.StudentConfig{
+			FrontendURL:     cfg.Frontend.BaseURL,
+			StatusTTL:       cfg.Students.StatusTTL,
+			ConfirmationTTL: cfg.Students.ConfirmationTTL,
+			ResendInterval:  cfg.Students.ResendInterval,
+		},
+		OutboxConfig: service.OutboxConfig{
+			PollInterval:  cfg.Outbox.PollInterval,
+			BatchSize:     cfg.Outbox.BatchSize,
+			MaxAttempts:   cfg.Outbox.MaxAttempts,
+			BackoffBase:   cfg.Outbox.BackoffBase,
+			BackoffMax:    cfg.Outbox.BackoffMax,
+			Lease:         cfg.Outbox.Lease,
+			SentRetention: cfg.Outbox.SentRetention,
+			DeadRetention: cfg.Outbox.DeadRetention,
+		},
+		AuthConfig: service.AuthConfig{
+			RefreshTokenGracePeriod: JWTConfig.RefreshGrace,
+			RevocationCacheTTL:      JWTConfig.RevocationTTL,
+			FrontendURL:             cfg.Frontend.BaseURL,
+			MagicLinkTTL:            cfg.AuthConfig.MagicLinkTTL,
+			VerificationCode: service.VerificationCodeConfig{
+				Length:      cfg.AuthConfig.VerificationCodeLength,
+				TTL:         cfg.AuthConfig.VerificationCodeTTL,
+				MaxAttempts: cfg.AuthConfig.VerificationCodeMaxAttempts,
+			},
+			WebAuthnSessionTTL:          webAuthnConfig.Timeout,
+			ConfirmationResendInterval:  cfg.AuthConfig.ConfirmationResendInterval,
+			SMSResendInterval:           cfg.AuthConfig.SMSResendInterval,
+			PasswordResetResendInterval: cfg.AuthConfig.PasswordResetResendInterval,
+			PhoneSignInLockout: service.SignInLockoutConfig{
+				MaxFailures: cfg.AuthConfig.PhoneSignInMaxFailures,
+				Window:      cfg.AuthConfig.PhoneSignInLockoutWindow,
+			},
+			UnconfirmedAccountTTL: cfg.AuthConfig.UnconfirmedAccountTTL,
+			TwoFactor: service.TwoFactorConfig{
+				Issuer:       cfg.AuthConfig.TwoFactor.Issuer,
+				ChallengeTTL: cfg.AuthConfig.TwoFactor.ChallengeTTL,
+				MaxAttempts:  cfg.AuthConfig.TwoFactor.MaxAttempts,
+				Lockout: service.SignInLockoutConfig{
+					MaxFailures: cfg.AuthConfig.TwoFactor.LockoutMaxFailures,
+					Window:      cfg.AuthConfig.TwoFactor.LockoutWindow,
+				},
+				RequiredRoles: cfg.AuthConfig.TwoFactor.RequiredRoles,
+			},
+		},
 	}
 
 	services := service.NewServices(repos, logger, deps)
 
-	handlers := handler.NewHandler(services, logger, tokenManager)
+	// the development mailbox shows captured emails, it is never mounted in prod
+	var mailbox *email.MemoryMailer
+	if memoryMailer, ok := mailer.(*email.MemoryMailer); ok && cfg.Env == envLocal {
+		mailbox = memoryMailer
+		logger.Info("captured emails are at /dev/mailbox")
+	}
+
+	handlers := handler.NewHandler(services, logger, tokenManager, mailbox)
 
 	srv := server.NewServer(cfg, handlers.InitAPI())
 
+	if err := service.RegisterHousekeepingJobs(services.Scheduler, services.Housekeeping, cfg.Scheduler.Jobs); err != nil {
+		logger.Error("error occurred when registering jobs", sl.Err(err))
+		return
+	}
+
+	workersCtx, stopWorkers := context.WithCancel(context.Background())
+	defer stopWorkers()
+
+	go services.Scheduler.Run(workersCtx)
+	go services.Outbox.Run(workersCtx)
+
 	go func() {
 		if err := srv.Start(); err != nil {
 			logger.Error("error occurred when starting the HTTP-server", sl.Err(err))
@@ -109,6 +334,8 @@ func Run(configDir string) {
 
 	<-quit
 
+	stopWorkers()
+
 	const timeout = 5 * time.Second
 
 	ctx, shutdown := context.WithTimeout(context.Background(), timeout)
@@ -9,12 +9,21 @@ import (
 
 type (
 	Config struct {
-		HTTP          HTTPConfig     `yaml:"http"`
-		SMTP          SMTPConfig     `yaml:"smtp"`
-		Postgres      PostgresConfig `yaml:"pg"`
-		AuthConfig    AuthConfig     `yaml:"auth"`
-		Env           string         `yaml:"env"`
-		MigrationPath string         `yaml:"migrationPath"`
+		HTTP          HTTPConfig          `yaml:"http"`
+		SMTP          SMTPConfig          `yaml:"smtp"`
+		Mail          MailConfig          `yaml:"mail"`
+		SMS           SMSConfig           `yaml:"sms"`
+		Postgres      PostgresConfig      `yaml:"pg"`
+		AuthConfig    AuthConfig          `yaml:"auth"`
+		Scheduler     SchedulerConfig     `yaml:"scheduler"`
+		Outbox        OutboxConfig        `yaml:"outbox"`
+		Organizations OrganizationsConfig `yaml:"organizations"`
+		Universities  UniversitiesConfig  `yaml:"universities"`
+		Students      StudentsConfig      `yaml:"students"`
+		Storage       StorageConfig       `yaml:"storage"`
+		Frontend      FrontendConfig      `yaml:"frontend"`
+		Env           string              `yaml:"env"`
+		MigrationPath string              `yaml:"migrationPath"`
 	}
 
 	HTTPConfig struct {
@@ -30,6 +39,85 @@ type (
 		Port     int    `yaml:"port"`
 		User     string `yaml:"user"`
 		Password string `env:"SMTP_PASSWORD"`
+		// TLS is "starttls" (usually port 587), "tls" (implicit, usually port 465) or "none"
+		TLS     string        `yaml:"tls" env-default:"starttls"`
+		Timeout time.Duration `yaml:"timeout" env-default:"30s"`
+	}
+
+	MailConfig struct {
+		// Driver is "smtp", "file" (a maildir for local development) or "memory"
+		Driver string `yaml:"driver" env:"MAIL_DRIVER" env-default:"smtp"`
+		// From is the sender of all emails, smtp.user if empty
+		From string `yaml:"from"`
+		// Dir is the maildir of the file driver
+		Dir  string     `yaml:"dir" env-default:"./tmp/mail"`
+		DKIM DKIMConfig `yaml:"dkim"`
+	}
+
+	DKIMConfig struct {
+		// Domain is the signing domain, emails are sent unsigned while it is empty
+		Domain   string `yaml:"domain" env:"DKIM_DOMAIN"`
+		Selector string `yaml:"selector" env-default:"mail"`
+		// PrivateKeyPath is a PEM file with the RSA key published in <selector>._domainkey.<domain>
+		PrivateKeyPath string `yaml:"privateKey" env:"DKIM_PRIVATE_KEY_PATH"`
+	}
+
+	SMSConfig struct {
+		// Driver is "smsru" or "log", which writes messages with the codes to the service log
+		// and is refused in prod
+		Driver string `yaml:"driver" env:"SMS_DRIVER" env-default:"log"`
+		APIID  string `env:"SMS_API_ID"`
+		// From is the sender name approved by the provider, the account default if empty
+		From    string        `yaml:"from"`
+		Timeout time.Duration `yaml:"timeout" env-default:"10s"`
+	}
+
+	SchedulerConfig struct {
+		// Tick is how often due jobs are looked for
+		Tick time.Duration `yaml:"tick" env-default:"30s"`
+		// Jobs overrides the default schedules by job name
+		Jobs map[string]string `yaml:"jobs"`
+	}
+
+	FrontendConfig struct {
+		// BaseURL is where links in emails lead
+		BaseURL string `yaml:"baseURL" env:"FRONTEND_BASE_URL"`
+	}
+
+	OrganizationsConfig struct {
+		// InvitationTTL is how long an invitation to an organization can be answered
+		InvitationTTL time.Duration `yaml:"invitationTTL" env-default:"168h"`
+	}
+
+	UniversitiesConfig struct {
+		// MaxDocuments is how many documents may be attached to an application
+		MaxDocuments         int `yaml:"maxDocuments" env-default:"5"`
+		MaxDocumentMegabytes int `yaml:"maxDocumentMegabytes" env-default:"10"`
+	}
+
+	StudentsConfig struct {
+		// StatusTTL is how long the student status lasts after the academic email is confirmed
+		StatusTTL       time.Duration `yaml:"statusTTL" env-default:"8760h"`
+		ConfirmationTTL time.Duration `yaml:"confirmationTTL" env-default:"24h"`
+		ResendInterval  time.Duration `yaml:"resendInterval" env-default:"1m"`
+	}
+
+	StorageConfig struct {
+		// Driver is the blob storage of uploaded files, only "local" is supported for now
+		Driver string `yaml:"driver" env-default:"local"`
+		// Dir is the directory of the local driver
+		Dir string `yaml:"dir" env:"STORAGE_DIR" env-default:"./tmp/storage"`
+	}
+
+	OutboxConfig struct {
+		PollInterval  time.Duration `yaml:"pollInterval" env-default:"2s"`
+		BatchSize     int           `yaml:"batchSize" env-default:"20"`
+		MaxAttempts   int           `yaml:"maxAttempts" env-default:"8"`
+		BackoffBase   time.Duration `yaml:"backoffBase" env-default:"30s"`
+		BackoffMax    time.Duration `yaml:"backoffMax" env-default:"1h"`
+		Lease         time.Duration `yaml:"lease" env-default:"2m"`
+		SentRetention time.Duration `yaml:"sentRetention" env-default:"168h"`
+		DeadRetention time.Duration `yaml:"deadRetention" env-default:"720h"`
 	}
 
 	PostgresConfig struct {
@@ -42,15 +130,63 @@ type (
 	}
 
 	AuthConfig struct {
-		JWT                    JWTConfig `yaml:"jwt"`
-		PasswordSalt           string    `env:"PASSWORD_SALT"`
-		VerificationCodeLength int       `yaml:"verificationCodeLength"`
+		JWT                         JWTConfig          `yaml:"jwt"`
+		PasswordHash                PasswordHashConfig `yaml:"passwordHash"`
+		PasswordSalt                string             `env:"PASSWORD_SALT"`
+		TokenHashKey                string             `env:"TOKEN_HASH_KEY"`
+		MagicLinkTTL                time.Duration      `yaml:"magicLinkTTL" env-default:"15m"`
+		TwoFactor                   TwoFactorConfig    `yaml:"twoFactor"`
+		WebAuthn                    WebAuthnConfig     `yaml:"webAuthn"`
+		VerificationCodeLength      int                `yaml:"verificationCodeLength" env-default:"6"`
+		VerificationCodeTTL         time.Duration      `yaml:"verificationCodeTTL" env-default:"15m"`
+		VerificationCodeMaxAttempts int                `yaml:"verificationCodeMaxAttempts" env-default:"5"`
+		ConfirmationResendInterval  time.Duration      `yaml:"confirmationResendInterval" env-default:"1m"`
+		SMSResendInterval           time.Duration      `yaml:"smsResendInterval" env-default:"1m"`
+		PasswordResetResendInterval time.Duration      `yaml:"passwordResetResendInterval" env-default:"1m"`
+		PhoneSignInMaxFailures      int                `yaml:"phoneSignInMaxFailures" env-default:"10"`
+		PhoneSignInLockoutWindow    time.Duration      `yaml:"phoneSignInLockoutWindow" env-default:"1h"`
+		UnconfirmedAccountTTL       time.Duration      `yaml:"unconfirmedAccountTTL" env-default:"72h"`
+	}
+
+	TwoFactorConfig struct {
+		Issuer             string        `yaml:"issuer" env-default:"EduTour"`
+		ChallengeTTL       time.Duration `yaml:"challengeTTL" env-default:"5m"`
+		MaxAttempts        int           `yaml:"maxAttempts" env-default:"5"`
+		LockoutMaxFailures int           `yaml:"lockoutMaxFailures" env-default:"10"`
+		LockoutWindow      time.Duration `yaml:"lockoutWindow" env-default:"1h"`
+		RequiredRoles      []string      `yaml:"requiredRoles" env-default:"admin,university"`
+		EncryptionKey      string        `env:"TOTP_ENCRYPTION_KEY"`
+	}
+
+	WebAuthnConfig struct {
+		RPID          string        `yaml:"rpID"`
+		RPDisplayName string        `yaml:"rpDisplayName" env-default:"EduTour"`
+		RPOrigins     []string      `yaml:"rpOrigins"`
+		Timeout       time.Duration `yaml:"timeout" env-default:"5m"`
+	}
+
+	PasswordHashConfig struct {
+		Time      uint32 `yaml:"time" env-default:"3"`
+		MemoryKiB uint32 `yaml:"memoryKiB" env-default:"65536"`
+		Threads   uint8  `yaml:"threads" env-default:"2"`
 	}
 
 	JWTConfig struct {
-		AccessTokenTTL  time.Duration `yaml:"accessTTL"`
-		RefreshTokenTTL time.Duration `yaml:"refreshTTL"`
-		SignedKey       string        `env:"JWT_SIGNED_KEY"`
+		AccessTokenTTL  time.Duration  `yaml:"accessTTL"`
+		RefreshTokenTTL time.Duration  `yaml:"refreshTTL"`
+		RefreshGrace    time.Duration  `yaml:"refreshReuseGrace"`
+		RevocationTTL   time.Duration  `yaml:"revocationCacheTTL" env-default:"30s"`
+		Issuer          string         `yaml:"issuer"`
+		Audience        string         `yaml:"audience"`
+		Leeway          time.Duration  `yaml:"leeway"`
+		SigningKeyID    string         `yaml:"signingKeyID" env:"JWT_SIGNING_KEY_ID"`
+		Keys            []JWTKeyConfig `yaml:"keys"`
+	}
+
+	JWTKeyConfig struct {
+		ID             string `yaml:"kid"`
+		PrivateKeyPath string `yaml:"privateKey"`
+		PublicKeyPath  string `yaml:"publicKey"`
 	}
 )
 
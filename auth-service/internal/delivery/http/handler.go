@@ -5,9 +5,11 @@ import (
 	v1 "github.com/shamank/edutour-backend/auth-service/internal/delivery/http/v1"
 	"github.com/shamank/edutour-backend/auth-service/internal/service"
 	"github.com/shamank/edutour-backend/auth-service/pkg/auth"
+	"github.com/shamank/edutour-backend/auth-service/pkg/email"
 	swaggerFiles "github.com/swaggo/files"
 	ginSwagger "github.com/swaggo/gin-swagger"
 	"log/slog"
+	"net/http"
 
 	_ "github.com/shamank/edutour-backend/auth-service/docs"
 )
@@ -16,13 +18,16 @@ type Handler struct {
 	services     *service.Services
 	logger       *slog.Logger
 	tokenManager auth.TokenManager
+	// mailbox is the development mailbox, nil outside the local environment
+	mailbox *email.MemoryMailer
 }
 
-func NewHandler(services *service.Services, logger *slog.Logger, tokenManager auth.TokenManager) *Handler {
+func NewHandler(services *service.Services, logger *slog.Logger, tokenManager auth.TokenManager, mailbox *email.MemoryMailer) *Handler {
 	return &Handler{
 		services:     services,
 		logger:       logger,
 		tokenManager: tokenManager,
+		mailbox:      mailbox,
 	}
 }
 
@@ -34,6 +39,11 @@ func (h *Handler) InitAPI() *gin.Engine {
 	handlerV1 := v1.NewHandler(h.services, h.logger, h.tokenManager)
 
 	router.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
+	router.GET("/.well-known/jwks.json", h.jwks)
+
+	if h.mailbox != nil {
+		h.initMailboxRouter(router)
+	}
 
 	api := router.Group("/api")
 	{
@@ -43,3 +53,15 @@ func (h *Handler) InitAPI() *gin.Engine {
 	return router
 
 }
+
+// @Summary JSON Web Key Set
+// @Tags backend
+// @Description public keys to verify access tokens with
+// @ModuleID jwks
+// @Produce  json
+// @Success 200 {object} auth.JWKS
+// @Router /.well-known/jwks.json [get]
+func (h *Handler) jwks(c *gin.Context) {
+	c.Header("Cache-Control", "public, max-age=300")
+	c.JSON(http.StatusOK, h.tokenManager.JWKS())
+}
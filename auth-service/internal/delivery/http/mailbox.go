@@ -0,0 +1,132 @@
+package http
+
+import (
+	"embed"
+	"github.com/gin-gonic/gin"
+	"github.com/shamank/edutour-backend/auth-service/pkg/email"
+	"github.com/shamank/edutour-backend/auth-service/pkg/logger/sl"
+	"html/template"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// The mailbox shows emails captured by email.MemoryMailer, so flows can be finished locally
+// without a real SMTP server. It is only mounted in the local environment.
+
+//go:embed mailbox
+var mailboxFiles embed.FS
+
+var urlPattern = regexp.MustCompile(`https?://[^\s<>"]+`)
+
+var mailboxFuncs = template.FuncMap{
+	"join": strings.Join,
+	// linkify escapes plain text and makes the URLs in it clickable
+	"linkify": func(text string) template.HTML {
+		escaped := template.HTMLEscapeString(text)
+		return template.HTML(urlPattern.ReplaceAllString(escaped, `<a href="$0" target="_blank" rel="noopener">$0</a>`))
+	},
+}
+
+type mailboxPages struct {
+	list    *template.Template
+	message *template.Template
+}
+
+func parseMailboxPages() mailboxPages {
+	parse := func(page string) *template.Template {
+		return template.Must(template.New("layout").Funcs(mailboxFuncs).
+			ParseFS(mailboxFiles, "mailbox/layout.html.tmpl", "mailbox/"+page+".html.tmpl"))
+	}
+
+	return mailboxPages{
+		list:    parse("list"),
+		message: parse("message"),
+	}
+}
+
+func (h *Handler) initMailboxRouter(router *gin.Engine) {
+	pages := parseMailboxPages()
+
+	mailbox := router.Group("/dev/mailbox")
+	{
+		mailbox.GET("", h.mailboxList(pages))
+		mailbox.POST("/clear", h.mailboxClear)
+		mailbox.GET("/:id", h.mailboxMessage(pages))
+		mailbox.GET("/:id/html", h.mailboxHTML)
+		mailbox.GET("/:id/raw", h.mailboxRaw)
+	}
+}
+
+func (h *Handler) mailboxList(pages mailboxPages) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		h.renderMailboxPage(c, pages.list, h.mailbox.Stored())
+	}
+}
+
+func (h *Handler) mailboxClear(c *gin.Context) {
+	h.mailbox.Reset()
+	c.Redirect(http.StatusSeeOther, "/dev/mailbox")
+}
+
+func (h *Handler) mailboxMessage(pages mailboxPages) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		msg, ok := h.storedMessage(c)
+		if !ok {
+			return
+		}
+
+		h.renderMailboxPage(c, pages.message, msg)
+	}
+}
+
+// mailboxHTML serves the HTML part as is, the message page shows it in a sandboxed iframe.
+func (h *Handler) mailboxHTML(c *gin.Context) {
+	msg, ok := h.storedMessage(c)
+	if !ok {
+		return
+	}
+
+	if msg.Message.HTML == "" {
+		c.String(http.StatusNotFound, "the email has no HTML part")
+		return
+	}
+
+	c.Header("Content-Security-Policy", "script-src 'none'")
+	c.Data(http.StatusOK, "text/html; charset=utf-8", []byte(msg.Message.HTML))
+}
+
+func (h *Handler) mailboxRaw(c *gin.Context) {
+	msg, ok := h.storedMessage(c)
+	if !ok {
+		return
+	}
+
+	c.Data(http.StatusOK, "text/plain; charset=utf-8", msg.Raw)
+}
+
+func (h *Handler) storedMessage(c *gin.Context) (email.StoredMessage, bool) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.String(http.StatusBadRequest, "invalid email id")
+		return email.StoredMessage{}, false
+	}
+
+	msg, ok := h.mailbox.Get(id)
+	if !ok {
+		c.String(http.StatusNotFound, "email not found")
+		return email.StoredMessage{}, false
+	}
+
+	return msg, true
+}
+
+func (h *Handler) renderMailboxPage(c *gin.Context, page *template.Template, data any) {
+	c.Header("Content-Type", "text/html; charset=utf-8")
+	c.Status(http.StatusOK)
+
+	if err := page.ExecuteTemplate(c.Writer, "layout", data); err != nil {
+		h.logger.Error("error occurred rendering the mailbox", sl.Err(err))
+	}
+}
@@ -0,0 +1,167 @@
+package v1
+
+import (
+	"errors"
+	"github.com/gin-gonic/gin"
+	"github.com/shamank/edutour-backend/auth-service/internal/domain"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+type jobOutput struct {
+	Name     string `json:"name"`
+	Schedule string `json:"schedule"`
+	// LastStatus is "ok", "failed" or empty if the job has never run
+	LastStatus     string     `json:"last_status"`
+	LastError      string     `json:"last_error,omitempty"`
+	LastStartedAt  *time.Time `json:"last_started_at"`
+	LastFinishedAt *time.Time `json:"last_finished_at"`
+	LastDurationMs int64      `json:"last_duration_ms"`
+	// LastRunBy is the replica that ran the job last
+	LastRunBy string    `json:"last_run_by"`
+	NextRunAt time.Time `json:"next_run_at"`
+}
+
+type jobsResponse struct {
+	Jobs []jobOutput `json:"jobs"`
+}
+
+type deadEmailOutput struct {
+	ID        int       `json:"id"`
+	To        []string  `json:"to"`
+	Subject   string    `json:"subject"`
+	Attempts  int       `json:"attempts"`
+	LastError string    `json:"last_error"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type deadEmailsResponse struct {
+	Emails []deadEmailOutput `json:"emails"`
+}
+
+func (h *Handler) initAdminRouter(api *gin.RouterGroup) {
+	admin := api.Group("/admin", h.userIdentity)
+	{
+		admin.GET("/jobs", h.requirePermission(domain.PermissionJobsReadAny), h.getJobs)
+
+		admin.GET("/emails/dead", h.requirePermission(domain.PermissionEmailsManageAny), h.getDeadEmails)
+		admin.POST("/emails/:id/retry", h.requirePermission(domain.PermissionEmailsManageAny), h.retryEmail)
+
+		h.initAdminUsersRouter(admin)
+		h.initAdminRolesRouter(admin)
+		h.initAdminUniversitiesRouter(admin)
+		h.initAdminAcademicDomainsRouter(admin)
+	}
+}
+
+// @Summary Scheduled Jobs
+// @Tags admin
+// @Description background jobs with their schedules and last runs on any replica
+// @ModuleID adminGetJobs
+// @Accept  json
+// @Produce  json
+// @Security ApiKeyAuth
+// @Success 200 {object} jobsResponse
+// @Failure 401,403 {object} errorResponse
+// @Failure 500 {object} errorResponse
+// @Failure default {object} errorResponse
+// @Router /admin/jobs [get]
+func (h *Handler) getJobs(c *gin.Context) {
+	res, err := h.services.Scheduler.Jobs(c.Request.Context())
+	if err != nil {
+		newErrorResponse(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	jobs := make([]jobOutput, 0, len(res))
+	for _, job := range res {
+		jobs = append(jobs, jobOutput{
+			Name:           job.Name,
+			Schedule:       job.Schedule,
+			LastStatus:     job.LastRun.LastStatus,
+			LastError:      job.LastRun.LastError,
+			LastStartedAt:  timeOrNil(job.LastRun.LastStartedAt),
+			LastFinishedAt: timeOrNil(job.LastRun.LastFinishedAt),
+			LastDurationMs: job.LastRun.LastDuration.Milliseconds(),
+			LastRunBy:      job.LastRun.RunBy,
+			NextRunAt:      job.NextRunAt,
+		})
+	}
+
+	c.JSON(http.StatusOK, jobsResponse{Jobs: jobs})
+}
+
+// @Summary Dead Emails
+// @Tags admin
+// @Description latest emails that ran out of delivery attempts
+// @ModuleID adminGetDeadEmails
+// @Accept  json
+// @Produce  json
+// @Security ApiKeyAuth
+// @Success 200 {object} deadEmailsResponse
+// @Failure 401,403 {object} errorResponse
+// @Failure 500 {object} errorResponse
+// @Failure default {object} errorResponse
+// @Router /admin/emails/dead [get]
+func (h *Handler) getDeadEmails(c *gin.Context) {
+	res, err := h.services.Outbox.GetDeadEmails(c.Request.Context())
+	if err != nil {
+		newErrorResponse(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	emails := make([]deadEmailOutput, 0, len(res))
+	for _, e := range res {
+		emails = append(emails, deadEmailOutput{
+			ID:        e.ID,
+			To:        e.To,
+			Subject:   e.Subject,
+			Attempts:  e.Attempts,
+			LastError: e.LastError,
+			CreatedAt: e.CreatedAt,
+		})
+	}
+
+	c.JSON(http.StatusOK, deadEmailsResponse{Emails: emails})
+}
+
+// @Summary Retry Email
+// @Tags admin
+// @Description queue a dead email again with a fresh set of attempts
+// @ModuleID adminRetryEmail
+// @Accept  json
+// @Produce  json
+// @Param id path int true "email id"
+// @Security ApiKeyAuth
+// @Success 200 {object} statusResponse
+// @Failure 400,401,403,404 {object} errorResponse
+// @Failure 500 {object} errorResponse
+// @Failure default {object} errorResponse
+// @Router /admin/emails/{id}/retry [post]
+func (h *Handler) retryEmail(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid email id")
+		return
+	}
+
+	if err := h.services.Outbox.RetryEmail(c.Request.Context(), id); err != nil {
+		if errors.Is(err, domain.ErrEmailNotFound) {
+			newErrorResponse(c, http.StatusNotFound, err.Error())
+			return
+		}
+		newErrorResponse(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	c.JSON(http.StatusOK, statusResponse{Status: "ok"})
+}
+
+// timeOrNil makes a never set time null in JSON instead of year 1.
+func timeOrNil(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	return &t
+}
@@ -0,0 +1,188 @@
+package v1
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/shamank/edutour-backend/auth-service/internal/domain"
+	"github.com/shamank/edutour-backend/auth-service/internal/service"
+	"net/http"
+	"time"
+)
+
+type academicDomainOutput struct {
+	ID             int       `json:"id"`
+	Domain         string    `json:"domain"`
+	UniversityName string    `json:"university_name"`
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+type academicDomainsResponse struct {
+	Domains []academicDomainOutput `json:"domains"`
+}
+
+type academicDomainCreateInput struct {
+	// Domain of the university's email addresses, e.g. msu.ru; its subdomains are allowed as well
+	Domain         string `json:"domain" binding:"required,fqdn,max=255"`
+	UniversityName string `json:"university_name" binding:"required,max=255"`
+}
+
+type academicDomainUpdateInput struct {
+	UniversityName string `json:"university_name" binding:"required,max=255"`
+}
+
+func (h *Handler) initAdminAcademicDomainsRouter(admin *gin.RouterGroup) {
+	domains := admin.Group("/academic-domains", h.requirePermission(domain.PermissionAcademicDomainsManageAny))
+	{
+		domains.GET("", h.adminGetAcademicDomains)
+		domains.POST("", h.adminCreateAcademicDomain)
+		domains.PUT("/:id", h.adminUpdateAcademicDomain)
+		domains.DELETE("/:id", h.adminDeleteAcademicDomain)
+	}
+}
+
+// @Summary Academic Domains
+// @Tags admin
+// @Description email domains of universities whose addresses make users students
+// @ModuleID adminGetAcademicDomains
+// @Accept  json
+// @Produce  json
+// @Security ApiKeyAuth
+// @Success 200 {object} academicDomainsResponse
+// @Failure 401,403 {object} errorResponse
+// @Failure 500 {object} errorResponse
+// @Failure default {object} errorResponse
+// @Router /admin/academic-domains [get]
+func (h *Handler) adminGetAcademicDomains(c *gin.Context) {
+	res, err := h.services.Students.GetAcademicDomains(c.Request.Context())
+	if err != nil {
+		newErrorResponse(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	domains := make([]academicDomainOutput, 0, len(res))
+	for _, d := range res {
+		domains = append(domains, newAcademicDomainOutput(d))
+	}
+
+	c.JSON(http.StatusOK, academicDomainsResponse{Domains: domains})
+}
+
+// @Summary Create Academic Domain
+// @Tags admin
+// @Description add an email domain of a university
+// @ModuleID adminCreateAcademicDomain
+// @Accept  json
+// @Produce  json
+// @Param input body academicDomainCreateInput true "domain"
+// @Security ApiKeyAuth
+// @Success 201 {object} academicDomainOutput
+// @Failure 400,401,403,409 {object} errorResponse
+// @Failure 500 {object} errorResponse
+// @Failure default {object} errorResponse
+// @Router /admin/academic-domains [post]
+func (h *Handler) adminCreateAcademicDomain(c *gin.Context) {
+	admin, err := getUserContext(c)
+	if err != nil {
+		newErrorResponse(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	var input academicDomainCreateInput
+	if err := c.BindJSON(&input); err != nil {
+		newErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	d, err := h.services.Students.CreateAcademicDomain(c.Request.Context(), admin.userID, service.AcademicDomainInput{
+		Domain:         input.Domain,
+		UniversityName: input.UniversityName,
+	})
+	if err != nil {
+		newStudentErrorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, newAcademicDomainOutput(d))
+}
+
+// @Summary Update Academic Domain
+// @Tags admin
+// @Description rename the university of a domain; the domain itself can't be changed
+// @ModuleID adminUpdateAcademicDomain
+// @Accept  json
+// @Produce  json
+// @Param id path int true "domain id"
+// @Param input body academicDomainUpdateInput true "university"
+// @Security ApiKeyAuth
+// @Success 200 {object} academicDomainOutput
+// @Failure 400,401,403,404 {object} errorResponse
+// @Failure 500 {object} errorResponse
+// @Failure default {object} errorResponse
+// @Router /admin/academic-domains/{id} [put]
+func (h *Handler) adminUpdateAcademicDomain(c *gin.Context) {
+	admin, err := getUserContext(c)
+	if err != nil {
+		newErrorResponse(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	domainID, ok := intParam(c, "id")
+	if !ok {
+		return
+	}
+
+	var input academicDomainUpdateInput
+	if err := c.BindJSON(&input); err != nil {
+		newErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	d, err := h.services.Students.UpdateAcademicDomain(c.Request.Context(), admin.userID, domainID, input.UniversityName)
+	if err != nil {
+		newStudentErrorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, newAcademicDomainOutput(d))
+}
+
+// @Summary Delete Academic Domain
+// @Tags admin
+// @Description remove an email domain; students confirmed on it keep the status until it expires
+// @ModuleID adminDeleteAcademicDomain
+// @Accept  json
+// @Produce  json
+// @Param id path int true "domain id"
+// @Security ApiKeyAuth
+// @Success 200 {object} statusResponse
+// @Failure 400,401,403,404 {object} errorResponse
+// @Failure 500 {object} errorResponse
+// @Failure default {object} errorResponse
+// @Router /admin/academic-domains/{id} [delete]
+func (h *Handler) adminDeleteAcademicDomain(c *gin.Context) {
+	admin, err := getUserContext(c)
+	if err != nil {
+		newErrorResponse(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	domainID, ok := intParam(c, "id")
+	if !ok {
+		return
+	}
+
+	if err := h.services.Students.DeleteAcademicDomain(c.Request.Context(), admin.userID, domainID); err != nil {
+		newStudentErrorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, statusResponse{Status: "ok"})
+}
+
+func newAcademicDomainOutput(d domain.AcademicDomain) academicDomainOutput {
+	return academicDomainOutput{
+		ID:             d.ID,
+		Domain:         d.Domain,
+		UniversityName: d.UniversityName,
+		CreatedAt:      d.CreatedAt,
+	}
+}
@@ -0,0 +1,301 @@
+package v1
+
+import (
+	"errors"
+	"github.com/gin-gonic/gin"
+	"github.com/shamank/edutour-backend/auth-service/internal/domain"
+	"github.com/shamank/edutour-backend/auth-service/internal/service"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+type roleOutput struct {
+	ID          int    `json:"id"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	// IsSystem roles come with the service and can't be deleted
+	IsSystem    bool      `json:"is_system"`
+	Permissions []string  `json:"permissions"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+type rolesResponse struct {
+	Roles []roleOutput `json:"roles"`
+}
+
+type permissionOutput struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+type permissionsResponse struct {
+	Permissions []permissionOutput `json:"permissions"`
+}
+
+type roleCreateInput struct {
+	// Name is 2 to 64 lowercase latin letters, digits, '_' or '-', starting with a letter
+	Name        string   `json:"name" binding:"required,max=64"`
+	Description string   `json:"description" binding:"max=255"`
+	Permissions []string `json:"permissions" binding:"dive,max=128"`
+}
+
+type roleUpdateInput struct {
+	Description string   `json:"description" binding:"max=255"`
+	Permissions []string `json:"permissions" binding:"dive,max=128"`
+}
+
+func (h *Handler) initAdminRolesRouter(admin *gin.RouterGroup) {
+	roles := admin.Group("/roles")
+	{
+		roles.GET("", h.requirePermission(domain.PermissionRolesReadAny), h.adminGetRoles)
+		roles.GET("/:id", h.requirePermission(domain.PermissionRolesReadAny), h.adminGetRole)
+		roles.POST("", h.requirePermission(domain.PermissionRolesManageAny), h.adminCreateRole)
+		roles.PUT("/:id", h.requirePermission(domain.PermissionRolesManageAny), h.adminUpdateRole)
+		roles.DELETE("/:id", h.requirePermission(domain.PermissionRolesManageAny), h.adminDeleteRole)
+	}
+
+	admin.GET("/permissions", h.requirePermission(domain.PermissionRolesReadAny), h.adminGetPermissions)
+}
+
+// @Summary Roles
+// @Tags admin
+// @Description roles with their permissions
+// @ModuleID adminGetRoles
+// @Accept  json
+// @Produce  json
+// @Security ApiKeyAuth
+// @Success 200 {object} rolesResponse
+// @Failure 401,403 {object} errorResponse
+// @Failure 500 {object} errorResponse
+// @Failure default {object} errorResponse
+// @Router /admin/roles [get]
+func (h *Handler) adminGetRoles(c *gin.Context) {
+	res, err := h.services.Roles.GetRoles(c.Request.Context())
+	if err != nil {
+		newErrorResponse(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	roles := make([]roleOutput, 0, len(res))
+	for _, role := range res {
+		roles = append(roles, newRoleOutput(role))
+	}
+
+	c.JSON(http.StatusOK, rolesResponse{Roles: roles})
+}
+
+// @Summary Role
+// @Tags admin
+// @Description role by id
+// @ModuleID adminGetRole
+// @Accept  json
+// @Produce  json
+// @Param id path int true "role id"
+// @Security ApiKeyAuth
+// @Success 200 {object} roleOutput
+// @Failure 400,401,403,404 {object} errorResponse
+// @Failure 500 {object} errorResponse
+// @Failure default {object} errorResponse
+// @Router /admin/roles/{id} [get]
+func (h *Handler) adminGetRole(c *gin.Context) {
+	roleID, ok := roleIDParam(c)
+	if !ok {
+		return
+	}
+
+	role, err := h.services.Roles.GetRole(c.Request.Context(), roleID)
+	if err != nil {
+		newRoleErrorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, newRoleOutput(role))
+}
+
+// @Summary Create Role
+// @Tags admin
+// @Description create a role with a set of permissions
+// @ModuleID adminCreateRole
+// @Accept  json
+// @Produce  json
+// @Param input body roleCreateInput true "role"
+// @Security ApiKeyAuth
+// @Success 201 {object} roleOutput
+// @Failure 400,401,403,409 {object} errorResponse
+// @Failure 500 {object} errorResponse
+// @Failure default {object} errorResponse
+// @Router /admin/roles [post]
+func (h *Handler) adminCreateRole(c *gin.Context) {
+	admin, err := getUserContext(c)
+	if err != nil {
+		newErrorResponse(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	var input roleCreateInput
+	if err := c.BindJSON(&input); err != nil {
+		newErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	role, err := h.services.Roles.CreateRole(c.Request.Context(), admin.userID, service.RoleInput{
+		Name:        input.Name,
+		Description: input.Description,
+		Permissions: input.Permissions,
+	})
+	if err != nil {
+		newRoleErrorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, newRoleOutput(role))
+}
+
+// @Summary Update Role
+// @Tags admin
+// @Description replace the description and the permissions of a role; users with the role get the new permissions with their next token
+// @ModuleID adminUpdateRole
+// @Accept  json
+// @Produce  json
+// @Param id path int true "role id"
+// @Param input body roleUpdateInput true "role"
+// @Security ApiKeyAuth
+// @Success 200 {object} roleOutput
+// @Failure 400,401,403,404,409 {object} errorResponse
+// @Failure 500 {object} errorResponse
+// @Failure default {object} errorResponse
+// @Router /admin/roles/{id} [put]
+func (h *Handler) adminUpdateRole(c *gin.Context) {
+	admin, err := getUserContext(c)
+	if err != nil {
+		newErrorResponse(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	roleID, ok := roleIDParam(c)
+	if !ok {
+		return
+	}
+
+	var input roleUpdateInput
+	if err := c.BindJSON(&input); err != nil {
+		newErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	role, err := h.services.Roles.UpdateRole(c.Request.Context(), admin.userID, roleID, service.RoleInput{
+		Description: input.Description,
+		Permissions: input.Permissions,
+	})
+	if err != nil {
+		newRoleErrorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, newRoleOutput(role))
+}
+
+// @Summary Delete Role
+// @Tags admin
+// @Description delete a role that isn't built in and that no user has
+// @ModuleID adminDeleteRole
+// @Accept  json
+// @Produce  json
+// @Param id path int true "role id"
+// @Security ApiKeyAuth
+// @Success 200 {object} statusResponse
+// @Failure 400,401,403,404,409 {object} errorResponse
+// @Failure 500 {object} errorResponse
+// @Failure default {object} errorResponse
+// @Router /admin/roles/{id} [delete]
+func (h *Handler) adminDeleteRole(c *gin.Context) {
+	admin, err := getUserContext(c)
+	if err != nil {
+		newErrorResponse(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	roleID, ok := roleIDParam(c)
+	if !ok {
+		return
+	}
+
+	if err := h.services.Roles.DeleteRole(c.Request.Context(), admin.userID, roleID); err != nil {
+		newRoleErrorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, statusResponse{Status: "ok"})
+}
+
+// @Summary Permissions
+// @Tags admin
+// @Description permissions that can be granted to roles
+// @ModuleID adminGetPermissions
+// @Accept  json
+// @Produce  json
+// @Security ApiKeyAuth
+// @Success 200 {object} permissionsResponse
+// @Failure 401,403 {object} errorResponse
+// @Failure 500 {object} errorResponse
+// @Failure default {object} errorResponse
+// @Router /admin/permissions [get]
+func (h *Handler) adminGetPermissions(c *gin.Context) {
+	res, err := h.services.Roles.GetPermissions(c.Request.Context())
+	if err != nil {
+		newErrorResponse(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	permissions := make([]permissionOutput, 0, len(res))
+	for _, p := range res {
+		permissions = append(permissions, permissionOutput{
+			Name:        p.Name,
+			Description: p.Description,
+		})
+	}
+
+	c.JSON(http.StatusOK, permissionsResponse{Permissions: permissions})
+}
+
+func roleIDParam(c *gin.Context) (int, bool) {
+	roleID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid role id")
+		return 0, false
+	}
+	return roleID, true
+}
+
+func newRoleErrorResponse(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, domain.ErrRoleNotFound):
+		newErrorResponse(c, http.StatusNotFound, err.Error())
+	case errors.Is(err, domain.ErrInvalidRoleName), errors.Is(err, domain.ErrPermissionNotFound):
+		newErrorResponse(c, http.StatusBadRequest, err.Error())
+	case errors.Is(err, domain.ErrRoleAlreadyExists), errors.Is(err, domain.ErrRoleInUse), errors.Is(err, domain.ErrRoleProtected),
+		errors.Is(err, domain.ErrChangeOwnRole):
+		newErrorResponse(c, http.StatusConflict, err.Error())
+	case errors.Is(err, domain.ErrRoleAboveOwn):
+		newErrorResponse(c, http.StatusForbidden, err.Error())
+	default:
+		newErrorResponse(c, http.StatusInternalServerError, err.Error())
+	}
+}
+
+func newRoleOutput(role domain.Role) roleOutput {
+	permissions := role.Permissions
+	if permissions == nil {
+		permissions = []string{}
+	}
+
+	return roleOutput{
+		ID:          role.ID,
+		Name:        role.Name,
+		Description: role.Description,
+		IsSystem:    role.IsSystem,
+		Permissions: permissions,
+		CreatedAt:   role.CreatedAt,
+	}
+}
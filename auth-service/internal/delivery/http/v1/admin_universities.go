@@ -0,0 +1,206 @@
+package v1
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/shamank/edutour-backend/auth-service/internal/domain"
+	"net/http"
+)
+
+type adminApplicationsQuery struct {
+	// Status is pending, approved or rejected, all applications are listed if it is empty
+	Status string `form:"status" binding:"omitempty,oneof=pending approved rejected"`
+}
+
+type approveApplicationInput struct {
+	Comment string `json:"comment" binding:"max=4000"`
+}
+
+type rejectApplicationInput struct {
+	// Comment tells the applicant why the application is rejected
+	Comment string `json:"comment" binding:"required,max=4000"`
+}
+
+func (h *Handler) initAdminUniversitiesRouter(admin *gin.RouterGroup) {
+	applications := admin.Group("/university-applications", h.requirePermission(domain.PermissionUniversitiesReviewAny))
+	{
+		applications.GET("", h.adminGetUniversityApplications)
+		applications.GET("/:id", h.adminGetUniversityApplication)
+		applications.GET("/:id/documents/:document_id", h.adminGetUniversityApplicationDocument)
+		applications.POST("/:id/approve", h.adminApproveUniversityApplication)
+		applications.POST("/:id/reject", h.adminRejectUniversityApplication)
+	}
+}
+
+// @Summary University Applications
+// @Tags admin
+// @Description applications to become a university representative, newest first
+// @ModuleID adminGetUniversityApplications
+// @Accept  json
+// @Produce  json
+// @Param status query string false "pending, approved or rejected"
+// @Security ApiKeyAuth
+// @Success 200 {object} applicationsResponse
+// @Failure 400,401,403 {object} errorResponse
+// @Failure 500 {object} errorResponse
+// @Failure default {object} errorResponse
+// @Router /admin/university-applications [get]
+func (h *Handler) adminGetUniversityApplications(c *gin.Context) {
+	var query adminApplicationsQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		newErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	res, err := h.services.Universities.GetApplications(c.Request.Context(), query.Status)
+	if err != nil {
+		newErrorResponse(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	c.JSON(http.StatusOK, newApplicationsResponse(res))
+}
+
+// @Summary University Application
+// @Tags admin
+// @Description application with its documents
+// @ModuleID adminGetUniversityApplication
+// @Accept  json
+// @Produce  json
+// @Param id path int true "application id"
+// @Security ApiKeyAuth
+// @Success 200 {object} applicationOutput
+// @Failure 400,401,403,404 {object} errorResponse
+// @Failure 500 {object} errorResponse
+// @Failure default {object} errorResponse
+// @Router /admin/university-applications/{id} [get]
+func (h *Handler) adminGetUniversityApplication(c *gin.Context) {
+	applicationID, ok := intParam(c, "id")
+	if !ok {
+		return
+	}
+
+	application, err := h.services.Universities.GetApplication(c.Request.Context(), applicationID)
+	if err != nil {
+		newUniversityErrorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, newApplicationOutput(application))
+}
+
+// @Summary University Application Document
+// @Tags admin
+// @Description download a document of an application
+// @ModuleID adminGetUniversityApplicationDocument
+// @Produce  octet-stream
+// @Param id path int true "application id"
+// @Param document_id path int true "document id"
+// @Security ApiKeyAuth
+// @Success 200 {file} file
+// @Failure 400,401,403,404 {object} errorResponse
+// @Failure 500 {object} errorResponse
+// @Failure default {object} errorResponse
+// @Router /admin/university-applications/{id}/documents/{document_id} [get]
+func (h *Handler) adminGetUniversityApplicationDocument(c *gin.Context) {
+	applicationID, ok := intParam(c, "id")
+	if !ok {
+		return
+	}
+
+	documentID, ok := intParam(c, "document_id")
+	if !ok {
+		return
+	}
+
+	document, r, err := h.services.Universities.OpenDocument(c.Request.Context(), applicationID, documentID)
+	if err != nil {
+		newUniversityErrorResponse(c, err)
+		return
+	}
+
+	sendDocument(c, document, r)
+}
+
+// @Summary Approve University Application
+// @Tags admin
+// @Description approve a pending application: the applicant gets the university role, unless their role has been changed since applying, and the attached organization is verified, unless another organization with its domain already is. The applicant is emailed
+// @ModuleID adminApproveUniversityApplication
+// @Accept  json
+// @Produce  json
+// @Param id path int true "application id"
+// @Param input body approveApplicationInput false "comment to the applicant"
+// @Security ApiKeyAuth
+// @Success 200 {object} applicationOutput
+// @Failure 400,401,403,404,409 {object} errorResponse
+// @Failure 500 {object} errorResponse
+// @Failure default {object} errorResponse
+// @Router /admin/university-applications/{id}/approve [post]
+func (h *Handler) adminApproveUniversityApplication(c *gin.Context) {
+	admin, err := getUserContext(c)
+	if err != nil {
+		newErrorResponse(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	applicationID, ok := intParam(c, "id")
+	if !ok {
+		return
+	}
+
+	var input approveApplicationInput
+	if c.Request.ContentLength != 0 {
+		if err := c.BindJSON(&input); err != nil {
+			newErrorResponse(c, http.StatusBadRequest, err.Error())
+			return
+		}
+	}
+
+	application, err := h.services.Universities.Approve(c.Request.Context(), admin.userID, applicationID, input.Comment)
+	if err != nil {
+		newUniversityErrorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, newApplicationOutput(application))
+}
+
+// @Summary Reject University Application
+// @Tags admin
+// @Description reject a pending application with a comment, which is emailed to the applicant
+// @ModuleID adminRejectUniversityApplication
+// @Accept  json
+// @Produce  json
+// @Param id path int true "application id"
+// @Param input body rejectApplicationInput true "reason"
+// @Security ApiKeyAuth
+// @Success 200 {object} applicationOutput
+// @Failure 400,401,403,404,409 {object} errorResponse
+// @Failure 500 {object} errorResponse
+// @Failure default {object} errorResponse
+// @Router /admin/university-applications/{id}/reject [post]
+func (h *Handler) adminRejectUniversityApplication(c *gin.Context) {
+	admin, err := getUserContext(c)
+	if err != nil {
+		newErrorResponse(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	applicationID, ok := intParam(c, "id")
+	if !ok {
+		return
+	}
+
+	var input rejectApplicationInput
+	if err := c.BindJSON(&input); err != nil {
+		newErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	application, err := h.services.Universities.Reject(c.Request.Context(), admin.userID, applicationID, input.Comment)
+	if err != nil {
+		newUniversityErrorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, newApplicationOutput(application))
+}
@@ -0,0 +1,368 @@
+package v1
+
+import (
+	"errors"
+	"github.com/gin-gonic/gin"
+	"github.com/shamank/edutour-backend/auth-service/internal/domain"
+	"github.com/shamank/edutour-backend/auth-service/internal/service"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+type adminUsersQuery struct {
+	Role      string `form:"role"`
+	Confirmed *bool  `form:"confirmed"`
+	Banned    *bool  `form:"banned"`
+	// CreatedFrom and CreatedTo are RFC 3339 times, e.g. 2024-01-31T00:00:00Z
+	CreatedFrom time.Time `form:"created_from" time_format:"2006-01-02T15:04:05Z07:00"`
+	CreatedTo   time.Time `form:"created_to" time_format:"2006-01-02T15:04:05Z07:00"`
+	// Q is searched for in username, email and name
+	Q      string `form:"q" binding:"max=128"`
+	Cursor string `form:"cursor"`
+	Limit  int    `form:"limit" binding:"omitempty,min=1,max=100"`
+}
+
+type adminUserOutput struct {
+	ID         int        `json:"id"`
+	UserName   string     `json:"username"`
+	Email      string     `json:"email"`
+	Phone      string     `json:"phone"`
+	FirstName  string     `json:"first_name"`
+	LastName   string     `json:"last_name"`
+	MiddleName string     `json:"middle_name"`
+	Avatar     string     `json:"avatar"`
+	Role       string     `json:"role"`
+	IsConfirm  bool       `json:"is_confirm"`
+	Locale     string     `json:"locale"`
+	BannedAt   *time.Time `json:"banned_at"`
+	BanReason  string     `json:"ban_reason,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+	UpdatedAt  time.Time  `json:"updated_at"`
+}
+
+type adminUsersResponse struct {
+	Users []adminUserOutput `json:"users"`
+	// NextCursor requests the next page, empty on the last one
+	NextCursor string `json:"next_cursor"`
+}
+
+type adminRoleInput struct {
+	Role string `json:"role" binding:"required,max=64"`
+}
+
+type adminBanInput struct {
+	Reason string `json:"reason" binding:"max=255"`
+}
+
+func (h *Handler) initAdminUsersRouter(admin *gin.RouterGroup) {
+	users := admin.Group("/users")
+	{
+		users.GET("", h.requirePermission(domain.PermissionUsersReadAny), h.adminGetUsers)
+		users.GET("/:id", h.requirePermission(domain.PermissionUsersReadAny), h.adminGetUser)
+		users.DELETE("/:id", h.requirePermission(domain.PermissionUsersDeleteAny), h.adminDeleteUser)
+
+		users.PUT("/:id/role", h.requirePermission(domain.PermissionUsersUpdateAny), h.adminChangeUserRole)
+		users.POST("/:id/confirm", h.requirePermission(domain.PermissionUsersUpdateAny), h.adminConfirmUser)
+		users.POST("/:id/ban", h.requirePermission(domain.PermissionUsersBanAny), h.adminBanUser)
+		users.POST("/:id/unban", h.requirePermission(domain.PermissionUsersBanAny), h.adminUnbanUser)
+	}
+}
+
+// @Summary Users
+// @Tags admin
+// @Description users newest first, filtered and searched; pass next_cursor as cursor for the next page
+// @ModuleID adminGetUsers
+// @Accept  json
+// @Produce  json
+// @Param role query string false "role name"
+// @Param confirmed query bool false "email confirmed"
+// @Param banned query bool false "banned"
+// @Param created_from query string false "signed up at or after, RFC 3339"
+// @Param created_to query string false "signed up before, RFC 3339"
+// @Param q query string false "search in username, email and name"
+// @Param cursor query string false "next_cursor of the previous page"
+// @Param limit query int false "page size, 20 by default, up to 100"
+// @Security ApiKeyAuth
+// @Success 200 {object} adminUsersResponse
+// @Failure 400,401,403 {object} errorResponse
+// @Failure 500 {object} errorResponse
+// @Failure default {object} errorResponse
+// @Router /admin/users [get]
+func (h *Handler) adminGetUsers(c *gin.Context) {
+	var query adminUsersQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		newErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	res, err := h.services.Admin.GetUsers(c.Request.Context(), service.UserListInput{
+		Filter: domain.UserFilter{
+			Role:        query.Role,
+			Confirmed:   query.Confirmed,
+			Banned:      query.Banned,
+			CreatedFrom: query.CreatedFrom,
+			CreatedTo:   query.CreatedTo,
+			Search:      query.Q,
+			Limit:       query.Limit,
+		},
+		Cursor: query.Cursor,
+	})
+	if err != nil {
+		if errors.Is(err, domain.ErrInvalidCursor) {
+			newErrorResponse(c, http.StatusBadRequest, err.Error())
+			return
+		}
+		newErrorResponse(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	users := make([]adminUserOutput, 0, len(res.Users))
+	for _, user := range res.Users {
+		users = append(users, newAdminUserOutput(user))
+	}
+
+	c.JSON(http.StatusOK, adminUsersResponse{
+		Users:      users,
+		NextCursor: res.NextCursor,
+	})
+}
+
+// @Summary User
+// @Tags admin
+// @Description user by id
+// @ModuleID adminGetUser
+// @Accept  json
+// @Produce  json
+// @Param id path int true "user id"
+// @Security ApiKeyAuth
+// @Success 200 {object} adminUserOutput
+// @Failure 400,401,403,404 {object} errorResponse
+// @Failure 500 {object} errorResponse
+// @Failure default {object} errorResponse
+// @Router /admin/users/{id} [get]
+func (h *Handler) adminGetUser(c *gin.Context) {
+	userID, ok := userIDParam(c)
+	if !ok {
+		return
+	}
+
+	user, err := h.services.Admin.GetUser(c.Request.Context(), userID)
+	if err != nil {
+		newAdminErrorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, newAdminUserOutput(user))
+}
+
+// @Summary Change User Role
+// @Tags admin
+// @Description change the role of a user, which ends all of the user's sessions. Both the current and the new role may grant only permissions the admin has
+// @ModuleID adminChangeUserRole
+// @Accept  json
+// @Produce  json
+// @Param id path int true "user id"
+// @Param input body adminRoleInput true "role name"
+// @Security ApiKeyAuth
+// @Success 200 {object} statusResponse
+// @Failure 400,401,403,404,409 {object} errorResponse
+// @Failure 500 {object} errorResponse
+// @Failure default {object} errorResponse
+// @Router /admin/users/{id}/role [put]
+func (h *Handler) adminChangeUserRole(c *gin.Context) {
+	admin, userID, ok := h.adminAction(c)
+	if !ok {
+		return
+	}
+
+	var input adminRoleInput
+	if err := c.BindJSON(&input); err != nil {
+		newErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if err := h.services.Admin.ChangeUserRole(c.Request.Context(), admin.userID, userID, input.Role); err != nil {
+		newAdminErrorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, statusResponse{Status: "ok"})
+}
+
+// @Summary Confirm User
+// @Tags admin
+// @Description mark the email of a user as confirmed
+// @ModuleID adminConfirmUser
+// @Accept  json
+// @Produce  json
+// @Param id path int true "user id"
+// @Security ApiKeyAuth
+// @Success 200 {object} statusResponse
+// @Failure 400,401,403,404 {object} errorResponse
+// @Failure 500 {object} errorResponse
+// @Failure default {object} errorResponse
+// @Router /admin/users/{id}/confirm [post]
+func (h *Handler) adminConfirmUser(c *gin.Context) {
+	admin, userID, ok := h.adminAction(c)
+	if !ok {
+		return
+	}
+
+	if err := h.services.Admin.ConfirmUser(c.Request.Context(), admin.userID, userID); err != nil {
+		newAdminErrorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, statusResponse{Status: "ok"})
+}
+
+// @Summary Ban User
+// @Tags admin
+// @Description stop a user from signing in and end all of the user's sessions
+// @ModuleID adminBanUser
+// @Accept  json
+// @Produce  json
+// @Param id path int true "user id"
+// @Param input body adminBanInput false "reason"
+// @Security ApiKeyAuth
+// @Success 200 {object} statusResponse
+// @Failure 400,401,403,404,409 {object} errorResponse
+// @Failure 500 {object} errorResponse
+// @Failure default {object} errorResponse
+// @Router /admin/users/{id}/ban [post]
+func (h *Handler) adminBanUser(c *gin.Context) {
+	admin, userID, ok := h.adminAction(c)
+	if !ok {
+		return
+	}
+
+	var input adminBanInput
+	if c.Request.ContentLength != 0 {
+		if err := c.BindJSON(&input); err != nil {
+			newErrorResponse(c, http.StatusBadRequest, err.Error())
+			return
+		}
+	}
+
+	if err := h.services.Admin.BanUser(c.Request.Context(), admin.userID, userID, input.Reason); err != nil {
+		newAdminErrorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, statusResponse{Status: "ok"})
+}
+
+// @Summary Unban User
+// @Tags admin
+// @Description let a banned user sign in again
+// @ModuleID adminUnbanUser
+// @Accept  json
+// @Produce  json
+// @Param id path int true "user id"
+// @Security ApiKeyAuth
+// @Success 200 {object} statusResponse
+// @Failure 400,401,403,404 {object} errorResponse
+// @Failure 500 {object} errorResponse
+// @Failure default {object} errorResponse
+// @Router /admin/users/{id}/unban [post]
+func (h *Handler) adminUnbanUser(c *gin.Context) {
+	admin, userID, ok := h.adminAction(c)
+	if !ok {
+		return
+	}
+
+	if err := h.services.Admin.UnbanUser(c.Request.Context(), admin.userID, userID); err != nil {
+		newAdminErrorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, statusResponse{Status: "ok"})
+}
+
+// @Summary Delete User
+// @Tags admin
+// @Description delete a user with all of the user's data
+// @ModuleID adminDeleteUser
+// @Accept  json
+// @Produce  json
+// @Param id path int true "user id"
+// @Security ApiKeyAuth
+// @Success 200 {object} statusResponse
+// @Failure 400,401,403,404,409 {object} errorResponse
+// @Failure 500 {object} errorResponse
+// @Failure default {object} errorResponse
+// @Router /admin/users/{id} [delete]
+func (h *Handler) adminDeleteUser(c *gin.Context) {
+	admin, userID, ok := h.adminAction(c)
+	if !ok {
+		return
+	}
+
+	if err := h.services.Admin.DeleteUser(c.Request.Context(), admin.userID, userID); err != nil {
+		newAdminErrorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, statusResponse{Status: "ok"})
+}
+
+// adminAction returns the acting admin and the user from the path.
+func (h *Handler) adminAction(c *gin.Context) (userContext, int, bool) {
+	admin, err := getUserContext(c)
+	if err != nil {
+		newErrorResponse(c, http.StatusInternalServerError, err.Error())
+		return userContext{}, 0, false
+	}
+
+	userID, ok := userIDParam(c)
+	if !ok {
+		return userContext{}, 0, false
+	}
+
+	return admin, userID, true
+}
+
+func userIDParam(c *gin.Context) (int, bool) {
+	userID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid user id")
+		return 0, false
+	}
+	return userID, true
+}
+
+func newAdminErrorResponse(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, domain.ErrUserNotFound):
+		newErrorResponse(c, http.StatusNotFound, err.Error())
+	case errors.Is(err, domain.ErrRoleNotFound):
+		newErrorResponse(c, http.StatusBadRequest, err.Error())
+	case errors.Is(err, domain.ErrCannotManageSelf):
+		newErrorResponse(c, http.StatusConflict, err.Error())
+	case errors.Is(err, domain.ErrRoleAboveOwn):
+		newErrorResponse(c, http.StatusForbidden, err.Error())
+	default:
+		newErrorResponse(c, http.StatusInternalServerError, err.Error())
+	}
+}
+
+func newAdminUserOutput(user domain.User) adminUserOutput {
+	return adminUserOutput{
+		ID:         user.ID,
+		UserName:   user.Username,
+		Email:      user.Email,
+		Phone:      user.Phone,
+		FirstName:  user.FirstName,
+		LastName:   user.LastName,
+		MiddleName: user.MiddleName,
+		Avatar:     user.Avatar,
+		Role:       user.Role.Name,
+		IsConfirm:  user.IsConfirm,
+		Locale:     user.Locale,
+		BannedAt:   timeOrNil(user.BannedAt),
+		BanReason:  user.BanReason,
+		CreatedAt:  user.CreatedAt,
+		UpdatedAt:  user.UpdateAt,
+	}
+}
@@ -1,18 +1,25 @@
 package v1
 
 import (
+	"encoding/base64"
 	"errors"
 	"github.com/gin-gonic/gin"
 	"github.com/shamank/edutour-backend/auth-service/internal/domain"
 	"github.com/shamank/edutour-backend/auth-service/internal/service"
+	"github.com/shamank/edutour-backend/auth-service/pkg/auth"
 	"github.com/shamank/edutour-backend/auth-service/pkg/logger/sl"
 	"net/http"
+	"time"
 )
 
 type userSignUpInput struct {
 	UserName string `json:"username" binding:"required,min=4,max=64"`
 	Email    string `json:"email" binding:"required,email,max=64"`
+	// Phone in E.164 format, e.g. +79991234567. It has to be confirmed with /users/me/phone.
+	Phone    string `json:"phone" binding:"omitempty,e164"`
 	Password string `json:"password" binding:"required,min=8,max=64"`
+	// Locale is the language of emails, "ru" or "en". Taken from Accept-Language if not set.
+	Locale string `json:"locale" binding:"omitempty,oneof=ru en"`
 }
 
 type userSignInInput struct {
@@ -20,6 +27,31 @@ type userSignInInput struct {
 	Password string `json:"password" binding:"required,min=8,max=64"`
 }
 
+type userSignInTwoFactorInput struct {
+	ChallengeToken string `json:"challenge_token" binding:"required"`
+	Code           string `json:"code" binding:"required"`
+}
+
+type signInChallengeInput struct {
+	ChallengeToken string `json:"challenge_token" binding:"required"`
+}
+
+// twoFactorChallengeResponse is returned by sign-in instead of tokens when the user has 2FA enabled.
+type twoFactorChallengeResponse struct {
+	TwoFactorRequired bool `json:"two_factor_required"`
+	// EnrolmentRequired means the role of the user requires 2FA, which the user has to enable
+	// with /auth/sign-in/2fa/totp and /auth/sign-in/2fa/totp/confirm to get tokens
+	EnrolmentRequired bool   `json:"enrolment_required"`
+	ChallengeToken    string `json:"challenge_token"`
+	ExpireIn          int    `json:"expire_in"`
+}
+
+// signInEnrolmentResponse is the tokens of a sign-in that enabled 2FA and the recovery codes.
+type signInEnrolmentResponse struct {
+	tokenResponse
+	RecoveryCodes []string `json:"recovery_codes"`
+}
+
 type tokenResponse struct {
 	AccessToken  string `json:"access_token"`
 	RefreshToken string `json:"refresh_token"`
@@ -31,11 +63,23 @@ func (h *Handler) initAuthRouter(api *gin.RouterGroup) {
 	{
 		auth.POST("/sign-up", h.signUp)
 		auth.POST("/sign-in", h.signIn)
+		auth.POST("/sign-in/2fa", h.signInTwoFactor)
+		auth.POST("/sign-in/2fa/totp", h.signInEnrollTOTP)
+		auth.POST("/sign-in/2fa/totp/confirm", h.signInConfirmTOTP)
+		auth.POST("/magic-link", h.sendMagicLink)
+		auth.POST("/magic-link/verify", h.signInMagicLink)
+		auth.POST("/phone/code", h.sendPhoneSignInCode)
+		auth.POST("/phone/sign-in", h.signInPhone)
 		auth.POST("/confirm", h.confirmUser)
+		auth.POST("/confirm/code", h.confirmUserByCode)
+		auth.POST("/confirm/resend", h.resendConfirmation)
 		auth.POST("/reset-password", h.resetPassword)
 		auth.POST("/confirm-password", h.confirmResetPassword)
+		auth.POST("/confirm-password/code", h.confirmResetPasswordByCode)
 
 		auth.POST("/refresh", h.userRefresh)
+		auth.POST("/logout", h.logout)
+		auth.POST("/logout-all", h.userIdentity, h.logoutAll)
 
 		auth.GET("/me", h.userIdentity, h.userPing)
 		auth.GET("/verify", h.userIdentity, h.verifyToken)
@@ -65,7 +109,9 @@ func (h *Handler) signUp(c *gin.Context) {
 	if err := h.services.Authorization.SignUp(c.Request.Context(), service.UserSignUpInput{
 		UserName: input.UserName,
 		Email:    input.Email,
+		Phone:    input.Phone,
 		Password: input.Password,
+		Locale:   preferredLocale(c, input.Locale),
 	}); err != nil {
 		if errors.Is(err, domain.ErrUserAlreadyExists) {
 			newErrorResponse(c, http.StatusBadRequest, err.Error())
@@ -84,7 +130,8 @@ func (h *Handler) signUp(c *gin.Context) {
 // @Produce  json
 // @Param input body userSignInInput true "sign in info"
 // @Success 200 {object} tokenResponse
-// @Failure 400,401,404 {object} errorResponse
+// @Success 202 {object} twoFactorChallengeResponse "two-factor code required, continue with /auth/sign-in/2fa"
+// @Failure 400,401,403,404 {object} errorResponse
 // @Failure 500 {object} errorResponse
 // @Failure default {object} errorResponse
 // @Router /auth/sign-in [post]
@@ -100,17 +147,71 @@ func (h *Handler) signIn(c *gin.Context) {
 	res, err := h.services.Authorization.SignIn(c.Request.Context(), service.UserSignInInput{
 		Login:    input.Login,
 		Password: input.Password,
+		Client:   clientInfo(c),
 	})
 
 	if err != nil {
-		if errors.Is(err, domain.ErrUserNotFound) {
+		if errors.Is(err, domain.ErrInvalidPassword) {
 			newErrorResponse(c, http.StatusUnauthorized, err.Error())
 			return
 		}
+		if errors.Is(err, domain.ErrUserBanned) {
+			newErrorResponse(c, http.StatusForbidden, err.Error())
+			return
+		}
 		newErrorResponse(c, http.StatusInternalServerError, err.Error())
 		return
 	}
 
+	newSignInResponse(c, res)
+}
+
+// newSignInResponse responds with tokens, or with a challenge if the user has to enter a second factor.
+func newSignInResponse(c *gin.Context, res service.SignInResult) {
+	if res.ChallengeToken != "" {
+		c.JSON(http.StatusAccepted, twoFactorChallengeResponse{
+			TwoFactorRequired: true,
+			EnrolmentRequired: res.EnrolmentRequired,
+			ChallengeToken:    res.ChallengeToken,
+			ExpireIn:          int(res.ChallengeExpireIn.Seconds()),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, tokenResponse{
+		AccessToken:  res.Tokens.AccessToken,
+		RefreshToken: res.Tokens.RefreshToken,
+		ExpireIn:     int(res.Tokens.ExpireIn.Seconds()),
+	})
+}
+
+// @Summary User SignIn Second Factor
+// @Tags auth
+// @Description finish sign in with a code from the authenticator app or a recovery code
+// @ModuleID authSignInTwoFactor
+// @Accept  json
+// @Produce  json
+// @Param input body userSignInTwoFactorInput true "challenge token and code"
+// @Success 200 {object} tokenResponse
+// @Failure 400,401,403,429 {object} errorResponse
+// @Failure 500 {object} errorResponse
+// @Failure default {object} errorResponse
+// @Router /auth/sign-in/2fa [post]
+func (h *Handler) signInTwoFactor(c *gin.Context) {
+
+	var input userSignInTwoFactorInput
+
+	if err := c.BindJSON(&input); err != nil {
+		newErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	res, err := h.services.Authorization.SignInTwoFactor(c.Request.Context(), input.ChallengeToken, input.Code, clientInfo(c))
+	if err != nil {
+		newSignInTwoFactorErrorResponse(c, err)
+		return
+	}
+
 	c.JSON(http.StatusOK, tokenResponse{
 		AccessToken:  res.AccessToken,
 		RefreshToken: res.RefreshToken,
@@ -118,6 +219,166 @@ func (h *Handler) signIn(c *gin.Context) {
 	})
 }
 
+// @Summary User SignIn Enroll TOTP
+// @Tags auth
+// @Description generate an authenticator app secret for a user whose role requires 2FA, after sign in answered with enrolment_required
+// @ModuleID authSignInEnrollTOTP
+// @Accept  json
+// @Produce  json
+// @Param input body signInChallengeInput true "challenge token"
+// @Success 200 {object} totpEnrollResponse
+// @Failure 400,401,409 {object} errorResponse
+// @Failure 500 {object} errorResponse
+// @Failure default {object} errorResponse
+// @Router /auth/sign-in/2fa/totp [post]
+func (h *Handler) signInEnrollTOTP(c *gin.Context) {
+	var input signInChallengeInput
+
+	if err := c.BindJSON(&input); err != nil {
+		newErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	key, err := h.services.Authorization.EnrollTwoFactorOnSignIn(c.Request.Context(), input.ChallengeToken)
+	if err != nil {
+		newSignInTwoFactorErrorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, totpEnrollResponse{
+		Secret:     key.Secret,
+		OTPAuthURI: key.URI,
+		QRCode:     base64.StdEncoding.EncodeToString(key.QRCode),
+	})
+}
+
+// @Summary User SignIn Confirm TOTP
+// @Tags auth
+// @Description enable 2FA with the first code from the authenticator app and finish sign in, returns tokens and one-time recovery codes
+// @ModuleID authSignInConfirmTOTP
+// @Accept  json
+// @Produce  json
+// @Param input body userSignInTwoFactorInput true "challenge token and code"
+// @Success 200 {object} signInEnrolmentResponse
+// @Failure 400,401,403,404,409,429 {object} errorResponse
+// @Failure 500 {object} errorResponse
+// @Failure default {object} errorResponse
+// @Router /auth/sign-in/2fa/totp/confirm [post]
+func (h *Handler) signInConfirmTOTP(c *gin.Context) {
+	var input userSignInTwoFactorInput
+
+	if err := c.BindJSON(&input); err != nil {
+		newErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	codes, res, err := h.services.Authorization.ConfirmTwoFactorOnSignIn(c.Request.Context(), input.ChallengeToken, input.Code, clientInfo(c))
+	if err != nil {
+		newSignInTwoFactorErrorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, signInEnrolmentResponse{
+		tokenResponse: tokenResponse{
+			AccessToken:  res.AccessToken,
+			RefreshToken: res.RefreshToken,
+			ExpireIn:     int(res.ExpireIn.Seconds()),
+		},
+		RecoveryCodes: codes,
+	})
+}
+
+func newSignInTwoFactorErrorResponse(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, domain.ErrInvalidToken), errors.Is(err, domain.ErrInvalidTwoFactorCode):
+		newErrorResponse(c, http.StatusUnauthorized, err.Error())
+	case errors.Is(err, domain.ErrUserBanned):
+		newErrorResponse(c, http.StatusForbidden, err.Error())
+	case errors.Is(err, domain.ErrTwoFactorNotEnabled):
+		newErrorResponse(c, http.StatusNotFound, err.Error())
+	case errors.Is(err, domain.ErrTwoFactorAlreadyEnabled):
+		newErrorResponse(c, http.StatusConflict, err.Error())
+	case errors.Is(err, domain.ErrSignInLocked):
+		newErrorResponse(c, http.StatusTooManyRequests, err.Error())
+	default:
+		newErrorResponse(c, http.StatusInternalServerError, err.Error())
+	}
+}
+
+type magicLinkInput struct {
+	Email string `json:"email" binding:"required,email,max=64"`
+}
+
+type magicLinkVerifyInput struct {
+	Token string `json:"token" binding:"required"`
+}
+
+// @Summary Send Magic Link
+// @Tags auth
+// @Description email a single-use link to sign in without a password
+// @ModuleID authSendMagicLink
+// @Accept  json
+// @Produce  json
+// @Param input body magicLinkInput true "email"
+// @Success 200 {object} statusResponse
+// @Failure 400 {object} errorResponse
+// @Failure 500 {object} errorResponse
+// @Failure default {object} errorResponse
+// @Router /auth/magic-link [post]
+func (h *Handler) sendMagicLink(c *gin.Context) {
+	var input magicLinkInput
+
+	if err := c.BindJSON(&input); err != nil {
+		newErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if err := h.services.Authorization.SendMagicLink(c.Request.Context(), input.Email); err != nil {
+		newErrorResponse(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	c.JSON(http.StatusOK, statusResponse{Status: "ok"})
+}
+
+// @Summary Sign In With Magic Link
+// @Tags auth
+// @Description exchange the token from a magic link for tokens
+// @ModuleID authSignInMagicLink
+// @Accept  json
+// @Produce  json
+// @Param input body magicLinkVerifyInput true "magic link token"
+// @Success 200 {object} tokenResponse
+// @Success 202 {object} twoFactorChallengeResponse "two-factor code required, continue with /auth/sign-in/2fa"
+// @Failure 400,401,403 {object} errorResponse
+// @Failure 500 {object} errorResponse
+// @Failure default {object} errorResponse
+// @Router /auth/magic-link/verify [post]
+func (h *Handler) signInMagicLink(c *gin.Context) {
+	var input magicLinkVerifyInput
+
+	if err := c.BindJSON(&input); err != nil {
+		newErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	res, err := h.services.Authorization.SignInMagicLink(c.Request.Context(), input.Token, clientInfo(c))
+	if err != nil {
+		if errors.Is(err, domain.ErrInvalidToken) {
+			newErrorResponse(c, http.StatusUnauthorized, err.Error())
+			return
+		}
+		if errors.Is(err, domain.ErrUserBanned) {
+			newErrorResponse(c, http.StatusForbidden, err.Error())
+			return
+		}
+		newErrorResponse(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	newSignInResponse(c, res)
+}
+
 type confirmUserRequest struct {
 	ConfirmToken string `json:"confirm_token" validate:"required"`
 }
@@ -153,6 +414,88 @@ func (h *Handler) confirmUser(c *gin.Context) {
 	return
 }
 
+type confirmUserByCodeRequest struct {
+	Email string `json:"email" binding:"required,email,max=64"`
+	Code  string `json:"code" binding:"required,numeric"`
+}
+
+// @Summary User Confirm By Code
+// @Tags auth
+// @Description user confirm email with the code from the confirmation email
+// @ModuleID authConfirmUserByCode
+// @Accept  json
+// @Produce  json
+// @Param input body confirmUserByCodeRequest true "email and code"
+// @Success 200 {object} statusResponse
+// @Failure 400,429 {object} errorResponse
+// @Failure 500 {object} errorResponse
+// @Failure default {object} errorResponse
+// @Router /auth/confirm/code [post]
+func (h *Handler) confirmUserByCode(c *gin.Context) {
+	var input confirmUserByCodeRequest
+
+	if err := c.BindJSON(&input); err != nil {
+		newErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if err := h.services.Authorization.ConfirmUserByCode(c.Request.Context(), input.Email, input.Code); err != nil {
+		newVerificationCodeErrorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, statusResponse{Status: "ok"})
+}
+
+type resendConfirmationRequest struct {
+	Email string `json:"email" binding:"required,email,max=64"`
+}
+
+// @Summary Resend Confirmation
+// @Tags auth
+// @Description send the confirmation email again, if the account isn't confirmed yet
+// @ModuleID authResendConfirmation
+// @Accept  json
+// @Produce  json
+// @Param input body resendConfirmationRequest true "email"
+// @Success 200 {object} statusResponse
+// @Failure 400,429 {object} errorResponse
+// @Failure 500 {object} errorResponse
+// @Failure default {object} errorResponse
+// @Router /auth/confirm/resend [post]
+func (h *Handler) resendConfirmation(c *gin.Context) {
+	var input resendConfirmationRequest
+
+	if err := c.BindJSON(&input); err != nil {
+		newErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if err := h.services.Authorization.ResendConfirmation(c.Request.Context(), input.Email); err != nil {
+		if errors.Is(err, domain.ErrConfirmationResendTooSoon) {
+			newErrorResponse(c, http.StatusTooManyRequests, err.Error())
+			return
+		}
+		newErrorResponse(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	c.JSON(http.StatusOK, statusResponse{Status: "ok"})
+}
+
+// newVerificationCodeErrorResponse tells a wrong code from a locked one,
+// so the client knows when to offer sending a new code.
+func newVerificationCodeErrorResponse(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, domain.ErrInvalidVerificationCode):
+		newErrorResponse(c, http.StatusBadRequest, err.Error())
+	case errors.Is(err, domain.ErrVerificationCodeLocked), errors.Is(err, domain.ErrSignInLocked):
+		newErrorResponse(c, http.StatusTooManyRequests, err.Error())
+	default:
+		newErrorResponse(c, http.StatusInternalServerError, err.Error())
+	}
+}
+
 type refreshInput struct {
 	RefreshToken string `json:"refresh_token" binding:"required"`
 }
@@ -165,7 +508,7 @@ type refreshInput struct {
 // @Produce  json
 // @Param input body refreshInput true "refresh token input"
 // @Success 200 {object} tokenResponse
-// @Failure 400,404 {object} errorResponse
+// @Failure 400,401,403,404 {object} errorResponse
 // @Failure 500 {object} errorResponse
 // @Failure default {object} errorResponse
 // @Router /auth/refresh [post]
@@ -177,8 +520,16 @@ func (h *Handler) userRefresh(c *gin.Context) {
 		return
 	}
 
-	res, err := h.services.Authorization.RefreshToken(c.Request.Context(), input.RefreshToken)
+	res, err := h.services.Authorization.RefreshToken(c.Request.Context(), input.RefreshToken, clientInfo(c))
 	if err != nil {
+		if errors.Is(err, domain.ErrInvalidRefreshToken) || errors.Is(err, domain.ErrRefreshTokenReused) {
+			newErrorResponse(c, http.StatusUnauthorized, err.Error())
+			return
+		}
+		if errors.Is(err, domain.ErrUserBanned) {
+			newErrorResponse(c, http.StatusForbidden, err.Error())
+			return
+		}
 		newErrorResponse(c, http.StatusInternalServerError, err.Error())
 		return
 	}
@@ -190,19 +541,85 @@ func (h *Handler) userRefresh(c *gin.Context) {
 	})
 }
 
+// @Summary Logout
+// @Tags auth
+// @Description revoke the session of the refresh token. The access token passed in Authorization, if any, is revoked as well
+// @ModuleID authLogout
+// @Accept  json
+// @Produce  json
+// @Param input body refreshInput true "refresh token input"
+// @Security ApiKeyAuth
+// @Success 200 {object} statusResponse
+// @Failure 400,401 {object} errorResponse
+// @Failure 500 {object} errorResponse
+// @Failure default {object} errorResponse
+// @Router /auth/logout [post]
+func (h *Handler) logout(c *gin.Context) {
+	var input refreshInput
+
+	if err := c.BindJSON(&input); err != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid body")
+		return
+	}
+
+	// the access token is optional here: a client whose token has expired must still be able to log out
+	var accessToken auth.UserClaims
+	if usr, err := h.parseAuthHeader(c); err == nil {
+		accessToken = usr.accessToken()
+	}
+
+	if err := h.services.Authorization.Logout(c.Request.Context(), input.RefreshToken, accessToken); err != nil {
+		if errors.Is(err, domain.ErrInvalidRefreshToken) {
+			newErrorResponse(c, http.StatusUnauthorized, err.Error())
+			return
+		}
+		newErrorResponse(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	c.JSON(http.StatusOK, statusResponse{Status: "ok"})
+}
+
+// @Summary Logout everywhere
+// @Tags auth
+// @Description revoke all sessions of the user
+// @ModuleID authLogoutAll
+// @Accept  json
+// @Produce  json
+// @Security ApiKeyAuth
+// @Success 200 {object} statusResponse
+// @Failure 401 {object} errorResponse
+// @Failure 500 {object} errorResponse
+// @Failure default {object} errorResponse
+// @Router /auth/logout-all [post]
+func (h *Handler) logoutAll(c *gin.Context) {
+	usr, err := getUserContext(c)
+	if err != nil {
+		newErrorResponse(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	if err := h.services.Authorization.LogoutAll(c.Request.Context(), usr.userID); err != nil {
+		newErrorResponse(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	c.JSON(http.StatusOK, statusResponse{Status: "ok"})
+}
+
 type resetPasswordRequest struct {
 	Email string `json:"email" binding:"email"`
 }
 
 // @Summary User reset password
 // @Tags auth
-// @Description user reset password request
+// @Description user reset password request, an unknown or unconfirmed email isn't reported
 // @ModuleID authResetPassword
 // @Accept  json
 // @Produce  json
 // @Param input body resetPasswordRequest true "reset password input"
 // @Success 200 {object} statusResponse
-// @Failure 400,404 {object} errorResponse
+// @Failure 400 {object} errorResponse
 // @Failure 500 {object} errorResponse
 // @Failure default {object} errorResponse
 // @Router /auth/reset-password [post]
@@ -255,6 +672,40 @@ func (h *Handler) confirmResetPassword(c *gin.Context) {
 	c.JSON(http.StatusOK, statusResponse{Status: "ok"})
 }
 
+type confirmPasswordByCodeRequest struct {
+	Email    string `json:"email" binding:"required,email,max=64"`
+	Code     string `json:"code" binding:"required,numeric"`
+	Password string `json:"password" binding:"required,min=8,max=64"`
+}
+
+// @Summary User reset password by code
+// @Tags auth
+// @Description user reset password confirm with the code from the reset email
+// @ModuleID authConfirmPasswordByCode
+// @Accept  json
+// @Produce  json
+// @Param input body confirmPasswordByCodeRequest true "reset password input"
+// @Success 200 {object} statusResponse
+// @Failure 400,429 {object} errorResponse
+// @Failure 500 {object} errorResponse
+// @Failure default {object} errorResponse
+// @Router /auth/confirm-password/code [post]
+func (h *Handler) confirmResetPasswordByCode(c *gin.Context) {
+	var input confirmPasswordByCodeRequest
+
+	if err := c.BindJSON(&input); err != nil {
+		newErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if err := h.services.Authorization.ConfirmResetPasswordByCode(c.Request.Context(), input.Email, input.Code, input.Password); err != nil {
+		newVerificationCodeErrorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, statusResponse{Status: "ok"})
+}
+
 type userPingResponse struct {
 	Status   string `json:"status"`
 	Username string `json:"username"`
@@ -275,7 +726,7 @@ type userPingResponse struct {
 func (h *Handler) userPing(c *gin.Context) {
 	usrCtx, err := h.parseAuthHeader(c)
 	if err != nil {
-		newErrorResponse(c, http.StatusUnauthorized, err.Error())
+		newAuthErrorResponse(c, err)
 		return
 	}
 
@@ -287,7 +738,7 @@ func (h *Handler) userPing(c *gin.Context) {
 
 // @Summary Verify token for other apps
 // @Tags backend
-// @Description verify token for other apps
+// @Description verify token for other apps, responds with the user id, role id, role name, permissions, the verified organization with the user's role in it and whether the user is a student
 // @ModuleID authVerify
 // @Accept  json
 // @Produce  json
@@ -300,7 +751,7 @@ func (h *Handler) userPing(c *gin.Context) {
 func (h *Handler) verifyToken(c *gin.Context) {
 	usr, err := h.parseAuthHeader(c)
 	if err != nil {
-		newErrorResponse(c, http.StatusUnauthorized, err.Error())
+		newAuthErrorResponse(c, err)
 		return
 	}
 	if err != nil {
@@ -312,9 +763,23 @@ func (h *Handler) verifyToken(c *gin.Context) {
 		newErrorResponse(c, http.StatusBadRequest, err.Error())
 		return
 	}
+	permissions := usr.permissions
+	if permissions == nil {
+		permissions = []string{}
+	}
+
+	// permissions and the organization are those of the token, the ones this service enforces as well;
+	// org_id is 0 and org_role empty for users in no verified organization;
+	// student_until is null for users who aren't students
 	c.JSON(http.StatusOK, map[string]interface{}{
-		"id":   res.ID,
-		"role": res.Role.ID,
+		"id":            res.ID,
+		"role":          res.Role.ID,
+		"role_name":     res.Role.Name,
+		"permissions":   permissions,
+		"org_id":        usr.orgID,
+		"org_role":      usr.orgRole,
+		"student":       usr.studentUntil.After(time.Now()),
+		"student_until": timeOrNil(usr.studentUntil),
 	})
 
 }
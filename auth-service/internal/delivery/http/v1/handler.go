@@ -3,11 +3,15 @@ package v1
 import (
 	"github.com/gin-gonic/gin"
 	"github.com/go-playground/validator/v10"
+	"github.com/shamank/edutour-backend/auth-service/internal/domain"
 	"github.com/shamank/edutour-backend/auth-service/internal/service"
 	"github.com/shamank/edutour-backend/auth-service/pkg/auth"
+	"golang.org/x/text/language"
 	"log/slog"
 )
 
+var localeMatcher = language.NewMatcher([]language.Tag{language.Russian, language.English})
+
 type Handler struct {
 	services     *service.Services
 	logger       *slog.Logger
@@ -30,5 +34,33 @@ func (h *Handler) InitAPI(api *gin.RouterGroup) {
 	{
 		h.initAuthRouter(v1)
 		h.initUsersRouter(v1)
+		h.initSessionsRouter(v1)
+		h.initTwoFactorRouter(v1)
+		h.initWebAuthnRouter(v1)
+		h.initPhoneRouter(v1)
+		h.initOrganizationsRouter(v1)
+		h.initUniversityApplicationsRouter(v1)
+		h.initStudentRouter(v1)
+		h.initAdminRouter(v1)
 	}
 }
+
+// clientInfo describes the device the request came from.
+func clientInfo(c *gin.Context) service.ClientInfo {
+	return service.ClientInfo{
+		IP:        c.ClientIP(),
+		UserAgent: c.Request.UserAgent(),
+	}
+}
+
+// preferredLocale returns locale if the client has chosen one, otherwise the best match for Accept-Language.
+func preferredLocale(c *gin.Context, locale string) string {
+	if locale != "" {
+		return locale
+	}
+
+	tag, _ := language.MatchStrings(localeMatcher, c.GetHeader("Accept-Language"))
+	base, _ := tag.Base()
+
+	return domain.NormalizeLocale(base.String())
+}
@@ -2,8 +2,11 @@ package v1
 
 import (
 	"errors"
+	"fmt"
 	"github.com/gin-gonic/gin"
+	"github.com/shamank/edutour-backend/auth-service/pkg/auth"
 	"net/http"
+	"slices"
 	"strings"
 	"time"
 )
@@ -11,68 +14,158 @@ import (
 const (
 	AuthorizationHeader = "Authorization"
 	userCtx             = "userID"
-	roleCtx             = "role"
+)
 
-	adminRole = "admin"
+var (
+	errAuthHeaderEmpty   = errors.New("auth header is empty")
+	errAuthHeaderInvalid = errors.New("auth header is invalid")
+	errAuthTokenEmpty    = errors.New("auth token is empty")
+	errAuthTokenRevoked  = errors.New("token is revoked")
 )
 
 type userContext struct {
-	userID   int
-	userName string
-	Role     string
+	// tokenID and expiresAt identify the access token of the request, to revoke it
+	tokenID     string
+	expiresAt   time.Time
+	userID      int
+	userName    string
+	Role        string
+	permissions []string
+	// orgID and orgRole are zero if the user is in no organization
+	orgID   int
+	orgRole string
+	// studentUntil is zero if the user isn't a student
+	studentUntil time.Time
+	sessionID    string
+}
+
+// accessToken returns the claims of the request's token that identify it for revocation.
+func (u userContext) accessToken() auth.UserClaims {
+	return auth.UserClaims{
+		TokenID:   u.tokenID,
+		UserID:    u.userID,
+		SessionID: u.sessionID,
+		ExpiresAt: u.expiresAt,
+	}
+}
+
+// can reports whether the token of the user grants the permission.
+func (u userContext) can(permission string) bool {
+	return slices.Contains(u.permissions, permission)
 }
 
 func (h *Handler) parseAuthHeader(c *gin.Context) (userContext, error) {
 	header := c.GetHeader(AuthorizationHeader)
 	if header == "" {
-		return userContext{}, errors.New("auth header is empty")
+		return userContext{}, errAuthHeaderEmpty
 	}
 	headerParts := strings.Split(header, " ")
 
 	if len(headerParts) != 2 || headerParts[0] != "Bearer" {
-		return userContext{}, errors.New("auth header is invalid")
+		return userContext{}, errAuthHeaderInvalid
 	}
 
 	if len(headerParts[1]) == 0 {
-		return userContext{}, errors.New("auth token is empty")
+		return userContext{}, errAuthTokenEmpty
 	}
 
 	res, err := h.tokenManager.Parse(headerParts[1])
 	if err != nil {
 		return userContext{}, err
 	}
-	if res.ExpireAt < time.Now().Unix() {
-		return userContext{}, errors.New("token expired")
+
+	revoked, err := h.services.Revocations.IsRevoked(c.Request.Context(), res)
+	if err != nil {
+		return userContext{}, err
+	}
+	if revoked {
+		return userContext{}, errAuthTokenRevoked
 	}
 
 	return userContext{
-		userID:   res.UserID,
-		userName: res.UserName,
-		Role:     res.Role,
+		tokenID:      res.TokenID,
+		expiresAt:    res.ExpiresAt,
+		userID:       res.UserID,
+		userName:     res.UserName,
+		Role:         res.Role,
+		permissions:  res.Permissions,
+		orgID:        res.OrganizationID,
+		orgRole:      res.OrganizationRole,
+		studentUntil: res.StudentUntil,
+		sessionID:    res.SessionID,
 	}, nil
 }
 
+// newAuthErrorResponse aborts with 401 and a code telling the client why the token was rejected,
+// e.g. an expired token should be refreshed while a malformed one should not.
+// Errors not caused by the token itself are reported as 500.
+func newAuthErrorResponse(c *gin.Context, err error) {
+	// bearerError is one of the RFC 6750 codes, code is our more specific one
+	bearerError, code := "invalid_token", ""
+
+	switch {
+	case errors.Is(err, errAuthHeaderEmpty), errors.Is(err, errAuthHeaderInvalid), errors.Is(err, errAuthTokenEmpty):
+		bearerError, code = "invalid_request", "invalid_request"
+	case errors.Is(err, auth.ErrTokenExpired):
+		code = "token_expired"
+	case errors.Is(err, auth.ErrTokenNotValidYet):
+		code = "token_not_valid_yet"
+	case errors.Is(err, auth.ErrTokenInvalidIssuer):
+		code = "invalid_issuer"
+	case errors.Is(err, auth.ErrTokenInvalidAudience):
+		code = "invalid_audience"
+	case errors.Is(err, auth.ErrTokenInvalidSignature):
+		code = "invalid_signature"
+	case errors.Is(err, auth.ErrTokenMalformed):
+		code = "malformed_token"
+	case errors.Is(err, errAuthTokenRevoked):
+		code = "token_revoked"
+	default:
+		newErrorResponse(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	c.Header("WWW-Authenticate", fmt.Sprintf(`Bearer error=%q, error_description=%q`, bearerError, err.Error()))
+	c.AbortWithStatusJSON(http.StatusUnauthorized, errorResponse{Message: err.Error(), Code: code})
+}
+
 func (h *Handler) userIdentity(c *gin.Context) {
 	usr, err := h.parseAuthHeader(c)
 	if err != nil {
-		newErrorResponse(c, http.StatusUnauthorized, err.Error())
+		newAuthErrorResponse(c, err)
 		return
 	}
 	c.Set(userCtx, usr)
 }
 
-func (h *Handler) adminOnly(c *gin.Context) {
-	role, ok := c.Get(roleCtx)
+// getUserContext returns the user set by userIdentity.
+func getUserContext(c *gin.Context) (userContext, error) {
+	usr, ok := c.Get(userCtx)
 	if !ok {
-		newErrorResponse(c, http.StatusForbidden, "you are not login")
-		return
+		return userContext{}, errors.New("user context is not set")
 	}
 
-	if role != adminRole {
-		newErrorResponse(c, http.StatusForbidden, "you are not admin")
-		return
+	res, ok := usr.(userContext)
+	if !ok {
+		return userContext{}, errors.New("user context is of invalid type")
 	}
 
-	return
+	return res, nil
+}
 
+// requirePermission returns a middleware letting through users whose token grants the permission.
+// It must follow userIdentity.
+func (h *Handler) requirePermission(permission string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		usr, err := getUserContext(c)
+		if err != nil {
+			newErrorResponse(c, http.StatusForbidden, "you are not login")
+			return
+		}
+
+		if !usr.can(permission) {
+			newErrorResponse(c, http.StatusForbidden, fmt.Sprintf("permission %s is required", permission))
+			return
+		}
+	}
 }
@@ -0,0 +1,623 @@
+package v1
+
+import (
+	"errors"
+	"github.com/gin-gonic/gin"
+	"github.com/shamank/edutour-backend/auth-service/internal/domain"
+	"github.com/shamank/edutour-backend/auth-service/internal/service"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+type organizationOutput struct {
+	ID     int    `json:"id"`
+	Name   string `json:"name"`
+	Domain string `json:"domain"`
+	// Verified organizations have been checked by the admins
+	Verified   bool       `json:"verified"`
+	VerifiedAt *time.Time `json:"verified_at"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+type currentOrganizationResponse struct {
+	Organization organizationOutput `json:"organization"`
+	// Role is the role of the user in the organization: owner, manager or viewer
+	Role string `json:"role"`
+}
+
+type organizationMemberOutput struct {
+	UserID   int       `json:"user_id"`
+	Username string    `json:"username"`
+	Email    string    `json:"email"`
+	Role     string    `json:"role"`
+	JoinedAt time.Time `json:"joined_at"`
+}
+
+type organizationMembersResponse struct {
+	Members []organizationMemberOutput `json:"members"`
+}
+
+type invitationOutput struct {
+	ID        int       `json:"id"`
+	Email     string    `json:"email"`
+	Role      string    `json:"role"`
+	ExpiresAt time.Time `json:"expires_at"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type invitationsResponse struct {
+	Invitations []invitationOutput `json:"invitations"`
+}
+
+type organizationCreateInput struct {
+	Name string `json:"name" binding:"required,max=255"`
+	// Domain is the domain of the organization's email addresses, e.g. msu.ru
+	Domain string `json:"domain" binding:"required,fqdn,max=255"`
+}
+
+type organizationUpdateInput struct {
+	Name string `json:"name" binding:"required,max=255"`
+}
+
+type organizationRoleInput struct {
+	Role string `json:"role" binding:"required,oneof=owner manager viewer"`
+}
+
+type invitationInput struct {
+	Email string `json:"email" binding:"required,email,max=255"`
+	Role  string `json:"role" binding:"required,oneof=owner manager viewer"`
+}
+
+type invitationTokenInput struct {
+	Token string `json:"token" binding:"required"`
+}
+
+func (h *Handler) initOrganizationsRouter(api *gin.RouterGroup) {
+	organizations := api.Group("/organizations", h.userIdentity)
+	{
+		organizations.POST("", h.createOrganization)
+		organizations.GET("/current", h.getCurrentOrganization)
+
+		organizations.POST("/invitations/accept", h.acceptInvitation)
+		organizations.POST("/invitations/decline", h.declineInvitation)
+
+		organizations.GET("/:id", h.getOrganization)
+		organizations.PUT("/:id", h.updateOrganization)
+		organizations.DELETE("/:id", h.deleteOrganization)
+
+		organizations.GET("/:id/members", h.getOrganizationMembers)
+		organizations.PUT("/:id/members/:user_id/role", h.changeOrganizationMemberRole)
+		organizations.DELETE("/:id/members/:user_id", h.removeOrganizationMember)
+
+		organizations.GET("/:id/invitations", h.getInvitations)
+		organizations.POST("/:id/invitations", h.inviteToOrganization)
+		organizations.DELETE("/:id/invitations/:invitation_id", h.revokeInvitation)
+	}
+}
+
+// @Summary Create Organization
+// @Tags organizations
+// @Description create an organization owned by the user; it is unverified until the admins check it. Refresh the access token to get one carrying the organization
+// @ModuleID createOrganization
+// @Accept  json
+// @Produce  json
+// @Param input body organizationCreateInput true "organization"
+// @Security ApiKeyAuth
+// @Success 201 {object} organizationOutput
+// @Failure 400,401,409 {object} errorResponse
+// @Failure 500 {object} errorResponse
+// @Failure default {object} errorResponse
+// @Router /organizations [post]
+func (h *Handler) createOrganization(c *gin.Context) {
+	usr, err := getUserContext(c)
+	if err != nil {
+		newErrorResponse(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	var input organizationCreateInput
+	if err := c.BindJSON(&input); err != nil {
+		newErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	org, err := h.services.Organizations.CreateOrganization(c.Request.Context(), usr.userID, service.OrganizationInput{
+		Name:   input.Name,
+		Domain: input.Domain,
+	})
+	if err != nil {
+		newOrganizationErrorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, newOrganizationOutput(org))
+}
+
+// @Summary Current Organization
+// @Tags organizations
+// @Description the organization of the user and the user's role in it
+// @ModuleID getCurrentOrganization
+// @Accept  json
+// @Produce  json
+// @Security ApiKeyAuth
+// @Success 200 {object} currentOrganizationResponse
+// @Failure 401,404 {object} errorResponse
+// @Failure 500 {object} errorResponse
+// @Failure default {object} errorResponse
+// @Router /organizations/current [get]
+func (h *Handler) getCurrentOrganization(c *gin.Context) {
+	usr, err := getUserContext(c)
+	if err != nil {
+		newErrorResponse(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	member, err := h.services.Organizations.Membership(c.Request.Context(), usr.userID)
+	if err != nil {
+		if errors.Is(err, domain.ErrNotOrganizationMember) {
+			newErrorResponse(c, http.StatusNotFound, err.Error())
+			return
+		}
+		newErrorResponse(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	org, err := h.services.Organizations.GetOrganization(c.Request.Context(), usr.userID, member.OrganizationID)
+	if err != nil {
+		newOrganizationErrorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, currentOrganizationResponse{
+		Organization: newOrganizationOutput(org),
+		Role:         member.Role,
+	})
+}
+
+// @Summary Organization
+// @Tags organizations
+// @Description organization by id, for its members
+// @ModuleID getOrganization
+// @Accept  json
+// @Produce  json
+// @Param id path int true "organization id"
+// @Security ApiKeyAuth
+// @Success 200 {object} organizationOutput
+// @Failure 400,401,403,404 {object} errorResponse
+// @Failure 500 {object} errorResponse
+// @Failure default {object} errorResponse
+// @Router /organizations/{id} [get]
+func (h *Handler) getOrganization(c *gin.Context) {
+	usr, orgID, ok := organizationAction(c)
+	if !ok {
+		return
+	}
+
+	org, err := h.services.Organizations.GetOrganization(c.Request.Context(), usr.userID, orgID)
+	if err != nil {
+		newOrganizationErrorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, newOrganizationOutput(org))
+}
+
+// @Summary Update Organization
+// @Tags organizations
+// @Description rename the organization, for owners; the domain can't be changed
+// @ModuleID updateOrganization
+// @Accept  json
+// @Produce  json
+// @Param id path int true "organization id"
+// @Param input body organizationUpdateInput true "organization"
+// @Security ApiKeyAuth
+// @Success 200 {object} organizationOutput
+// @Failure 400,401,403,404 {object} errorResponse
+// @Failure 500 {object} errorResponse
+// @Failure default {object} errorResponse
+// @Router /organizations/{id} [put]
+func (h *Handler) updateOrganization(c *gin.Context) {
+	usr, orgID, ok := organizationAction(c)
+	if !ok {
+		return
+	}
+
+	var input organizationUpdateInput
+	if err := c.BindJSON(&input); err != nil {
+		newErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	org, err := h.services.Organizations.UpdateOrganization(c.Request.Context(), usr.userID, orgID, input.Name)
+	if err != nil {
+		newOrganizationErrorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, newOrganizationOutput(org))
+}
+
+// @Summary Delete Organization
+// @Tags organizations
+// @Description delete the organization with its members and invitations, for owners
+// @ModuleID deleteOrganization
+// @Accept  json
+// @Produce  json
+// @Param id path int true "organization id"
+// @Security ApiKeyAuth
+// @Success 200 {object} statusResponse
+// @Failure 400,401,403,404 {object} errorResponse
+// @Failure 500 {object} errorResponse
+// @Failure default {object} errorResponse
+// @Router /organizations/{id} [delete]
+func (h *Handler) deleteOrganization(c *gin.Context) {
+	usr, orgID, ok := organizationAction(c)
+	if !ok {
+		return
+	}
+
+	if err := h.services.Organizations.DeleteOrganization(c.Request.Context(), usr.userID, orgID); err != nil {
+		newOrganizationErrorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, statusResponse{Status: "ok"})
+}
+
+// @Summary Organization Members
+// @Tags organizations
+// @Description members of the organization, for its members
+// @ModuleID getOrganizationMembers
+// @Accept  json
+// @Produce  json
+// @Param id path int true "organization id"
+// @Security ApiKeyAuth
+// @Success 200 {object} organizationMembersResponse
+// @Failure 400,401,403,404 {object} errorResponse
+// @Failure 500 {object} errorResponse
+// @Failure default {object} errorResponse
+// @Router /organizations/{id}/members [get]
+func (h *Handler) getOrganizationMembers(c *gin.Context) {
+	usr, orgID, ok := organizationAction(c)
+	if !ok {
+		return
+	}
+
+	res, err := h.services.Organizations.GetMembers(c.Request.Context(), usr.userID, orgID)
+	if err != nil {
+		newOrganizationErrorResponse(c, err)
+		return
+	}
+
+	members := make([]organizationMemberOutput, 0, len(res))
+	for _, m := range res {
+		members = append(members, organizationMemberOutput{
+			UserID:   m.UserID,
+			Username: m.Username,
+			Email:    m.Email,
+			Role:     m.Role,
+			JoinedAt: m.CreatedAt,
+		})
+	}
+
+	c.JSON(http.StatusOK, organizationMembersResponse{Members: members})
+}
+
+// @Summary Change Organization Member Role
+// @Tags organizations
+// @Description change the role of a member, for owners; the last owner can't be demoted
+// @ModuleID changeOrganizationMemberRole
+// @Accept  json
+// @Produce  json
+// @Param id path int true "organization id"
+// @Param user_id path int true "member user id"
+// @Param input body organizationRoleInput true "role"
+// @Security ApiKeyAuth
+// @Success 200 {object} statusResponse
+// @Failure 400,401,403,404,409 {object} errorResponse
+// @Failure 500 {object} errorResponse
+// @Failure default {object} errorResponse
+// @Router /organizations/{id}/members/{user_id}/role [put]
+func (h *Handler) changeOrganizationMemberRole(c *gin.Context) {
+	usr, orgID, ok := organizationAction(c)
+	if !ok {
+		return
+	}
+
+	memberID, ok := intParam(c, "user_id")
+	if !ok {
+		return
+	}
+
+	var input organizationRoleInput
+	if err := c.BindJSON(&input); err != nil {
+		newErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if err := h.services.Organizations.ChangeMemberRole(c.Request.Context(), usr.userID, orgID, memberID, input.Role); err != nil {
+		newOrganizationErrorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, statusResponse{Status: "ok"})
+}
+
+// @Summary Remove Organization Member
+// @Tags organizations
+// @Description remove a member, for owners; any member can remove themselves to leave, except the last owner
+// @ModuleID removeOrganizationMember
+// @Accept  json
+// @Produce  json
+// @Param id path int true "organization id"
+// @Param user_id path int true "member user id"
+// @Security ApiKeyAuth
+// @Success 200 {object} statusResponse
+// @Failure 400,401,403,404,409 {object} errorResponse
+// @Failure 500 {object} errorResponse
+// @Failure default {object} errorResponse
+// @Router /organizations/{id}/members/{user_id} [delete]
+func (h *Handler) removeOrganizationMember(c *gin.Context) {
+	usr, orgID, ok := organizationAction(c)
+	if !ok {
+		return
+	}
+
+	memberID, ok := intParam(c, "user_id")
+	if !ok {
+		return
+	}
+
+	if err := h.services.Organizations.RemoveMember(c.Request.Context(), usr.userID, orgID, memberID); err != nil {
+		newOrganizationErrorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, statusResponse{Status: "ok"})
+}
+
+// @Summary Organization Invitations
+// @Tags organizations
+// @Description pending invitations of the organization, for owners and managers
+// @ModuleID getInvitations
+// @Accept  json
+// @Produce  json
+// @Param id path int true "organization id"
+// @Security ApiKeyAuth
+// @Success 200 {object} invitationsResponse
+// @Failure 400,401,403,404 {object} errorResponse
+// @Failure 500 {object} errorResponse
+// @Failure default {object} errorResponse
+// @Router /organizations/{id}/invitations [get]
+func (h *Handler) getInvitations(c *gin.Context) {
+	usr, orgID, ok := organizationAction(c)
+	if !ok {
+		return
+	}
+
+	res, err := h.services.Organizations.GetInvitations(c.Request.Context(), usr.userID, orgID)
+	if err != nil {
+		newOrganizationErrorResponse(c, err)
+		return
+	}
+
+	invitations := make([]invitationOutput, 0, len(res))
+	for _, i := range res {
+		invitations = append(invitations, newInvitationOutput(i))
+	}
+
+	c.JSON(http.StatusOK, invitationsResponse{Invitations: invitations})
+}
+
+// @Summary Invite To Organization
+// @Tags organizations
+// @Description email an invitation to join the organization, for owners and managers; only owners can invite owners. Inviting an email again replaces its pending invitation
+// @ModuleID inviteToOrganization
+// @Accept  json
+// @Produce  json
+// @Param id path int true "organization id"
+// @Param input body invitationInput true "invitation"
+// @Security ApiKeyAuth
+// @Success 201 {object} invitationOutput
+// @Failure 400,401,403,404 {object} errorResponse
+// @Failure 500 {object} errorResponse
+// @Failure default {object} errorResponse
+// @Router /organizations/{id}/invitations [post]
+func (h *Handler) inviteToOrganization(c *gin.Context) {
+	usr, orgID, ok := organizationAction(c)
+	if !ok {
+		return
+	}
+
+	var input invitationInput
+	if err := c.BindJSON(&input); err != nil {
+		newErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	invitation, err := h.services.Organizations.Invite(c.Request.Context(), usr.userID, orgID, service.InvitationInput{
+		Email: input.Email,
+		Role:  input.Role,
+	})
+	if err != nil {
+		newOrganizationErrorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, newInvitationOutput(invitation))
+}
+
+// @Summary Revoke Invitation
+// @Tags organizations
+// @Description revoke a pending invitation, for owners and managers
+// @ModuleID revokeInvitation
+// @Accept  json
+// @Produce  json
+// @Param id path int true "organization id"
+// @Param invitation_id path int true "invitation id"
+// @Security ApiKeyAuth
+// @Success 200 {object} statusResponse
+// @Failure 400,401,403,404 {object} errorResponse
+// @Failure 500 {object} errorResponse
+// @Failure default {object} errorResponse
+// @Router /organizations/{id}/invitations/{invitation_id} [delete]
+func (h *Handler) revokeInvitation(c *gin.Context) {
+	usr, orgID, ok := organizationAction(c)
+	if !ok {
+		return
+	}
+
+	invitationID, ok := intParam(c, "invitation_id")
+	if !ok {
+		return
+	}
+
+	if err := h.services.Organizations.RevokeInvitation(c.Request.Context(), usr.userID, orgID, invitationID); err != nil {
+		newOrganizationErrorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, statusResponse{Status: "ok"})
+}
+
+// @Summary Accept Invitation
+// @Tags organizations
+// @Description join the organization with the token from an invitation sent to the user's email. Refresh the access token to get one carrying the organization
+// @ModuleID acceptInvitation
+// @Accept  json
+// @Produce  json
+// @Param input body invitationTokenInput true "invitation token"
+// @Security ApiKeyAuth
+// @Success 200 {object} currentOrganizationResponse
+// @Failure 400,401,403,404,409 {object} errorResponse
+// @Failure 500 {object} errorResponse
+// @Failure default {object} errorResponse
+// @Router /organizations/invitations/accept [post]
+func (h *Handler) acceptInvitation(c *gin.Context) {
+	usr, err := getUserContext(c)
+	if err != nil {
+		newErrorResponse(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	var input invitationTokenInput
+	if err := c.BindJSON(&input); err != nil {
+		newErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	member, err := h.services.Organizations.AcceptInvitation(c.Request.Context(), usr.userID, input.Token)
+	if err != nil {
+		newOrganizationErrorResponse(c, err)
+		return
+	}
+
+	org, err := h.services.Organizations.GetOrganization(c.Request.Context(), usr.userID, member.OrganizationID)
+	if err != nil {
+		newOrganizationErrorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, currentOrganizationResponse{
+		Organization: newOrganizationOutput(org),
+		Role:         member.Role,
+	})
+}
+
+// @Summary Decline Invitation
+// @Tags organizations
+// @Description decline an invitation sent to the user's email
+// @ModuleID declineInvitation
+// @Accept  json
+// @Produce  json
+// @Param input body invitationTokenInput true "invitation token"
+// @Security ApiKeyAuth
+// @Success 200 {object} statusResponse
+// @Failure 400,401,403,404 {object} errorResponse
+// @Failure 500 {object} errorResponse
+// @Failure default {object} errorResponse
+// @Router /organizations/invitations/decline [post]
+func (h *Handler) declineInvitation(c *gin.Context) {
+	usr, err := getUserContext(c)
+	if err != nil {
+		newErrorResponse(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	var input invitationTokenInput
+	if err := c.BindJSON(&input); err != nil {
+		newErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if err := h.services.Organizations.DeclineInvitation(c.Request.Context(), usr.userID, input.Token); err != nil {
+		newOrganizationErrorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, statusResponse{Status: "ok"})
+}
+
+// organizationAction returns the acting user and the organization from the path.
+func organizationAction(c *gin.Context) (userContext, int, bool) {
+	usr, err := getUserContext(c)
+	if err != nil {
+		newErrorResponse(c, http.StatusInternalServerError, err.Error())
+		return userContext{}, 0, false
+	}
+
+	orgID, ok := intParam(c, "id")
+	if !ok {
+		return userContext{}, 0, false
+	}
+
+	return usr, orgID, true
+}
+
+func intParam(c *gin.Context, name string) (int, bool) {
+	value, err := strconv.Atoi(c.Param(name))
+	if err != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid "+name)
+		return 0, false
+	}
+	return value, true
+}
+
+func newOrganizationErrorResponse(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, domain.ErrOrganizationNotFound), errors.Is(err, domain.ErrInvitationNotFound):
+		newErrorResponse(c, http.StatusNotFound, err.Error())
+	case errors.Is(err, domain.ErrNotOrganizationMember), errors.Is(err, domain.ErrOrganizationForbidden),
+		errors.Is(err, domain.ErrInvitationEmailMismatch):
+		newErrorResponse(c, http.StatusForbidden, err.Error())
+	case errors.Is(err, domain.ErrOrganizationExists), errors.Is(err, domain.ErrAlreadyOrganizationMember),
+		errors.Is(err, domain.ErrLastOrganizationOwner):
+		newErrorResponse(c, http.StatusConflict, err.Error())
+	case errors.Is(err, domain.ErrInvalidOrganizationRole):
+		newErrorResponse(c, http.StatusBadRequest, err.Error())
+	default:
+		newErrorResponse(c, http.StatusInternalServerError, err.Error())
+	}
+}
+
+func newOrganizationOutput(org domain.Organization) organizationOutput {
+	return organizationOutput{
+		ID:         org.ID,
+		Name:       org.Name,
+		Domain:     org.Domain,
+		Verified:   org.IsVerified(),
+		VerifiedAt: timeOrNil(org.VerifiedAt),
+		CreatedAt:  org.CreatedAt,
+	}
+}
+
+func newInvitationOutput(invitation domain.OrganizationInvitation) invitationOutput {
+	return invitationOutput{
+		ID:        invitation.ID,
+		Email:     invitation.Email,
+		Role:      invitation.Role,
+		ExpiresAt: invitation.ExpiresAt,
+		CreatedAt: invitation.CreatedAt,
+	}
+}
@@ -0,0 +1,180 @@
+package v1
+
+import (
+	"errors"
+	"github.com/gin-gonic/gin"
+	"github.com/shamank/edutour-backend/auth-service/internal/domain"
+	"net/http"
+)
+
+type phoneInput struct {
+	// Phone in E.164 format, e.g. +79991234567
+	Phone string `json:"phone" binding:"required,e164"`
+}
+
+type phoneVerificationInput struct {
+	// Phone to confirm in E.164 format. Leave empty to resend the code to the number given at sign up.
+	Phone string `json:"phone" binding:"omitempty,e164"`
+}
+
+type phoneCodeInput struct {
+	Code string `json:"code" binding:"required,numeric"`
+}
+
+type phoneSignInInput struct {
+	Phone string `json:"phone" binding:"required,e164"`
+	Code  string `json:"code" binding:"required,numeric"`
+}
+
+func (h *Handler) initPhoneRouter(api *gin.RouterGroup) {
+	phone := api.Group("users/me/phone", h.userIdentity)
+	{
+		phone.POST("", h.sendPhoneVerification)
+		phone.POST("/confirm", h.confirmPhone)
+	}
+}
+
+// @Summary Send Phone Verification
+// @Tags users
+// @Description text a confirmation code to the phone number
+// @ModuleID userSendPhoneVerification
+// @Accept  json
+// @Produce  json
+// @Param input body phoneVerificationInput true "phone"
+// @Security ApiKeyAuth
+// @Success 200 {object} statusResponse
+// @Failure 400,401,409,429 {object} errorResponse
+// @Failure 500 {object} errorResponse
+// @Failure default {object} errorResponse
+// @Router /users/me/phone [post]
+func (h *Handler) sendPhoneVerification(c *gin.Context) {
+	usr, err := getUserContext(c)
+	if err != nil {
+		newErrorResponse(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	var input phoneVerificationInput
+	if err := c.BindJSON(&input); err != nil {
+		newErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if err := h.services.Authorization.SendPhoneVerification(c.Request.Context(), usr.userID, input.Phone); err != nil {
+		newPhoneErrorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, statusResponse{Status: "ok"})
+}
+
+// @Summary Confirm Phone
+// @Tags users
+// @Description confirm the phone number with the texted code
+// @ModuleID userConfirmPhone
+// @Accept  json
+// @Produce  json
+// @Param input body phoneCodeInput true "code"
+// @Security ApiKeyAuth
+// @Success 200 {object} statusResponse
+// @Failure 400,401,409,429 {object} errorResponse
+// @Failure 500 {object} errorResponse
+// @Failure default {object} errorResponse
+// @Router /users/me/phone/confirm [post]
+func (h *Handler) confirmPhone(c *gin.Context) {
+	usr, err := getUserContext(c)
+	if err != nil {
+		newErrorResponse(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	var input phoneCodeInput
+	if err := c.BindJSON(&input); err != nil {
+		newErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if err := h.services.Authorization.ConfirmPhone(c.Request.Context(), usr.userID, input.Code); err != nil {
+		newPhoneErrorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, statusResponse{Status: "ok"})
+}
+
+// @Summary Send Phone Sign In Code
+// @Tags auth
+// @Description text a sign in code to a confirmed phone number
+// @ModuleID authSendPhoneSignInCode
+// @Accept  json
+// @Produce  json
+// @Param input body phoneInput true "phone"
+// @Success 200 {object} statusResponse
+// @Failure 400,429 {object} errorResponse
+// @Failure 500 {object} errorResponse
+// @Failure default {object} errorResponse
+// @Router /auth/phone/code [post]
+func (h *Handler) sendPhoneSignInCode(c *gin.Context) {
+	var input phoneInput
+	if err := c.BindJSON(&input); err != nil {
+		newErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if err := h.services.Authorization.SendPhoneSignInCode(c.Request.Context(), input.Phone); err != nil {
+		if errors.Is(err, domain.ErrCodeResendTooSoon) {
+			newErrorResponse(c, http.StatusTooManyRequests, err.Error())
+			return
+		}
+		newErrorResponse(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	c.JSON(http.StatusOK, statusResponse{Status: "ok"})
+}
+
+// @Summary Sign In With Phone
+// @Tags auth
+// @Description sign in with the phone number and the texted code
+// @ModuleID authSignInPhone
+// @Accept  json
+// @Produce  json
+// @Param input body phoneSignInInput true "phone and code"
+// @Success 200 {object} tokenResponse
+// @Success 202 {object} twoFactorChallengeResponse "two-factor code required, continue with /auth/sign-in/2fa"
+// @Failure 400,403,429 {object} errorResponse
+// @Failure 500 {object} errorResponse
+// @Failure default {object} errorResponse
+// @Router /auth/phone/sign-in [post]
+func (h *Handler) signInPhone(c *gin.Context) {
+	var input phoneSignInInput
+	if err := c.BindJSON(&input); err != nil {
+		newErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	res, err := h.services.Authorization.SignInPhone(c.Request.Context(), input.Phone, input.Code, clientInfo(c))
+	if err != nil {
+		if errors.Is(err, domain.ErrUserBanned) {
+			newErrorResponse(c, http.StatusForbidden, err.Error())
+			return
+		}
+		newVerificationCodeErrorResponse(c, err)
+		return
+	}
+
+	newSignInResponse(c, res)
+}
+
+func newPhoneErrorResponse(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, domain.ErrPhoneAlreadyUsed):
+		newErrorResponse(c, http.StatusConflict, err.Error())
+	case errors.Is(err, domain.ErrPhoneNotSet):
+		newErrorResponse(c, http.StatusBadRequest, err.Error())
+	case errors.Is(err, domain.ErrCodeResendTooSoon):
+		newErrorResponse(c, http.StatusTooManyRequests, err.Error())
+	default:
+		newVerificationCodeErrorResponse(c, err)
+	}
+}
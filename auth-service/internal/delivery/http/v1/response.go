@@ -6,6 +6,7 @@ import (
 
 type errorResponse struct {
 	Message string `json:"message"`
+	Code    string `json:"code,omitempty"`
 }
 
 type statusResponse struct {
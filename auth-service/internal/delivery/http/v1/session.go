@@ -0,0 +1,115 @@
+package v1
+
+import (
+	"errors"
+	"github.com/gin-gonic/gin"
+	"github.com/shamank/edutour-backend/auth-service/internal/domain"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+type sessionOutput struct {
+	ID         int       `json:"id"`
+	IP         string    `json:"ip"`
+	Browser    string    `json:"browser"`
+	OS         string    `json:"os"`
+	Device     string    `json:"device"`
+	UserAgent  string    `json:"user_agent"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastUsedAt time.Time `json:"last_used_at"`
+	Current    bool      `json:"current"`
+}
+
+type sessionsResponse struct {
+	Sessions []sessionOutput `json:"sessions"`
+}
+
+func (h *Handler) initSessionsRouter(api *gin.RouterGroup) {
+	sessions := api.Group("users/me/sessions", h.userIdentity)
+	{
+		sessions.GET("", h.getUserSessions)
+		sessions.DELETE("/:id", h.revokeUserSession)
+	}
+}
+
+// @Summary Get Sessions
+// @Tags users
+// @Description list devices the user is signed in on
+// @ModuleID userGetSessions
+// @Accept  json
+// @Produce  json
+// @Security ApiKeyAuth
+// @Success 200 {object} sessionsResponse
+// @Failure 401 {object} errorResponse
+// @Failure 500 {object} errorResponse
+// @Failure default {object} errorResponse
+// @Router /users/me/sessions [get]
+func (h *Handler) getUserSessions(c *gin.Context) {
+	usr, err := getUserContext(c)
+	if err != nil {
+		newErrorResponse(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	res, err := h.services.Sessions.GetUserSessions(c.Request.Context(), usr.userID)
+	if err != nil {
+		newErrorResponse(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	sessions := make([]sessionOutput, 0, len(res))
+	for _, s := range res {
+		sessions = append(sessions, sessionOutput{
+			ID:         s.ID,
+			IP:         s.IP,
+			Browser:    s.Browser,
+			OS:         s.OS,
+			Device:     s.Device,
+			UserAgent:  s.UserAgent,
+			CreatedAt:  s.CreatedAt,
+			LastUsedAt: s.LastUsedAt,
+			Current:    s.FamilyID == usr.sessionID,
+		})
+	}
+
+	c.JSON(http.StatusOK, sessionsResponse{Sessions: sessions})
+}
+
+// @Summary Revoke Session
+// @Tags users
+// @Description sign out one of the user's devices
+// @ModuleID userRevokeSession
+// @Accept  json
+// @Produce  json
+// @Param id path int true "session id"
+// @Security ApiKeyAuth
+// @Success 200 {object} statusResponse
+// @Failure 400,401,404 {object} errorResponse
+// @Failure 500 {object} errorResponse
+// @Failure default {object} errorResponse
+// @Router /users/me/sessions/{id} [delete]
+func (h *Handler) revokeUserSession(c *gin.Context) {
+	usr, err := getUserContext(c)
+	if err != nil {
+		newErrorResponse(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	sessionID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid session id")
+		return
+	}
+
+	if err := h.services.Sessions.RevokeSession(c.Request.Context(), usr.userID, sessionID, usr.accessToken()); err != nil {
+		if errors.Is(err, domain.ErrSessionNotFound) {
+			newErrorResponse(c, http.StatusNotFound, err.Error())
+			return
+		}
+		newErrorResponse(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	c.JSON(http.StatusOK, statusResponse{Status: "ok"})
+}
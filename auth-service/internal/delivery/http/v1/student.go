@@ -0,0 +1,191 @@
+package v1
+
+import (
+	"errors"
+	"github.com/gin-gonic/gin"
+	"github.com/shamank/edutour-backend/auth-service/internal/domain"
+	"net/http"
+	"time"
+)
+
+type studentStatusOutput struct {
+	// AcademicEmail is the confirmed academic email, empty if there is none
+	AcademicEmail string `json:"academic_email"`
+	// PendingAcademicEmail is waiting for confirmation, empty if there is none
+	PendingAcademicEmail string     `json:"pending_academic_email"`
+	Student              bool       `json:"student"`
+	StudentUntil         *time.Time `json:"student_until"`
+}
+
+type academicEmailInput struct {
+	Email string `json:"email" binding:"required,email,max=255"`
+}
+
+type academicEmailConfirmInput struct {
+	Token string `json:"token" binding:"required"`
+}
+
+func (h *Handler) initStudentRouter(api *gin.RouterGroup) {
+	academicEmail := api.Group("users/me/academic-email", h.userIdentity)
+	{
+		academicEmail.GET("", h.getStudentStatus)
+		academicEmail.PUT("", h.setAcademicEmail)
+		academicEmail.POST("/confirm", h.confirmAcademicEmail)
+		academicEmail.DELETE("", h.removeAcademicEmail)
+	}
+}
+
+// @Summary Student Status
+// @Tags users
+// @Description academic email of the user and the student status it gives
+// @ModuleID userGetStudentStatus
+// @Accept  json
+// @Produce  json
+// @Security ApiKeyAuth
+// @Success 200 {object} studentStatusOutput
+// @Failure 401 {object} errorResponse
+// @Failure 500 {object} errorResponse
+// @Failure default {object} errorResponse
+// @Router /users/me/academic-email [get]
+func (h *Handler) getStudentStatus(c *gin.Context) {
+	usr, err := getUserContext(c)
+	if err != nil {
+		newErrorResponse(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	status, err := h.services.Students.GetStudentStatus(c.Request.Context(), usr.userID)
+	if err != nil {
+		newStudentErrorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, newStudentStatusOutput(status))
+}
+
+// @Summary Set Academic Email
+// @Tags users
+// @Description email a confirmation link to an academic email. Its domain has to be on the list of university domains; the current academic email stays until the new one is confirmed
+// @ModuleID userSetAcademicEmail
+// @Accept  json
+// @Produce  json
+// @Param input body academicEmailInput true "academic email"
+// @Security ApiKeyAuth
+// @Success 200 {object} statusResponse
+// @Failure 400,401,409,429 {object} errorResponse
+// @Failure 500 {object} errorResponse
+// @Failure default {object} errorResponse
+// @Router /users/me/academic-email [put]
+func (h *Handler) setAcademicEmail(c *gin.Context) {
+	usr, err := getUserContext(c)
+	if err != nil {
+		newErrorResponse(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	var input academicEmailInput
+	if err := c.BindJSON(&input); err != nil {
+		newErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if err := h.services.Students.SendAcademicEmailConfirmation(c.Request.Context(), usr.userID, input.Email); err != nil {
+		newStudentErrorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, statusResponse{Status: "ok"})
+}
+
+// @Summary Confirm Academic Email
+// @Tags users
+// @Description confirm the academic email with the token from the emailed link, which makes the user a student. Access tokens issued before lack the status, refresh them
+// @ModuleID userConfirmAcademicEmail
+// @Accept  json
+// @Produce  json
+// @Param input body academicEmailConfirmInput true "token"
+// @Security ApiKeyAuth
+// @Success 200 {object} studentStatusOutput
+// @Failure 400,401,409 {object} errorResponse
+// @Failure 500 {object} errorResponse
+// @Failure default {object} errorResponse
+// @Router /users/me/academic-email/confirm [post]
+func (h *Handler) confirmAcademicEmail(c *gin.Context) {
+	usr, err := getUserContext(c)
+	if err != nil {
+		newErrorResponse(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	var input academicEmailConfirmInput
+	if err := c.BindJSON(&input); err != nil {
+		newErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	status, err := h.services.Students.ConfirmAcademicEmail(c.Request.Context(), usr.userID, input.Token)
+	if err != nil {
+		newStudentErrorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, newStudentStatusOutput(status))
+}
+
+// @Summary Remove Academic Email
+// @Tags users
+// @Description remove the academic email, confirmed and pending, which ends the student status
+// @ModuleID userRemoveAcademicEmail
+// @Accept  json
+// @Produce  json
+// @Security ApiKeyAuth
+// @Success 200 {object} statusResponse
+// @Failure 401 {object} errorResponse
+// @Failure 500 {object} errorResponse
+// @Failure default {object} errorResponse
+// @Router /users/me/academic-email [delete]
+func (h *Handler) removeAcademicEmail(c *gin.Context) {
+	usr, err := getUserContext(c)
+	if err != nil {
+		newErrorResponse(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	if err := h.services.Students.RemoveAcademicEmail(c.Request.Context(), usr.userID); err != nil {
+		newStudentErrorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, statusResponse{Status: "ok"})
+}
+
+func newStudentErrorResponse(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, domain.ErrAcademicDomainNotFound):
+		newErrorResponse(c, http.StatusNotFound, err.Error())
+	case errors.Is(err, domain.ErrAcademicDomainNotAllowed), errors.Is(err, domain.ErrAcademicDomainTooBroad),
+		errors.Is(err, domain.ErrInvalidToken):
+		newErrorResponse(c, http.StatusBadRequest, err.Error())
+	case errors.Is(err, domain.ErrAcademicDomainExists), errors.Is(err, domain.ErrAcademicEmailUsed),
+		errors.Is(err, domain.ErrAcademicEmailNotSet):
+		newErrorResponse(c, http.StatusConflict, err.Error())
+	case errors.Is(err, domain.ErrConfirmationResendTooSoon):
+		newErrorResponse(c, http.StatusTooManyRequests, err.Error())
+	default:
+		newErrorResponse(c, http.StatusInternalServerError, err.Error())
+	}
+}
+
+func newStudentStatusOutput(status domain.StudentStatus) studentStatusOutput {
+	out := studentStatusOutput{
+		AcademicEmail:        status.AcademicEmail,
+		PendingAcademicEmail: status.PendingAcademicEmail,
+		Student:              status.IsStudent(time.Now()),
+	}
+
+	if out.Student {
+		out.StudentUntil = &status.StudentUntil
+	}
+
+	return out
+}
@@ -0,0 +1,151 @@
+package v1
+
+import (
+	"encoding/base64"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"github.com/shamank/edutour-backend/auth-service/internal/domain"
+	"net/http"
+)
+
+type totpEnrollResponse struct {
+	Secret     string `json:"secret"`
+	OTPAuthURI string `json:"otpauth_uri"`
+	// QRCode is a base64 encoded PNG of OTPAuthURI
+	QRCode string `json:"qr_code"`
+}
+
+type twoFactorCodeInput struct {
+	Code string `json:"code" binding:"required"`
+}
+
+type recoveryCodesResponse struct {
+	RecoveryCodes []string `json:"recovery_codes"`
+}
+
+func (h *Handler) initTwoFactorRouter(api *gin.RouterGroup) {
+	twoFactor := api.Group("users/me/2fa", h.userIdentity)
+	{
+		twoFactor.POST("/totp", h.enrollTOTP)
+		twoFactor.POST("/totp/confirm", h.confirmTOTP)
+		twoFactor.POST("/totp/disable", h.disableTOTP)
+	}
+}
+
+// @Summary Enroll TOTP
+// @Tags users
+// @Description generate an authenticator app secret, 2FA is enabled after confirmation
+// @ModuleID userEnrollTOTP
+// @Accept  json
+// @Produce  json
+// @Security ApiKeyAuth
+// @Success 200 {object} totpEnrollResponse
+// @Failure 401,409 {object} errorResponse
+// @Failure 500 {object} errorResponse
+// @Failure default {object} errorResponse
+// @Router /users/me/2fa/totp [post]
+func (h *Handler) enrollTOTP(c *gin.Context) {
+	usr, err := getUserContext(c)
+	if err != nil {
+		newErrorResponse(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	key, err := h.services.TwoFactor.EnrollTOTP(c.Request.Context(), usr.userID, usr.userName)
+	if err != nil {
+		if errors.Is(err, domain.ErrTwoFactorAlreadyEnabled) {
+			newErrorResponse(c, http.StatusConflict, err.Error())
+			return
+		}
+		newErrorResponse(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	c.JSON(http.StatusOK, totpEnrollResponse{
+		Secret:     key.Secret,
+		OTPAuthURI: key.URI,
+		QRCode:     base64.StdEncoding.EncodeToString(key.QRCode),
+	})
+}
+
+// @Summary Confirm TOTP
+// @Tags users
+// @Description enable 2FA with the first code from the authenticator app, returns one-time recovery codes
+// @ModuleID userConfirmTOTP
+// @Accept  json
+// @Produce  json
+// @Param input body twoFactorCodeInput true "code"
+// @Security ApiKeyAuth
+// @Success 200 {object} recoveryCodesResponse
+// @Failure 400,401,404,409 {object} errorResponse
+// @Failure 500 {object} errorResponse
+// @Failure default {object} errorResponse
+// @Router /users/me/2fa/totp/confirm [post]
+func (h *Handler) confirmTOTP(c *gin.Context) {
+	usr, err := getUserContext(c)
+	if err != nil {
+		newErrorResponse(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	var input twoFactorCodeInput
+	if err := c.BindJSON(&input); err != nil {
+		newErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	codes, err := h.services.TwoFactor.ConfirmTOTP(c.Request.Context(), usr.userID, input.Code)
+	if err != nil {
+		newTwoFactorErrorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, recoveryCodesResponse{RecoveryCodes: codes})
+}
+
+// @Summary Disable TOTP
+// @Tags users
+// @Description disable 2FA, requires a code from the authenticator app or a recovery code
+// @ModuleID userDisableTOTP
+// @Accept  json
+// @Produce  json
+// @Param input body twoFactorCodeInput true "code"
+// @Security ApiKeyAuth
+// @Success 200 {object} statusResponse
+// @Failure 400,401,404 {object} errorResponse
+// @Failure 500 {object} errorResponse
+// @Failure default {object} errorResponse
+// @Router /users/me/2fa/totp/disable [post]
+func (h *Handler) disableTOTP(c *gin.Context) {
+	usr, err := getUserContext(c)
+	if err != nil {
+		newErrorResponse(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	var input twoFactorCodeInput
+	if err := c.BindJSON(&input); err != nil {
+		newErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if err := h.services.TwoFactor.DisableTOTP(c.Request.Context(), usr.userID, input.Code); err != nil {
+		newTwoFactorErrorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, statusResponse{Status: "ok"})
+}
+
+func newTwoFactorErrorResponse(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, domain.ErrInvalidTwoFactorCode):
+		newErrorResponse(c, http.StatusBadRequest, err.Error())
+	case errors.Is(err, domain.ErrTwoFactorNotEnabled):
+		newErrorResponse(c, http.StatusNotFound, err.Error())
+	case errors.Is(err, domain.ErrTwoFactorAlreadyEnabled):
+		newErrorResponse(c, http.StatusConflict, err.Error())
+	default:
+		newErrorResponse(c, http.StatusInternalServerError, err.Error())
+	}
+}
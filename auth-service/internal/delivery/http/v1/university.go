@@ -0,0 +1,304 @@
+package v1
+
+import (
+	"errors"
+	"github.com/gin-gonic/gin"
+	"github.com/shamank/edutour-backend/auth-service/internal/domain"
+	"github.com/shamank/edutour-backend/auth-service/internal/service"
+	"io"
+	"mime"
+	"net/http"
+	"time"
+)
+
+// maxApplicationUploadSize bounds the whole multipart body of an application. It only keeps huge
+// uploads off the disk, the service checks the configured number and size of documents.
+const maxApplicationUploadSize = 100 << 20
+
+type applicationDocumentOutput struct {
+	ID          int       `json:"id"`
+	FileName    string    `json:"file_name"`
+	ContentType string    `json:"content_type"`
+	Size        int64     `json:"size"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+type applicationOutput struct {
+	ID       int    `json:"id"`
+	UserID   int    `json:"user_id"`
+	Username string `json:"username"`
+	Email    string `json:"email"`
+	// OrganizationID is the organization that gets verified on approval, 0 if there is none
+	OrganizationID int    `json:"organization_id"`
+	UniversityName string `json:"university_name"`
+	Position       string `json:"position"`
+	Message        string `json:"message"`
+	// Status is pending, approved or rejected
+	Status        string     `json:"status"`
+	ReviewComment string     `json:"review_comment"`
+	ReviewedAt    *time.Time `json:"reviewed_at"`
+	CreatedAt     time.Time  `json:"created_at"`
+	// Documents are only listed for a single application
+	Documents []applicationDocumentOutput `json:"documents,omitempty"`
+}
+
+type applicationsResponse struct {
+	Applications []applicationOutput `json:"applications"`
+}
+
+type applicationInput struct {
+	UniversityName string `form:"university_name" binding:"required,max=255"`
+	// Position is the applicant's position at the university
+	Position string `form:"position" binding:"required,max=255"`
+	Message  string `form:"message" binding:"max=4000"`
+}
+
+func (h *Handler) initUniversityApplicationsRouter(api *gin.RouterGroup) {
+	applications := api.Group("/university-applications", h.userIdentity)
+	{
+		applications.POST("", h.applyForUniversity)
+		applications.GET("", h.getUniversityApplications)
+		applications.GET("/:id", h.getUniversityApplication)
+		applications.GET("/:id/documents/:document_id", h.getUniversityApplicationDocument)
+	}
+}
+
+// @Summary Apply For University
+// @Tags university-applications
+// @Description apply to become a representative of a university. Documents are PDF, JPEG or PNG files proving the position; an owned unverified organization is verified on approval. Only users with the default role can apply
+// @ModuleID applyForUniversity
+// @Accept  mpfd
+// @Produce  json
+// @Param university_name formData string true "university"
+// @Param position formData string true "position at the university"
+// @Param message formData string false "message to the reviewer"
+// @Param documents formData file true "supporting documents, the field may be repeated"
+// @Security ApiKeyAuth
+// @Success 201 {object} applicationOutput
+// @Failure 400,401,409,413 {object} errorResponse
+// @Failure 500 {object} errorResponse
+// @Failure default {object} errorResponse
+// @Router /university-applications [post]
+func (h *Handler) applyForUniversity(c *gin.Context) {
+	usr, err := getUserContext(c)
+	if err != nil {
+		newErrorResponse(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxApplicationUploadSize)
+
+	form, err := c.MultipartForm()
+	if err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			newErrorResponse(c, http.StatusRequestEntityTooLarge, "documents are too large")
+			return
+		}
+		newErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	defer form.RemoveAll()
+
+	var input applicationInput
+	if err := c.ShouldBind(&input); err != nil {
+		newErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	documents := make([]service.DocumentInput, 0, len(form.File["documents"]))
+
+	for _, header := range form.File["documents"] {
+		f, err := header.Open()
+		if err != nil {
+			newErrorResponse(c, http.StatusInternalServerError, err.Error())
+			return
+		}
+		defer f.Close()
+
+		documents = append(documents, service.DocumentInput{
+			FileName: header.Filename,
+			Size:     header.Size,
+			Content:  f,
+		})
+	}
+
+	application, err := h.services.Universities.Apply(c.Request.Context(), usr.userID, service.ApplicationInput{
+		UniversityName: input.UniversityName,
+		Position:       input.Position,
+		Message:        input.Message,
+		Documents:      documents,
+	})
+	if err != nil {
+		newUniversityErrorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, newApplicationOutput(application))
+}
+
+// @Summary University Applications
+// @Tags university-applications
+// @Description applications of the user, newest first
+// @ModuleID getUniversityApplications
+// @Accept  json
+// @Produce  json
+// @Security ApiKeyAuth
+// @Success 200 {object} applicationsResponse
+// @Failure 401 {object} errorResponse
+// @Failure 500 {object} errorResponse
+// @Failure default {object} errorResponse
+// @Router /university-applications [get]
+func (h *Handler) getUniversityApplications(c *gin.Context) {
+	usr, err := getUserContext(c)
+	if err != nil {
+		newErrorResponse(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	res, err := h.services.Universities.GetUserApplications(c.Request.Context(), usr.userID)
+	if err != nil {
+		newErrorResponse(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	c.JSON(http.StatusOK, newApplicationsResponse(res))
+}
+
+// @Summary University Application
+// @Tags university-applications
+// @Description application of the user with its documents
+// @ModuleID getUniversityApplication
+// @Accept  json
+// @Produce  json
+// @Param id path int true "application id"
+// @Security ApiKeyAuth
+// @Success 200 {object} applicationOutput
+// @Failure 400,401,404 {object} errorResponse
+// @Failure 500 {object} errorResponse
+// @Failure default {object} errorResponse
+// @Router /university-applications/{id} [get]
+func (h *Handler) getUniversityApplication(c *gin.Context) {
+	usr, err := getUserContext(c)
+	if err != nil {
+		newErrorResponse(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	applicationID, ok := intParam(c, "id")
+	if !ok {
+		return
+	}
+
+	application, err := h.services.Universities.GetUserApplication(c.Request.Context(), usr.userID, applicationID)
+	if err != nil {
+		newUniversityErrorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, newApplicationOutput(application))
+}
+
+// @Summary University Application Document
+// @Tags university-applications
+// @Description download a document of an application of the user
+// @ModuleID getUniversityApplicationDocument
+// @Produce  octet-stream
+// @Param id path int true "application id"
+// @Param document_id path int true "document id"
+// @Security ApiKeyAuth
+// @Success 200 {file} file
+// @Failure 400,401,404 {object} errorResponse
+// @Failure 500 {object} errorResponse
+// @Failure default {object} errorResponse
+// @Router /university-applications/{id}/documents/{document_id} [get]
+func (h *Handler) getUniversityApplicationDocument(c *gin.Context) {
+	usr, err := getUserContext(c)
+	if err != nil {
+		newErrorResponse(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	applicationID, ok := intParam(c, "id")
+	if !ok {
+		return
+	}
+
+	documentID, ok := intParam(c, "document_id")
+	if !ok {
+		return
+	}
+
+	document, r, err := h.services.Universities.OpenUserDocument(c.Request.Context(), usr.userID, applicationID, documentID)
+	if err != nil {
+		newUniversityErrorResponse(c, err)
+		return
+	}
+
+	sendDocument(c, document, r)
+}
+
+// sendDocument responds with the document as an attachment and closes r.
+func sendDocument(c *gin.Context, document domain.ApplicationDocument, r io.ReadCloser) {
+	defer r.Close()
+
+	c.DataFromReader(http.StatusOK, document.Size, document.ContentType, r, map[string]string{
+		"Content-Disposition":    mime.FormatMediaType("attachment", map[string]string{"filename": document.FileName}),
+		"X-Content-Type-Options": "nosniff",
+	})
+}
+
+func newUniversityErrorResponse(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, domain.ErrApplicationNotFound), errors.Is(err, domain.ErrDocumentNotFound):
+		newErrorResponse(c, http.StatusNotFound, err.Error())
+	case errors.Is(err, domain.ErrInvalidDocument), errors.Is(err, domain.ErrTooManyDocuments),
+		errors.Is(err, domain.ErrDocumentsRequired):
+		newErrorResponse(c, http.StatusBadRequest, err.Error())
+	case errors.Is(err, domain.ErrApplicationPending), errors.Is(err, domain.ErrApplicationReviewed),
+		errors.Is(err, domain.ErrAlreadyUniversity), errors.Is(err, domain.ErrApplicantRole),
+		errors.Is(err, domain.ErrOrganizationExists):
+		newErrorResponse(c, http.StatusConflict, err.Error())
+	case errors.Is(err, domain.ErrReviewOwnApplication):
+		newErrorResponse(c, http.StatusForbidden, err.Error())
+	default:
+		newErrorResponse(c, http.StatusInternalServerError, err.Error())
+	}
+}
+
+func newApplicationsResponse(applications []domain.UniversityApplication) applicationsResponse {
+	res := applicationsResponse{Applications: make([]applicationOutput, 0, len(applications))}
+	for _, application := range applications {
+		res.Applications = append(res.Applications, newApplicationOutput(application))
+	}
+	return res
+}
+
+func newApplicationOutput(application domain.UniversityApplication) applicationOutput {
+	out := applicationOutput{
+		ID:             application.ID,
+		UserID:         application.UserID,
+		Username:       application.Username,
+		Email:          application.Email,
+		OrganizationID: application.OrganizationID,
+		UniversityName: application.UniversityName,
+		Position:       application.Position,
+		Message:        application.Message,
+		Status:         application.Status,
+		ReviewComment:  application.ReviewComment,
+		ReviewedAt:     timeOrNil(application.ReviewedAt),
+		CreatedAt:      application.CreatedAt,
+	}
+
+	for _, d := range application.Documents {
+		out.Documents = append(out.Documents, applicationDocumentOutput{
+			ID:          d.ID,
+			FileName:    d.FileName,
+			ContentType: d.ContentType,
+			Size:        d.Size,
+			CreatedAt:   d.CreatedAt,
+		})
+	}
+
+	return out
+}
@@ -2,8 +2,10 @@ package v1
 
 import (
 	"github.com/gin-gonic/gin"
+	"github.com/shamank/edutour-backend/auth-service/internal/domain"
 	"github.com/shamank/edutour-backend/auth-service/internal/service"
 	"net/http"
+	"time"
 )
 
 type userProfileOutput struct {
@@ -13,6 +15,9 @@ type userProfileOutput struct {
 	MiddleName string `json:"middle_name"`
 	Avatar     string `json:"avatar"`
 	Role       string `json:"role"`
+	// Student is true while the user has a confirmed academic email, until StudentUntil
+	Student      bool       `json:"student"`
+	StudentUntil *time.Time `json:"student_until"`
 }
 
 type userProfileInput struct {
@@ -20,6 +25,8 @@ type userProfileInput struct {
 	LastName   string `json:"last_name"`
 	MiddleName string `json:"middle_name"`
 	Avatar     string `json:"avatar"`
+	// Locale is the language of emails, "ru" or "en"
+	Locale string `json:"locale" binding:"omitempty,oneof=ru en"`
 }
 
 func (h *Handler) initUsersRouter(api *gin.RouterGroup) {
@@ -55,12 +62,14 @@ func (h *Handler) getUserProfile(c *gin.Context) {
 	}
 
 	c.JSON(http.StatusOK, userProfileOutput{
-		UserName:   res.UserName,
-		FirstName:  res.FirstName,
-		LastName:   res.LastName,
-		MiddleName: res.MiddleName,
-		Avatar:     res.Avatar,
-		Role:       res.Role,
+		UserName:     res.UserName,
+		FirstName:    res.FirstName,
+		LastName:     res.LastName,
+		MiddleName:   res.MiddleName,
+		Avatar:       res.Avatar,
+		Role:         res.Role,
+		Student:      !res.StudentUntil.IsZero(),
+		StudentUntil: timeOrNil(res.StudentUntil),
 	})
 
 }
@@ -86,13 +95,11 @@ func (h *Handler) updateUserProfile(c *gin.Context) {
 
 	usr, err := h.parseAuthHeader(c)
 	if err != nil {
-		newErrorResponse(c, http.StatusInternalServerError, err.Error())
+		newAuthErrorResponse(c, err)
 		return
 	}
 
-	IsAdmin := usr.Role == adminRole
-
-	if usr.userName != userName && !IsAdmin {
+	if usr.userName != userName && !usr.can(domain.PermissionUsersUpdateAny) {
 		newErrorResponse(c, http.StatusForbidden, "permission denied")
 		return
 	}
@@ -107,6 +114,7 @@ func (h *Handler) updateUserProfile(c *gin.Context) {
 		LastName:   input.LastName,
 		MiddleName: input.MiddleName,
 		Avatar:     input.Avatar,
+		Locale:     input.Locale,
 	})
 	if err != nil {
 		newErrorResponse(c, http.StatusBadRequest, err.Error())
@@ -140,7 +148,7 @@ func (h *Handler) userChangePassword(c *gin.Context) {
 
 	usr, err := h.parseAuthHeader(c)
 	if err != nil {
-		newErrorResponse(c, http.StatusInternalServerError, err.Error())
+		newAuthErrorResponse(c, err)
 		return
 	}
 
@@ -0,0 +1,281 @@
+package v1
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"github.com/go-webauthn/webauthn/protocol"
+	"github.com/shamank/edutour-backend/auth-service/internal/domain"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+type webAuthnRegistrationResponse struct {
+	SessionID string `json:"session_id"`
+	// Options are passed to navigator.credentials.create()
+	Options *protocol.CredentialCreation `json:"options" swaggertype:"object"`
+}
+
+type webAuthnLoginResponse struct {
+	SessionID string `json:"session_id"`
+	// Options are passed to navigator.credentials.get()
+	Options *protocol.CredentialAssertion `json:"options" swaggertype:"object"`
+}
+
+type webAuthnRegistrationInput struct {
+	SessionID string `json:"session_id" binding:"required"`
+	// Name helps the user tell their passkeys apart, e.g. "work laptop"
+	Name string `json:"name" binding:"max=255"`
+	// Credential is the PublicKeyCredential returned by navigator.credentials.create()
+	Credential json.RawMessage `json:"credential" binding:"required" swaggertype:"object"`
+}
+
+type webAuthnLoginInput struct {
+	SessionID string `json:"session_id" binding:"required"`
+	// Credential is the PublicKeyCredential returned by navigator.credentials.get()
+	Credential json.RawMessage `json:"credential" binding:"required" swaggertype:"object"`
+}
+
+type webAuthnCredentialOutput struct {
+	ID         int       `json:"id"`
+	Name       string    `json:"name"`
+	Synced     bool      `json:"synced"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastUsedAt time.Time `json:"last_used_at"`
+}
+
+type webAuthnCredentialsResponse struct {
+	Credentials []webAuthnCredentialOutput `json:"credentials"`
+}
+
+func (h *Handler) initWebAuthnRouter(api *gin.RouterGroup) {
+	webAuthn := api.Group("auth/webauthn")
+	{
+		webAuthn.POST("/register/begin", h.userIdentity, h.webAuthnBeginRegistration)
+		webAuthn.POST("/register/finish", h.userIdentity, h.webAuthnFinishRegistration)
+
+		webAuthn.POST("/login/begin", h.webAuthnBeginLogin)
+		webAuthn.POST("/login/finish", h.webAuthnFinishLogin)
+
+		webAuthn.GET("/credentials", h.userIdentity, h.getWebAuthnCredentials)
+		webAuthn.DELETE("/credentials/:id", h.userIdentity, h.deleteWebAuthnCredential)
+	}
+}
+
+// @Summary WebAuthn Begin Registration
+// @Tags webauthn
+// @Description start adding a passkey to the account
+// @ModuleID webAuthnBeginRegistration
+// @Accept  json
+// @Produce  json
+// @Security ApiKeyAuth
+// @Success 200 {object} webAuthnRegistrationResponse
+// @Failure 401 {object} errorResponse
+// @Failure 500 {object} errorResponse
+// @Failure default {object} errorResponse
+// @Router /auth/webauthn/register/begin [post]
+func (h *Handler) webAuthnBeginRegistration(c *gin.Context) {
+	usr, err := getUserContext(c)
+	if err != nil {
+		newErrorResponse(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	options, sessionID, err := h.services.WebAuthn.BeginRegistration(c.Request.Context(), usr.userID)
+	if err != nil {
+		newErrorResponse(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	c.JSON(http.StatusOK, webAuthnRegistrationResponse{
+		SessionID: sessionID,
+		Options:   options,
+	})
+}
+
+// @Summary WebAuthn Finish Registration
+// @Tags webauthn
+// @Description verify the authenticator response and save the passkey
+// @ModuleID webAuthnFinishRegistration
+// @Accept  json
+// @Produce  json
+// @Param input body webAuthnRegistrationInput true "ceremony id and credential"
+// @Security ApiKeyAuth
+// @Success 200 {object} webAuthnCredentialOutput
+// @Failure 400,401,409 {object} errorResponse
+// @Failure 500 {object} errorResponse
+// @Failure default {object} errorResponse
+// @Router /auth/webauthn/register/finish [post]
+func (h *Handler) webAuthnFinishRegistration(c *gin.Context) {
+	usr, err := getUserContext(c)
+	if err != nil {
+		newErrorResponse(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	var input webAuthnRegistrationInput
+	if err := c.BindJSON(&input); err != nil {
+		newErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	res, err := h.services.WebAuthn.FinishRegistration(c.Request.Context(), usr.userID, input.SessionID, input.Name, input.Credential)
+	if err != nil {
+		switch {
+		case errors.Is(err, domain.ErrWebAuthnSessionNotFound), errors.Is(err, domain.ErrWebAuthnVerificationFailed):
+			newErrorResponse(c, http.StatusBadRequest, err.Error())
+		case errors.Is(err, domain.ErrWebAuthnCredentialExists):
+			newErrorResponse(c, http.StatusConflict, err.Error())
+		default:
+			newErrorResponse(c, http.StatusInternalServerError, err.Error())
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, newWebAuthnCredentialOutput(res))
+}
+
+// @Summary WebAuthn Begin Login
+// @Tags webauthn
+// @Description start signing in with a passkey
+// @ModuleID webAuthnBeginLogin
+// @Accept  json
+// @Produce  json
+// @Success 200 {object} webAuthnLoginResponse
+// @Failure 500 {object} errorResponse
+// @Failure default {object} errorResponse
+// @Router /auth/webauthn/login/begin [post]
+func (h *Handler) webAuthnBeginLogin(c *gin.Context) {
+	options, sessionID, err := h.services.WebAuthn.BeginLogin(c.Request.Context())
+	if err != nil {
+		newErrorResponse(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	c.JSON(http.StatusOK, webAuthnLoginResponse{
+		SessionID: sessionID,
+		Options:   options,
+	})
+}
+
+// @Summary WebAuthn Finish Login
+// @Tags webauthn
+// @Description verify the passkey assertion and sign in
+// @ModuleID webAuthnFinishLogin
+// @Accept  json
+// @Produce  json
+// @Param input body webAuthnLoginInput true "ceremony id and credential"
+// @Success 200 {object} tokenResponse
+// @Failure 400,401,403 {object} errorResponse
+// @Failure 500 {object} errorResponse
+// @Failure default {object} errorResponse
+// @Router /auth/webauthn/login/finish [post]
+func (h *Handler) webAuthnFinishLogin(c *gin.Context) {
+	var input webAuthnLoginInput
+	if err := c.BindJSON(&input); err != nil {
+		newErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	res, err := h.services.Authorization.SignInWebAuthn(c.Request.Context(), input.SessionID, input.Credential, clientInfo(c))
+	if err != nil {
+		if errors.Is(err, domain.ErrWebAuthnSessionNotFound) || errors.Is(err, domain.ErrWebAuthnVerificationFailed) {
+			newErrorResponse(c, http.StatusUnauthorized, err.Error())
+			return
+		}
+		if errors.Is(err, domain.ErrUserBanned) {
+			newErrorResponse(c, http.StatusForbidden, err.Error())
+			return
+		}
+		newErrorResponse(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	c.JSON(http.StatusOK, tokenResponse{
+		AccessToken:  res.AccessToken,
+		RefreshToken: res.RefreshToken,
+		ExpireIn:     int(res.ExpireIn.Seconds()),
+	})
+}
+
+// @Summary WebAuthn Credentials
+// @Tags webauthn
+// @Description list the user's passkeys
+// @ModuleID webAuthnGetCredentials
+// @Accept  json
+// @Produce  json
+// @Security ApiKeyAuth
+// @Success 200 {object} webAuthnCredentialsResponse
+// @Failure 401 {object} errorResponse
+// @Failure 500 {object} errorResponse
+// @Failure default {object} errorResponse
+// @Router /auth/webauthn/credentials [get]
+func (h *Handler) getWebAuthnCredentials(c *gin.Context) {
+	usr, err := getUserContext(c)
+	if err != nil {
+		newErrorResponse(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	res, err := h.services.WebAuthn.GetCredentials(c.Request.Context(), usr.userID)
+	if err != nil {
+		newErrorResponse(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	credentials := make([]webAuthnCredentialOutput, 0, len(res))
+	for _, credential := range res {
+		credentials = append(credentials, newWebAuthnCredentialOutput(credential))
+	}
+
+	c.JSON(http.StatusOK, webAuthnCredentialsResponse{Credentials: credentials})
+}
+
+// @Summary WebAuthn Delete Credential
+// @Tags webauthn
+// @Description remove a passkey from the account
+// @ModuleID webAuthnDeleteCredential
+// @Accept  json
+// @Produce  json
+// @Param id path int true "credential id"
+// @Security ApiKeyAuth
+// @Success 200 {object} statusResponse
+// @Failure 400,401,404 {object} errorResponse
+// @Failure 500 {object} errorResponse
+// @Failure default {object} errorResponse
+// @Router /auth/webauthn/credentials/{id} [delete]
+func (h *Handler) deleteWebAuthnCredential(c *gin.Context) {
+	usr, err := getUserContext(c)
+	if err != nil {
+		newErrorResponse(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid credential id")
+		return
+	}
+
+	if err := h.services.WebAuthn.DeleteCredential(c.Request.Context(), usr.userID, id); err != nil {
+		if errors.Is(err, domain.ErrWebAuthnCredentialNotFound) {
+			newErrorResponse(c, http.StatusNotFound, err.Error())
+			return
+		}
+		newErrorResponse(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	c.JSON(http.StatusOK, statusResponse{Status: "ok"})
+}
+
+func newWebAuthnCredentialOutput(credential domain.WebAuthnCredential) webAuthnCredentialOutput {
+	return webAuthnCredentialOutput{
+		ID:         credential.ID,
+		Name:       credential.Name,
+		Synced:     credential.BackupState,
+		CreatedAt:  credential.CreatedAt,
+		LastUsedAt: credential.LastUsedAt,
+	}
+}
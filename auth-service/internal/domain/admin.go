@@ -0,0 +1,18 @@
+package domain
+
+import "time"
+
+// UserFilter narrows down the user list of the admin panel. Zero values don't filter.
+type UserFilter struct {
+	Role      string
+	Confirmed *bool
+	Banned    *bool
+	// CreatedFrom and CreatedTo bound the sign-up time, inclusive and exclusive
+	CreatedFrom time.Time
+	CreatedTo   time.Time
+	// Search is matched against username, email and name by word prefixes
+	Search string
+	// AfterID continues the list, which is sorted by id descending, after the user with this id
+	AfterID int
+	Limit   int
+}
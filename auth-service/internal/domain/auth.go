@@ -18,6 +18,16 @@ type User struct {
 
 	IsConfirm bool `json,db:"is_confirm"`
 
+	// Locale is the language of emails to the user
+	Locale string `json,db:"locale"`
+
+	// BannedAt is zero unless the user is banned
+	BannedAt  time.Time `json,db:"banned_at"`
+	BanReason string    `json,db:"ban_reason"`
+
+	// StudentUntil is when the student status ends, zero if the user has no academic email
+	StudentUntil time.Time `json,db:"student_until"`
+
 	CreatedAt time.Time `json,db:"created_at"`
 	UpdateAt  time.Time `json,db:"update_at"`
 
@@ -5,4 +5,73 @@ import "errors"
 var (
 	ErrUserNotFound      = errors.New("user doesn't exists")
 	ErrUserAlreadyExists = errors.New("user with such email or username is already exists")
+	ErrInvalidPassword   = errors.New("invalid login or password")
+	ErrUserBanned        = errors.New("user is banned")
+
+	ErrRoleNotFound       = errors.New("role doesn't exists")
+	ErrRoleAlreadyExists  = errors.New("role with such name is already exists")
+	ErrRoleInUse          = errors.New("role is assigned to users")
+	ErrRoleProtected      = errors.New("role is built in and can't be changed this way")
+	ErrInvalidRoleName    = errors.New("role name must be 2 to 64 lowercase latin letters, digits, '_' or '-'")
+	ErrPermissionNotFound = errors.New("permission doesn't exists")
+	ErrCannotManageSelf   = errors.New("admins can't change the role of, ban or delete themselves")
+	ErrRoleAboveOwn       = errors.New("role grants permissions the admin doesn't have")
+	ErrChangeOwnRole      = errors.New("admins can't change the permissions of their own role")
+	ErrInvalidCursor      = errors.New("cursor is invalid")
+
+	ErrInvalidRefreshToken = errors.New("refresh token is invalid or expired")
+	ErrRefreshTokenReused  = errors.New("refresh token has already been used")
+
+	ErrSessionNotFound = errors.New("session doesn't exists")
+
+	ErrOrganizationNotFound      = errors.New("organization doesn't exists")
+	ErrOrganizationExists        = errors.New("organization with such domain is already verified")
+	ErrAlreadyOrganizationMember = errors.New("user is already a member of an organization")
+	ErrNotOrganizationMember     = errors.New("user is not a member of the organization")
+	ErrOrganizationForbidden     = errors.New("your role in the organization doesn't allow this")
+	ErrLastOrganizationOwner     = errors.New("organization must keep at least one owner")
+	ErrInvalidOrganizationRole   = errors.New("organization role must be owner, manager or viewer")
+	ErrInvitationNotFound        = errors.New("invitation is invalid, expired or already answered")
+	ErrInvitationEmailMismatch   = errors.New("invitation was sent to another email")
+
+	ErrApplicationNotFound  = errors.New("university application doesn't exists")
+	ErrApplicationPending   = errors.New("you already have an application under review")
+	ErrApplicationReviewed  = errors.New("university application has already been reviewed")
+	ErrAlreadyUniversity    = errors.New("user is already a university representative")
+	ErrApplicantRole        = errors.New("only users with the default role can apply")
+	ErrInvalidDocument      = errors.New("documents must be PDF, JPEG or PNG files within the size limit")
+	ErrTooManyDocuments     = errors.New("too many documents attached")
+	ErrDocumentsRequired    = errors.New("at least one supporting document is required")
+	ErrDocumentNotFound     = errors.New("document doesn't exists")
+	ErrReviewOwnApplication = errors.New("admins can't review their own application")
+
+	ErrAcademicDomainNotAllowed = errors.New("email domain is not in the list of university domains")
+	ErrAcademicDomainExists     = errors.New("university domain is already in the list")
+	ErrAcademicDomainNotFound   = errors.New("university domain doesn't exists")
+	ErrAcademicDomainTooBroad   = errors.New("university domain must have a name below the top-level domain")
+	ErrAcademicEmailUsed        = errors.New("academic email is already used by another user")
+	ErrAcademicEmailNotSet      = errors.New("academic email to confirm is not set")
+
+	ErrInvalidToken = errors.New("token is invalid or expired")
+
+	ErrConfirmationResendTooSoon = errors.New("confirmation email was sent recently, try again later")
+	ErrCodeResendTooSoon         = errors.New("code was texted recently, try again later")
+
+	ErrEmailNotFound = errors.New("email doesn't exists or isn't dead")
+
+	ErrInvalidVerificationCode = errors.New("verification code is invalid or expired")
+	ErrVerificationCodeLocked  = errors.New("too many wrong codes, request a new one")
+	ErrSignInLocked            = errors.New("too many wrong codes, sign in is locked for a while")
+
+	ErrPhoneAlreadyUsed = errors.New("phone number is already used by another user")
+	ErrPhoneNotSet      = errors.New("phone number to confirm is not set")
+
+	ErrTwoFactorNotEnabled     = errors.New("two-factor authentication is not enabled")
+	ErrTwoFactorAlreadyEnabled = errors.New("two-factor authentication is already enabled")
+	ErrInvalidTwoFactorCode    = errors.New("invalid two-factor code")
+
+	ErrWebAuthnSessionNotFound    = errors.New("webauthn ceremony doesn't exists or has expired")
+	ErrWebAuthnVerificationFailed = errors.New("webauthn verification failed")
+	ErrWebAuthnCredentialNotFound = errors.New("webauthn credential doesn't exists")
+	ErrWebAuthnCredentialExists   = errors.New("webauthn credential is already registered")
 )
@@ -0,0 +1,21 @@
+package domain
+
+import "time"
+
+const (
+	JobStatusOK     = "ok"
+	JobStatusFailed = "failed"
+)
+
+// JobRun is the last run of a scheduled job, shared by all replicas.
+type JobRun struct {
+	Name           string        `json,db:"name"`
+	LastStartedAt  time.Time     `json,db:"last_started_at"`
+	LastFinishedAt time.Time     `json,db:"last_finished_at"`
+	LastStatus     string        `json,db:"last_status"`
+	LastError      string        `json,db:"last_error"`
+	LastDuration   time.Duration `json,db:"last_duration_ms"`
+	NextRunAt      time.Time     `json,db:"next_run_at"`
+	// RunBy is the replica that ran the job last
+	RunBy string `json,db:"run_by"`
+}
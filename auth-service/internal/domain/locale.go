@@ -0,0 +1,21 @@
+package domain
+
+const (
+	LocaleRU = "ru"
+	LocaleEN = "en"
+
+	DefaultLocale = LocaleRU
+)
+
+// Locales are the languages emails and messages are available in.
+var Locales = []string{LocaleRU, LocaleEN}
+
+// NormalizeLocale returns locale if it is supported, otherwise DefaultLocale.
+func NormalizeLocale(locale string) string {
+	for _, l := range Locales {
+		if l == locale {
+			return l
+		}
+	}
+	return DefaultLocale
+}
@@ -0,0 +1,80 @@
+package domain
+
+import "time"
+
+// Roles of a member within an organization. Owners manage the organization and its members,
+// managers invite new members, viewers only see it.
+const (
+	OrganizationRoleOwner   = "owner"
+	OrganizationRoleManager = "manager"
+	OrganizationRoleViewer  = "viewer"
+)
+
+// Statuses of an organization invitation. Only a pending one can be answered.
+const (
+	InvitationStatusPending  = "pending"
+	InvitationStatusAccepted = "accepted"
+	InvitationStatusDeclined = "declined"
+	InvitationStatusRevoked  = "revoked"
+)
+
+// IsOrganizationRole reports whether role is one of the organization roles.
+func IsOrganizationRole(role string) bool {
+	switch role {
+	case OrganizationRoleOwner, OrganizationRoleManager, OrganizationRoleViewer:
+		return true
+	}
+	return false
+}
+
+// Organization is a university whose staff share an account in the service.
+// Domain is the domain of its email addresses, e.g. msu.ru.
+type Organization struct {
+	ID         int
+	Name       string
+	Domain     string
+	VerifiedAt time.Time
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+}
+
+func (o Organization) IsVerified() bool {
+	return !o.VerifiedAt.IsZero()
+}
+
+// OrganizationMember is a user in an organization. A user is a member of one organization at most.
+type OrganizationMember struct {
+	OrganizationID int
+	// OrganizationVerified is whether an admin has verified the organization
+	OrganizationVerified bool
+	UserID               int
+	Username             string
+	Email                string
+	Locale               string
+	Role                 string
+	CreatedAt            time.Time
+}
+
+func (m OrganizationMember) IsOwner() bool {
+	return m.Role == OrganizationRoleOwner
+}
+
+// CanInvite reports whether the member may invite others and see pending invitations.
+func (m OrganizationMember) CanInvite() bool {
+	return m.Role == OrganizationRoleOwner || m.Role == OrganizationRoleManager
+}
+
+// OrganizationInvitation invites whoever has Email to join the organization with Role.
+// Only the hash of its token is stored.
+type OrganizationInvitation struct {
+	ID               int
+	OrganizationID   int
+	OrganizationName string
+	Email            string
+	Role             string
+	TokenHash        string
+	InvitedBy        int
+	Status           string
+	ExpiresAt        time.Time
+	CreatedAt        time.Time
+}
@@ -0,0 +1,25 @@
+package domain
+
+import "time"
+
+const (
+	EmailStatusPending = "pending"
+	EmailStatusSent    = "sent"
+	// EmailStatusDead is an email that ran out of attempts. It is kept for inspection and can be retried by hand.
+	EmailStatusDead = "dead"
+)
+
+// OutboxEmail is an email waiting for or done with delivery.
+type OutboxEmail struct {
+	ID            int       `json,db:"id"`
+	To            []string  `json,db:"recipients"`
+	Subject       string    `json,db:"subject"`
+	Body          string    `json,db:"body"`
+	HTMLBody      string    `json,db:"html_body"`
+	Status        string    `json,db:"status"`
+	Attempts      int       `json,db:"attempts"`
+	LastError     string    `json,db:"last_error"`
+	NextAttemptAt time.Time `json,db:"next_attempt_at"`
+	CreatedAt     time.Time `json,db:"created_at"`
+	SentAt        time.Time `json,db:"sent_at"`
+}
@@ -0,0 +1,45 @@
+package domain
+
+import "time"
+
+// Permissions are named resource:action:scope, e.g. users:update:any lets a user update
+// any user while users:update:own would only let them update themselves.
+// Routes check them by name, new ones are added together with a migration inserting them.
+const (
+	PermissionUsersReadAny   = "users:read:any"
+	PermissionUsersUpdateAny = "users:update:any"
+	PermissionUsersBanAny    = "users:ban:any"
+	PermissionUsersDeleteAny = "users:delete:any"
+
+	PermissionRolesReadAny   = "roles:read:any"
+	PermissionRolesManageAny = "roles:manage:any"
+
+	PermissionJobsReadAny     = "jobs:read:any"
+	PermissionEmailsManageAny = "emails:manage:any"
+
+	PermissionUniversitiesReviewAny    = "universities:review:any"
+	PermissionAcademicDomainsManageAny = "academic_domains:manage:any"
+)
+
+// AdminRoleName is the role that holds every permission and can't be changed through the API,
+// so that admins can't lock themselves out.
+const AdminRoleName = "admin"
+
+// UserRoleName is the default role of registered users.
+const UserRoleName = "user"
+
+// Role is a named set of permissions. System roles come with migrations and can't be deleted.
+type Role struct {
+	ID          int
+	Name        string
+	Description string
+	IsSystem    bool
+	Permissions []string
+	CreatedAt   time.Time
+}
+
+type Permission struct {
+	ID          int
+	Name        string
+	Description string
+}
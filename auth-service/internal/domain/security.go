@@ -0,0 +1,15 @@
+package domain
+
+import "time"
+
+const (
+	SecurityEventRefreshTokenReuse = "refresh_token_reuse"
+)
+
+type SecurityEvent struct {
+	ID          int       `json,db:"id"`
+	UserID      int       `json,db:"user_id"`
+	Type        string    `json,db:"event_type"`
+	Description string    `json,db:"description"`
+	CreatedAt   time.Time `json,db:"created_at"`
+}
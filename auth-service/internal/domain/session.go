@@ -0,0 +1,19 @@
+package domain
+
+import "time"
+
+// Session is a signed-in device. It lives as long as its refresh token family.
+type Session struct {
+	ID       int    `json,db:"id"`
+	UserID   int    `json,db:"user_id"`
+	FamilyID string `json,db:"family_id"`
+
+	IP        string `json,db:"ip"`
+	UserAgent string `json,db:"user_agent"`
+	Browser   string `json,db:"browser"`
+	OS        string `json,db:"os"`
+	Device    string `json,db:"device"`
+
+	CreatedAt  time.Time `json,db:"created_at"`
+	LastUsedAt time.Time `json,db:"last_used_at"`
+}
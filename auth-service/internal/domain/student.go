@@ -0,0 +1,28 @@
+package domain
+
+import "time"
+
+// AcademicDomain is an email domain of a university. A confirmed address on the domain
+// or on its subdomains, e.g. student.msu.ru for msu.ru, makes the user a student.
+type AcademicDomain struct {
+	ID             int
+	Domain         string
+	UniversityName string
+	CreatedAt      time.Time
+}
+
+// StudentStatus is the academic email of a user. The user is a student until StudentUntil,
+// after that the academic email has to be confirmed again.
+type StudentStatus struct {
+	UserID               int
+	Username             string
+	Locale               string
+	AcademicEmail        string
+	PendingAcademicEmail string
+	StudentUntil         time.Time
+}
+
+// IsStudent reports whether the student status is in effect at t.
+func (s StudentStatus) IsStudent(t time.Time) bool {
+	return s.AcademicEmail != "" && s.StudentUntil.After(t)
+}
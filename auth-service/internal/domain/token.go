@@ -1,8 +1,34 @@
 package domain
 
+// Token types of USER_TOKENS, see TOKEN_TYPES.
+const (
+	TokenTypeEmailVerify         = 1
+	TokenTypePasswordReset       = 2
+	TokenTypeTwoFactorChallenge  = 3
+	TokenTypeMagicLink           = 4
+	TokenTypeEmailVerifyCode     = 5
+	TokenTypePasswordResetCode   = 6
+	TokenTypePhoneVerifyCode     = 7
+	TokenTypePhoneSignInCode     = 8
+	TokenTypeAcademicEmailVerify = 9
+	TokenTypeTwoFactorEnrolment  = 10
+	TokenTypeTwoFactorFailure    = 11
+	TokenTypePhoneSignInFailure  = 12
+)
+
+// UserCode is a short numeric code sent to a user, e.g. to confirm the email from a mobile app.
+type UserCode struct {
+	UserID    int    `json:"user_id"`
+	TokenHash string `json:"token_hash"`
+	Attempts  int    `json:"attempts"`
+}
+
+// RefreshToken is a stored refresh token. Only the hash of the token is kept.
 type RefreshToken struct {
-	RefreshToken string `json:"refresh_token"`
-	ExpiresAt    int64  `json:"expires_at"`
+	TokenHash string `json:"token_hash"`
+	ExpiresAt int64  `json:"expires_at"`
+	// FamilyID links all tokens issued by rotation from the same sign-in.
+	FamilyID string `json:"family_id"`
 }
 
 type Token struct {
@@ -0,0 +1,13 @@
+package domain
+
+import "time"
+
+// TOTP is a user's time-based one-time password enrolment.
+// Secret is stored encrypted.
+type TOTP struct {
+	UserID       int       `json,db:"user_id"`
+	Secret       string    `json,db:"secret"`
+	Enabled      bool      `json,db:"enabled"`
+	LastUsedStep int64     `json,db:"last_used_step"`
+	CreatedAt    time.Time `json,db:"created_at"`
+}
@@ -0,0 +1,48 @@
+package domain
+
+import "time"
+
+// UniversityRoleName is the role of university representatives, given by approving their application.
+const UniversityRoleName = "university"
+
+// Statuses of a university application. Only a pending one can be reviewed.
+const (
+	ApplicationStatusPending  = "pending"
+	ApplicationStatusApproved = "approved"
+	ApplicationStatusRejected = "rejected"
+)
+
+// UniversityApplication is a request of a user to become a representative of a university.
+// OrganizationID is the organization the applicant owned when applying, it gets verified on approval.
+type UniversityApplication struct {
+	ID             int
+	UserID         int
+	Username       string
+	Email          string
+	Locale         string
+	OrganizationID int
+	UniversityName string
+	Position       string
+	Message        string
+	Status         string
+	ReviewerID     int
+	ReviewComment  string
+	ReviewedAt     time.Time
+	CreatedAt      time.Time
+	Documents      []ApplicationDocument
+}
+
+func (a UniversityApplication) IsPending() bool {
+	return a.Status == ApplicationStatusPending
+}
+
+// ApplicationDocument describes a file supporting an application, the file itself is in blob storage under StorageKey.
+type ApplicationDocument struct {
+	ID            int
+	ApplicationID int
+	FileName      string
+	ContentType   string
+	Size          int64
+	StorageKey    string
+	CreatedAt     time.Time
+}
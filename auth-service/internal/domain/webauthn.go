@@ -0,0 +1,37 @@
+package domain
+
+import "time"
+
+// WebAuthn ceremonies a WebAuthnSession can belong to.
+const (
+	WebAuthnCeremonyRegistration = "registration"
+	WebAuthnCeremonyLogin        = "login"
+)
+
+// WebAuthnCredential is a passkey or security key registered by a user.
+type WebAuthnCredential struct {
+	ID              int      `json,db:"id"`
+	UserID          int      `json,db:"user_id"`
+	CredentialID    []byte   `json,db:"credential_id"`
+	PublicKey       []byte   `json,db:"public_key"`
+	AttestationType string   `json,db:"attestation_type"`
+	AAGUID          []byte   `json,db:"aaguid"`
+	SignCount       uint32   `json,db:"sign_count"`
+	Transports      []string `json,db:"transports"`
+	BackupEligible  bool     `json,db:"backup_eligible"`
+	BackupState     bool     `json,db:"backup_state"`
+	Name            string   `json,db:"name"`
+
+	CreatedAt  time.Time `json,db:"created_at"`
+	LastUsedAt time.Time `json,db:"last_used_at"`
+}
+
+// WebAuthnSession is the server side state of a ceremony between its begin and finish requests.
+// UserID is 0 for a passkey login, where the user is only known once the assertion arrives.
+type WebAuthnSession struct {
+	SessionHash string `json,db:"session_hash"`
+	Ceremony    string `json,db:"ceremony"`
+	UserID      int    `json,db:"user_id"`
+	Data        []byte `json,db:"data"`
+	ExpiresAt   int64  `json,db:"expire_at"`
+}
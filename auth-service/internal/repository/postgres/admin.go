@@ -0,0 +1,249 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/shamank/edutour-backend/auth-service/internal/domain"
+	"github.com/shamank/edutour-backend/auth-service/pkg/logger/sl"
+	"log/slog"
+	"strings"
+	"unicode"
+)
+
+const adminUserColumns = `u.id, u.username, u.email, COALESCE(u.phone, ''), COALESCE(u.avatar, ''),
+				COALESCE(u.first_name, ''), COALESCE(u.last_name, ''), COALESCE(u.middle_name, ''),
+				COALESCE(u.is_confirm, false), u.locale, u.banned_at, u.ban_reason,
+				u.created_at, u.updated_at, r.id, r.name`
+
+type AdminRepo struct {
+	db     *sql.DB
+	logger *slog.Logger
+}
+
+func NewAdminRepo(db *sql.DB, logger *slog.Logger) *AdminRepo {
+	return &AdminRepo{
+		db:     db,
+		logger: logger,
+	}
+}
+
+// GetUsers returns up to filter.Limit users matching the filter, newest first.
+func (r *AdminRepo) GetUsers(ctx context.Context, filter domain.UserFilter) ([]domain.User, error) {
+	const op = "Repository.Postgres.AdminRepo.GetUsers"
+	logger := r.logger.With(slog.String("op", op))
+
+	conditions := make([]string, 0)
+	args := make([]interface{}, 0)
+	argID := 1
+
+	if filter.Role != "" {
+		conditions = append(conditions, fmt.Sprintf("r.name = $%d", argID))
+		args = append(args, filter.Role)
+		argID++
+	}
+
+	if filter.Confirmed != nil {
+		conditions = append(conditions, fmt.Sprintf("COALESCE(u.is_confirm, false) = $%d", argID))
+		args = append(args, *filter.Confirmed)
+		argID++
+	}
+
+	if filter.Banned != nil {
+		conditions = append(conditions, fmt.Sprintf("(u.banned_at IS NOT NULL) = $%d", argID))
+		args = append(args, *filter.Banned)
+		argID++
+	}
+
+	if !filter.CreatedFrom.IsZero() {
+		conditions = append(conditions, fmt.Sprintf("u.created_at >= to_timestamp($%d)", argID))
+		args = append(args, filter.CreatedFrom.Unix())
+		argID++
+	}
+
+	if !filter.CreatedTo.IsZero() {
+		conditions = append(conditions, fmt.Sprintf("u.created_at < to_timestamp($%d)", argID))
+		args = append(args, filter.CreatedTo.Unix())
+		argID++
+	}
+
+	if search := prefixTSQuery(filter.Search); search != "" {
+		conditions = append(conditions, fmt.Sprintf("u.search_vector @@ to_tsquery('simple', $%d)", argID))
+		args = append(args, search)
+		argID++
+	}
+
+	if filter.AfterID > 0 {
+		conditions = append(conditions, fmt.Sprintf("u.id < $%d", argID))
+		args = append(args, filter.AfterID)
+		argID++
+	}
+
+	where := ""
+	if len(conditions) > 0 {
+		where = "WHERE " + strings.Join(conditions, " AND ")
+	}
+
+	query := fmt.Sprintf(`SELECT %s
+				FROM USERS u
+				INNER JOIN ROLES r on r.id = u.role_id
+				%s
+				ORDER BY u.id DESC
+				LIMIT $%d`, adminUserColumns, where, argID)
+
+	args = append(args, filter.Limit)
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		logger.Error("error occurred when select from users", sl.Err(err))
+		return nil, err
+	}
+	defer rows.Close()
+
+	users := make([]domain.User, 0)
+
+	for rows.Next() {
+		u, err := scanAdminUser(rows)
+		if err != nil {
+			logger.Error("error occurred when scan users", sl.Err(err))
+			return nil, err
+		}
+		users = append(users, u)
+	}
+
+	return users, rows.Err()
+}
+
+func (r *AdminRepo) GetUser(ctx context.Context, userID int) (domain.User, error) {
+	const op = "Repository.Postgres.AdminRepo.GetUser"
+	logger := r.logger.With(slog.String("op", op))
+
+	query := fmt.Sprintf(`SELECT %s
+				FROM USERS u
+				INNER JOIN ROLES r on r.id = u.role_id
+				WHERE u.id = $1`, adminUserColumns)
+
+	u, err := scanAdminUser(r.db.QueryRowContext(ctx, query, userID))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return domain.User{}, domain.ErrUserNotFound
+		}
+		logger.Error("error occurred when select from users", sl.Err(err))
+		return domain.User{}, err
+	}
+
+	return u, nil
+}
+
+func (r *AdminRepo) SetUserRole(ctx context.Context, userID int, role string) error {
+	const op = "Repository.Postgres.AdminRepo.SetUserRole"
+	logger := r.logger.With(slog.String("op", op))
+
+	var roleID int
+
+	if err := r.db.QueryRowContext(ctx, `SELECT id FROM ROLES WHERE name = $1`, role).Scan(&roleID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return domain.ErrRoleNotFound
+		}
+		logger.Error("error occurred when select from roles", sl.Err(err))
+		return err
+	}
+
+	return r.updateUser(ctx, logger, `UPDATE USERS SET role_id = $2 WHERE id = $1`, userID, roleID)
+}
+
+func (r *AdminRepo) ConfirmUser(ctx context.Context, userID int) error {
+	const op = "Repository.Postgres.AdminRepo.ConfirmUser"
+	logger := r.logger.With(slog.String("op", op))
+
+	return r.updateUser(ctx, logger, `UPDATE USERS SET is_confirm = true WHERE id = $1`, userID)
+}
+
+// BanUser bans the user. Banning a banned user again only updates the reason.
+func (r *AdminRepo) BanUser(ctx context.Context, userID int, reason string) error {
+	const op = "Repository.Postgres.AdminRepo.BanUser"
+	logger := r.logger.With(slog.String("op", op))
+
+	query := `UPDATE USERS
+				SET banned_at = COALESCE(banned_at, CURRENT_TIMESTAMP), ban_reason = $2
+				WHERE id = $1`
+
+	return r.updateUser(ctx, logger, query, userID, reason)
+}
+
+func (r *AdminRepo) UnbanUser(ctx context.Context, userID int) error {
+	const op = "Repository.Postgres.AdminRepo.UnbanUser"
+	logger := r.logger.With(slog.String("op", op))
+
+	return r.updateUser(ctx, logger, `UPDATE USERS SET banned_at = NULL, ban_reason = '' WHERE id = $1`, userID)
+}
+
+// DeleteUser deletes the user together with everything that references it.
+func (r *AdminRepo) DeleteUser(ctx context.Context, userID int) error {
+	const op = "Repository.Postgres.AdminRepo.DeleteUser"
+	logger := r.logger.With(slog.String("op", op))
+
+	return r.updateUser(ctx, logger, `DELETE FROM USERS WHERE id = $1`, userID)
+}
+
+// updateUser runs a statement on the user with the id passed as $1 and reports ErrUserNotFound if there is none.
+func (r *AdminRepo) updateUser(ctx context.Context, logger *slog.Logger, query string, userID int, args ...interface{}) error {
+	res, err := r.db.ExecContext(ctx, query, append([]interface{}{userID}, args...)...)
+	if err != nil {
+		logger.Error("error occurred when update users", sl.Err(err))
+		return err
+	}
+
+	rowCount, err := res.RowsAffected()
+	if err != nil {
+		logger.Error("error occurred when get RowsAffected", sl.Err(err))
+		return err
+	}
+
+	if rowCount == 0 {
+		return domain.ErrUserNotFound
+	}
+
+	return nil
+}
+
+func scanAdminUser(row interface{ Scan(dest ...any) error }) (domain.User, error) {
+	var u domain.User
+	var bannedAt sql.NullTime
+
+	err := row.Scan(&u.ID,
+		&u.Username,
+		&u.Email,
+		&u.Phone,
+		&u.Avatar,
+		&u.FirstName,
+		&u.LastName,
+		&u.MiddleName,
+		&u.IsConfirm,
+		&u.Locale,
+		&bannedAt,
+		&u.BanReason,
+		&u.CreatedAt,
+		&u.UpdateAt,
+		&u.Role.ID,
+		&u.Role.Name)
+
+	u.BannedAt = bannedAt.Time
+
+	return u, err
+}
+
+// prefixTSQuery turns a search string into a tsquery matching all of its words as prefixes,
+// e.g. "ivan petr" becomes "ivan:* & petr:*". Characters with a meaning in tsquery are dropped.
+func prefixTSQuery(search string) string {
+	words := strings.FieldsFunc(strings.ToLower(search), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '@' && r != '.' && r != '_'
+	})
+
+	for i, word := range words {
+		words[i] = word + ":*"
+	}
+
+	return strings.Join(words, " & ")
+}
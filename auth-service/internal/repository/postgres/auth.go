@@ -5,14 +5,11 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
+	"github.com/lib/pq"
 	"github.com/shamank/edutour-backend/auth-service/internal/domain"
 	"github.com/shamank/edutour-backend/auth-service/pkg/logger/sl"
 	"log/slog"
-)
-
-const (
-	tokenTypeEmail    = 1
-	tokenTypePassword = 2
+	"time"
 )
 
 type AuthRepo struct {
@@ -24,60 +21,60 @@ func NewAuthRepo(db *sql.DB, logger *slog.Logger) *AuthRepo {
 	return &AuthRepo{db: db, logger: logger}
 }
 
-func (r *AuthRepo) Create(ctx context.Context, user domain.User, confirmToken string, expireAt int64) error {
+func (r *AuthRepo) Create(ctx context.Context, user domain.User, confirmTokenHash string, expireAt int64) (int, error) {
 	const op = "Repository.Postgres.AuthRepo.Create"
 
 	logger := r.logger.With(slog.String("op", op))
 
-	insertUserQuery := `INSERT INTO USERS (username, email, password_hash) 
-			VALUES ($1, $2, $3) RETURNING id`
+	insertUserQuery := `INSERT INTO USERS (username, email, password_hash, pending_phone, locale) 
+			VALUES ($1, $2, $3, NULLIF($4, ''), $5) RETURNING id`
 
-	tx, err := r.db.Begin()
+	tx, err := begin(ctx, r.db)
 	if err != nil {
 		logger.Error("fail create r.db.Begin()!", sl.Err(err))
-		return err
+		return 0, err
 	}
 
 	var id int
-	row := tx.QueryRow(insertUserQuery, user.Username, user.Email, user.PasswordHash)
+	row := tx.QueryRow(insertUserQuery, user.Username, user.Email, user.PasswordHash, user.Phone, user.Locale)
 	if err := row.Scan(&id); err != nil {
 
 		logger.Error("error occurred when insert new user", sl.Err(err))
 
 		tx.Rollback()
-		return err
+		return 0, err
 	}
 
-	insertUserTokensQuery := `INSERT INTO USER_TOKENS (user_id, token_type, token_value, expire_at)
+	insertUserTokensQuery := `INSERT INTO USER_TOKENS (user_id, token_type, token_hash, expire_at)
 				VALUES ($1, $2, $3, to_timestamp($4))`
 
-	_, err = tx.Exec(insertUserTokensQuery, id, tokenTypeEmail, confirmToken, expireAt)
+	_, err = tx.Exec(insertUserTokensQuery, id, domain.TokenTypeEmailVerify, confirmTokenHash, expireAt)
 	if err != nil {
 
 		logger.Error("error occurred when insert new user_token", sl.Err(err))
 
 		tx.Rollback()
-		return err
+		return 0, err
 	}
 
 	//logger.Debug("created new user:")
 
-	return tx.Commit()
+	return id, tx.Commit()
 }
 
-func (r *AuthRepo) ConfirmUser(ctx context.Context, confirmToken string) error {
+func (r *AuthRepo) ConfirmUser(ctx context.Context, tokenType int, confirmTokenHash string) error {
 	const op = "Repository.Postgres.AuthRepo.ConfirmUser"
 	logger := r.logger.With(slog.String("op", op))
 
 	//query1 := `UPDATE USER_TOKENS
 	//			SET black_list = True
-	//			WHERE token_type = $1 AND token_value = $2 AND black_list = FALSE AND expire_at > CURRENT_TIMESTAMP
+	//			WHERE token_type = $1 AND token_hash = $2 AND black_list = FALSE AND expire_at > CURRENT_TIMESTAMP
 	//			RETURNING user_id`
 
-	// TODO: сделать так, чтобы при expire_at удалялся аккаунт и не проходила проверка...
+	// неподтвержденные аккаунты удаляет задача purge-unconfirmed-accounts
 	query1 := `UPDATE USER_TOKENS
 				SET black_list = True
-				WHERE token_type = $1 AND token_value = $2 AND black_list = FALSE
+				WHERE token_type = $1 AND token_hash = $2 AND black_list = FALSE AND expire_at > CURRENT_TIMESTAMP
 				RETURNING user_id`
 
 	query2 := `UPDATE USERS
@@ -92,11 +89,14 @@ func (r *AuthRepo) ConfirmUser(ctx context.Context, confirmToken string) error {
 
 	var Id int
 
-	row := tx.QueryRow(query1, tokenTypeEmail, confirmToken)
+	row := tx.QueryRow(query1, tokenType, confirmTokenHash)
 	if err := row.Scan(&Id); err != nil {
-		logger.Error("error occurred when inserting in user_tokens", sl.Err(err))
-
 		tx.Rollback()
+
+		if errors.Is(err, sql.ErrNoRows) {
+			return domain.ErrInvalidToken
+		}
+		logger.Error("error occurred when inserting in user_tokens", sl.Err(err))
 		return err
 	}
 
@@ -115,14 +115,14 @@ func (r *AuthRepo) ConfirmUser(ctx context.Context, confirmToken string) error {
 	return err
 }
 
-func (r *AuthRepo) SetTokenResetPassword(ctx context.Context, email string, token string, expireAt int64) error {
+func (r *AuthRepo) SetTokenResetPassword(ctx context.Context, email string, tokenHash string, expireAt int64) (int, error) {
 	const op = "Repository.Postgres.AuthRepo.SetTokenResetPassword"
 	logger := r.logger.With(slog.String("op", op))
 
-	tx, err := r.db.Begin()
+	tx, err := begin(ctx, r.db)
 	if err != nil {
 		logger.Error("fail create r.db.Begin()!", sl.Err(err))
-		return err
+		return 0, err
 	}
 	query1 := `SELECT u.ID from USERS u where email = $1 AND is_confirm = TRUE`
 
@@ -130,52 +130,53 @@ func (r *AuthRepo) SetTokenResetPassword(ctx context.Context, email string, toke
 
 	row := tx.QueryRow(query1, email)
 	if err := row.Scan(&userID); err != nil {
-		logger.Error("error occurred when select user", sl.Err(err))
-
 		tx.Rollback()
-		return err
+
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, domain.ErrUserNotFound
+		}
+		logger.Error("error occurred when select user", sl.Err(err))
+		return 0, err
 	}
 
-	query2 := `INSERT INTO user_tokens(user_id, token_type, token_value, expire_at)
+	query2 := `INSERT INTO user_tokens(user_id, token_type, token_hash, expire_at)
 				VALUES($1, $2, $3, to_timestamp($4))`
 
-	_, err = tx.Exec(query2, userID, tokenTypePassword, token, expireAt)
+	_, err = tx.Exec(query2, userID, domain.TokenTypePasswordReset, tokenHash, expireAt)
 	if err != nil {
 		logger.Error("error occurred when insert into user_tokens", sl.Err(err))
 
 		tx.Rollback()
-		return err
+		return 0, err
 	}
 
-	return tx.Commit()
+	return userID, tx.Commit()
 }
 
-func (r *AuthRepo) ConfirmResetPassword(ctx context.Context, token string, passwordHash string) error {
+func (r *AuthRepo) ConfirmResetPassword(ctx context.Context, tokenType int, tokenHash string, passwordHash string) (int, error) {
 	const op = "Repository.Postgres.AuthRepo.ConfirmResetPassword"
 	logger := r.logger.With(slog.String("op", op))
 
 	tx, err := r.db.Begin()
 	if err != nil {
 		logger.Error("fail create r.db.Begin()!", sl.Err(err))
-		return err
+		return 0, err
 	}
 
-	// TODO: при смене пароля сбрасывать все сессии
-
 	//query1 := `SELECT user_id FROM user_tokens
-	//			WHERE token_type = $1 AND token_value = $2 AND expire_at < CURRENT_TIMESTAMP`
+	//			WHERE token_type = $1 AND token_hash = $2 AND expire_at < CURRENT_TIMESTAMP`
 
 	query1 := `SELECT user_id FROM user_tokens
-				WHERE token_type = $1 AND token_value = $2 AND expire_at > CURRENT_TIMESTAMP`
+				WHERE token_type = $1 AND token_hash = $2 AND expire_at > CURRENT_TIMESTAMP AND black_list = FALSE`
 
 	var userID int
 
-	row := tx.QueryRow(query1, tokenTypePassword, token)
+	row := tx.QueryRow(query1, tokenType, tokenHash)
 	if err := row.Scan(&userID); err != nil {
 		logger.Error("error occurred when select from user_tokens", sl.Err(err))
 
 		tx.Rollback()
-		return err
+		return 0, err
 	}
 
 	query2 := `UPDATE USERS
@@ -184,36 +185,38 @@ func (r *AuthRepo) ConfirmResetPassword(ctx context.Context, token string, passw
 	if err != nil {
 		logger.Error("error occurred when update users", sl.Err(err))
 		tx.Rollback()
-		return err
+		return 0, err
 	}
 
 	query3 := `UPDATE USER_TOKENS
-				SET black_list = True WHERE token_type = $1 AND token_value = $2`
-	_, err = tx.Exec(query3, tokenTypePassword, token)
+				SET black_list = True WHERE token_type = $1 AND token_hash = $2`
+	_, err = tx.Exec(query3, tokenType, tokenHash)
 	if err != nil {
 		logger.Error("error occurred when update user_tokens", sl.Err(err))
 		tx.Rollback()
-		return err
+		return 0, err
 	}
 
-	tx.Commit()
-	return nil
+	return userID, tx.Commit()
 }
 
-func (r *AuthRepo) GetByCredentials(ctx context.Context, email string, passwordHash string) (domain.User, error) {
+func (r *AuthRepo) GetByCredentials(ctx context.Context, email string) (domain.User, error) {
 	const op = "Repository.Postgres.AuthRepo.GetByCredentials"
 	logger := r.logger.With(slog.String("op", op))
 
 	var user domain.User
 
-	query := `SELECT u.id, u.username, u.email, u.role_id, r.name
+	query := `SELECT u.id, u.username, u.email, u.password_hash, COALESCE(u.is_confirm, false), u.locale, u.role_id, r.name
 				FROM USERS u
-				INNER JOIN ROLE_TYPES r on u.role_id = r.id
-				WHERE u.email = $1 AND u.password_hash = $2`
+				INNER JOIN ROLES r on u.role_id = r.id
+				WHERE u.email = $1`
 
-	err := r.db.QueryRow(query, email, passwordHash).Scan(&user.ID,
+	err := r.db.QueryRow(query, email).Scan(&user.ID,
 		&user.Username,
 		&user.Email,
+		&user.PasswordHash,
+		&user.IsConfirm,
+		&user.Locale,
 		&user.Role.ID,
 		&user.Role.Name)
 
@@ -221,29 +224,28 @@ func (r *AuthRepo) GetByCredentials(ctx context.Context, email string, passwordH
 		if errors.Is(err, sql.ErrNoRows) {
 			return domain.User{}, domain.ErrUserNotFound
 		}
-
-		logger.Error(fmt.Sprintf("error occurred when select from users: %s, %s",
-			email, passwordHash),
-			sl.Err(err))
+		logger.Error("error occurred when select from users", sl.Err(err))
+		return domain.User{}, err
 	}
 
-	return user, err
+	return user, nil
 }
 
-func (r *AuthRepo) GetByUsername(ctx context.Context, username string, passwordHash string) (domain.User, error) {
+func (r *AuthRepo) GetByUsername(ctx context.Context, username string) (domain.User, error) {
 	const op = "Repository.Postgres.AuthRepo.GetByUsername"
 	logger := r.logger.With(slog.String("op", op))
 
 	var user domain.User
 
-	query := `SELECT u.id, u.username, u.email, u.role_id, r.name
+	query := `SELECT u.id, u.username, u.email, u.password_hash, u.role_id, r.name
 				FROM USERS u
-				INNER JOIN ROLE_TYPES r on u.role_id = r.id
-				WHERE u.username = $1 AND u.password_hash = $2`
+				INNER JOIN ROLES r on u.role_id = r.id
+				WHERE u.username = $1`
 
-	err := r.db.QueryRow(query, username, passwordHash).Scan(&user.ID,
+	err := r.db.QueryRow(query, username).Scan(&user.ID,
 		&user.Username,
 		&user.Email,
+		&user.PasswordHash,
 		&user.Role.ID,
 		&user.Role.Name)
 
@@ -258,60 +260,357 @@ func (r *AuthRepo) GetByUsername(ctx context.Context, username string, passwordH
 	return user, nil
 }
 
-func (r *AuthRepo) GetByRefreshToken(ctx context.Context, refreshToken string) (domain.User, error) {
-	const op = "Repository.Postgres.AuthRepo.GetByRefreshToken"
+func (r *AuthRepo) UpdatePasswordHash(ctx context.Context, userID int, passwordHash string) error {
+	const op = "Repository.Postgres.AuthRepo.UpdatePasswordHash"
 	logger := r.logger.With(slog.String("op", op))
 
-	var user domain.User
+	query := `UPDATE USERS
+				SET password_hash = $1 WHERE id = $2`
+
+	_, err := r.db.Exec(query, passwordHash, userID)
+	if err != nil {
+		logger.Error("error occurred when update users", sl.Err(err))
+		return err
+	}
+
+	return nil
+}
+
+// RotateRefreshToken exchanges the token with refreshTokenHash for newToken in the same family.
+// A token that was already rotated more than gracePeriod ago is treated as stolen:
+// the whole family is revoked, a security event is recorded and ErrRefreshTokenReused is returned.
+// The session of the family is touched with the IP of the client.
+func (r *AuthRepo) RotateRefreshToken(ctx context.Context, refreshTokenHash string, newToken domain.RefreshToken, gracePeriod time.Duration, client domain.Session) (domain.User, string, error) {
+	const op = "Repository.Postgres.AuthRepo.RotateRefreshToken"
+	logger := r.logger.With(slog.String("op", op))
+
+	tx, err := r.db.Begin()
+	if err != nil {
+		logger.Error("fail create r.db.Begin()!", sl.Err(err))
+		return domain.User{}, "", err
+	}
+
 	var tokenID int
-	query := `SELECT u.id, u.username, u.email, u.role_id, r.name, t.id
-				FROM USERS u
-				INNER JOIN ROLE_TYPES r on r.id = u.role_id
-				INNER JOIN REFRESH_TOKENS t on t.user_id = u.id
-				WHERE t.refresh_token = $1 AND t.expire_at > CURRENT_TIMESTAMP AND NOT t.black_list`
+	var familyID string
+	var revoked, rotated, inGrace bool
+	var user domain.User
 
-	err := r.db.QueryRow(query, refreshToken).Scan(&user.ID,
+	query1 := `SELECT t.id, t.family_id, t.black_list, t.rotated_at IS NOT NULL,
+       			COALESCE(t.rotated_at > CURRENT_TIMESTAMP - make_interval(secs => $2), false),
+       			u.id, u.username, u.email, u.role_id, r.name
+				FROM REFRESH_TOKENS t
+				INNER JOIN USERS u on u.id = t.user_id
+				INNER JOIN ROLES r on r.id = u.role_id
+				WHERE t.token_hash = $1 AND t.expire_at > CURRENT_TIMESTAMP
+				FOR UPDATE OF t`
+
+	err = tx.QueryRow(query1, refreshTokenHash, gracePeriod.Seconds()).Scan(&tokenID,
+		&familyID,
+		&revoked,
+		&rotated,
+		&inGrace,
+		&user.ID,
 		&user.Username,
 		&user.Email,
 		&user.Role.ID,
-		&user.Role.Name,
-		&tokenID)
+		&user.Role.Name)
 	if err != nil {
-		logger.Error("error occurred when select from users", sl.Err(err))
-		return domain.User{}, err
+		tx.Rollback()
+		if errors.Is(err, sql.ErrNoRows) {
+			return domain.User{}, "", domain.ErrInvalidRefreshToken
+		}
+		logger.Error("error occurred when select from refresh_tokens", sl.Err(err))
+		return domain.User{}, "", err
 	}
 
-	query2 := `UPDATE REFRESH_TOKENS
-				SET black_list = true
+	if revoked {
+		tx.Rollback()
+		return domain.User{}, "", domain.ErrInvalidRefreshToken
+	}
+
+	if rotated {
+		if !inGrace {
+			if err := r.revokeFamily(tx, user.ID, familyID); err != nil {
+				logger.Error("error occurred when revoking token family", sl.Err(err))
+				tx.Rollback()
+				return domain.User{}, "", err
+			}
+			if err := tx.Commit(); err != nil {
+				return domain.User{}, "", err
+			}
+
+			logger.Warn("refresh token reuse detected",
+				slog.Int("user_id", user.ID),
+				slog.String("family_id", familyID))
+
+			return domain.User{}, "", domain.ErrRefreshTokenReused
+		}
+	} else {
+		query2 := `UPDATE REFRESH_TOKENS
+				SET rotated_at = CURRENT_TIMESTAMP
 				WHERE id = $1`
-	_, err = r.db.Exec(query2, tokenID)
+		if _, err := tx.Exec(query2, tokenID); err != nil {
+			logger.Error("error occurred when update refresh_tokens", sl.Err(err))
+			tx.Rollback()
+			return domain.User{}, "", err
+		}
+	}
+
+	query3 := `INSERT INTO REFRESH_TOKENS (user_id, token_hash, expire_at, family_id, parent_id)
+				VALUES ($1, $2, to_timestamp($3), $4, $5)`
+
+	_, err = tx.Exec(query3, user.ID, newToken.TokenHash, newToken.ExpiresAt, familyID, tokenID)
+	if err != nil {
+		logger.Error("error occurred when insert into refresh_tokens", sl.Err(err))
+		tx.Rollback()
+		return domain.User{}, "", err
+	}
+
+	query4 := `UPDATE SESSIONS
+				SET last_used_at = CURRENT_TIMESTAMP, ip = $2
+				WHERE family_id = $1`
+
+	_, err = tx.Exec(query4, familyID, client.IP)
+	if err != nil {
+		logger.Error("error occurred when update sessions", sl.Err(err))
+		tx.Rollback()
+		return domain.User{}, "", err
+	}
+
+	return user, familyID, tx.Commit()
+}
+
+func (r *AuthRepo) revokeFamily(tx *sql.Tx, userID int, familyID string) error {
+	query1 := `UPDATE REFRESH_TOKENS
+				SET black_list = true
+				WHERE family_id = $1`
+	if _, err := tx.Exec(query1, familyID); err != nil {
+		return err
+	}
+
+	query2 := `INSERT INTO SECURITY_EVENTS (user_id, event_type, description)
+				VALUES ($1, $2, $3)`
+	_, err := tx.Exec(query2, userID, domain.SecurityEventRefreshTokenReuse,
+		fmt.Sprintf("rotated refresh token presented again, family %s revoked", familyID))
+
+	return err
+}
+
+// SetRefreshToken starts a new session with the first token of its family.
+func (r *AuthRepo) SetRefreshToken(ctx context.Context, userID int, refreshInput domain.RefreshToken, session domain.Session) error {
+	const op = "Repository.Postgres.AuthRepo.SetRefreshToken"
+	logger := r.logger.With(slog.String("op", op))
+
+	tx, err := r.db.Begin()
+	if err != nil {
+		logger.Error("fail create r.db.Begin()!", sl.Err(err))
+		return err
+	}
+
+	query1 := `INSERT INTO SESSIONS (user_id, family_id, ip, user_agent, browser, os, device)
+				VALUES ($1, $2, $3, $4, $5, $6, $7)`
+
+	_, err = tx.Exec(query1, userID, refreshInput.FamilyID,
+		session.IP, session.UserAgent, session.Browser, session.OS, session.Device)
+	if err != nil {
+		logger.Error("error occurred when insert into sessions", sl.Err(err))
+		tx.Rollback()
+		return err
+	}
+
+	query2 := `INSERT INTO REFRESH_TOKENS (user_id, token_hash, expire_at, family_id)
+				VALUES ($1, $2, to_timestamp($3), $4)`
+
+	_, err = tx.Exec(query2, userID, refreshInput.TokenHash, int(refreshInput.ExpiresAt), refreshInput.FamilyID)
+	if err != nil {
+		logger.Error("error occurred when insert into refresh_tokens", sl.Err(err))
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// RevokeRefreshToken revokes the whole family of the given token, i.e. the session it belongs to.
+func (r *AuthRepo) RevokeRefreshToken(ctx context.Context, refreshTokenHash string) error {
+	const op = "Repository.Postgres.AuthRepo.RevokeRefreshToken"
+	logger := r.logger.With(slog.String("op", op))
+
+	query := `UPDATE REFRESH_TOKENS
+				SET black_list = true
+				WHERE family_id = (SELECT family_id FROM REFRESH_TOKENS WHERE token_hash = $1)`
+
+	res, err := r.db.Exec(query, refreshTokenHash)
+	if err != nil {
+		logger.Error("error occurred when update refresh_tokens", sl.Err(err))
+		return err
+	}
+
+	rowCount, err := res.RowsAffected()
+	if err != nil {
+		logger.Error("error occurred when get RowsAffected", sl.Err(err))
+		return err
+	}
+
+	if rowCount == 0 {
+		return domain.ErrInvalidRefreshToken
+	}
+
+	return nil
+}
+
+func (r *AuthRepo) RevokeAllRefreshTokens(ctx context.Context, userID int) error {
+	const op = "Repository.Postgres.AuthRepo.RevokeAllRefreshTokens"
+	logger := r.logger.With(slog.String("op", op))
+
+	query := `UPDATE REFRESH_TOKENS
+				SET black_list = true
+				WHERE user_id = $1 AND NOT black_list`
+
+	_, err := r.db.Exec(query, userID)
+	if err != nil {
+		logger.Error("error occurred when update refresh_tokens", sl.Err(err))
+		return err
+	}
+
+	return nil
+}
+
+func (r *AuthRepo) CreateUserToken(ctx context.Context, userID int, tokenType int, tokenHash string, expireAt int64) error {
+	const op = "Repository.Postgres.AuthRepo.CreateUserToken"
+	logger := r.logger.With(slog.String("op", op))
+
+	query := `INSERT INTO USER_TOKENS (user_id, token_type, token_hash, expire_at)
+				VALUES ($1, $2, $3, to_timestamp($4))`
+
+	_, err := conn(ctx, r.db).Exec(query, userID, tokenType, tokenHash, expireAt)
+	if err != nil {
+		logger.Error("error occurred when insert into user_tokens", sl.Err(err))
+		return err
+	}
+
+	return nil
+}
+
+// GetUserByToken returns the owner of an unused, unexpired token that has had fewer than maxAttempts failed attempts.
+func (r *AuthRepo) GetUserByToken(ctx context.Context, tokenType int, tokenHash string, maxAttempts int) (domain.User, error) {
+	const op = "Repository.Postgres.AuthRepo.GetUserByToken"
+	logger := r.logger.With(slog.String("op", op))
+
+	var user domain.User
+
+	query := `SELECT u.id, u.username, u.email, u.role_id, r.name
+				FROM USER_TOKENS t
+				INNER JOIN USERS u on u.id = t.user_id
+				INNER JOIN ROLES r on r.id = u.role_id
+				WHERE t.token_type = $1 AND t.token_hash = $2 AND NOT t.black_list
+					AND t.expire_at > CURRENT_TIMESTAMP AND t.attempts < $3`
+
+	err := r.db.QueryRow(query, tokenType, tokenHash, maxAttempts).Scan(&user.ID,
+		&user.Username,
+		&user.Email,
+		&user.Role.ID,
+		&user.Role.Name)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
-			return domain.User{}, domain.ErrUserNotFound
+			return domain.User{}, domain.ErrInvalidToken
 		}
-		logger.Error("error occurred when update refresh tokens", sl.Err(err))
+		logger.Error("error occurred when select from user_tokens", sl.Err(err))
 		return domain.User{}, err
 	}
 
 	return user, nil
 }
 
-func (r *AuthRepo) SetRefreshToken(ctx context.Context, userID int, refreshInput domain.RefreshToken) error {
-	const op = "Repository.Postgres.AuthRepo.SetRefreshToken"
+// GetUserCode returns the latest unused, unexpired verification code of the user.
+// Codes sent earlier are superseded by it.
+func (r *AuthRepo) GetUserCode(ctx context.Context, userID int, tokenType int) (domain.UserCode, error) {
+	const op = "Repository.Postgres.AuthRepo.GetUserCode"
 	logger := r.logger.With(slog.String("op", op))
 
-	query := `INSERT INTO REFRESH_TOKENS (user_id, refresh_token, expire_at) VALUES ($1, $2, to_timestamp($3))`
+	var code domain.UserCode
 
-	_, err := r.db.Exec(query, userID, refreshInput.RefreshToken, int(refreshInput.ExpiresAt))
+	query := `SELECT user_id, token_hash, attempts
+				FROM USER_TOKENS
+				WHERE user_id = $1 AND token_type = $2 AND NOT black_list AND expire_at > CURRENT_TIMESTAMP
+				ORDER BY created_at DESC, id DESC
+				LIMIT 1`
 
+	err := r.db.QueryRow(query, userID, tokenType).Scan(&code.UserID, &code.TokenHash, &code.Attempts)
 	if err != nil {
-		logger.Error("error occurred when insert into refresh_tokens", sl.Err(err))
+		if errors.Is(err, sql.ErrNoRows) {
+			return domain.UserCode{}, domain.ErrInvalidVerificationCode
+		}
+		logger.Error("error occurred when select from user_tokens", sl.Err(err))
+		return domain.UserCode{}, err
+	}
+
+	return code, nil
+}
+
+// RevokeUserTokensOfType marks all unused tokens of the type issued to the user as used.
+func (r *AuthRepo) RevokeUserTokensOfType(ctx context.Context, userID int, tokenType int) error {
+	const op = "Repository.Postgres.AuthRepo.RevokeUserTokensOfType"
+	logger := r.logger.With(slog.String("op", op))
+
+	query := `UPDATE USER_TOKENS
+				SET black_list = true
+				WHERE user_id = $1 AND token_type = $2 AND NOT black_list`
+
+	if _, err := conn(ctx, r.db).Exec(query, userID, tokenType); err != nil {
+		logger.Error("error occurred when update user_tokens", sl.Err(err))
+		return err
+	}
+
+	return nil
+}
+
+func (r *AuthRepo) AddUserTokenAttempt(ctx context.Context, tokenType int, tokenHash string) error {
+	const op = "Repository.Postgres.AuthRepo.AddUserTokenAttempt"
+	logger := r.logger.With(slog.String("op", op))
+
+	query := `UPDATE USER_TOKENS
+				SET attempts = attempts + 1
+				WHERE token_type = $1 AND token_hash = $2`
+
+	_, err := r.db.Exec(query, tokenType, tokenHash)
+	if err != nil {
+		logger.Error("error occurred when update user_tokens", sl.Err(err))
 		return err
 	}
 
 	return nil
 }
 
+// RevokeUserToken marks a token as used. It fails with ErrInvalidToken if the token was already used,
+// so of two concurrent requests with the same single-use token only one succeeds.
+func (r *AuthRepo) RevokeUserToken(ctx context.Context, tokenType int, tokenHash string) error {
+	const op = "Repository.Postgres.AuthRepo.RevokeUserToken"
+	logger := r.logger.With(slog.String("op", op))
+
+	query := `UPDATE USER_TOKENS
+				SET black_list = true
+				WHERE token_type = $1 AND token_hash = $2 AND NOT black_list`
+
+	res, err := r.db.Exec(query, tokenType, tokenHash)
+	if err != nil {
+		logger.Error("error occurred when update user_tokens", sl.Err(err))
+		return err
+	}
+
+	rowCount, err := res.RowsAffected()
+	if err != nil {
+		logger.Error("error occurred when get RowsAffected", sl.Err(err))
+		return err
+	}
+
+	if rowCount == 0 {
+		return domain.ErrInvalidToken
+	}
+
+	return nil
+}
+
 func (r *AuthRepo) Verify(ctx context.Context, userID int) error {
 	return nil
 }
@@ -327,7 +626,7 @@ func (r *AuthRepo) GetFullUserInfo(ctx context.Context, userID int) (domain.User
        COALESCE(u.last_name, '') as last_name, COALESCE(u.middle_name, '') as middle_name,
 				u.created_at, r.id, r.name
 				FROM USERS u
-				INNER JOIN ROLE_TYPES r on r.id = u.role_id
+				INNER JOIN ROLES r on r.id = u.role_id
 				WHERE u.id = $1`
 	err := r.db.QueryRow(query, userID).Scan(&u.ID,
 		&u.Username,
@@ -346,3 +645,217 @@ func (r *AuthRepo) GetFullUserInfo(ctx context.Context, userID int) (domain.User
 	}
 	return u, nil
 }
+
+// HasRecentUserToken reports whether a token of the type was issued to the user within the last period.
+func (r *AuthRepo) HasRecentUserToken(ctx context.Context, userID int, tokenType int, period time.Duration) (bool, error) {
+	const op = "Repository.Postgres.AuthRepo.HasRecentUserToken"
+	logger := r.logger.With(slog.String("op", op))
+
+	var exists bool
+
+	query := `SELECT EXISTS(SELECT 1 FROM USER_TOKENS
+				WHERE user_id = $1 AND token_type = $2 AND created_at > CURRENT_TIMESTAMP - make_interval(secs => $3))`
+
+	if err := r.db.QueryRow(query, userID, tokenType, period.Seconds()).Scan(&exists); err != nil {
+		logger.Error("error occurred when select from user_tokens", sl.Err(err))
+		return false, err
+	}
+
+	return exists, nil
+}
+
+// CountUserTokens returns how many unused, unexpired tokens of the type the user has.
+func (r *AuthRepo) CountUserTokens(ctx context.Context, userID int, tokenType int) (int, error) {
+	const op = "Repository.Postgres.AuthRepo.CountUserTokens"
+	logger := r.logger.With(slog.String("op", op))
+
+	var count int
+
+	query := `SELECT COUNT(*) FROM USER_TOKENS
+				WHERE user_id = $1 AND token_type = $2 AND NOT black_list AND expire_at > CURRENT_TIMESTAMP`
+
+	if err := r.db.QueryRow(query, userID, tokenType).Scan(&count); err != nil {
+		logger.Error("error occurred when select from user_tokens", sl.Err(err))
+		return 0, err
+	}
+
+	return count, nil
+}
+
+// DeleteUnconfirmedUsers deletes users who haven't confirmed their email within ttl of signing up
+// and returns them.
+func (r *AuthRepo) DeleteUnconfirmedUsers(ctx context.Context, ttl time.Duration) ([]domain.User, error) {
+	const op = "Repository.Postgres.AuthRepo.DeleteUnconfirmedUsers"
+	logger := r.logger.With(slog.String("op", op))
+
+	query := `DELETE FROM USERS
+				WHERE NOT COALESCE(is_confirm, false) AND created_at < CURRENT_TIMESTAMP - make_interval(secs => $1)
+				RETURNING id, username, email, created_at`
+
+	rows, err := r.db.Query(query, ttl.Seconds())
+	if err != nil {
+		logger.Error("error occurred when delete from users", sl.Err(err))
+		return nil, err
+	}
+	defer rows.Close()
+
+	users := make([]domain.User, 0)
+
+	for rows.Next() {
+		var u domain.User
+		if err := rows.Scan(&u.ID, &u.Username, &u.Email, &u.CreatedAt); err != nil {
+			logger.Error("error occurred when scan users", sl.Err(err))
+			return nil, err
+		}
+		users = append(users, u)
+	}
+
+	return users, rows.Err()
+}
+
+// SetPendingPhone stores a phone number waiting for confirmation. A number already
+// confirmed by another user can't be taken.
+func (r *AuthRepo) SetPendingPhone(ctx context.Context, userID int, phone string) error {
+	const op = "Repository.Postgres.AuthRepo.SetPendingPhone"
+	logger := r.logger.With(slog.String("op", op))
+
+	query := `UPDATE USERS
+				SET pending_phone = $2
+				WHERE id = $1 AND NOT EXISTS(SELECT 1 FROM USERS WHERE phone = $2 AND id <> $1)`
+
+	res, err := r.db.Exec(query, userID, phone)
+	if err != nil {
+		logger.Error("error occurred when update users", sl.Err(err))
+		return err
+	}
+
+	rowCount, err := res.RowsAffected()
+	if err != nil {
+		logger.Error("error occurred when get RowsAffected", sl.Err(err))
+		return err
+	}
+
+	if rowCount == 0 {
+		return domain.ErrPhoneAlreadyUsed
+	}
+
+	return nil
+}
+
+func (r *AuthRepo) GetPendingPhone(ctx context.Context, userID int) (string, error) {
+	const op = "Repository.Postgres.AuthRepo.GetPendingPhone"
+	logger := r.logger.With(slog.String("op", op))
+
+	var phone sql.NullString
+
+	query := `SELECT pending_phone FROM USERS WHERE id = $1`
+
+	if err := r.db.QueryRow(query, userID).Scan(&phone); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return "", domain.ErrUserNotFound
+		}
+		logger.Error("error occurred when select from users", sl.Err(err))
+		return "", err
+	}
+
+	return phone.String, nil
+}
+
+// ConfirmPhone uses the code and makes the pending phone number the user's phone.
+func (r *AuthRepo) ConfirmPhone(ctx context.Context, userID int, codeHash string) error {
+	const op = "Repository.Postgres.AuthRepo.ConfirmPhone"
+	logger := r.logger.With(slog.String("op", op))
+
+	tx, err := r.db.Begin()
+	if err != nil {
+		logger.Error("fail create r.db.Begin()!", sl.Err(err))
+		return err
+	}
+	defer tx.Rollback()
+
+	query1 := `UPDATE USER_TOKENS
+				SET black_list = true
+				WHERE user_id = $1 AND token_type = $2 AND token_hash = $3 AND NOT black_list`
+
+	res, err := tx.Exec(query1, userID, domain.TokenTypePhoneVerifyCode, codeHash)
+	if err != nil {
+		logger.Error("error occurred when update user_tokens", sl.Err(err))
+		return err
+	}
+
+	rowCount, err := res.RowsAffected()
+	if err != nil {
+		logger.Error("error occurred when get RowsAffected", sl.Err(err))
+		return err
+	}
+
+	if rowCount == 0 {
+		return domain.ErrInvalidVerificationCode
+	}
+
+	query2 := `UPDATE USERS
+				SET phone = pending_phone, pending_phone = NULL
+				WHERE id = $1 AND pending_phone IS NOT NULL`
+
+	if _, err := tx.Exec(query2, userID); err != nil {
+		if isUniqueViolation(err) {
+			return domain.ErrPhoneAlreadyUsed
+		}
+		logger.Error("error occurred when update users", sl.Err(err))
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (r *AuthRepo) GetByPhone(ctx context.Context, phone string) (domain.User, error) {
+	const op = "Repository.Postgres.AuthRepo.GetByPhone"
+	logger := r.logger.With(slog.String("op", op))
+
+	var user domain.User
+
+	query := `SELECT u.id, u.username, u.email, u.phone, u.role_id, r.name
+				FROM USERS u
+				INNER JOIN ROLES r on u.role_id = r.id
+				WHERE u.phone = $1`
+
+	err := r.db.QueryRow(query, phone).Scan(&user.ID,
+		&user.Username,
+		&user.Email,
+		&user.Phone,
+		&user.Role.ID,
+		&user.Role.Name)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return domain.User{}, domain.ErrUserNotFound
+		}
+		logger.Error("error occurred when select from users", sl.Err(err))
+		return domain.User{}, err
+	}
+
+	return user, nil
+}
+
+func isUniqueViolation(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == "23505"
+}
+
+func (r *AuthRepo) IsUserBanned(ctx context.Context, userID int) (bool, error) {
+	const op = "Repository.Postgres.AuthRepo.IsUserBanned"
+	logger := r.logger.With(slog.String("op", op))
+
+	var banned bool
+
+	query := `SELECT banned_at IS NOT NULL FROM USERS WHERE id = $1`
+
+	if err := r.db.QueryRow(query, userID).Scan(&banned); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return false, domain.ErrUserNotFound
+		}
+		logger.Error("error occurred when select from users", sl.Err(err))
+		return false, err
+	}
+
+	return banned, nil
+}
@@ -0,0 +1,91 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"github.com/shamank/edutour-backend/auth-service/pkg/logger/sl"
+	"log/slog"
+	"time"
+)
+
+type HousekeepingRepo struct {
+	db     *sql.DB
+	logger *slog.Logger
+}
+
+func NewHousekeepingRepo(db *sql.DB, logger *slog.Logger) *HousekeepingRepo {
+	return &HousekeepingRepo{
+		db:     db,
+		logger: logger,
+	}
+}
+
+// DeleteExpiredUserTokens deletes email, password reset, sign-in and other one-off tokens that can't be used anymore.
+func (r *HousekeepingRepo) DeleteExpiredUserTokens(ctx context.Context) (int64, error) {
+	const op = "Repository.Postgres.HousekeepingRepo.DeleteExpiredUserTokens"
+
+	return r.exec(op, "user_tokens", `DELETE FROM USER_TOKENS WHERE expire_at < CURRENT_TIMESTAMP`)
+}
+
+// DeleteExpiredRefreshTokens deletes expired refresh tokens. Rotated tokens are kept until they expire,
+// because reuse detection needs them.
+func (r *HousekeepingRepo) DeleteExpiredRefreshTokens(ctx context.Context) (int64, error) {
+	const op = "Repository.Postgres.HousekeepingRepo.DeleteExpiredRefreshTokens"
+
+	return r.exec(op, "refresh_tokens", `DELETE FROM REFRESH_TOKENS WHERE expire_at < CURRENT_TIMESTAMP`)
+}
+
+// DeleteEndedSessions deletes sessions that have no refresh tokens left.
+func (r *HousekeepingRepo) DeleteEndedSessions(ctx context.Context) (int64, error) {
+	const op = "Repository.Postgres.HousekeepingRepo.DeleteEndedSessions"
+
+	return r.exec(op, "sessions", `DELETE FROM SESSIONS s
+				WHERE NOT EXISTS(SELECT 1 FROM REFRESH_TOKENS t WHERE t.family_id = s.family_id)`)
+}
+
+// DeleteExpiredRevokedAccessTokens deletes revocations of access tokens that have expired on their own.
+func (r *HousekeepingRepo) DeleteExpiredRevokedAccessTokens(ctx context.Context) (int64, error) {
+	const op = "Repository.Postgres.HousekeepingRepo.DeleteExpiredRevokedAccessTokens"
+
+	return r.exec(op, "revoked_access_tokens", `DELETE FROM REVOKED_ACCESS_TOKENS WHERE expire_at < CURRENT_TIMESTAMP`)
+}
+
+func (r *HousekeepingRepo) DeleteExpiredWebAuthnSessions(ctx context.Context) (int64, error) {
+	const op = "Repository.Postgres.HousekeepingRepo.DeleteExpiredWebAuthnSessions"
+
+	return r.exec(op, "webauthn_sessions", `DELETE FROM WEBAUTHN_SESSIONS WHERE expire_at < CURRENT_TIMESTAMP`)
+}
+
+// DeleteSentEmails deletes delivered emails older than retention.
+func (r *HousekeepingRepo) DeleteSentEmails(ctx context.Context, retention time.Duration) (int64, error) {
+	const op = "Repository.Postgres.HousekeepingRepo.DeleteSentEmails"
+
+	return r.exec(op, "email_outbox", `DELETE FROM EMAIL_OUTBOX
+				WHERE status = 'sent' AND sent_at < CURRENT_TIMESTAMP - make_interval(secs => $1)`, retention.Seconds())
+}
+
+// DeleteDeadEmails deletes emails that ran out of attempts and were queued more than retention ago.
+func (r *HousekeepingRepo) DeleteDeadEmails(ctx context.Context, retention time.Duration) (int64, error) {
+	const op = "Repository.Postgres.HousekeepingRepo.DeleteDeadEmails"
+
+	return r.exec(op, "email_outbox", `DELETE FROM EMAIL_OUTBOX
+				WHERE status = 'dead' AND created_at < CURRENT_TIMESTAMP - make_interval(secs => $1)`, retention.Seconds())
+}
+
+func (r *HousekeepingRepo) exec(op string, table string, query string, args ...any) (int64, error) {
+	logger := r.logger.With(slog.String("op", op))
+
+	res, err := r.db.Exec(query, args...)
+	if err != nil {
+		logger.Error("error occurred when delete from "+table, sl.Err(err))
+		return 0, err
+	}
+
+	rowCount, err := res.RowsAffected()
+	if err != nil {
+		logger.Error("error occurred when get RowsAffected", sl.Err(err))
+		return 0, err
+	}
+
+	return rowCount, nil
+}
@@ -0,0 +1,177 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/shamank/edutour-backend/auth-service/internal/domain"
+	"github.com/shamank/edutour-backend/auth-service/pkg/logger/sl"
+	"log/slog"
+	"time"
+)
+
+// jobLockNamespace is the first key of the job advisory locks, so they don't collide with other advisory locks.
+const jobLockNamespace = "scheduled_jobs"
+
+type JobsRepo struct {
+	db     *sql.DB
+	logger *slog.Logger
+}
+
+func NewJobsRepo(db *sql.DB, logger *slog.Logger) *JobsRepo {
+	return &JobsRepo{
+		db:     db,
+		logger: logger,
+	}
+}
+
+// TryLockJob takes a session-level advisory lock for the job, so only one replica runs it at a time.
+// The lock lives on a dedicated connection until release is called; if the process dies,
+// Postgres drops the lock with the connection.
+func (r *JobsRepo) TryLockJob(ctx context.Context, name string) (func(), bool, error) {
+	const op = "Repository.Postgres.JobsRepo.TryLockJob"
+	logger := r.logger.With(slog.String("op", op))
+
+	conn, err := r.db.Conn(ctx)
+	if err != nil {
+		logger.Error("error occurred when get connection", sl.Err(err))
+		return nil, false, err
+	}
+
+	var acquired bool
+
+	query := `SELECT pg_try_advisory_lock(hashtext($1), hashtext($2))`
+
+	if err := conn.QueryRowContext(ctx, query, jobLockNamespace, name).Scan(&acquired); err != nil {
+		logger.Error("error occurred when take advisory lock", sl.Err(err))
+		conn.Close()
+		return nil, false, err
+	}
+
+	if !acquired {
+		conn.Close()
+		return nil, false, nil
+	}
+
+	release := func() {
+		// the job context may be cancelled already, the lock must be released anyway
+		_, err := conn.ExecContext(context.Background(), `SELECT pg_advisory_unlock(hashtext($1), hashtext($2))`, jobLockNamespace, name)
+		if err != nil {
+			logger.Error("error occurred when release advisory lock", sl.Err(err))
+		}
+		conn.Close()
+	}
+
+	return release, true, nil
+}
+
+func (r *JobsRepo) GetJobRun(ctx context.Context, name string) (domain.JobRun, error) {
+	const op = "Repository.Postgres.JobsRepo.GetJobRun"
+	logger := r.logger.With(slog.String("op", op))
+
+	query := `SELECT name, last_started_at, last_finished_at, last_status, last_error, last_duration_ms, next_run_at, run_by
+				FROM SCHEDULED_JOBS
+				WHERE name = $1`
+
+	run, err := scanJobRun(r.db.QueryRow(query, name))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return domain.JobRun{Name: name}, nil
+		}
+		logger.Error("error occurred when select from scheduled_jobs", sl.Err(err))
+		return domain.JobRun{}, err
+	}
+
+	return run, nil
+}
+
+func (r *JobsRepo) GetJobRuns(ctx context.Context) ([]domain.JobRun, error) {
+	const op = "Repository.Postgres.JobsRepo.GetJobRuns"
+	logger := r.logger.With(slog.String("op", op))
+
+	query := `SELECT name, last_started_at, last_finished_at, last_status, last_error, last_duration_ms, next_run_at, run_by
+				FROM SCHEDULED_JOBS
+				ORDER BY name`
+
+	rows, err := r.db.Query(query)
+	if err != nil {
+		logger.Error("error occurred when select from scheduled_jobs", sl.Err(err))
+		return nil, err
+	}
+	defer rows.Close()
+
+	runs := make([]domain.JobRun, 0)
+
+	for rows.Next() {
+		run, err := scanJobRun(rows)
+		if err != nil {
+			logger.Error("error occurred when scan scheduled_jobs", sl.Err(err))
+			return nil, err
+		}
+		runs = append(runs, run)
+	}
+
+	return runs, rows.Err()
+}
+
+func (r *JobsRepo) SaveJobRun(ctx context.Context, run domain.JobRun) error {
+	const op = "Repository.Postgres.JobsRepo.SaveJobRun"
+	logger := r.logger.With(slog.String("op", op))
+
+	query := `INSERT INTO SCHEDULED_JOBS (name, last_started_at, last_finished_at, last_status, last_error,
+                            last_duration_ms, next_run_at, run_by)
+				VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+				ON CONFLICT (name) DO UPDATE
+				SET last_started_at = excluded.last_started_at,
+				    last_finished_at = excluded.last_finished_at,
+				    last_status = excluded.last_status,
+				    last_error = excluded.last_error,
+				    last_duration_ms = excluded.last_duration_ms,
+				    next_run_at = excluded.next_run_at,
+				    run_by = excluded.run_by`
+
+	_, err := r.db.Exec(query,
+		run.Name,
+		run.LastStartedAt,
+		run.LastFinishedAt,
+		run.LastStatus,
+		run.LastError,
+		run.LastDuration.Milliseconds(),
+		run.NextRunAt,
+		run.RunBy)
+	if err != nil {
+		logger.Error("error occurred when upsert scheduled_jobs", sl.Err(err))
+		return err
+	}
+
+	return nil
+}
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanJobRun(row rowScanner) (domain.JobRun, error) {
+	var run domain.JobRun
+	var startedAt, finishedAt, nextRunAt sql.NullTime
+	var durationMs int64
+
+	err := row.Scan(&run.Name,
+		&startedAt,
+		&finishedAt,
+		&run.LastStatus,
+		&run.LastError,
+		&durationMs,
+		&nextRunAt,
+		&run.RunBy)
+	if err != nil {
+		return domain.JobRun{}, err
+	}
+
+	run.LastStartedAt = startedAt.Time
+	run.LastFinishedAt = finishedAt.Time
+	run.NextRunAt = nextRunAt.Time
+	run.LastDuration = time.Duration(durationMs) * time.Millisecond
+
+	return run, nil
+}
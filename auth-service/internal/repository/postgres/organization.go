@@ -0,0 +1,401 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/shamank/edutour-backend/auth-service/internal/domain"
+	"github.com/shamank/edutour-backend/auth-service/pkg/logger/sl"
+	"log/slog"
+)
+
+const (
+	selectOrganizationsQuery = `SELECT id, name, domain, verified_at, created_at, updated_at
+				FROM ORGANIZATIONS`
+
+	selectMembersQuery = `SELECT m.organization_id, o.verified_at IS NOT NULL, m.user_id, u.username, u.email, u.locale,
+					m.role, m.created_at
+				FROM ORGANIZATION_MEMBERS m
+				INNER JOIN ORGANIZATIONS o on o.id = m.organization_id
+				INNER JOIN USERS u on u.id = m.user_id`
+
+	selectInvitationsQuery = `SELECT i.id, i.organization_id, o.name, i.email, i.role, i.token_hash,
+					COALESCE(i.invited_by, 0), i.status, i.expire_at, i.created_at
+				FROM ORGANIZATION_INVITATIONS i
+				INNER JOIN ORGANIZATIONS o on o.id = i.organization_id`
+)
+
+type OrganizationRepo struct {
+	db     *sql.DB
+	logger *slog.Logger
+}
+
+func NewOrganizationRepo(db *sql.DB, logger *slog.Logger) *OrganizationRepo {
+	return &OrganizationRepo{
+		db:     db,
+		logger: logger,
+	}
+}
+
+// CreateOrganization creates an organization with the user as its owner and returns its id.
+func (r *OrganizationRepo) CreateOrganization(ctx context.Context, org domain.Organization, ownerID int) (int, error) {
+	const op = "Repository.Postgres.OrganizationRepo.CreateOrganization"
+	logger := r.logger.With(slog.String("op", op))
+
+	tx, err := begin(ctx, r.db)
+	if err != nil {
+		logger.Error("fail create r.db.Begin()!", sl.Err(err))
+		return 0, err
+	}
+
+	var id int
+
+	// unverified organizations may share a domain, a verified one takes it
+	query := `INSERT INTO ORGANIZATIONS (name, domain)
+				SELECT $1, $2
+				WHERE NOT EXISTS(SELECT 1 FROM ORGANIZATIONS WHERE domain = $2 AND verified_at IS NOT NULL)
+				RETURNING id`
+
+	if err := tx.QueryRow(query, org.Name, org.Domain).Scan(&id); err != nil {
+		tx.Rollback()
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, domain.ErrOrganizationExists
+		}
+		logger.Error("error occurred when insert into organizations", sl.Err(err))
+		return 0, err
+	}
+
+	query = `INSERT INTO ORGANIZATION_MEMBERS (organization_id, user_id, role) VALUES ($1, $2, $3)`
+
+	if _, err := tx.Exec(query, id, ownerID, domain.OrganizationRoleOwner); err != nil {
+		tx.Rollback()
+		if isUniqueViolation(err) {
+			return 0, domain.ErrAlreadyOrganizationMember
+		}
+		logger.Error("error occurred when insert into organization_members", sl.Err(err))
+		return 0, err
+	}
+
+	return id, tx.Commit()
+}
+
+func (r *OrganizationRepo) GetOrganization(ctx context.Context, orgID int) (domain.Organization, error) {
+	const op = "Repository.Postgres.OrganizationRepo.GetOrganization"
+	logger := r.logger.With(slog.String("op", op))
+
+	var org domain.Organization
+	var verifiedAt sql.NullTime
+
+	err := conn(ctx, r.db).QueryRow(selectOrganizationsQuery+` WHERE id = $1`, orgID).Scan(&org.ID,
+		&org.Name,
+		&org.Domain,
+		&verifiedAt,
+		&org.CreatedAt,
+		&org.UpdatedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return domain.Organization{}, domain.ErrOrganizationNotFound
+		}
+		logger.Error("error occurred when select from organizations", sl.Err(err))
+		return domain.Organization{}, err
+	}
+
+	org.VerifiedAt = verifiedAt.Time
+
+	return org, nil
+}
+
+func (r *OrganizationRepo) UpdateOrganization(ctx context.Context, org domain.Organization) error {
+	const op = "Repository.Postgres.OrganizationRepo.UpdateOrganization"
+	logger := r.logger.With(slog.String("op", op))
+
+	return r.exec(ctx, logger, domain.ErrOrganizationNotFound,
+		`UPDATE ORGANIZATIONS SET name = $2 WHERE id = $1`, org.ID, org.Name)
+}
+
+// DeleteOrganization deletes the organization with its memberships and invitations.
+func (r *OrganizationRepo) DeleteOrganization(ctx context.Context, orgID int) error {
+	const op = "Repository.Postgres.OrganizationRepo.DeleteOrganization"
+	logger := r.logger.With(slog.String("op", op))
+
+	return r.exec(ctx, logger, domain.ErrOrganizationNotFound,
+		`DELETE FROM ORGANIZATIONS WHERE id = $1`, orgID)
+}
+
+// LockOrganization locks the organization until the end of the transaction ctx is in,
+// so that concurrent changes of its members are applied one by one.
+func (r *OrganizationRepo) LockOrganization(ctx context.Context, orgID int) error {
+	const op = "Repository.Postgres.OrganizationRepo.LockOrganization"
+	logger := r.logger.With(slog.String("op", op))
+
+	var id int
+
+	err := conn(ctx, r.db).QueryRow(`SELECT id FROM ORGANIZATIONS WHERE id = $1 FOR UPDATE`, orgID).Scan(&id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return domain.ErrOrganizationNotFound
+		}
+		logger.Error("error occurred when select from organizations", sl.Err(err))
+		return err
+	}
+
+	return nil
+}
+
+// GetMembership returns the membership of the user, ErrNotOrganizationMember if the user is in no organization.
+func (r *OrganizationRepo) GetMembership(ctx context.Context, userID int) (domain.OrganizationMember, error) {
+	const op = "Repository.Postgres.OrganizationRepo.GetMembership"
+	logger := r.logger.With(slog.String("op", op))
+
+	m, err := scanMember(conn(ctx, r.db).QueryRow(selectMembersQuery+` WHERE m.user_id = $1`, userID))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return domain.OrganizationMember{}, domain.ErrNotOrganizationMember
+		}
+		logger.Error("error occurred when select from organization_members", sl.Err(err))
+		return domain.OrganizationMember{}, err
+	}
+
+	return m, nil
+}
+
+func (r *OrganizationRepo) GetMembers(ctx context.Context, orgID int) ([]domain.OrganizationMember, error) {
+	const op = "Repository.Postgres.OrganizationRepo.GetMembers"
+	logger := r.logger.With(slog.String("op", op))
+
+	rows, err := conn(ctx, r.db).Query(selectMembersQuery+`
+				WHERE m.organization_id = $1
+				ORDER BY m.created_at, m.user_id`, orgID)
+	if err != nil {
+		logger.Error("error occurred when select from organization_members", sl.Err(err))
+		return nil, err
+	}
+	defer rows.Close()
+
+	members := make([]domain.OrganizationMember, 0)
+
+	for rows.Next() {
+		m, err := scanMember(rows)
+		if err != nil {
+			logger.Error("error occurred when scan organization_members", sl.Err(err))
+			return nil, err
+		}
+		members = append(members, m)
+	}
+
+	return members, rows.Err()
+}
+
+func (r *OrganizationRepo) AddMember(ctx context.Context, member domain.OrganizationMember) error {
+	const op = "Repository.Postgres.OrganizationRepo.AddMember"
+	logger := r.logger.With(slog.String("op", op))
+
+	query := `INSERT INTO ORGANIZATION_MEMBERS (organization_id, user_id, role) VALUES ($1, $2, $3)`
+
+	if _, err := conn(ctx, r.db).Exec(query, member.OrganizationID, member.UserID, member.Role); err != nil {
+		if isUniqueViolation(err) {
+			return domain.ErrAlreadyOrganizationMember
+		}
+		logger.Error("error occurred when insert into organization_members", sl.Err(err))
+		return err
+	}
+
+	return nil
+}
+
+func (r *OrganizationRepo) SetMemberRole(ctx context.Context, orgID int, userID int, role string) error {
+	const op = "Repository.Postgres.OrganizationRepo.SetMemberRole"
+	logger := r.logger.With(slog.String("op", op))
+
+	return r.exec(ctx, logger, domain.ErrNotOrganizationMember,
+		`UPDATE ORGANIZATION_MEMBERS SET role = $3 WHERE organization_id = $1 AND user_id = $2`, orgID, userID, role)
+}
+
+func (r *OrganizationRepo) RemoveMember(ctx context.Context, orgID int, userID int) error {
+	const op = "Repository.Postgres.OrganizationRepo.RemoveMember"
+	logger := r.logger.With(slog.String("op", op))
+
+	return r.exec(ctx, logger, domain.ErrNotOrganizationMember,
+		`DELETE FROM ORGANIZATION_MEMBERS WHERE organization_id = $1 AND user_id = $2`, orgID, userID)
+}
+
+func (r *OrganizationRepo) CountOwners(ctx context.Context, orgID int) (int, error) {
+	const op = "Repository.Postgres.OrganizationRepo.CountOwners"
+	logger := r.logger.With(slog.String("op", op))
+
+	var count int
+
+	query := `SELECT count(*) FROM ORGANIZATION_MEMBERS WHERE organization_id = $1 AND role = $2`
+
+	if err := conn(ctx, r.db).QueryRow(query, orgID, domain.OrganizationRoleOwner).Scan(&count); err != nil {
+		logger.Error("error occurred when select from organization_members", sl.Err(err))
+		return 0, err
+	}
+
+	return count, nil
+}
+
+// CreateInvitation creates a pending invitation and returns its id.
+// A pending invitation of the same email to the organization is revoked, so inviting again resends it.
+func (r *OrganizationRepo) CreateInvitation(ctx context.Context, invitation domain.OrganizationInvitation) (int, error) {
+	const op = "Repository.Postgres.OrganizationRepo.CreateInvitation"
+	logger := r.logger.With(slog.String("op", op))
+
+	tx, err := begin(ctx, r.db)
+	if err != nil {
+		logger.Error("fail create r.db.Begin()!", sl.Err(err))
+		return 0, err
+	}
+
+	query := `UPDATE ORGANIZATION_INVITATIONS
+				SET status = $3, responded_at = CURRENT_TIMESTAMP
+				WHERE organization_id = $1 AND lower(email) = lower($2) AND status = $4`
+
+	_, err = tx.Exec(query, invitation.OrganizationID, invitation.Email, domain.InvitationStatusRevoked, domain.InvitationStatusPending)
+	if err != nil {
+		logger.Error("error occurred when update organization_invitations", sl.Err(err))
+		tx.Rollback()
+		return 0, err
+	}
+
+	var id int
+
+	query = `INSERT INTO ORGANIZATION_INVITATIONS (organization_id, email, role, token_hash, invited_by, expire_at)
+				VALUES ($1, $2, $3, $4, $5, to_timestamp($6))
+				RETURNING id`
+
+	err = tx.QueryRow(query, invitation.OrganizationID, invitation.Email, invitation.Role, invitation.TokenHash,
+		invitation.InvitedBy, invitation.ExpiresAt.Unix()).Scan(&id)
+	if err != nil {
+		logger.Error("error occurred when insert into organization_invitations", sl.Err(err))
+		tx.Rollback()
+		return 0, err
+	}
+
+	return id, tx.Commit()
+}
+
+// GetInvitations returns the pending invitations of the organization that haven't expired.
+func (r *OrganizationRepo) GetInvitations(ctx context.Context, orgID int) ([]domain.OrganizationInvitation, error) {
+	const op = "Repository.Postgres.OrganizationRepo.GetInvitations"
+	logger := r.logger.With(slog.String("op", op))
+
+	rows, err := conn(ctx, r.db).Query(selectInvitationsQuery+`
+				WHERE i.organization_id = $1 AND i.status = $2 AND i.expire_at > CURRENT_TIMESTAMP
+				ORDER BY i.id DESC`, orgID, domain.InvitationStatusPending)
+	if err != nil {
+		logger.Error("error occurred when select from organization_invitations", sl.Err(err))
+		return nil, err
+	}
+	defer rows.Close()
+
+	invitations := make([]domain.OrganizationInvitation, 0)
+
+	for rows.Next() {
+		i, err := scanInvitation(rows)
+		if err != nil {
+			logger.Error("error occurred when scan organization_invitations", sl.Err(err))
+			return nil, err
+		}
+		invitations = append(invitations, i)
+	}
+
+	return invitations, rows.Err()
+}
+
+// GetInvitationByToken returns the pending, unexpired invitation with the token and, within a transaction,
+// locks it until the transaction ends.
+func (r *OrganizationRepo) GetInvitationByToken(ctx context.Context, tokenHash string) (domain.OrganizationInvitation, error) {
+	const op = "Repository.Postgres.OrganizationRepo.GetInvitationByToken"
+	logger := r.logger.With(slog.String("op", op))
+
+	i, err := scanInvitation(conn(ctx, r.db).QueryRow(selectInvitationsQuery+`
+				WHERE i.token_hash = $1 AND i.status = $2 AND i.expire_at > CURRENT_TIMESTAMP
+				FOR UPDATE OF i`, tokenHash, domain.InvitationStatusPending))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return domain.OrganizationInvitation{}, domain.ErrInvitationNotFound
+		}
+		logger.Error("error occurred when select from organization_invitations", sl.Err(err))
+		return domain.OrganizationInvitation{}, err
+	}
+
+	return i, nil
+}
+
+// AnswerInvitation moves a pending invitation to status.
+func (r *OrganizationRepo) AnswerInvitation(ctx context.Context, invitationID int, status string) error {
+	const op = "Repository.Postgres.OrganizationRepo.AnswerInvitation"
+	logger := r.logger.With(slog.String("op", op))
+
+	query := `UPDATE ORGANIZATION_INVITATIONS
+				SET status = $2, responded_at = CURRENT_TIMESTAMP
+				WHERE id = $1 AND status = $3`
+
+	return r.exec(ctx, logger, domain.ErrInvitationNotFound, query, invitationID, status, domain.InvitationStatusPending)
+}
+
+func (r *OrganizationRepo) RevokeInvitation(ctx context.Context, orgID int, invitationID int) error {
+	const op = "Repository.Postgres.OrganizationRepo.RevokeInvitation"
+	logger := r.logger.With(slog.String("op", op))
+
+	query := `UPDATE ORGANIZATION_INVITATIONS
+				SET status = $3, responded_at = CURRENT_TIMESTAMP
+				WHERE organization_id = $1 AND id = $2 AND status = $4`
+
+	return r.exec(ctx, logger, domain.ErrInvitationNotFound, query, orgID, invitationID,
+		domain.InvitationStatusRevoked, domain.InvitationStatusPending)
+}
+
+// exec runs a statement and reports notFound if it affected no rows.
+func (r *OrganizationRepo) exec(ctx context.Context, logger *slog.Logger, notFound error, query string, args ...interface{}) error {
+	res, err := conn(ctx, r.db).Exec(query, args...)
+	if err != nil {
+		logger.Error("error occurred when exec query", sl.Err(err))
+		return err
+	}
+
+	rowCount, err := res.RowsAffected()
+	if err != nil {
+		logger.Error("error occurred when get RowsAffected", sl.Err(err))
+		return err
+	}
+
+	if rowCount == 0 {
+		return notFound
+	}
+
+	return nil
+}
+
+func scanMember(row interface{ Scan(dest ...any) error }) (domain.OrganizationMember, error) {
+	var m domain.OrganizationMember
+
+	err := row.Scan(&m.OrganizationID,
+		&m.OrganizationVerified,
+		&m.UserID,
+		&m.Username,
+		&m.Email,
+		&m.Locale,
+		&m.Role,
+		&m.CreatedAt)
+
+	return m, err
+}
+
+func scanInvitation(row interface{ Scan(dest ...any) error }) (domain.OrganizationInvitation, error) {
+	var i domain.OrganizationInvitation
+
+	err := row.Scan(&i.ID,
+		&i.OrganizationID,
+		&i.OrganizationName,
+		&i.Email,
+		&i.Role,
+		&i.TokenHash,
+		&i.InvitedBy,
+		&i.Status,
+		&i.ExpiresAt,
+		&i.CreatedAt)
+
+	return i, err
+}
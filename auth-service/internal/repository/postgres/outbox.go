@@ -0,0 +1,200 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"github.com/lib/pq"
+	"github.com/shamank/edutour-backend/auth-service/internal/domain"
+	"github.com/shamank/edutour-backend/auth-service/pkg/logger/sl"
+	"log/slog"
+	"time"
+)
+
+type OutboxRepo struct {
+	db     *sql.DB
+	logger *slog.Logger
+}
+
+func NewOutboxRepo(db *sql.DB, logger *slog.Logger) *OutboxRepo {
+	return &OutboxRepo{
+		db:     db,
+		logger: logger,
+	}
+}
+
+// EnqueueEmail stores an email for delivery. It joins the transaction ctx is in,
+// so the email is sent only if the rest of the transaction commits.
+func (r *OutboxRepo) EnqueueEmail(ctx context.Context, email domain.OutboxEmail) error {
+	const op = "Repository.Postgres.OutboxRepo.EnqueueEmail"
+	logger := r.logger.With(slog.String("op", op))
+
+	query := `INSERT INTO EMAIL_OUTBOX (recipients, subject, body, html_body)
+				VALUES ($1, $2, $3, $4)`
+
+	_, err := conn(ctx, r.db).Exec(query, pq.Array(email.To), email.Subject, email.Body, email.HTMLBody)
+	if err != nil {
+		logger.Error("error occurred when insert into email_outbox", sl.Err(err))
+		return err
+	}
+
+	return nil
+}
+
+// ClaimEmails takes up to limit due emails for delivery. Claimed emails are hidden from other workers
+// for lease, after which a worker that died before reporting the result is assumed.
+func (r *OutboxRepo) ClaimEmails(ctx context.Context, limit int, lease time.Duration) ([]domain.OutboxEmail, error) {
+	const op = "Repository.Postgres.OutboxRepo.ClaimEmails"
+	logger := r.logger.With(slog.String("op", op))
+
+	query := `UPDATE EMAIL_OUTBOX
+				SET locked_until = CURRENT_TIMESTAMP + make_interval(secs => $2)
+				WHERE id IN (
+					SELECT id FROM EMAIL_OUTBOX
+					WHERE status = 'pending' AND next_attempt_at <= CURRENT_TIMESTAMP
+						AND (locked_until IS NULL OR locked_until < CURRENT_TIMESTAMP)
+					ORDER BY next_attempt_at
+					LIMIT $1
+					FOR UPDATE SKIP LOCKED)
+				RETURNING id, recipients, subject, body, html_body, status, attempts, last_error, next_attempt_at, created_at`
+
+	rows, err := r.db.Query(query, limit, lease.Seconds())
+	if err != nil {
+		logger.Error("error occurred when update email_outbox", sl.Err(err))
+		return nil, err
+	}
+	defer rows.Close()
+
+	emails := make([]domain.OutboxEmail, 0)
+
+	for rows.Next() {
+		var e domain.OutboxEmail
+		if err := rows.Scan(&e.ID,
+			pq.Array(&e.To),
+			&e.Subject,
+			&e.Body,
+			&e.HTMLBody,
+			&e.Status,
+			&e.Attempts,
+			&e.LastError,
+			&e.NextAttemptAt,
+			&e.CreatedAt); err != nil {
+			logger.Error("error occurred when scan email_outbox", sl.Err(err))
+			return nil, err
+		}
+		emails = append(emails, e)
+	}
+
+	return emails, rows.Err()
+}
+
+// MarkEmailSent records the delivery and clears the body, which isn't needed anymore.
+func (r *OutboxRepo) MarkEmailSent(ctx context.Context, id int) error {
+	const op = "Repository.Postgres.OutboxRepo.MarkEmailSent"
+	logger := r.logger.With(slog.String("op", op))
+
+	query := `UPDATE EMAIL_OUTBOX
+				SET status = 'sent', attempts = attempts + 1, last_error = '', locked_until = NULL, sent_at = CURRENT_TIMESTAMP,
+				    body = '', html_body = ''
+				WHERE id = $1`
+
+	if _, err := r.db.Exec(query, id); err != nil {
+		logger.Error("error occurred when update email_outbox", sl.Err(err))
+		return err
+	}
+
+	return nil
+}
+
+// MarkEmailFailed records a failed attempt. The email is retried after retryIn,
+// or becomes dead if dead is set.
+func (r *OutboxRepo) MarkEmailFailed(ctx context.Context, id int, lastError string, retryIn time.Duration, dead bool) error {
+	const op = "Repository.Postgres.OutboxRepo.MarkEmailFailed"
+	logger := r.logger.With(slog.String("op", op))
+
+	status := domain.EmailStatusPending
+	if dead {
+		status = domain.EmailStatusDead
+	}
+
+	query := `UPDATE EMAIL_OUTBOX
+				SET status = $2, attempts = attempts + 1, last_error = $3, locked_until = NULL,
+				    next_attempt_at = CURRENT_TIMESTAMP + make_interval(secs => $4)
+				WHERE id = $1`
+
+	if _, err := r.db.Exec(query, id, status, lastError, retryIn.Seconds()); err != nil {
+		logger.Error("error occurred when update email_outbox", sl.Err(err))
+		return err
+	}
+
+	return nil
+}
+
+// GetEmails returns the latest emails with the status. Bodies are left out, only the worker reads them.
+func (r *OutboxRepo) GetEmails(ctx context.Context, status string, limit int) ([]domain.OutboxEmail, error) {
+	const op = "Repository.Postgres.OutboxRepo.GetEmails"
+	logger := r.logger.With(slog.String("op", op))
+
+	query := `SELECT id, recipients, subject, status, attempts, last_error, next_attempt_at, created_at, sent_at
+				FROM EMAIL_OUTBOX
+				WHERE status = $1
+				ORDER BY id DESC
+				LIMIT $2`
+
+	rows, err := r.db.Query(query, status, limit)
+	if err != nil {
+		logger.Error("error occurred when select from email_outbox", sl.Err(err))
+		return nil, err
+	}
+	defer rows.Close()
+
+	emails := make([]domain.OutboxEmail, 0)
+
+	for rows.Next() {
+		var e domain.OutboxEmail
+		var sentAt sql.NullTime
+		if err := rows.Scan(&e.ID,
+			pq.Array(&e.To),
+			&e.Subject,
+			&e.Status,
+			&e.Attempts,
+			&e.LastError,
+			&e.NextAttemptAt,
+			&e.CreatedAt,
+			&sentAt); err != nil {
+			logger.Error("error occurred when scan email_outbox", sl.Err(err))
+			return nil, err
+		}
+		e.SentAt = sentAt.Time
+		emails = append(emails, e)
+	}
+
+	return emails, rows.Err()
+}
+
+// RetryEmail gives a dead email a new set of attempts.
+func (r *OutboxRepo) RetryEmail(ctx context.Context, id int) error {
+	const op = "Repository.Postgres.OutboxRepo.RetryEmail"
+	logger := r.logger.With(slog.String("op", op))
+
+	query := `UPDATE EMAIL_OUTBOX
+				SET status = 'pending', attempts = 0, next_attempt_at = CURRENT_TIMESTAMP
+				WHERE id = $1 AND status = 'dead'`
+
+	res, err := r.db.Exec(query, id)
+	if err != nil {
+		logger.Error("error occurred when update email_outbox", sl.Err(err))
+		return err
+	}
+
+	rowCount, err := res.RowsAffected()
+	if err != nil {
+		logger.Error("error occurred when get RowsAffected", sl.Err(err))
+		return err
+	}
+
+	if rowCount == 0 {
+		return domain.ErrEmailNotFound
+	}
+
+	return nil
+}
@@ -0,0 +1,119 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/shamank/edutour-backend/auth-service/internal/domain"
+	"github.com/shamank/edutour-backend/auth-service/pkg/logger/sl"
+	"log/slog"
+	"time"
+)
+
+type RevocationRepo struct {
+	db     *sql.DB
+	logger *slog.Logger
+}
+
+func NewRevocationRepo(db *sql.DB, logger *slog.Logger) *RevocationRepo {
+	return &RevocationRepo{
+		db:     db,
+		logger: logger,
+	}
+}
+
+func (r *RevocationRepo) RevokeAccessToken(ctx context.Context, tokenID string, userID int, expireAt time.Time) error {
+	const op = "Repository.Postgres.RevocationRepo.RevokeAccessToken"
+	logger := r.logger.With(slog.String("op", op))
+
+	query := `INSERT INTO REVOKED_ACCESS_TOKENS (jti, user_id, expire_at)
+				VALUES ($1, $2, $3)
+				ON CONFLICT (jti) DO NOTHING`
+
+	_, err := r.db.Exec(query, tokenID, userID, expireAt)
+	if err != nil {
+		logger.Error("error occurred when insert into revoked_access_tokens", sl.Err(err))
+		return err
+	}
+
+	return nil
+}
+
+// IsAccessTokenRevoked reports whether the token is on the revocation list or its session has ended,
+// i.e. no refresh token of the session's family is left unrevoked.
+func (r *RevocationRepo) IsAccessTokenRevoked(ctx context.Context, tokenID string, sessionID string) (bool, error) {
+	const op = "Repository.Postgres.RevocationRepo.IsAccessTokenRevoked"
+	logger := r.logger.With(slog.String("op", op))
+
+	var revoked bool
+
+	query := `SELECT EXISTS(SELECT 1 FROM REVOKED_ACCESS_TOKENS WHERE jti = $1)
+				OR ($2 <> '' AND NOT EXISTS(SELECT 1 FROM REFRESH_TOKENS WHERE family_id = $2 AND NOT black_list))`
+
+	if err := r.db.QueryRow(query, tokenID, sessionID).Scan(&revoked); err != nil {
+		logger.Error("error occurred when select from revoked_access_tokens", sl.Err(err))
+		return false, err
+	}
+
+	return revoked, nil
+}
+
+func (r *RevocationRepo) SetTokensValidAfter(ctx context.Context, userID int, validAfter time.Time) error {
+	const op = "Repository.Postgres.RevocationRepo.SetTokensValidAfter"
+	logger := r.logger.With(slog.String("op", op))
+
+	query := `UPDATE USERS
+				SET tokens_valid_after = $1
+				WHERE id = $2`
+
+	_, err := r.db.Exec(query, validAfter, userID)
+	if err != nil {
+		logger.Error("error occurred when update users", sl.Err(err))
+		return err
+	}
+
+	return nil
+}
+
+// SetRoleTokensValidAfter sets the watermark of every user with the role.
+func (r *RevocationRepo) SetRoleTokensValidAfter(ctx context.Context, roleID int, validAfter time.Time) error {
+	const op = "Repository.Postgres.RevocationRepo.SetRoleTokensValidAfter"
+	logger := r.logger.With(slog.String("op", op))
+
+	query := `UPDATE USERS
+				SET tokens_valid_after = $1
+				WHERE role_id = $2`
+
+	_, err := r.db.Exec(query, validAfter, roleID)
+	if err != nil {
+		logger.Error("error occurred when update users", sl.Err(err))
+		return err
+	}
+
+	return nil
+}
+
+// GetTokensValidAfter returns the user's watermark, zero time if it was never set.
+func (r *RevocationRepo) GetTokensValidAfter(ctx context.Context, userID int) (time.Time, error) {
+	const op = "Repository.Postgres.RevocationRepo.GetTokensValidAfter"
+	logger := r.logger.With(slog.String("op", op))
+
+	var validAfter sql.NullTime
+
+	query := `SELECT tokens_valid_after FROM USERS WHERE id = $1`
+
+	err := r.db.QueryRow(query, userID).Scan(&validAfter)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return time.Time{}, domain.ErrUserNotFound
+		}
+		logger.Error("error occurred when select from users", sl.Err(err))
+		return time.Time{}, err
+	}
+
+	if !validAfter.Valid {
+		return time.Time{}, nil
+	}
+
+	return validAfter.Time, nil
+}
@@ -0,0 +1,303 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/lib/pq"
+	"github.com/shamank/edutour-backend/auth-service/internal/domain"
+	"github.com/shamank/edutour-backend/auth-service/pkg/logger/sl"
+	"log/slog"
+)
+
+const selectRolesQuery = `SELECT r.id, r.name, r.description, r.is_system, r.created_at,
+					COALESCE(array_agg(p.name ORDER BY p.name) FILTER (WHERE p.name IS NOT NULL), '{}')
+				FROM ROLES r
+				LEFT JOIN ROLE_PERMISSIONS rp on rp.role_id = r.id
+				LEFT JOIN PERMISSIONS p on p.id = rp.permission_id`
+
+type RoleRepo struct {
+	db     *sql.DB
+	logger *slog.Logger
+}
+
+func NewRoleRepo(db *sql.DB, logger *slog.Logger) *RoleRepo {
+	return &RoleRepo{
+		db:     db,
+		logger: logger,
+	}
+}
+
+func (r *RoleRepo) GetRoles(ctx context.Context) ([]domain.Role, error) {
+	const op = "Repository.Postgres.RoleRepo.GetRoles"
+	logger := r.logger.With(slog.String("op", op))
+
+	rows, err := r.db.QueryContext(ctx, selectRolesQuery+`
+				GROUP BY r.id
+				ORDER BY r.id`)
+	if err != nil {
+		logger.Error("error occurred when select from roles", sl.Err(err))
+		return nil, err
+	}
+	defer rows.Close()
+
+	roles := make([]domain.Role, 0)
+
+	for rows.Next() {
+		role, err := scanRole(rows)
+		if err != nil {
+			logger.Error("error occurred when scan roles", sl.Err(err))
+			return nil, err
+		}
+		roles = append(roles, role)
+	}
+
+	return roles, rows.Err()
+}
+
+func (r *RoleRepo) GetRole(ctx context.Context, roleID int) (domain.Role, error) {
+	const op = "Repository.Postgres.RoleRepo.GetRole"
+	logger := r.logger.With(slog.String("op", op))
+
+	role, err := scanRole(r.db.QueryRowContext(ctx, selectRolesQuery+`
+				WHERE r.id = $1
+				GROUP BY r.id`, roleID))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return domain.Role{}, domain.ErrRoleNotFound
+		}
+		logger.Error("error occurred when select from roles", sl.Err(err))
+		return domain.Role{}, err
+	}
+
+	return role, nil
+}
+
+// CreateRole creates a role with its permissions and returns its id.
+func (r *RoleRepo) CreateRole(ctx context.Context, role domain.Role) (int, error) {
+	const op = "Repository.Postgres.RoleRepo.CreateRole"
+	logger := r.logger.With(slog.String("op", op))
+
+	tx, err := begin(ctx, r.db)
+	if err != nil {
+		logger.Error("fail create r.db.Begin()!", sl.Err(err))
+		return 0, err
+	}
+
+	var id int
+
+	query := `INSERT INTO ROLES (name, description) VALUES ($1, $2) RETURNING id`
+
+	if err := tx.QueryRow(query, role.Name, role.Description).Scan(&id); err != nil {
+		tx.Rollback()
+		if isUniqueViolation(err) {
+			return 0, domain.ErrRoleAlreadyExists
+		}
+		logger.Error("error occurred when insert into roles", sl.Err(err))
+		return 0, err
+	}
+
+	if err := r.setRolePermissions(tx, logger, id, role.Permissions); err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+
+	return id, tx.Commit()
+}
+
+// UpdateRole replaces the description and the permissions of the role. The name stays,
+// as it is what access tokens carry.
+func (r *RoleRepo) UpdateRole(ctx context.Context, role domain.Role) error {
+	const op = "Repository.Postgres.RoleRepo.UpdateRole"
+	logger := r.logger.With(slog.String("op", op))
+
+	tx, err := begin(ctx, r.db)
+	if err != nil {
+		logger.Error("fail create r.db.Begin()!", sl.Err(err))
+		return err
+	}
+
+	res, err := tx.Exec(`UPDATE ROLES SET description = $2 WHERE id = $1`, role.ID, role.Description)
+	if err != nil {
+		logger.Error("error occurred when update roles", sl.Err(err))
+		tx.Rollback()
+		return err
+	}
+
+	rowCount, err := res.RowsAffected()
+	if err != nil {
+		logger.Error("error occurred when get RowsAffected", sl.Err(err))
+		tx.Rollback()
+		return err
+	}
+
+	if rowCount == 0 {
+		tx.Rollback()
+		return domain.ErrRoleNotFound
+	}
+
+	if _, err := tx.Exec(`DELETE FROM ROLE_PERMISSIONS WHERE role_id = $1`, role.ID); err != nil {
+		logger.Error("error occurred when delete from role_permissions", sl.Err(err))
+		tx.Rollback()
+		return err
+	}
+
+	if err := r.setRolePermissions(tx, logger, role.ID, role.Permissions); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// DeleteRole deletes a role no user has.
+func (r *RoleRepo) DeleteRole(ctx context.Context, roleID int) error {
+	const op = "Repository.Postgres.RoleRepo.DeleteRole"
+	logger := r.logger.With(slog.String("op", op))
+
+	res, err := r.db.ExecContext(ctx, `DELETE FROM ROLES WHERE id = $1`, roleID)
+	if err != nil {
+		if isForeignKeyViolation(err) {
+			return domain.ErrRoleInUse
+		}
+		logger.Error("error occurred when delete from roles", sl.Err(err))
+		return err
+	}
+
+	rowCount, err := res.RowsAffected()
+	if err != nil {
+		logger.Error("error occurred when get RowsAffected", sl.Err(err))
+		return err
+	}
+
+	if rowCount == 0 {
+		return domain.ErrRoleNotFound
+	}
+
+	return nil
+}
+
+func (r *RoleRepo) GetPermissions(ctx context.Context) ([]domain.Permission, error) {
+	const op = "Repository.Postgres.RoleRepo.GetPermissions"
+	logger := r.logger.With(slog.String("op", op))
+
+	rows, err := r.db.QueryContext(ctx, `SELECT id, name, description FROM PERMISSIONS ORDER BY name`)
+	if err != nil {
+		logger.Error("error occurred when select from permissions", sl.Err(err))
+		return nil, err
+	}
+	defer rows.Close()
+
+	permissions := make([]domain.Permission, 0)
+
+	for rows.Next() {
+		var p domain.Permission
+		if err := rows.Scan(&p.ID, &p.Name, &p.Description); err != nil {
+			logger.Error("error occurred when scan permissions", sl.Err(err))
+			return nil, err
+		}
+		permissions = append(permissions, p)
+	}
+
+	return permissions, rows.Err()
+}
+
+// GetRolePermissions returns the names of the permissions of the role with the given name.
+func (r *RoleRepo) GetRolePermissions(ctx context.Context, role string) ([]string, error) {
+	const op = "Repository.Postgres.RoleRepo.GetRolePermissions"
+	logger := r.logger.With(slog.String("op", op))
+
+	query := `SELECT p.name
+				FROM PERMISSIONS p
+				INNER JOIN ROLE_PERMISSIONS rp on rp.permission_id = p.id
+				INNER JOIN ROLES r on r.id = rp.role_id
+				WHERE r.name = $1
+				ORDER BY p.name`
+
+	rows, err := r.db.QueryContext(ctx, query, role)
+	if err != nil {
+		logger.Error("error occurred when select from permissions", sl.Err(err))
+		return nil, err
+	}
+	defer rows.Close()
+
+	permissions := make([]string, 0)
+
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			logger.Error("error occurred when scan permissions", sl.Err(err))
+			return nil, err
+		}
+		permissions = append(permissions, name)
+	}
+
+	return permissions, rows.Err()
+}
+
+// setRolePermissions grants the permissions to the role, which must have none.
+// An unknown permission name fails with ErrPermissionNotFound.
+// GetUserRole returns the role of the user with its permissions.
+func (r *RoleRepo) GetUserRole(ctx context.Context, userID int) (domain.Role, error) {
+	const op = "Repository.Postgres.RoleRepo.GetUserRole"
+	logger := r.logger.With(slog.String("op", op))
+
+	role, err := scanRole(r.db.QueryRowContext(ctx, selectRolesQuery+`
+				WHERE r.id = (SELECT role_id FROM USERS WHERE id = $1)
+				GROUP BY r.id`, userID))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return domain.Role{}, domain.ErrUserNotFound
+		}
+		logger.Error("error occurred when select from roles", sl.Err(err))
+		return domain.Role{}, err
+	}
+
+	return role, nil
+}
+
+func (r *RoleRepo) setRolePermissions(tx txn, logger *slog.Logger, roleID int, permissions []string) error {
+	if len(permissions) == 0 {
+		return nil
+	}
+
+	query := `INSERT INTO ROLE_PERMISSIONS (role_id, permission_id)
+				SELECT $1, id FROM PERMISSIONS WHERE name = ANY($2)`
+
+	res, err := tx.Exec(query, roleID, pq.Array(permissions))
+	if err != nil {
+		logger.Error("error occurred when insert into role_permissions", sl.Err(err))
+		return err
+	}
+
+	rowCount, err := res.RowsAffected()
+	if err != nil {
+		logger.Error("error occurred when get RowsAffected", sl.Err(err))
+		return err
+	}
+
+	// permissions has no duplicates, so a missing row is an unknown name
+	if rowCount != int64(len(permissions)) {
+		return domain.ErrPermissionNotFound
+	}
+
+	return nil
+}
+
+func scanRole(row interface{ Scan(dest ...any) error }) (domain.Role, error) {
+	var role domain.Role
+
+	err := row.Scan(&role.ID,
+		&role.Name,
+		&role.Description,
+		&role.IsSystem,
+		&role.CreatedAt,
+		pq.Array(&role.Permissions))
+
+	return role, err
+}
+
+func isForeignKeyViolation(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == "23503"
+}
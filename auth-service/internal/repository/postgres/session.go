@@ -0,0 +1,91 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/shamank/edutour-backend/auth-service/internal/domain"
+	"github.com/shamank/edutour-backend/auth-service/pkg/logger/sl"
+	"log/slog"
+)
+
+type SessionRepo struct {
+	db     *sql.DB
+	logger *slog.Logger
+}
+
+func NewSessionRepo(db *sql.DB, logger *slog.Logger) *SessionRepo {
+	return &SessionRepo{
+		db:     db,
+		logger: logger,
+	}
+}
+
+// GetUserSessions returns sessions that still have a usable refresh token, most recently used first.
+func (r *SessionRepo) GetUserSessions(ctx context.Context, userID int) ([]domain.Session, error) {
+	const op = "Repository.Postgres.SessionRepo.GetUserSessions"
+	logger := r.logger.With(slog.String("op", op))
+
+	query := `SELECT s.id, s.user_id, s.family_id, s.ip, s.user_agent, s.browser, s.os, s.device,
+       			s.created_at, s.last_used_at
+				FROM SESSIONS s
+				WHERE s.user_id = $1 AND EXISTS(
+					SELECT 1 FROM REFRESH_TOKENS t
+					WHERE t.family_id = s.family_id AND NOT t.black_list AND t.expire_at > CURRENT_TIMESTAMP)
+				ORDER BY s.last_used_at DESC`
+
+	rows, err := r.db.Query(query, userID)
+	if err != nil {
+		logger.Error("error occurred when select from sessions", sl.Err(err))
+		return nil, err
+	}
+	defer rows.Close()
+
+	sessions := make([]domain.Session, 0)
+
+	for rows.Next() {
+		var s domain.Session
+		if err := rows.Scan(&s.ID,
+			&s.UserID,
+			&s.FamilyID,
+			&s.IP,
+			&s.UserAgent,
+			&s.Browser,
+			&s.OS,
+			&s.Device,
+			&s.CreatedAt,
+			&s.LastUsedAt); err != nil {
+			logger.Error("error occurred when scan sessions", sl.Err(err))
+			return nil, err
+		}
+		sessions = append(sessions, s)
+	}
+
+	return sessions, rows.Err()
+}
+
+// RevokeSession revokes all refresh tokens of the user's session and returns its family.
+func (r *SessionRepo) RevokeSession(ctx context.Context, userID int, sessionID int) (string, error) {
+	const op = "Repository.Postgres.SessionRepo.RevokeSession"
+	logger := r.logger.With(slog.String("op", op))
+
+	var familyID string
+
+	query := `WITH revoked AS (
+					UPDATE REFRESH_TOKENS t
+					SET black_list = true
+					FROM SESSIONS s
+					WHERE s.family_id = t.family_id AND s.id = $1 AND s.user_id = $2 AND NOT t.black_list
+					RETURNING t.family_id)
+				SELECT family_id FROM revoked LIMIT 1`
+
+	if err := r.db.QueryRow(query, sessionID, userID).Scan(&familyID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return "", domain.ErrSessionNotFound
+		}
+		logger.Error("error occurred when update refresh_tokens", sl.Err(err))
+		return "", err
+	}
+
+	return familyID, nil
+}
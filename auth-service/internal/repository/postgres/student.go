@@ -0,0 +1,290 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/lib/pq"
+	"github.com/shamank/edutour-backend/auth-service/internal/domain"
+	"github.com/shamank/edutour-backend/auth-service/pkg/logger/sl"
+	"log/slog"
+	"time"
+)
+
+const selectAcademicDomainsQuery = `SELECT id, domain, university_name, created_at FROM ACADEMIC_DOMAINS`
+
+type StudentRepo struct {
+	db     *sql.DB
+	logger *slog.Logger
+}
+
+func NewStudentRepo(db *sql.DB, logger *slog.Logger) *StudentRepo {
+	return &StudentRepo{
+		db:     db,
+		logger: logger,
+	}
+}
+
+func (r *StudentRepo) GetAcademicDomains(ctx context.Context) ([]domain.AcademicDomain, error) {
+	const op = "Repository.Postgres.StudentRepo.GetAcademicDomains"
+	logger := r.logger.With(slog.String("op", op))
+
+	rows, err := conn(ctx, r.db).Query(selectAcademicDomainsQuery + ` ORDER BY domain`)
+	if err != nil {
+		logger.Error("error occurred when select from academic_domains", sl.Err(err))
+		return nil, err
+	}
+	defer rows.Close()
+
+	domains := make([]domain.AcademicDomain, 0)
+
+	for rows.Next() {
+		d, err := scanAcademicDomain(rows)
+		if err != nil {
+			logger.Error("error occurred when scan academic_domains", sl.Err(err))
+			return nil, err
+		}
+		domains = append(domains, d)
+	}
+
+	return domains, rows.Err()
+}
+
+func (r *StudentRepo) GetAcademicDomain(ctx context.Context, id int) (domain.AcademicDomain, error) {
+	const op = "Repository.Postgres.StudentRepo.GetAcademicDomain"
+	logger := r.logger.With(slog.String("op", op))
+
+	d, err := scanAcademicDomain(conn(ctx, r.db).QueryRow(selectAcademicDomainsQuery+` WHERE id = $1`, id))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return domain.AcademicDomain{}, domain.ErrAcademicDomainNotFound
+		}
+		logger.Error("error occurred when select from academic_domains", sl.Err(err))
+		return domain.AcademicDomain{}, err
+	}
+
+	return d, nil
+}
+
+// FindAcademicDomain returns the longest listed domain among domains, ErrAcademicDomainNotAllowed if none is listed.
+func (r *StudentRepo) FindAcademicDomain(ctx context.Context, domains []string) (domain.AcademicDomain, error) {
+	const op = "Repository.Postgres.StudentRepo.FindAcademicDomain"
+	logger := r.logger.With(slog.String("op", op))
+
+	d, err := scanAcademicDomain(conn(ctx, r.db).QueryRow(selectAcademicDomainsQuery+`
+				WHERE domain = ANY($1)
+				ORDER BY length(domain) DESC
+				LIMIT 1`, pq.Array(domains)))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return domain.AcademicDomain{}, domain.ErrAcademicDomainNotAllowed
+		}
+		logger.Error("error occurred when select from academic_domains", sl.Err(err))
+		return domain.AcademicDomain{}, err
+	}
+
+	return d, nil
+}
+
+func (r *StudentRepo) CreateAcademicDomain(ctx context.Context, d domain.AcademicDomain) (int, error) {
+	const op = "Repository.Postgres.StudentRepo.CreateAcademicDomain"
+	logger := r.logger.With(slog.String("op", op))
+
+	var id int
+
+	query := `INSERT INTO ACADEMIC_DOMAINS (domain, university_name) VALUES ($1, $2) RETURNING id`
+
+	if err := conn(ctx, r.db).QueryRow(query, d.Domain, d.UniversityName).Scan(&id); err != nil {
+		if isUniqueViolation(err) {
+			return 0, domain.ErrAcademicDomainExists
+		}
+		logger.Error("error occurred when insert into academic_domains", sl.Err(err))
+		return 0, err
+	}
+
+	return id, nil
+}
+
+func (r *StudentRepo) UpdateAcademicDomain(ctx context.Context, d domain.AcademicDomain) error {
+	const op = "Repository.Postgres.StudentRepo.UpdateAcademicDomain"
+	logger := r.logger.With(slog.String("op", op))
+
+	return r.exec(ctx, logger, domain.ErrAcademicDomainNotFound,
+		`UPDATE ACADEMIC_DOMAINS SET university_name = $2 WHERE id = $1`, d.ID, d.UniversityName)
+}
+
+func (r *StudentRepo) DeleteAcademicDomain(ctx context.Context, id int) error {
+	const op = "Repository.Postgres.StudentRepo.DeleteAcademicDomain"
+	logger := r.logger.With(slog.String("op", op))
+
+	return r.exec(ctx, logger, domain.ErrAcademicDomainNotFound,
+		`DELETE FROM ACADEMIC_DOMAINS WHERE id = $1`, id)
+}
+
+func (r *StudentRepo) GetStudentStatus(ctx context.Context, userID int) (domain.StudentStatus, error) {
+	const op = "Repository.Postgres.StudentRepo.GetStudentStatus"
+	logger := r.logger.With(slog.String("op", op))
+
+	var status domain.StudentStatus
+	var academicEmail, pendingAcademicEmail sql.NullString
+	var studentUntil sql.NullTime
+
+	query := `SELECT id, username, locale, academic_email, pending_academic_email, student_until
+				FROM USERS
+				WHERE id = $1`
+
+	err := conn(ctx, r.db).QueryRow(query, userID).Scan(&status.UserID,
+		&status.Username,
+		&status.Locale,
+		&academicEmail,
+		&pendingAcademicEmail,
+		&studentUntil)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return domain.StudentStatus{}, domain.ErrUserNotFound
+		}
+		logger.Error("error occurred when select from users", sl.Err(err))
+		return domain.StudentStatus{}, err
+	}
+
+	status.AcademicEmail = academicEmail.String
+	status.PendingAcademicEmail = pendingAcademicEmail.String
+	status.StudentUntil = studentUntil.Time
+
+	return status, nil
+}
+
+// SetPendingAcademicEmail sets the academic email waiting for confirmation,
+// ErrAcademicEmailUsed if another user has confirmed it.
+func (r *StudentRepo) SetPendingAcademicEmail(ctx context.Context, userID int, email string) error {
+	const op = "Repository.Postgres.StudentRepo.SetPendingAcademicEmail"
+	logger := r.logger.With(slog.String("op", op))
+
+	query := `UPDATE USERS
+				SET pending_academic_email = $2
+				WHERE id = $1 AND NOT EXISTS(SELECT 1 FROM USERS WHERE academic_email = $2 AND id <> $1)`
+
+	res, err := conn(ctx, r.db).Exec(query, userID, email)
+	if err != nil {
+		logger.Error("error occurred when update users", sl.Err(err))
+		return err
+	}
+
+	rowCount, err := res.RowsAffected()
+	if err != nil {
+		logger.Error("error occurred when get RowsAffected", sl.Err(err))
+		return err
+	}
+
+	if rowCount == 0 {
+		return domain.ErrAcademicEmailUsed
+	}
+
+	return nil
+}
+
+// ConfirmAcademicEmail uses the confirmation token of the user and makes the pending academic email
+// the confirmed one, with the student status lasting until studentUntil.
+func (r *StudentRepo) ConfirmAcademicEmail(ctx context.Context, userID int, tokenHash string, studentUntil time.Time) error {
+	const op = "Repository.Postgres.StudentRepo.ConfirmAcademicEmail"
+	logger := r.logger.With(slog.String("op", op))
+
+	tx, err := begin(ctx, r.db)
+	if err != nil {
+		logger.Error("fail create r.db.Begin()!", sl.Err(err))
+		return err
+	}
+
+	query := `UPDATE USER_TOKENS
+				SET black_list = true
+				WHERE user_id = $1 AND token_type = $2 AND token_hash = $3 AND NOT black_list
+					AND expire_at > CURRENT_TIMESTAMP`
+
+	res, err := tx.Exec(query, userID, domain.TokenTypeAcademicEmailVerify, tokenHash)
+	if err != nil {
+		tx.Rollback()
+		logger.Error("error occurred when update user_tokens", sl.Err(err))
+		return err
+	}
+
+	rowCount, err := res.RowsAffected()
+	if err != nil {
+		tx.Rollback()
+		logger.Error("error occurred when get RowsAffected", sl.Err(err))
+		return err
+	}
+
+	if rowCount == 0 {
+		tx.Rollback()
+		return domain.ErrInvalidToken
+	}
+
+	query = `UPDATE USERS
+				SET academic_email = pending_academic_email, pending_academic_email = NULL, student_until = to_timestamp($2)
+				WHERE id = $1 AND pending_academic_email IS NOT NULL`
+
+	res, err = tx.Exec(query, userID, studentUntil.Unix())
+	if err != nil {
+		tx.Rollback()
+		if isUniqueViolation(err) {
+			return domain.ErrAcademicEmailUsed
+		}
+		logger.Error("error occurred when update users", sl.Err(err))
+		return err
+	}
+
+	rowCount, err = res.RowsAffected()
+	if err != nil {
+		tx.Rollback()
+		logger.Error("error occurred when get RowsAffected", sl.Err(err))
+		return err
+	}
+
+	if rowCount == 0 {
+		tx.Rollback()
+		return domain.ErrAcademicEmailNotSet
+	}
+
+	return tx.Commit()
+}
+
+// ClearAcademicEmail removes the academic email, confirmed and pending, together with the student status.
+func (r *StudentRepo) ClearAcademicEmail(ctx context.Context, userID int) error {
+	const op = "Repository.Postgres.StudentRepo.ClearAcademicEmail"
+	logger := r.logger.With(slog.String("op", op))
+
+	return r.exec(ctx, logger, domain.ErrUserNotFound,
+		`UPDATE USERS SET academic_email = NULL, pending_academic_email = NULL, student_until = NULL WHERE id = $1`, userID)
+}
+
+// exec runs a statement and reports notFound if it affected no rows.
+func (r *StudentRepo) exec(ctx context.Context, logger *slog.Logger, notFound error, query string, args ...interface{}) error {
+	res, err := conn(ctx, r.db).Exec(query, args...)
+	if err != nil {
+		logger.Error("error occurred when exec query", sl.Err(err))
+		return err
+	}
+
+	rowCount, err := res.RowsAffected()
+	if err != nil {
+		logger.Error("error occurred when get RowsAffected", sl.Err(err))
+		return err
+	}
+
+	if rowCount == 0 {
+		return notFound
+	}
+
+	return nil
+}
+
+func scanAcademicDomain(row interface{ Scan(dest ...any) error }) (domain.AcademicDomain, error) {
+	var d domain.AcademicDomain
+
+	err := row.Scan(&d.ID,
+		&d.Domain,
+		&d.UniversityName,
+		&d.CreatedAt)
+
+	return d, err
+}
@@ -0,0 +1,201 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/shamank/edutour-backend/auth-service/internal/domain"
+	"github.com/shamank/edutour-backend/auth-service/pkg/logger/sl"
+	"log/slog"
+)
+
+type TwoFactorRepo struct {
+	db     *sql.DB
+	logger *slog.Logger
+}
+
+func NewTwoFactorRepo(db *sql.DB, logger *slog.Logger) *TwoFactorRepo {
+	return &TwoFactorRepo{
+		db:     db,
+		logger: logger,
+	}
+}
+
+// SetPendingTOTP stores a new secret waiting for confirmation, replacing an unconfirmed one.
+func (r *TwoFactorRepo) SetPendingTOTP(ctx context.Context, userID int, secret string) error {
+	const op = "Repository.Postgres.TwoFactorRepo.SetPendingTOTP"
+	logger := r.logger.With(slog.String("op", op))
+
+	query := `INSERT INTO USER_TOTP (user_id, secret)
+				VALUES ($1, $2)
+				ON CONFLICT (user_id) DO UPDATE
+				SET secret = EXCLUDED.secret, last_used_step = 0, created_at = CURRENT_TIMESTAMP
+				WHERE NOT USER_TOTP.enabled`
+
+	res, err := r.db.Exec(query, userID, secret)
+	if err != nil {
+		logger.Error("error occurred when upsert user_totp", sl.Err(err))
+		return err
+	}
+
+	rowCount, err := res.RowsAffected()
+	if err != nil {
+		logger.Error("error occurred when get RowsAffected", sl.Err(err))
+		return err
+	}
+
+	if rowCount == 0 {
+		return domain.ErrTwoFactorAlreadyEnabled
+	}
+
+	return nil
+}
+
+func (r *TwoFactorRepo) GetTOTP(ctx context.Context, userID int) (domain.TOTP, error) {
+	const op = "Repository.Postgres.TwoFactorRepo.GetTOTP"
+	logger := r.logger.With(slog.String("op", op))
+
+	var totp domain.TOTP
+
+	query := `SELECT user_id, secret, enabled, last_used_step, created_at
+				FROM USER_TOTP
+				WHERE user_id = $1`
+
+	err := r.db.QueryRow(query, userID).Scan(&totp.UserID,
+		&totp.Secret,
+		&totp.Enabled,
+		&totp.LastUsedStep,
+		&totp.CreatedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return domain.TOTP{}, domain.ErrTwoFactorNotEnabled
+		}
+		logger.Error("error occurred when select from user_totp", sl.Err(err))
+		return domain.TOTP{}, err
+	}
+
+	return totp, nil
+}
+
+// EnableTOTP confirms the pending secret and replaces the user's recovery codes.
+func (r *TwoFactorRepo) EnableTOTP(ctx context.Context, userID int, step int64, recoveryCodeHashes []string) error {
+	const op = "Repository.Postgres.TwoFactorRepo.EnableTOTP"
+	logger := r.logger.With(slog.String("op", op))
+
+	tx, err := r.db.Begin()
+	if err != nil {
+		logger.Error("error occurred when begin transaction", sl.Err(err))
+		return err
+	}
+	defer tx.Rollback()
+
+	query := `UPDATE USER_TOTP
+				SET enabled = true, enabled_at = CURRENT_TIMESTAMP, last_used_step = $2
+				WHERE user_id = $1 AND NOT enabled`
+
+	res, err := tx.Exec(query, userID, step)
+	if err != nil {
+		logger.Error("error occurred when update user_totp", sl.Err(err))
+		return err
+	}
+
+	rowCount, err := res.RowsAffected()
+	if err != nil {
+		logger.Error("error occurred when get RowsAffected", sl.Err(err))
+		return err
+	}
+
+	if rowCount == 0 {
+		return domain.ErrTwoFactorAlreadyEnabled
+	}
+
+	if _, err := tx.Exec(`DELETE FROM RECOVERY_CODES WHERE user_id = $1`, userID); err != nil {
+		logger.Error("error occurred when delete from recovery_codes", sl.Err(err))
+		return err
+	}
+
+	query = `INSERT INTO RECOVERY_CODES (user_id, code_hash)
+				VALUES ($1, $2)`
+
+	for _, codeHash := range recoveryCodeHashes {
+		if _, err := tx.Exec(query, userID, codeHash); err != nil {
+			logger.Error("error occurred when insert into recovery_codes", sl.Err(err))
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+func (r *TwoFactorRepo) DisableTOTP(ctx context.Context, userID int) error {
+	const op = "Repository.Postgres.TwoFactorRepo.DisableTOTP"
+	logger := r.logger.With(slog.String("op", op))
+
+	tx, err := r.db.Begin()
+	if err != nil {
+		logger.Error("error occurred when begin transaction", sl.Err(err))
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`DELETE FROM USER_TOTP WHERE user_id = $1`, userID); err != nil {
+		logger.Error("error occurred when delete from user_totp", sl.Err(err))
+		return err
+	}
+
+	if _, err := tx.Exec(`DELETE FROM RECOVERY_CODES WHERE user_id = $1`, userID); err != nil {
+		logger.Error("error occurred when delete from recovery_codes", sl.Err(err))
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// UpdateTOTPStep records the time step of an accepted code. It returns false if
+// a code of this or a later step was already used, so every code works only once.
+func (r *TwoFactorRepo) UpdateTOTPStep(ctx context.Context, userID int, step int64) (bool, error) {
+	const op = "Repository.Postgres.TwoFactorRepo.UpdateTOTPStep"
+	logger := r.logger.With(slog.String("op", op))
+
+	query := `UPDATE USER_TOTP
+				SET last_used_step = $2
+				WHERE user_id = $1 AND last_used_step < $2`
+
+	res, err := r.db.Exec(query, userID, step)
+	if err != nil {
+		logger.Error("error occurred when update user_totp", sl.Err(err))
+		return false, err
+	}
+
+	rowCount, err := res.RowsAffected()
+	if err != nil {
+		logger.Error("error occurred when get RowsAffected", sl.Err(err))
+		return false, err
+	}
+
+	return rowCount > 0, nil
+}
+
+// UseRecoveryCode marks an unused recovery code as used. It returns false if there is no such code.
+func (r *TwoFactorRepo) UseRecoveryCode(ctx context.Context, userID int, codeHash string) (bool, error) {
+	const op = "Repository.Postgres.TwoFactorRepo.UseRecoveryCode"
+	logger := r.logger.With(slog.String("op", op))
+
+	query := `UPDATE RECOVERY_CODES
+				SET used_at = CURRENT_TIMESTAMP
+				WHERE user_id = $1 AND code_hash = $2 AND used_at IS NULL`
+
+	res, err := r.db.Exec(query, userID, codeHash)
+	if err != nil {
+		logger.Error("error occurred when update recovery_codes", sl.Err(err))
+		return false, err
+	}
+
+	rowCount, err := res.RowsAffected()
+	if err != nil {
+		logger.Error("error occurred when get RowsAffected", sl.Err(err))
+		return false, err
+	}
+
+	return rowCount > 0, nil
+}
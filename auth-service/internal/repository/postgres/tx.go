@@ -0,0 +1,97 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"github.com/shamank/edutour-backend/auth-service/pkg/logger/sl"
+	"log/slog"
+)
+
+type txKey struct{}
+
+// Transactor runs calls to several repositories in one transaction.
+type Transactor struct {
+	db     *sql.DB
+	logger *slog.Logger
+}
+
+func NewTransactor(db *sql.DB, logger *slog.Logger) *Transactor {
+	return &Transactor{
+		db:     db,
+		logger: logger,
+	}
+}
+
+// WithinTransaction runs fn in a transaction, which is committed if fn returns nil.
+// Repository methods called with the ctx passed to fn join the transaction, and so does a nested WithinTransaction.
+func (t *Transactor) WithinTransaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	const op = "Repository.Postgres.Transactor.WithinTransaction"
+	logger := t.logger.With(slog.String("op", op))
+
+	if _, ok := ctx.Value(txKey{}).(*sql.Tx); ok {
+		return fn(ctx)
+	}
+
+	tx, err := t.db.BeginTx(ctx, nil)
+	if err != nil {
+		logger.Error("fail create r.db.Begin()!", sl.Err(err))
+		return err
+	}
+
+	if err := fn(context.WithValue(ctx, txKey{}, tx)); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// executor is what *sql.DB and *sql.Tx have in common.
+type executor interface {
+	Exec(query string, args ...any) (sql.Result, error)
+	Query(query string, args ...any) (*sql.Rows, error)
+	QueryRow(query string, args ...any) *sql.Row
+}
+
+// conn returns the transaction ctx is in, or db outside of one.
+func conn(ctx context.Context, db *sql.DB) executor {
+	if tx, ok := ctx.Value(txKey{}).(*sql.Tx); ok {
+		return tx
+	}
+	return db
+}
+
+// txn is a transaction of a single repository method. Inside WithinTransaction it is the outer
+// transaction, and committing or rolling it back is left to WithinTransaction.
+type txn struct {
+	*sql.Tx
+	joined bool
+}
+
+func (t txn) Commit() error {
+	if t.joined {
+		return nil
+	}
+	return t.Tx.Commit()
+}
+
+func (t txn) Rollback() error {
+	if t.joined {
+		return nil
+	}
+	return t.Tx.Rollback()
+}
+
+// begin starts a transaction, or joins the one ctx is in.
+func begin(ctx context.Context, db *sql.DB) (txn, error) {
+	if tx, ok := ctx.Value(txKey{}).(*sql.Tx); ok {
+		return txn{Tx: tx, joined: true}, nil
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return txn{}, err
+	}
+
+	return txn{Tx: tx}, nil
+}
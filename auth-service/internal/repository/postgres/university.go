@@ -0,0 +1,293 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/shamank/edutour-backend/auth-service/internal/domain"
+	"github.com/shamank/edutour-backend/auth-service/pkg/logger/sl"
+	"log/slog"
+	"strconv"
+)
+
+const (
+	selectApplicationsQuery = `SELECT a.id, a.user_id, u.username, u.email, u.locale, COALESCE(a.organization_id, 0),
+					a.university_name, a.position, a.message, a.status, COALESCE(a.reviewer_id, 0),
+					a.review_comment, a.reviewed_at, a.created_at
+				FROM UNIVERSITY_APPLICATIONS a
+				INNER JOIN USERS u on u.id = a.user_id`
+
+	selectDocumentsQuery = `SELECT id, application_id, file_name, content_type, size, storage_key, created_at
+				FROM APPLICATION_DOCUMENTS`
+)
+
+type UniversityRepo struct {
+	db     *sql.DB
+	logger *slog.Logger
+}
+
+func NewUniversityRepo(db *sql.DB, logger *slog.Logger) *UniversityRepo {
+	return &UniversityRepo{
+		db:     db,
+		logger: logger,
+	}
+}
+
+// CreateApplication creates a pending application and returns its id.
+func (r *UniversityRepo) CreateApplication(ctx context.Context, application domain.UniversityApplication) (int, error) {
+	const op = "Repository.Postgres.UniversityRepo.CreateApplication"
+	logger := r.logger.With(slog.String("op", op))
+
+	var id int
+
+	query := `INSERT INTO UNIVERSITY_APPLICATIONS (user_id, organization_id, university_name, position, message)
+				VALUES ($1, NULLIF($2, 0), $3, $4, $5)
+				RETURNING id`
+
+	err := conn(ctx, r.db).QueryRow(query, application.UserID, application.OrganizationID, application.UniversityName,
+		application.Position, application.Message).Scan(&id)
+	if err != nil {
+		if isUniqueViolation(err) {
+			return 0, domain.ErrApplicationPending
+		}
+		logger.Error("error occurred when insert into university_applications", sl.Err(err))
+		return 0, err
+	}
+
+	return id, nil
+}
+
+func (r *UniversityRepo) AddDocument(ctx context.Context, document domain.ApplicationDocument) (int, error) {
+	const op = "Repository.Postgres.UniversityRepo.AddDocument"
+	logger := r.logger.With(slog.String("op", op))
+
+	var id int
+
+	query := `INSERT INTO APPLICATION_DOCUMENTS (application_id, file_name, content_type, size, storage_key)
+				VALUES ($1, $2, $3, $4, $5)
+				RETURNING id`
+
+	err := conn(ctx, r.db).QueryRow(query, document.ApplicationID, document.FileName, document.ContentType,
+		document.Size, document.StorageKey).Scan(&id)
+	if err != nil {
+		logger.Error("error occurred when insert into application_documents", sl.Err(err))
+		return 0, err
+	}
+
+	return id, nil
+}
+
+// GetApplication returns the application with its documents.
+func (r *UniversityRepo) GetApplication(ctx context.Context, applicationID int) (domain.UniversityApplication, error) {
+	const op = "Repository.Postgres.UniversityRepo.GetApplication"
+	logger := r.logger.With(slog.String("op", op))
+
+	a, err := scanApplication(conn(ctx, r.db).QueryRow(selectApplicationsQuery+` WHERE a.id = $1`, applicationID))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return domain.UniversityApplication{}, domain.ErrApplicationNotFound
+		}
+		logger.Error("error occurred when select from university_applications", sl.Err(err))
+		return domain.UniversityApplication{}, err
+	}
+
+	rows, err := conn(ctx, r.db).Query(selectDocumentsQuery+`
+				WHERE application_id = $1
+				ORDER BY id`, applicationID)
+	if err != nil {
+		logger.Error("error occurred when select from application_documents", sl.Err(err))
+		return domain.UniversityApplication{}, err
+	}
+	defer rows.Close()
+
+	a.Documents = make([]domain.ApplicationDocument, 0)
+
+	for rows.Next() {
+		d, err := scanDocument(rows)
+		if err != nil {
+			logger.Error("error occurred when scan application_documents", sl.Err(err))
+			return domain.UniversityApplication{}, err
+		}
+		a.Documents = append(a.Documents, d)
+	}
+
+	return a, rows.Err()
+}
+
+// GetApplications returns applications without their documents, newest first.
+// Zero UserID and empty Status don't filter.
+func (r *UniversityRepo) GetApplications(ctx context.Context, userID int, status string) ([]domain.UniversityApplication, error) {
+	const op = "Repository.Postgres.UniversityRepo.GetApplications"
+	logger := r.logger.With(slog.String("op", op))
+
+	query := selectApplicationsQuery + ` WHERE true`
+	args := make([]interface{}, 0, 2)
+
+	if userID != 0 {
+		args = append(args, userID)
+		query += ` AND a.user_id = $` + strconv.Itoa(len(args))
+	}
+	if status != "" {
+		args = append(args, status)
+		query += ` AND a.status = $` + strconv.Itoa(len(args))
+	}
+
+	rows, err := conn(ctx, r.db).Query(query+` ORDER BY a.id DESC`, args...)
+	if err != nil {
+		logger.Error("error occurred when select from university_applications", sl.Err(err))
+		return nil, err
+	}
+	defer rows.Close()
+
+	applications := make([]domain.UniversityApplication, 0)
+
+	for rows.Next() {
+		a, err := scanApplication(rows)
+		if err != nil {
+			logger.Error("error occurred when scan university_applications", sl.Err(err))
+			return nil, err
+		}
+		applications = append(applications, a)
+	}
+
+	return applications, rows.Err()
+}
+
+func (r *UniversityRepo) GetDocument(ctx context.Context, applicationID int, documentID int) (domain.ApplicationDocument, error) {
+	const op = "Repository.Postgres.UniversityRepo.GetDocument"
+	logger := r.logger.With(slog.String("op", op))
+
+	d, err := scanDocument(conn(ctx, r.db).QueryRow(selectDocumentsQuery+`
+				WHERE application_id = $1 AND id = $2`, applicationID, documentID))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return domain.ApplicationDocument{}, domain.ErrDocumentNotFound
+		}
+		logger.Error("error occurred when select from application_documents", sl.Err(err))
+		return domain.ApplicationDocument{}, err
+	}
+
+	return d, nil
+}
+
+// ApproveApplication approves a pending application, gives the applicant the university role
+// unless their role has been changed from the default one since applying,
+// and verifies the organization attached to the application.
+func (r *UniversityRepo) ApproveApplication(ctx context.Context, applicationID int, reviewerID int, comment string) error {
+	const op = "Repository.Postgres.UniversityRepo.ApproveApplication"
+	logger := r.logger.With(slog.String("op", op))
+
+	tx, err := begin(ctx, r.db)
+	if err != nil {
+		logger.Error("fail create r.db.Begin()!", sl.Err(err))
+		return err
+	}
+
+	var userID, orgID int
+
+	query := `UPDATE UNIVERSITY_APPLICATIONS
+				SET status = $2, reviewer_id = $3, review_comment = $4, reviewed_at = CURRENT_TIMESTAMP
+				WHERE id = $1 AND status = $5
+				RETURNING user_id, COALESCE(organization_id, 0)`
+
+	err = tx.QueryRow(query, applicationID, domain.ApplicationStatusApproved, reviewerID, comment,
+		domain.ApplicationStatusPending).Scan(&userID, &orgID)
+	if err != nil {
+		tx.Rollback()
+		if errors.Is(err, sql.ErrNoRows) {
+			return domain.ErrApplicationReviewed
+		}
+		logger.Error("error occurred when update university_applications", sl.Err(err))
+		return err
+	}
+
+	query = `UPDATE USERS SET role_id = (SELECT id FROM ROLES WHERE name = $2)
+				WHERE id = $1 AND role_id = (SELECT id FROM ROLES WHERE name = $3)`
+
+	if _, err := tx.Exec(query, userID, domain.UniversityRoleName, domain.UserRoleName); err != nil {
+		tx.Rollback()
+		logger.Error("error occurred when update users", sl.Err(err))
+		return err
+	}
+
+	if orgID != 0 {
+		query = `UPDATE ORGANIZATIONS SET verified_at = CURRENT_TIMESTAMP WHERE id = $1 AND verified_at IS NULL`
+
+		if _, err := tx.Exec(query, orgID); err != nil {
+			tx.Rollback()
+			if isUniqueViolation(err) {
+				return domain.ErrOrganizationExists
+			}
+			logger.Error("error occurred when update organizations", sl.Err(err))
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+func (r *UniversityRepo) RejectApplication(ctx context.Context, applicationID int, reviewerID int, comment string) error {
+	const op = "Repository.Postgres.UniversityRepo.RejectApplication"
+	logger := r.logger.With(slog.String("op", op))
+
+	query := `UPDATE UNIVERSITY_APPLICATIONS
+				SET status = $2, reviewer_id = $3, review_comment = $4, reviewed_at = CURRENT_TIMESTAMP
+				WHERE id = $1 AND status = $5`
+
+	res, err := conn(ctx, r.db).Exec(query, applicationID, domain.ApplicationStatusRejected, reviewerID, comment,
+		domain.ApplicationStatusPending)
+	if err != nil {
+		logger.Error("error occurred when update university_applications", sl.Err(err))
+		return err
+	}
+
+	rowCount, err := res.RowsAffected()
+	if err != nil {
+		logger.Error("error occurred when get RowsAffected", sl.Err(err))
+		return err
+	}
+
+	if rowCount == 0 {
+		return domain.ErrApplicationReviewed
+	}
+
+	return nil
+}
+
+func scanApplication(row interface{ Scan(dest ...any) error }) (domain.UniversityApplication, error) {
+	var a domain.UniversityApplication
+	var reviewedAt sql.NullTime
+
+	err := row.Scan(&a.ID,
+		&a.UserID,
+		&a.Username,
+		&a.Email,
+		&a.Locale,
+		&a.OrganizationID,
+		&a.UniversityName,
+		&a.Position,
+		&a.Message,
+		&a.Status,
+		&a.ReviewerID,
+		&a.ReviewComment,
+		&reviewedAt,
+		&a.CreatedAt)
+
+	a.ReviewedAt = reviewedAt.Time
+
+	return a, err
+}
+
+func scanDocument(row interface{ Scan(dest ...any) error }) (domain.ApplicationDocument, error) {
+	var d domain.ApplicationDocument
+
+	err := row.Scan(&d.ID,
+		&d.ApplicationID,
+		&d.FileName,
+		&d.ContentType,
+		&d.Size,
+		&d.StorageKey,
+		&d.CreatedAt)
+
+	return d, err
+}
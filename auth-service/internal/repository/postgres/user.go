@@ -28,12 +28,14 @@ func (r *UserRepo) GetUserProfile(ctx context.Context, userName string) (domain.
 	logger := r.logger.With(slog.String("op", op))
 
 	var user domain.User
+	var studentUntil sql.NullTime
 
 	query := `SELECT u.username, COALESCE(u.first_name, '') as first_name,
        COALESCE(u.last_name, '') as last_name, COALESCE(u.middle_name, '') as middle_name,
-       COALESCE(u.avatar, '') as avatar, r.id, r.name
+       COALESCE(u.avatar, '') as avatar, r.id, r.name,
+       CASE WHEN u.academic_email IS NOT NULL THEN u.student_until END as student_until
 				FROM users u
-				INNER JOIN role_types r on r.id = u.role_id
+				INNER JOIN roles r on r.id = u.role_id
 				WHERE u.username = $1`
 
 	err := r.db.QueryRow(query, userName).Scan(
@@ -43,13 +45,16 @@ func (r *UserRepo) GetUserProfile(ctx context.Context, userName string) (domain.
 		&user.MiddleName,
 		&user.Avatar,
 		&user.Role.ID,
-		&user.Role.Name)
+		&user.Role.Name,
+		&studentUntil)
 
 	if err != nil {
 		logger.Error("error occurred when select user", sl.Err(err))
 		return domain.User{}, err
 	}
 
+	user.StudentUntil = studentUntil.Time
+
 	return user, nil
 }
 
@@ -85,6 +90,12 @@ func (r *UserRepo) UpdateUserProfile(ctx context.Context, user domain.User) erro
 		argID++
 	}
 
+	if user.Locale != "" {
+		setValues = append(setValues, fmt.Sprintf("locale=$%d", argID))
+		args = append(args, user.Locale)
+		argID++
+	}
+
 	setQuery := strings.Join(setValues, ", ")
 
 	query := fmt.Sprintf(`UPDATE users SET %s WHERE username=$%d`, setQuery, argID)
@@ -100,18 +111,40 @@ func (r *UserRepo) UpdateUserProfile(ctx context.Context, user domain.User) erro
 	return nil
 }
 
-func (r *UserRepo) ChangeUserPassword(ctx context.Context, userID int, oldPasswordHash, newPasswordHash string) error {
-	const op = "Repository.Postgres.UserRepo.UpdateUserProfile"
+func (r *UserRepo) GetPasswordHash(ctx context.Context, userID int) (string, error) {
+	const op = "Repository.Postgres.UserRepo.GetPasswordHash"
+	logger := r.logger.With(slog.String("op", op))
+
+	var passwordHash string
+
+	query := `SELECT password_hash FROM users WHERE id = $1`
+
+	err := r.db.QueryRow(query, userID).Scan(&passwordHash)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return "", domain.ErrUserNotFound
+		}
+		logger.Error("error occurred when select from users", sl.Err(err))
+		logger.Debug(fmt.Sprintf("userID: %d", userID))
+
+		return "", err
+	}
+
+	return passwordHash, nil
+}
+
+func (r *UserRepo) ChangeUserPassword(ctx context.Context, userID int, passwordHash string) error {
+	const op = "Repository.Postgres.UserRepo.ChangeUserPassword"
 	logger := r.logger.With(slog.String("op", op))
 
 	query := `UPDATE users
 				SET password_hash = $1
-					WHERE id = $2 AND password_hash = $3`
+					WHERE id = $2`
 
-	res, err := r.db.Exec(query, newPasswordHash, userID, oldPasswordHash)
+	res, err := r.db.Exec(query, passwordHash, userID)
 	if err != nil {
 		logger.Error("error occured when update users", sl.Err(err))
-		logger.Debug(fmt.Sprintf("userID: %d"))
+		logger.Debug(fmt.Sprintf("userID: %d", userID))
 
 		return err
 	}
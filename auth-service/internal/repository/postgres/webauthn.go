@@ -0,0 +1,222 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/lib/pq"
+	"github.com/shamank/edutour-backend/auth-service/internal/domain"
+	"github.com/shamank/edutour-backend/auth-service/pkg/logger/sl"
+	"log/slog"
+)
+
+type WebAuthnRepo struct {
+	db     *sql.DB
+	logger *slog.Logger
+}
+
+func NewWebAuthnRepo(db *sql.DB, logger *slog.Logger) *WebAuthnRepo {
+	return &WebAuthnRepo{
+		db:     db,
+		logger: logger,
+	}
+}
+
+func (r *WebAuthnRepo) CreateSession(ctx context.Context, session domain.WebAuthnSession) error {
+	const op = "Repository.Postgres.WebAuthnRepo.CreateSession"
+	logger := r.logger.With(slog.String("op", op))
+
+	query := `INSERT INTO WEBAUTHN_SESSIONS (session_hash, ceremony, user_id, data, expire_at)
+				VALUES ($1, $2, $3, $4, to_timestamp($5))`
+
+	var userID sql.NullInt64
+	if session.UserID != 0 {
+		userID = sql.NullInt64{Int64: int64(session.UserID), Valid: true}
+	}
+
+	_, err := r.db.Exec(query, session.SessionHash, session.Ceremony, userID, session.Data, session.ExpiresAt)
+	if err != nil {
+		logger.Error("error occurred when insert into webauthn_sessions", sl.Err(err))
+		return err
+	}
+
+	return nil
+}
+
+// TakeSession deletes and returns an unexpired ceremony, so every ceremony can be finished only once.
+func (r *WebAuthnRepo) TakeSession(ctx context.Context, sessionHash string, ceremony string) (domain.WebAuthnSession, error) {
+	const op = "Repository.Postgres.WebAuthnRepo.TakeSession"
+	logger := r.logger.With(slog.String("op", op))
+
+	var session domain.WebAuthnSession
+	var userID sql.NullInt64
+
+	query := `DELETE FROM WEBAUTHN_SESSIONS
+				WHERE session_hash = $1 AND ceremony = $2 AND expire_at > CURRENT_TIMESTAMP
+				RETURNING session_hash, ceremony, user_id, data, extract(epoch from expire_at)::bigint`
+
+	err := r.db.QueryRow(query, sessionHash, ceremony).Scan(&session.SessionHash,
+		&session.Ceremony,
+		&userID,
+		&session.Data,
+		&session.ExpiresAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return domain.WebAuthnSession{}, domain.ErrWebAuthnSessionNotFound
+		}
+		logger.Error("error occurred when delete from webauthn_sessions", sl.Err(err))
+		return domain.WebAuthnSession{}, err
+	}
+
+	session.UserID = int(userID.Int64)
+
+	return session, nil
+}
+
+func (r *WebAuthnRepo) GetUser(ctx context.Context, userID int) (domain.User, error) {
+	const op = "Repository.Postgres.WebAuthnRepo.GetUser"
+	logger := r.logger.With(slog.String("op", op))
+
+	var user domain.User
+
+	query := `SELECT u.id, u.username, u.email, u.role_id, r.name
+				FROM USERS u
+				INNER JOIN ROLES r on r.id = u.role_id
+				WHERE u.id = $1`
+
+	err := r.db.QueryRow(query, userID).Scan(&user.ID,
+		&user.Username,
+		&user.Email,
+		&user.Role.ID,
+		&user.Role.Name)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return domain.User{}, domain.ErrUserNotFound
+		}
+		logger.Error("error occurred when select from users", sl.Err(err))
+		return domain.User{}, err
+	}
+
+	return user, nil
+}
+
+func (r *WebAuthnRepo) GetCredentials(ctx context.Context, userID int) ([]domain.WebAuthnCredential, error) {
+	const op = "Repository.Postgres.WebAuthnRepo.GetCredentials"
+	logger := r.logger.With(slog.String("op", op))
+
+	query := `SELECT id, user_id, credential_id, public_key, attestation_type, aaguid, sign_count, transports,
+       			backup_eligible, backup_state, name, created_at, last_used_at
+				FROM WEBAUTHN_CREDENTIALS
+				WHERE user_id = $1
+				ORDER BY created_at`
+
+	rows, err := r.db.Query(query, userID)
+	if err != nil {
+		logger.Error("error occurred when select from webauthn_credentials", sl.Err(err))
+		return nil, err
+	}
+	defer rows.Close()
+
+	credentials := make([]domain.WebAuthnCredential, 0)
+
+	for rows.Next() {
+		var c domain.WebAuthnCredential
+		if err := rows.Scan(&c.ID,
+			&c.UserID,
+			&c.CredentialID,
+			&c.PublicKey,
+			&c.AttestationType,
+			&c.AAGUID,
+			&c.SignCount,
+			pq.Array(&c.Transports),
+			&c.BackupEligible,
+			&c.BackupState,
+			&c.Name,
+			&c.CreatedAt,
+			&c.LastUsedAt); err != nil {
+			logger.Error("error occurred when scan webauthn_credentials", sl.Err(err))
+			return nil, err
+		}
+		credentials = append(credentials, c)
+	}
+
+	return credentials, rows.Err()
+}
+
+func (r *WebAuthnRepo) CreateCredential(ctx context.Context, credential domain.WebAuthnCredential) (int, error) {
+	const op = "Repository.Postgres.WebAuthnRepo.CreateCredential"
+	logger := r.logger.With(slog.String("op", op))
+
+	var id int
+
+	query := `INSERT INTO WEBAUTHN_CREDENTIALS (user_id, credential_id, public_key, attestation_type, aaguid,
+                                  sign_count, transports, backup_eligible, backup_state, name)
+				VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+				ON CONFLICT (credential_id) DO NOTHING
+				RETURNING id`
+
+	err := r.db.QueryRow(query,
+		credential.UserID,
+		credential.CredentialID,
+		credential.PublicKey,
+		credential.AttestationType,
+		credential.AAGUID,
+		credential.SignCount,
+		pq.Array(credential.Transports),
+		credential.BackupEligible,
+		credential.BackupState,
+		credential.Name).Scan(&id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, domain.ErrWebAuthnCredentialExists
+		}
+		logger.Error("error occurred when insert into webauthn_credentials", sl.Err(err))
+		return 0, err
+	}
+
+	return id, nil
+}
+
+// UpdateCredentialUsage stores the state an authenticator reported in a successful assertion.
+func (r *WebAuthnRepo) UpdateCredentialUsage(ctx context.Context, credentialID []byte, signCount uint32, backupState bool) error {
+	const op = "Repository.Postgres.WebAuthnRepo.UpdateCredentialUsage"
+	logger := r.logger.With(slog.String("op", op))
+
+	query := `UPDATE WEBAUTHN_CREDENTIALS
+				SET sign_count = $2, backup_state = $3, last_used_at = CURRENT_TIMESTAMP
+				WHERE credential_id = $1`
+
+	_, err := r.db.Exec(query, credentialID, signCount, backupState)
+	if err != nil {
+		logger.Error("error occurred when update webauthn_credentials", sl.Err(err))
+		return err
+	}
+
+	return nil
+}
+
+func (r *WebAuthnRepo) DeleteCredential(ctx context.Context, userID int, id int) error {
+	const op = "Repository.Postgres.WebAuthnRepo.DeleteCredential"
+	logger := r.logger.With(slog.String("op", op))
+
+	query := `DELETE FROM WEBAUTHN_CREDENTIALS
+				WHERE id = $1 AND user_id = $2`
+
+	res, err := r.db.Exec(query, id, userID)
+	if err != nil {
+		logger.Error("error occurred when delete from webauthn_credentials", sl.Err(err))
+		return err
+	}
+
+	rowCount, err := res.RowsAffected()
+	if err != nil {
+		logger.Error("error occurred when get RowsAffected", sl.Err(err))
+		return err
+	}
+
+	if rowCount == 0 {
+		return domain.ErrWebAuthnCredentialNotFound
+	}
+
+	return nil
+}
@@ -6,6 +6,7 @@ import (
 	"github.com/shamank/edutour-backend/auth-service/internal/domain"
 	"github.com/shamank/edutour-backend/auth-service/internal/repository/postgres"
 	"log/slog"
+	"time"
 )
 
 type RefreshToken struct {
@@ -14,18 +15,40 @@ type RefreshToken struct {
 }
 
 type Authorization interface {
-	Create(ctx context.Context, user domain.User, confirmToken string, expireAt int64) error
-	GetByCredentials(ctx context.Context, email string, passwordHash string) (domain.User, error)
-	GetByUsername(ctx context.Context, username string, passwordHash string) (domain.User, error)
+	Create(ctx context.Context, user domain.User, confirmTokenHash string, expireAt int64) (int, error)
+	GetByCredentials(ctx context.Context, email string) (domain.User, error)
+	GetByUsername(ctx context.Context, username string) (domain.User, error)
+	UpdatePasswordHash(ctx context.Context, userID int, passwordHash string) error
 
-	SetTokenResetPassword(ctx context.Context, email string, token string, expireAt int64) error
-	ConfirmResetPassword(ctx context.Context, token string, passwordHash string) error
+	SetTokenResetPassword(ctx context.Context, email string, tokenHash string, expireAt int64) (int, error)
+	ConfirmResetPassword(ctx context.Context, tokenType int, tokenHash string, passwordHash string) (int, error)
 
-	ConfirmUser(ctx context.Context, confirmToken string) error
+	ConfirmUser(ctx context.Context, tokenType int, confirmTokenHash string) error
 
-	GetByRefreshToken(ctx context.Context, refreshToken string) (domain.User, error)
+	RotateRefreshToken(ctx context.Context, refreshTokenHash string, newToken domain.RefreshToken, gracePeriod time.Duration, client domain.Session) (domain.User, string, error)
+
+	SetRefreshToken(ctx context.Context, userID int, refreshInput domain.RefreshToken, session domain.Session) error
+	RevokeRefreshToken(ctx context.Context, refreshTokenHash string) error
+	RevokeAllRefreshTokens(ctx context.Context, userID int) error
+
+	CreateUserToken(ctx context.Context, userID int, tokenType int, tokenHash string, expireAt int64) error
+	GetUserByToken(ctx context.Context, tokenType int, tokenHash string, maxAttempts int) (domain.User, error)
+	GetUserCode(ctx context.Context, userID int, tokenType int) (domain.UserCode, error)
+	AddUserTokenAttempt(ctx context.Context, tokenType int, tokenHash string) error
+	RevokeUserToken(ctx context.Context, tokenType int, tokenHash string) error
+	RevokeUserTokensOfType(ctx context.Context, userID int, tokenType int) error
+
+	HasRecentUserToken(ctx context.Context, userID int, tokenType int, period time.Duration) (bool, error)
+	CountUserTokens(ctx context.Context, userID int, tokenType int) (int, error)
+	DeleteUnconfirmedUsers(ctx context.Context, ttl time.Duration) ([]domain.User, error)
+
+	SetPendingPhone(ctx context.Context, userID int, phone string) error
+	GetPendingPhone(ctx context.Context, userID int) (string, error)
+	ConfirmPhone(ctx context.Context, userID int, codeHash string) error
+	GetByPhone(ctx context.Context, phone string) (domain.User, error)
+
+	IsUserBanned(ctx context.Context, userID int) (bool, error)
 
-	SetRefreshToken(ctx context.Context, userID int, refreshInput domain.RefreshToken) error
 	Verify(ctx context.Context, userID int) error
 	GetFullUserInfo(ctx context.Context, userID int) (domain.User, error)
 }
@@ -33,7 +56,138 @@ type Authorization interface {
 type Users interface {
 	GetUserProfile(ctx context.Context, userName string) (domain.User, error)
 	UpdateUserProfile(ctx context.Context, user domain.User) error
-	ChangeUserPassword(ctx context.Context, userID int, oldPasswordHash, newPasswordHash string) error
+	GetPasswordHash(ctx context.Context, userID int) (string, error)
+	ChangeUserPassword(ctx context.Context, userID int, passwordHash string) error
+}
+
+type Admin interface {
+	GetUsers(ctx context.Context, filter domain.UserFilter) ([]domain.User, error)
+	GetUser(ctx context.Context, userID int) (domain.User, error)
+	SetUserRole(ctx context.Context, userID int, role string) error
+	ConfirmUser(ctx context.Context, userID int) error
+	BanUser(ctx context.Context, userID int, reason string) error
+	UnbanUser(ctx context.Context, userID int) error
+	DeleteUser(ctx context.Context, userID int) error
+}
+
+type Roles interface {
+	GetRoles(ctx context.Context) ([]domain.Role, error)
+	GetRole(ctx context.Context, roleID int) (domain.Role, error)
+	CreateRole(ctx context.Context, role domain.Role) (int, error)
+	UpdateRole(ctx context.Context, role domain.Role) error
+	DeleteRole(ctx context.Context, roleID int) error
+	GetPermissions(ctx context.Context) ([]domain.Permission, error)
+	GetRolePermissions(ctx context.Context, role string) ([]string, error)
+	GetUserRole(ctx context.Context, userID int) (domain.Role, error)
+}
+
+type Organizations interface {
+	CreateOrganization(ctx context.Context, org domain.Organization, ownerID int) (int, error)
+	GetOrganization(ctx context.Context, orgID int) (domain.Organization, error)
+	UpdateOrganization(ctx context.Context, org domain.Organization) error
+	DeleteOrganization(ctx context.Context, orgID int) error
+	LockOrganization(ctx context.Context, orgID int) error
+
+	GetMembership(ctx context.Context, userID int) (domain.OrganizationMember, error)
+	GetMembers(ctx context.Context, orgID int) ([]domain.OrganizationMember, error)
+	AddMember(ctx context.Context, member domain.OrganizationMember) error
+	SetMemberRole(ctx context.Context, orgID int, userID int, role string) error
+	RemoveMember(ctx context.Context, orgID int, userID int) error
+	CountOwners(ctx context.Context, orgID int) (int, error)
+
+	CreateInvitation(ctx context.Context, invitation domain.OrganizationInvitation) (int, error)
+	GetInvitations(ctx context.Context, orgID int) ([]domain.OrganizationInvitation, error)
+	GetInvitationByToken(ctx context.Context, tokenHash string) (domain.OrganizationInvitation, error)
+	AnswerInvitation(ctx context.Context, invitationID int, status string) error
+	RevokeInvitation(ctx context.Context, orgID int, invitationID int) error
+}
+
+type Universities interface {
+	CreateApplication(ctx context.Context, application domain.UniversityApplication) (int, error)
+	AddDocument(ctx context.Context, document domain.ApplicationDocument) (int, error)
+	GetApplication(ctx context.Context, applicationID int) (domain.UniversityApplication, error)
+	GetApplications(ctx context.Context, userID int, status string) ([]domain.UniversityApplication, error)
+	GetDocument(ctx context.Context, applicationID int, documentID int) (domain.ApplicationDocument, error)
+	ApproveApplication(ctx context.Context, applicationID int, reviewerID int, comment string) error
+	RejectApplication(ctx context.Context, applicationID int, reviewerID int, comment string) error
+}
+
+type Students interface {
+	GetAcademicDomains(ctx context.Context) ([]domain.AcademicDomain, error)
+	GetAcademicDomain(ctx context.Context, id int) (domain.AcademicDomain, error)
+	FindAcademicDomain(ctx context.Context, domains []string) (domain.AcademicDomain, error)
+	CreateAcademicDomain(ctx context.Context, d domain.AcademicDomain) (int, error)
+	UpdateAcademicDomain(ctx context.Context, d domain.AcademicDomain) error
+	DeleteAcademicDomain(ctx context.Context, id int) error
+
+	GetStudentStatus(ctx context.Context, userID int) (domain.StudentStatus, error)
+	SetPendingAcademicEmail(ctx context.Context, userID int, email string) error
+	ConfirmAcademicEmail(ctx context.Context, userID int, tokenHash string, studentUntil time.Time) error
+	ClearAcademicEmail(ctx context.Context, userID int) error
+}
+
+type Sessions interface {
+	GetUserSessions(ctx context.Context, userID int) ([]domain.Session, error)
+	// RevokeSession returns the family of refresh tokens of the session, which access tokens carry as sid.
+	RevokeSession(ctx context.Context, userID int, sessionID int) (string, error)
+}
+
+type Revocations interface {
+	RevokeAccessToken(ctx context.Context, tokenID string, userID int, expireAt time.Time) error
+	IsAccessTokenRevoked(ctx context.Context, tokenID string, sessionID string) (bool, error)
+	SetTokensValidAfter(ctx context.Context, userID int, validAfter time.Time) error
+	SetRoleTokensValidAfter(ctx context.Context, roleID int, validAfter time.Time) error
+	GetTokensValidAfter(ctx context.Context, userID int) (time.Time, error)
+}
+
+type TwoFactor interface {
+	SetPendingTOTP(ctx context.Context, userID int, secret string) error
+	GetTOTP(ctx context.Context, userID int) (domain.TOTP, error)
+	EnableTOTP(ctx context.Context, userID int, step int64, recoveryCodeHashes []string) error
+	DisableTOTP(ctx context.Context, userID int) error
+	UpdateTOTPStep(ctx context.Context, userID int, step int64) (bool, error)
+	UseRecoveryCode(ctx context.Context, userID int, codeHash string) (bool, error)
+}
+
+type WebAuthn interface {
+	CreateSession(ctx context.Context, session domain.WebAuthnSession) error
+	TakeSession(ctx context.Context, sessionHash string, ceremony string) (domain.WebAuthnSession, error)
+
+	GetUser(ctx context.Context, userID int) (domain.User, error)
+	GetCredentials(ctx context.Context, userID int) ([]domain.WebAuthnCredential, error)
+	CreateCredential(ctx context.Context, credential domain.WebAuthnCredential) (int, error)
+	UpdateCredentialUsage(ctx context.Context, credentialID []byte, signCount uint32, backupState bool) error
+	DeleteCredential(ctx context.Context, userID int, id int) error
+}
+
+type Jobs interface {
+	TryLockJob(ctx context.Context, name string) (release func(), acquired bool, err error)
+	GetJobRun(ctx context.Context, name string) (domain.JobRun, error)
+	GetJobRuns(ctx context.Context) ([]domain.JobRun, error)
+	SaveJobRun(ctx context.Context, run domain.JobRun) error
+}
+
+type Housekeeping interface {
+	DeleteExpiredUserTokens(ctx context.Context) (int64, error)
+	DeleteExpiredRefreshTokens(ctx context.Context) (int64, error)
+	DeleteEndedSessions(ctx context.Context) (int64, error)
+	DeleteExpiredRevokedAccessTokens(ctx context.Context) (int64, error)
+	DeleteExpiredWebAuthnSessions(ctx context.Context) (int64, error)
+	DeleteSentEmails(ctx context.Context, retention time.Duration) (int64, error)
+	DeleteDeadEmails(ctx context.Context, retention time.Duration) (int64, error)
+}
+
+type Outbox interface {
+	EnqueueEmail(ctx context.Context, email domain.OutboxEmail) error
+	ClaimEmails(ctx context.Context, limit int, lease time.Duration) ([]domain.OutboxEmail, error)
+	MarkEmailSent(ctx context.Context, id int) error
+	MarkEmailFailed(ctx context.Context, id int, lastError string, retryIn time.Duration, dead bool) error
+	GetEmails(ctx context.Context, status string, limit int) ([]domain.OutboxEmail, error)
+	RetryEmail(ctx context.Context, id int) error
+}
+
+type Transactor interface {
+	WithinTransaction(ctx context.Context, fn func(ctx context.Context) error) error
 }
 
 type Migrator interface {
@@ -46,6 +200,19 @@ type Repository struct {
 	logger        *slog.Logger
 	Authorization Authorization
 	Users         Users
+	Admin         Admin
+	Roles         Roles
+	Organizations Organizations
+	Universities  Universities
+	Students      Students
+	Sessions      Sessions
+	Revocations   Revocations
+	TwoFactor     TwoFactor
+	WebAuthn      WebAuthn
+	Jobs          Jobs
+	Housekeeping  Housekeeping
+	Outbox        Outbox
+	Transactor    Transactor
 }
 
 func NewRepository(db *sql.DB, logger *slog.Logger) *Repository {
@@ -54,5 +221,18 @@ func NewRepository(db *sql.DB, logger *slog.Logger) *Repository {
 		logger:        logger,
 		Authorization: postgres.NewAuthRepo(db, logger),
 		Users:         postgres.NewUserRepo(db, logger),
+		Admin:         postgres.NewAdminRepo(db, logger),
+		Roles:         postgres.NewRoleRepo(db, logger),
+		Organizations: postgres.NewOrganizationRepo(db, logger),
+		Universities:  postgres.NewUniversityRepo(db, logger),
+		Students:      postgres.NewStudentRepo(db, logger),
+		Sessions:      postgres.NewSessionRepo(db, logger),
+		Revocations:   postgres.NewRevocationRepo(db, logger),
+		TwoFactor:     postgres.NewTwoFactorRepo(db, logger),
+		WebAuthn:      postgres.NewWebAuthnRepo(db, logger),
+		Jobs:          postgres.NewJobsRepo(db, logger),
+		Housekeeping:  postgres.NewHousekeepingRepo(db, logger),
+		Outbox:        postgres.NewOutboxRepo(db, logger),
+		Transactor:    postgres.NewTransactor(db, logger),
 	}
 }
@@ -0,0 +1,223 @@
+package service
+
+import (
+	"context"
+	"encoding/base64"
+	"github.com/shamank/edutour-backend/auth-service/internal/domain"
+	"github.com/shamank/edutour-backend/auth-service/internal/repository"
+	"log/slog"
+	"slices"
+	"strconv"
+)
+
+const (
+	defaultUsersPageSize = 20
+	maxUsersPageSize     = 100
+)
+
+// UserListInput is a page request of the admin user list.
+type UserListInput struct {
+	Filter domain.UserFilter
+	// Cursor is NextCursor of the previous page, empty for the first one
+	Cursor string
+}
+
+type UserList struct {
+	Users []domain.User
+	// NextCursor requests the next page, it is empty on the last one
+	NextCursor string
+}
+
+// AdminService manages users on behalf of admins. Actions that change what a user may do
+// end the user's sessions, as the role is part of the access token.
+type AdminService struct {
+	repo          repository.Admin
+	logger        *slog.Logger
+	authorization Authorization
+	roles         Roles
+}
+
+func NewAdminService(repo repository.Admin, logger *slog.Logger, authorization Authorization, roles Roles) *AdminService {
+	return &AdminService{
+		repo:          repo,
+		logger:        logger,
+		authorization: authorization,
+		roles:         roles,
+	}
+}
+
+func (s *AdminService) GetUsers(ctx context.Context, input UserListInput) (UserList, error) {
+	filter := input.Filter
+
+	if filter.Limit <= 0 {
+		filter.Limit = defaultUsersPageSize
+	}
+	if filter.Limit > maxUsersPageSize {
+		filter.Limit = maxUsersPageSize
+	}
+
+	if input.Cursor != "" {
+		afterID, err := decodeUsersCursor(input.Cursor)
+		if err != nil {
+			return UserList{}, err
+		}
+		filter.AfterID = afterID
+	}
+
+	pageSize := filter.Limit
+	// one more user tells whether there is a next page
+	filter.Limit++
+
+	users, err := s.repo.GetUsers(ctx, filter)
+	if err != nil {
+		return UserList{}, err
+	}
+
+	list := UserList{Users: users}
+
+	if len(users) > pageSize {
+		list.Users = users[:pageSize]
+		list.NextCursor = encodeUsersCursor(list.Users[pageSize-1].ID)
+	}
+
+	return list, nil
+}
+
+func (s *AdminService) GetUser(ctx context.Context, userID int) (domain.User, error) {
+	return s.repo.GetUser(ctx, userID)
+}
+
+// ChangeUserRole gives the user another role. Both the new role and the current one of the user
+// may grant only permissions the admin has, so users.update can't be turned into more rights
+// by promoting an accomplice, and a weaker admin can't demote a stronger one.
+func (s *AdminService) ChangeUserRole(ctx context.Context, adminID int, userID int, role string) error {
+	const op = "Service.AdminService.ChangeUserRole"
+	logger := s.logger.With(slog.String("op", op))
+
+	if adminID == userID {
+		return domain.ErrCannotManageSelf
+	}
+
+	admin, err := s.repo.GetUser(ctx, adminID)
+	if err != nil {
+		return err
+	}
+
+	user, err := s.repo.GetUser(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	adminPermissions, err := s.roles.RolePermissions(ctx, admin.Role.Name)
+	if err != nil {
+		return err
+	}
+
+	for _, r := range []string{user.Role.Name, role} {
+		permissions, err := s.roles.RolePermissions(ctx, r)
+		if err != nil {
+			return err
+		}
+
+		for _, permission := range permissions {
+			if !slices.Contains(adminPermissions, permission) {
+				return domain.ErrRoleAboveOwn
+			}
+		}
+	}
+
+	if err := s.repo.SetUserRole(ctx, userID, role); err != nil {
+		return err
+	}
+
+	logger.Info("user role changed", slog.Int("admin_id", adminID), slog.Int("user_id", userID), slog.String("role", role))
+
+	// tokens with the old role must not keep working
+	return s.authorization.LogoutAll(ctx, userID)
+}
+
+func (s *AdminService) ConfirmUser(ctx context.Context, adminID int, userID int) error {
+	const op = "Service.AdminService.ConfirmUser"
+	logger := s.logger.With(slog.String("op", op))
+
+	if err := s.repo.ConfirmUser(ctx, userID); err != nil {
+		return err
+	}
+
+	logger.Info("user confirmed", slog.Int("admin_id", adminID), slog.Int("user_id", userID))
+
+	return nil
+}
+
+// BanUser stops the user from signing in and ends their sessions.
+func (s *AdminService) BanUser(ctx context.Context, adminID int, userID int, reason string) error {
+	const op = "Service.AdminService.BanUser"
+	logger := s.logger.With(slog.String("op", op))
+
+	if adminID == userID {
+		return domain.ErrCannotManageSelf
+	}
+
+	if err := s.repo.BanUser(ctx, userID, reason); err != nil {
+		return err
+	}
+
+	logger.Info("user banned", slog.Int("admin_id", adminID), slog.Int("user_id", userID))
+
+	return s.authorization.LogoutAll(ctx, userID)
+}
+
+func (s *AdminService) UnbanUser(ctx context.Context, adminID int, userID int) error {
+	const op = "Service.AdminService.UnbanUser"
+	logger := s.logger.With(slog.String("op", op))
+
+	if err := s.repo.UnbanUser(ctx, userID); err != nil {
+		return err
+	}
+
+	logger.Info("user unbanned", slog.Int("admin_id", adminID), slog.Int("user_id", userID))
+
+	return nil
+}
+
+func (s *AdminService) DeleteUser(ctx context.Context, adminID int, userID int) error {
+	const op = "Service.AdminService.DeleteUser"
+	logger := s.logger.With(slog.String("op", op))
+
+	if adminID == userID {
+		return domain.ErrCannotManageSelf
+	}
+
+	// revoked first: once the user is gone there is nothing to put the revocation on,
+	// and replicas would accept the access tokens until their caches expire
+	if err := s.authorization.LogoutAll(ctx, userID); err != nil {
+		return err
+	}
+
+	if err := s.repo.DeleteUser(ctx, userID); err != nil {
+		return err
+	}
+
+	logger.Info("user deleted", slog.Int("admin_id", adminID), slog.Int("user_id", userID))
+
+	return nil
+}
+
+// The cursor is opaque to clients, so the order of the list can change without breaking them.
+func encodeUsersCursor(lastID int) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(strconv.Itoa(lastID)))
+}
+
+func decodeUsersCursor(cursor string) (int, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return 0, domain.ErrInvalidCursor
+	}
+
+	lastID, err := strconv.Atoi(string(raw))
+	if err != nil || lastID <= 0 {
+		return 0, domain.ErrInvalidCursor
+	}
+
+	return lastID, nil
+}
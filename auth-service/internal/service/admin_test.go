@@ -0,0 +1,118 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"github.com/shamank/edutour-backend/auth-service/internal/domain"
+	"github.com/shamank/edutour-backend/auth-service/internal/repository"
+	"io"
+	"log/slog"
+	"testing"
+)
+
+// memAdminRepo is an in-memory repository.Admin, only users and their roles are implemented.
+type memAdminRepo struct {
+	repository.Admin
+	users map[int]domain.User
+}
+
+func (r *memAdminRepo) GetUser(ctx context.Context, userID int) (domain.User, error) {
+	user, ok := r.users[userID]
+	if !ok {
+		return domain.User{}, domain.ErrUserNotFound
+	}
+	return user, nil
+}
+
+func (r *memAdminRepo) SetUserRole(ctx context.Context, userID int, role string) error {
+	user, ok := r.users[userID]
+	if !ok {
+		return domain.ErrUserNotFound
+	}
+	user.Role.Name = role
+	r.users[userID] = user
+	return nil
+}
+
+// staticRoles is a Roles with fixed permissions of every role.
+type staticRoles struct {
+	Roles
+	permissions map[string][]string
+}
+
+func (r staticRoles) RolePermissions(ctx context.Context, role string) ([]string, error) {
+	return r.permissions[role], nil
+}
+
+// loggedOutUsers is an Authorization that records whose sessions were ended.
+type loggedOutUsers struct {
+	Authorization
+	users []int
+}
+
+func (a *loggedOutUsers) LogoutAll(ctx context.Context, userID int) error {
+	a.users = append(a.users, userID)
+	return nil
+}
+
+func TestAdminChangeUserRole(t *testing.T) {
+	const (
+		adminID     = 1
+		moderatorID = 2
+		userID      = 3
+		otherAdmin  = 4
+	)
+
+	roles := staticRoles{permissions: map[string][]string{
+		domain.AdminRoleName: {domain.PermissionRolesManageAny, domain.PermissionUsersReadAny, domain.PermissionUsersUpdateAny},
+		"moderator":          {domain.PermissionUsersReadAny, domain.PermissionUsersUpdateAny},
+		"support":            {domain.PermissionUsersReadAny},
+		"user":               {},
+	}}
+
+	tests := []struct {
+		name    string
+		adminID int
+		userID  int
+		role    string
+		wantErr error
+	}{
+		{name: "admin promotes to moderator", adminID: adminID, userID: userID, role: "moderator"},
+		{name: "moderator assigns a weaker role", adminID: moderatorID, userID: userID, role: "support"},
+		{name: "moderator promotes to admin", adminID: moderatorID, userID: userID, role: domain.AdminRoleName, wantErr: domain.ErrRoleAboveOwn},
+		{name: "moderator demotes an admin", adminID: moderatorID, userID: otherAdmin, role: "user", wantErr: domain.ErrRoleAboveOwn},
+		{name: "admin changes own role", adminID: adminID, userID: adminID, role: "user", wantErr: domain.ErrCannotManageSelf},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := &memAdminRepo{users: map[int]domain.User{
+				adminID:     {ID: adminID, Role: domain.UserRole{Name: domain.AdminRoleName}},
+				moderatorID: {ID: moderatorID, Role: domain.UserRole{Name: "moderator"}},
+				userID:      {ID: userID, Role: domain.UserRole{Name: "user"}},
+				otherAdmin:  {ID: otherAdmin, Role: domain.UserRole{Name: domain.AdminRoleName}},
+			}}
+			authorization := &loggedOutUsers{}
+			s := NewAdminService(repo, slog.New(slog.NewTextHandler(io.Discard, nil)), authorization, roles)
+
+			before := repo.users[tt.userID].Role.Name
+
+			err := s.ChangeUserRole(context.Background(), tt.adminID, tt.userID, tt.role)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("err = %v, want %v", err, tt.wantErr)
+			}
+
+			want := tt.role
+			if tt.wantErr != nil {
+				want = before
+			}
+			if got := repo.users[tt.userID].Role.Name; got != want {
+				t.Fatalf("role = %q, want %q", got, want)
+			}
+
+			if tt.wantErr == nil && len(authorization.users) != 1 {
+				t.Fatalf("sessions of the user weren't ended")
+			}
+		})
+	}
+}
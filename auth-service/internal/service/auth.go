@@ -2,31 +2,73 @@ package service
 
 import (
 	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"errors"
+	"fmt"
 	"github.com/shamank/edutour-backend/auth-service/internal/domain"
 	"github.com/shamank/edutour-backend/auth-service/internal/repository"
+	"github.com/shamank/edutour-backend/auth-service/internal/templates"
 	"github.com/shamank/edutour-backend/auth-service/pkg/auth"
-	"github.com/shamank/edutour-backend/auth-service/pkg/email"
 	"github.com/shamank/edutour-backend/auth-service/pkg/hash"
+	"github.com/shamank/edutour-backend/auth-service/pkg/logger/sl"
+	"github.com/shamank/edutour-backend/auth-service/pkg/otp"
+	"github.com/shamank/edutour-backend/auth-service/pkg/sms"
 	"log/slog"
+	"math/big"
 	"net/mail"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 )
 
+// emailLinkTTL is how long confirmation and password reset links work.
+const emailLinkTTL = 2 * time.Hour
+
 type AuthService struct {
-	repo         repository.Authorization
-	logger       *slog.Logger
-	hasher       hash.PasswordHasher
-	tokenManager auth.TokenManager
-	emailManager *email.EmailManager
+	repo          repository.Authorization
+	logger        *slog.Logger
+	hasher        hash.PasswordHasher
+	tokenHasher   hash.TokenHasher
+	tokenManager  auth.TokenManager
+	outbox        repository.Outbox
+	transactor    repository.Transactor
+	templates     EmailTemplates
+	smsSender     sms.SMSSender
+	revocations   Revocations
+	twoFactor     TwoFactor
+	webAuthn      WebAuthn
+	roles         Roles
+	organizations Organizations
+	students      Students
+	cfg           AuthConfig
+
+	// dummyHash is verified for unknown logins, see verifyDummyPassword
+	dummyHashOnce sync.Once
+	dummyHash     string
 }
 
-func NewAuthService(repo repository.Authorization, logger *slog.Logger, hasher hash.PasswordHasher, tokenManager auth.TokenManager, emailManager *email.EmailManager) *AuthService {
+func NewAuthService(repo repository.Authorization, logger *slog.Logger, hasher hash.PasswordHasher, tokenHasher hash.TokenHasher, tokenManager auth.TokenManager, outbox repository.Outbox, transactor repository.Transactor, templates EmailTemplates, smsSender sms.SMSSender, revocations Revocations, twoFactor TwoFactor, webAuthn WebAuthn, roles Roles, organizations Organizations, students Students, cfg AuthConfig) *AuthService {
 	return &AuthService{
-		repo:         repo,
-		logger:       logger,
-		hasher:       hasher,
-		tokenManager: tokenManager,
-		emailManager: emailManager,
+		repo:          repo,
+		logger:        logger,
+		hasher:        hasher,
+		tokenHasher:   tokenHasher,
+		tokenManager:  tokenManager,
+		outbox:        outbox,
+		transactor:    transactor,
+		templates:     templates,
+		smsSender:     smsSender,
+		revocations:   revocations,
+		twoFactor:     twoFactor,
+		webAuthn:      webAuthn,
+		roles:         roles,
+		organizations: organizations,
+		students:      students,
+		cfg:           cfg,
 	}
 }
 
@@ -43,114 +85,674 @@ func (s *AuthService) SignUp(ctx context.Context, input UserSignUpInput) error {
 	user := domain.User{
 		Username:     input.UserName,
 		Email:        input.Email,
+		Phone:        input.Phone,
 		PasswordHash: passwordHash,
+		Locale:       domain.NormalizeLocale(input.Locale),
+	}
+
+	return s.transactor.WithinTransaction(ctx, func(ctx context.Context) error {
+		user.ID, err = s.repo.Create(ctx, user, s.tokenHasher.Hash(confirmToken), time.Now().Add(emailLinkTTL).Unix())
+		if err != nil {
+			return err
+		}
+
+		return s.sendConfirmation(ctx, user, confirmToken)
+	})
+}
+
+// ResendConfirmation sends a new confirmation link and code to a user who hasn't confirmed their email yet.
+// Unknown and already confirmed emails are not reported, so the endpoint can't be used to find out who is registered.
+func (s *AuthService) ResendConfirmation(ctx context.Context, email string) error {
+	const op = "Service.AuthService.ResendConfirmation"
+	logger := s.logger.With(slog.String("op", op))
+
+	user, err := s.repo.GetByCredentials(ctx, email)
+	if err != nil {
+		if errors.Is(err, domain.ErrUserNotFound) {
+			return nil
+		}
+		return err
+	}
+	if user.IsConfirm {
+		return nil
+	}
+
+	sentRecently, err := s.repo.HasRecentUserToken(ctx, user.ID, domain.TokenTypeEmailVerify, s.cfg.ConfirmationResendInterval)
+	if err != nil {
+		return err
+	}
+	if sentRecently {
+		return domain.ErrConfirmationResendTooSoon
 	}
 
-	if err := s.repo.Create(ctx, user, confirmToken, time.Now().Add(2*time.Hour).Unix()); err != nil {
+	confirmToken, err := s.tokenManager.GenerateToken(32)
+	if err != nil {
 		return err
 	}
 
-	// TODO: сделать нормальную верстку
-	err = s.emailManager.SendMail([]string{input.Email},
-		"Password confirm",
-		"confirm email:  https://education-tourism.netlify.app/verifyemail/"+confirmToken)
+	err = s.transactor.WithinTransaction(ctx, func(ctx context.Context) error {
+		err := s.repo.CreateUserToken(ctx, user.ID, domain.TokenTypeEmailVerify, s.tokenHasher.Hash(confirmToken), time.Now().Add(emailLinkTTL).Unix())
+		if err != nil {
+			return err
+		}
 
-	// TODO: сделать обработку ошибки
+		return s.sendConfirmation(ctx, user, confirmToken)
+	})
 	if err != nil {
 		return err
 	}
 
+	logger.Info("confirmation email resent", slog.Int("user_id", user.ID))
+
 	return nil
 }
 
-func (s *AuthService) SignIn(ctx context.Context, input UserSignInInput) (Tokens, error) {
-	passwordHash, err := s.hasher.Hash(input.Password)
+// sendConfirmation queues the confirmation link together with a new numeric code.
+// It should run in the transaction that creates confirmToken.
+func (s *AuthService) sendConfirmation(ctx context.Context, user domain.User, confirmToken string) error {
+	code, err := s.createVerificationCode(ctx, user.ID, domain.TokenTypeEmailVerifyCode)
 	if err != nil {
-		return Tokens{}, err
+		return err
 	}
 
+	return s.sendEmail(ctx, user, templates.EmailConfirm, templates.LinkData{
+		Username: user.Username,
+		Link:     frontendLink(s.cfg.FrontendURL, "verifyemail", confirmToken),
+		Code:     code,
+		LinkTTL:  int(emailLinkTTL.Minutes()),
+		CodeTTL:  int(s.cfg.VerificationCode.TTL.Minutes()),
+	})
+}
+
+func (s *AuthService) SignIn(ctx context.Context, input UserSignInInput) (SignInResult, error) {
 	var user domain.User
+	var err error
 
 	_, err = mail.ParseAddress(input.Login)
 	if err != nil {
-		user, err = s.repo.GetByUsername(ctx, input.Login, passwordHash)
+		user, err = s.repo.GetByUsername(ctx, input.Login)
+	} else {
+		user, err = s.repo.GetByCredentials(ctx, input.Login)
+	}
+	if err != nil {
+		if errors.Is(err, domain.ErrUserNotFound) {
+			s.verifyDummyPassword(input.Password)
+			return SignInResult{}, domain.ErrInvalidPassword
+		}
+		return SignInResult{}, err
+	}
+
+	ok, needsRehash, err := s.hasher.Verify(input.Password, user.PasswordHash)
+	if err != nil {
+		return SignInResult{}, err
+	}
+	if !ok {
+		return SignInResult{}, domain.ErrInvalidPassword
+	}
+
+	if needsRehash {
+		s.rehashPassword(ctx, user.ID, input.Password)
+	}
+
+	return s.completeSignIn(ctx, user, input.Client)
+}
+
+// verifyDummyPassword takes as long as checking the password of an existing user,
+// so that the response time doesn't tell whether a login is registered.
+func (s *AuthService) verifyDummyPassword(password string) {
+	s.dummyHashOnce.Do(func() {
+		s.dummyHash, _ = s.hasher.Hash("dummy password")
+	})
+
+	s.hasher.Verify(password, s.dummyHash)
+}
+
+// completeSignIn is called once the user has proven the first factor. It issues tokens,
+// or a challenge for the second factor when the user has two-factor authentication enabled.
+// A user whose role requires two-factor authentication but who hasn't enabled it gets
+// a challenge to enable it instead of tokens.
+func (s *AuthService) completeSignIn(ctx context.Context, user domain.User, client ClientInfo) (SignInResult, error) {
+	twoFactorEnabled, err := s.twoFactor.IsEnabled(ctx, user.ID)
+	if err != nil {
+		return SignInResult{}, err
+	}
+
+	enrolmentRequired := !twoFactorEnabled && slices.Contains(s.cfg.TwoFactor.RequiredRoles, user.Role.Name)
+
+	if twoFactorEnabled || enrolmentRequired {
+		// setRefreshToken would refuse later, but a banned user shouldn't be asked for a code at all
+		if err := s.checkNotBanned(ctx, user.ID); err != nil {
+			return SignInResult{}, err
+		}
+
+		challengeToken, err := s.tokenManager.GenerateToken(32)
 		if err != nil {
-			return Tokens{}, err
+			return SignInResult{}, err
 		}
-	} else {
-		user, err = s.repo.GetByCredentials(ctx, input.Login, passwordHash)
+
+		tokenType := domain.TokenTypeTwoFactorChallenge
+		if enrolmentRequired {
+			tokenType = domain.TokenTypeTwoFactorEnrolment
+		}
+
+		expireIn := s.cfg.TwoFactor.ChallengeTTL
+		err = s.repo.CreateUserToken(ctx, user.ID, tokenType, s.tokenHasher.Hash(challengeToken), time.Now().Add(expireIn).Unix())
+		if err != nil {
+			return SignInResult{}, err
+		}
+
+		return SignInResult{
+			ChallengeToken:    challengeToken,
+			ChallengeExpireIn: expireIn,
+			EnrolmentRequired: enrolmentRequired,
+		}, nil
+	}
+
+	tokens, err := s.setRefreshToken(ctx, user.ID, user.Username, user.Role.Name, client)
+	if err != nil {
+		return SignInResult{}, err
+	}
+
+	return SignInResult{Tokens: tokens}, nil
+}
+
+// SignInTwoFactor completes a sign-in started by SignIn with a code from the authenticator app
+// or a recovery code. A challenge is single-use and dies after MaxAttempts wrong codes,
+// and the user is locked out after Lockout.MaxFailures wrong codes over all challenges.
+func (s *AuthService) SignInTwoFactor(ctx context.Context, challengeToken string, code string, client ClientInfo) (Tokens, error) {
+	tokenHash := s.tokenHasher.Hash(challengeToken)
+
+	user, err := s.repo.GetUserByToken(ctx, domain.TokenTypeTwoFactorChallenge, tokenHash, s.cfg.TwoFactor.MaxAttempts)
+	if err != nil {
+		return Tokens{}, err
+	}
+
+	if err := s.checkSignInLockout(ctx, user.ID, domain.TokenTypeTwoFactorFailure, s.cfg.TwoFactor.Lockout); err != nil {
+		return Tokens{}, err
+	}
+
+	if err := s.twoFactor.VerifyCode(ctx, user.ID, code); err != nil {
+		if errors.Is(err, domain.ErrInvalidTwoFactorCode) {
+			if err := s.addTwoFactorFailure(ctx, user.ID, domain.TokenTypeTwoFactorChallenge, tokenHash); err != nil {
+				return Tokens{}, err
+			}
+		}
+		return Tokens{}, err
+	}
+
+	if err := s.repo.RevokeUserToken(ctx, domain.TokenTypeTwoFactorChallenge, tokenHash); err != nil {
+		return Tokens{}, err
+	}
+
+	return s.setRefreshToken(ctx, user.ID, user.Username, user.Role.Name, client)
+}
+
+// EnrollTwoFactorOnSignIn is TwoFactor.EnrollTOTP for a user whose sign-in returned EnrolmentRequired.
+// Such a user has no access token yet, so the challenge token identifies them.
+func (s *AuthService) EnrollTwoFactorOnSignIn(ctx context.Context, challengeToken string) (otp.TOTPKey, error) {
+	user, err := s.repo.GetUserByToken(ctx, domain.TokenTypeTwoFactorEnrolment, s.tokenHasher.Hash(challengeToken), s.cfg.TwoFactor.MaxAttempts)
+	if err != nil {
+		return otp.TOTPKey{}, err
+	}
+
+	return s.twoFactor.EnrollTOTP(ctx, user.ID, user.Username)
+}
+
+// ConfirmTwoFactorOnSignIn enables two-factor authentication enrolled with EnrollTwoFactorOnSignIn
+// and completes the sign-in. It returns the recovery codes along with the tokens.
+func (s *AuthService) ConfirmTwoFactorOnSignIn(ctx context.Context, challengeToken string, code string, client ClientInfo) ([]string, Tokens, error) {
+	tokenHash := s.tokenHasher.Hash(challengeToken)
+
+	user, err := s.repo.GetUserByToken(ctx, domain.TokenTypeTwoFactorEnrolment, tokenHash, s.cfg.TwoFactor.MaxAttempts)
+	if err != nil {
+		return nil, Tokens{}, err
+	}
+
+	if err := s.checkSignInLockout(ctx, user.ID, domain.TokenTypeTwoFactorFailure, s.cfg.TwoFactor.Lockout); err != nil {
+		return nil, Tokens{}, err
+	}
+
+	recoveryCodes, err := s.twoFactor.ConfirmTOTP(ctx, user.ID, code)
+	if err != nil {
+		if errors.Is(err, domain.ErrInvalidTwoFactorCode) {
+			if err := s.addTwoFactorFailure(ctx, user.ID, domain.TokenTypeTwoFactorEnrolment, tokenHash); err != nil {
+				return nil, Tokens{}, err
+			}
+		}
+		return nil, Tokens{}, err
+	}
+
+	if err := s.repo.RevokeUserToken(ctx, domain.TokenTypeTwoFactorEnrolment, tokenHash); err != nil {
+		return nil, Tokens{}, err
+	}
+
+	tokens, err := s.setRefreshToken(ctx, user.ID, user.Username, user.Role.Name, client)
+	if err != nil {
+		return nil, Tokens{}, err
+	}
+
+	return recoveryCodes, tokens, nil
+}
+
+// addTwoFactorFailure counts a wrong code against both the challenge and the user.
+func (s *AuthService) addTwoFactorFailure(ctx context.Context, userID int, tokenType int, tokenHash string) error {
+	if err := s.repo.AddUserTokenAttempt(ctx, tokenType, tokenHash); err != nil {
+		return err
+	}
+
+	return s.addSignInFailure(ctx, userID, domain.TokenTypeTwoFactorFailure, s.cfg.TwoFactor.Lockout)
+}
+
+// checkSignInLockout returns ErrSignInLocked if the user has entered lockout.MaxFailures wrong codes
+// within lockout.Window. Attempts of a single challenge or code are limited too, but requesting
+// a new one resets them; the failures counted here add up over all of them.
+func (s *AuthService) checkSignInLockout(ctx context.Context, userID int, failureType int, lockout SignInLockoutConfig) error {
+	failures, err := s.repo.CountUserTokens(ctx, userID, failureType)
+	if err != nil {
+		return err
+	}
+	if failures >= lockout.MaxFailures {
+		return domain.ErrSignInLocked
+	}
+
+	return nil
+}
+
+// addSignInFailure records a wrong code for checkSignInLockout. A failure is stored
+// as a user token of failureType that expires once it leaves the lockout window.
+func (s *AuthService) addSignInFailure(ctx context.Context, userID int, failureType int, lockout SignInLockoutConfig) error {
+	failureID, err := s.tokenManager.GenerateToken(16)
+	if err != nil {
+		return err
+	}
+
+	return s.repo.CreateUserToken(ctx, userID, failureType, s.tokenHasher.Hash(failureID), time.Now().Add(lockout.Window).Unix())
+}
+
+// SignInWebAuthn signs in with a passkey. A passkey already proves possession and,
+// with user verification, knowledge or biometrics, so no second factor is asked for.
+func (s *AuthService) SignInWebAuthn(ctx context.Context, sessionID string, response []byte, client ClientInfo) (Tokens, error) {
+	user, err := s.webAuthn.FinishLogin(ctx, sessionID, response)
+	if err != nil {
+		return Tokens{}, err
+	}
+
+	return s.setRefreshToken(ctx, user.ID, user.Username, user.Role.Name, client)
+}
+
+// SendMagicLink emails the user a single-use link that signs them in without a password.
+// An unknown email is not reported, so the endpoint can't be used to find out who is registered.
+func (s *AuthService) SendMagicLink(ctx context.Context, email string) error {
+	user, err := s.repo.GetByCredentials(ctx, email)
+	if err != nil {
+		if errors.Is(err, domain.ErrUserNotFound) {
+			return nil
+		}
+		return err
+	}
+
+	token, err := s.tokenManager.GenerateToken(32)
+	if err != nil {
+		return err
+	}
+
+	return s.transactor.WithinTransaction(ctx, func(ctx context.Context) error {
+		err := s.repo.CreateUserToken(ctx, user.ID, domain.TokenTypeMagicLink, s.tokenHasher.Hash(token), time.Now().Add(s.cfg.MagicLinkTTL).Unix())
 		if err != nil {
-			return Tokens{}, err
+			return err
 		}
+
+		return s.sendEmail(ctx, user, templates.EmailMagicLink, templates.LinkData{
+			Username: user.Username,
+			Link:     frontendLink(s.cfg.FrontendURL, "magic-link", token),
+			LinkTTL:  int(s.cfg.MagicLinkTTL.Minutes()),
+		})
+	})
+}
+
+// SignInMagicLink exchanges a magic link token for tokens. The link replaces only the password,
+// so a user with two-factor authentication still gets a challenge.
+func (s *AuthService) SignInMagicLink(ctx context.Context, token string, client ClientInfo) (SignInResult, error) {
+	tokenHash := s.tokenHasher.Hash(token)
+
+	// a magic link is never retried, so it has no attempts to count
+	user, err := s.repo.GetUserByToken(ctx, domain.TokenTypeMagicLink, tokenHash, 1)
+	if err != nil {
+		return SignInResult{}, err
+	}
+
+	if err := s.repo.RevokeUserToken(ctx, domain.TokenTypeMagicLink, tokenHash); err != nil {
+		return SignInResult{}, err
 	}
 
-	return s.setRefreshToken(ctx, user.ID, user.Username, user.Role.Name)
+	return s.completeSignIn(ctx, user, client)
+}
+
+// sendEmail renders the email in the user's locale and queues it for OutboxService.
+// Called within a transaction, it is sent only if the transaction commits.
+func (s *AuthService) sendEmail(ctx context.Context, user domain.User, template string, data any) error {
+	email, err := s.templates.Render(user.Locale, template, data)
+	if err != nil {
+		return err
+	}
+
+	return s.outbox.EnqueueEmail(ctx, domain.OutboxEmail{
+		To:       []string{user.Email},
+		Subject:  email.Subject,
+		Body:     email.Text,
+		HTMLBody: email.HTML,
+	})
+}
+
+// frontendLink returns the link to the frontend page that takes token.
+func frontendLink(baseURL string, page string, token string) string {
+	return strings.TrimSuffix(baseURL, "/") + "/" + page + "/" + url.PathEscape(token)
+}
+
+// rehashPassword upgrades the stored hash of a user who has just proven the password.
+// Failure is not fatal for sign-in: the upgrade is retried on the next one.
+func (s *AuthService) rehashPassword(ctx context.Context, userID int, password string) {
+	const op = "Service.AuthService.rehashPassword"
+	logger := s.logger.With(slog.String("op", op))
+
+	passwordHash, err := s.hasher.Hash(password)
+	if err != nil {
+		logger.Error("error occurred when hashing password", sl.Err(err))
+		return
+	}
+
+	if err := s.repo.UpdatePasswordHash(ctx, userID, passwordHash); err != nil {
+		logger.Error("error occurred when updating password hash", sl.Err(err))
+		return
+	}
+
+	logger.Info("password hash upgraded", slog.Int("user_id", userID))
 }
 
 func (s *AuthService) ConfirmUser(ctx context.Context, confirmToken string) error {
 
-	if err := s.repo.ConfirmUser(ctx, confirmToken); err != nil {
+	if err := s.repo.ConfirmUser(ctx, domain.TokenTypeEmailVerify, s.tokenHasher.Hash(confirmToken)); err != nil {
 		return err
 	}
 
 	return nil
 }
 
+// ResetPassword emails a password reset link and code to a confirmed user. Unknown and unconfirmed
+// emails are not reported, so the endpoint can't be used to find out who is registered. For the same
+// reason a request within PasswordResetResendInterval of the previous one is silently dropped.
 func (s *AuthService) ResetPassword(ctx context.Context, email string) error {
+	const op = "Service.AuthService.ResetPassword"
+	logger := s.logger.With(slog.String("op", op))
+
+	user, err := s.repo.GetByCredentials(ctx, email)
+	if err != nil {
+		if errors.Is(err, domain.ErrUserNotFound) {
+			return nil
+		}
+		return err
+	}
+	if !user.IsConfirm {
+		return nil
+	}
+
+	sentRecently, err := s.repo.HasRecentUserToken(ctx, user.ID, domain.TokenTypePasswordReset, s.cfg.PasswordResetResendInterval)
+	if err != nil {
+		return err
+	}
+	if sentRecently {
+		logger.Info("password reset requested again too soon", slog.Int("user_id", user.ID))
+		return nil
+	}
 
 	resetToken, err := s.tokenManager.GenerateToken(32)
 	if err != nil {
 		return err
 	}
 
-	err = s.repo.SetTokenResetPassword(ctx, email, resetToken, time.Now().Add(2*time.Hour).Unix())
+	err = s.transactor.WithinTransaction(ctx, func(ctx context.Context) error {
+		userID, err := s.repo.SetTokenResetPassword(ctx, email, s.tokenHasher.Hash(resetToken), time.Now().Add(emailLinkTTL).Unix())
+		if err != nil {
+			return err
+		}
+
+		code, err := s.createVerificationCode(ctx, userID, domain.TokenTypePasswordResetCode)
+		if err != nil {
+			return err
+		}
+
+		return s.sendEmail(ctx, user, templates.EmailResetPassword, templates.LinkData{
+			Username: user.Username,
+			Link:     frontendLink(s.cfg.FrontendURL, "reset-password", resetToken),
+			Code:     code,
+			LinkTTL:  int(emailLinkTTL.Minutes()),
+			CodeTTL:  int(s.cfg.VerificationCode.TTL.Minutes()),
+		})
+	})
+	// the user was deleted or lost the confirmation in between
+	if errors.Is(err, domain.ErrUserNotFound) {
+		return nil
+	}
+
+	return err
+}
+
+func (s *AuthService) ConfirmResetPassword(ctx context.Context, token string, password string) error {
+	passwordHash, err := s.hasher.Hash(password)
+	if err != nil {
+		return err
+	}
+
+	userID, err := s.repo.ConfirmResetPassword(ctx, domain.TokenTypePasswordReset, s.tokenHasher.Hash(token), passwordHash)
 	if err != nil {
 		return err
 	}
 
-	// TODO: сделать нормальную верстку
-	err = s.emailManager.SendMail([]string{email},
-		"Password confirm",
-		"confirm reset password: https://education-tourism.netlify.app/reset-password/"+resetToken)
+	// whoever knew the old password must not stay signed in
+	return s.LogoutAll(ctx, userID)
+}
 
-	// TODO: сделать обработку ошибки
+// ConfirmUserByCode confirms the email with the numeric code sent along with the confirmation link.
+func (s *AuthService) ConfirmUserByCode(ctx context.Context, email string, code string) error {
+	codeHash, err := s.checkEmailVerificationCode(ctx, email, domain.TokenTypeEmailVerifyCode, code)
 	if err != nil {
 		return err
 	}
 
-	return nil
+	return s.repo.ConfirmUser(ctx, domain.TokenTypeEmailVerifyCode, codeHash)
 }
 
-func (s *AuthService) ConfirmResetPassword(ctx context.Context, token string, password string) error {
+// ConfirmResetPasswordByCode sets a new password with the numeric code sent along with the reset link.
+func (s *AuthService) ConfirmResetPasswordByCode(ctx context.Context, email string, code string, password string) error {
+	codeHash, err := s.checkEmailVerificationCode(ctx, email, domain.TokenTypePasswordResetCode, code)
+	if err != nil {
+		return err
+	}
+
 	passwordHash, err := s.hasher.Hash(password)
 	if err != nil {
 		return err
 	}
 
-	err = s.repo.ConfirmResetPassword(ctx, token, passwordHash)
+	userID, err := s.repo.ConfirmResetPassword(ctx, domain.TokenTypePasswordResetCode, codeHash, passwordHash)
 	if err != nil {
 		return err
 	}
-	return nil
+
+	return s.LogoutAll(ctx, userID)
 }
 
-func (s *AuthService) RefreshToken(ctx context.Context, refreshToken string) (Tokens, error) {
+// createVerificationCode stores a new numeric code for the user and returns it.
+func (s *AuthService) createVerificationCode(ctx context.Context, userID int, tokenType int) (string, error) {
+	code, err := generateNumericCode(s.cfg.VerificationCode.Length)
+	if err != nil {
+		return "", err
+	}
 
-	user, err := s.repo.GetByRefreshToken(ctx, refreshToken)
+	err = s.repo.CreateUserToken(ctx, userID, tokenType, s.verificationCodeHash(userID, code), time.Now().Add(s.cfg.VerificationCode.TTL).Unix())
+	if err != nil {
+		return "", err
+	}
+
+	return code, nil
+}
+
+// checkEmailVerificationCode is checkVerificationCode for the user with the email.
+func (s *AuthService) checkEmailVerificationCode(ctx context.Context, email string, tokenType int, code string) (string, error) {
+	user, err := s.repo.GetByCredentials(ctx, email)
+	if err != nil {
+		if errors.Is(err, domain.ErrUserNotFound) {
+			return "", domain.ErrInvalidVerificationCode
+		}
+		return "", err
+	}
+
+	return s.checkVerificationCode(ctx, user.ID, tokenType, code)
+}
+
+// checkVerificationCode compares code with the latest one sent to the user and returns its hash.
+// Every wrong guess is counted, and after MaxAttempts the code stops working even if guessed right.
+func (s *AuthService) checkVerificationCode(ctx context.Context, userID int, tokenType int, code string) (string, error) {
+	userCode, err := s.repo.GetUserCode(ctx, userID, tokenType)
+	if err != nil {
+		return "", err
+	}
+
+	if userCode.Attempts >= s.cfg.VerificationCode.MaxAttempts {
+		return "", domain.ErrVerificationCodeLocked
+	}
+
+	codeHash := s.verificationCodeHash(userCode.UserID, code)
+
+	if subtle.ConstantTimeCompare([]byte(codeHash), []byte(userCode.TokenHash)) != 1 {
+		if err := s.repo.AddUserTokenAttempt(ctx, tokenType, userCode.TokenHash); err != nil {
+			return "", err
+		}
+		return "", domain.ErrInvalidVerificationCode
+	}
+
+	return codeHash, nil
+}
+
+// verificationCodeHash binds a code to its user: codes are short,
+// so different users get the same one and it can't be looked up by hash alone.
+func (s *AuthService) verificationCodeHash(userID int, code string) string {
+	return s.tokenHasher.Hash(strconv.Itoa(userID) + ":" + code)
+}
+
+// generateNumericCode returns a uniformly random code of length digits.
+func generateNumericCode(length int) (string, error) {
+	max := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(length)), nil)
+
+	n, err := rand.Int(rand.Reader, max)
+	if err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("%0*d", length, n), nil
+}
+
+// Logout ends the session the refresh token belongs to.
+// Logout ends the session of the refresh token. accessToken is the access token of the client, if it sent one;
+// it is revoked right away instead of working until it expires.
+func (s *AuthService) Logout(ctx context.Context, refreshToken string, accessToken auth.UserClaims) error {
+	if err := s.repo.RevokeRefreshToken(ctx, s.tokenHasher.Hash(refreshToken)); err != nil {
+		return err
+	}
+
+	if accessToken.TokenID == "" {
+		return nil
+	}
+
+	return s.revocations.RevokeAccessToken(ctx, accessToken)
+}
+
+// LogoutAll ends every session of the user and invalidates access tokens already issued.
+func (s *AuthService) LogoutAll(ctx context.Context, userID int) error {
+	if err := s.repo.RevokeAllRefreshTokens(ctx, userID); err != nil {
+		return err
+	}
+
+	return s.revocations.RevokeUserTokens(ctx, userID)
+}
+
+func (s *AuthService) RefreshToken(ctx context.Context, refreshToken string, client ClientInfo) (Tokens, error) {
+	newRefreshToken, expireAt, err := s.tokenManager.GenerateRefreshToken()
+	if err != nil {
+		return Tokens{}, err
+	}
+
+	user, familyID, err := s.repo.RotateRefreshToken(ctx, s.tokenHasher.Hash(refreshToken), domain.RefreshToken{
+		TokenHash: s.tokenHasher.Hash(newRefreshToken),
+		ExpiresAt: expireAt,
+	}, s.cfg.RefreshTokenGracePeriod, newSession(client))
+	if err != nil {
+		return Tokens{}, err
+	}
+
+	// checked after the rotation: a ban that revoked the refresh tokens while the new one was being
+	// inserted is seen here, and the session of the new token is ended as well
+	if err := s.checkNotBanned(ctx, user.ID); err != nil {
+		if errors.Is(err, domain.ErrUserBanned) {
+			if err := s.repo.RevokeRefreshToken(ctx, s.tokenHasher.Hash(newRefreshToken)); err != nil {
+				return Tokens{}, err
+			}
+		}
+		return Tokens{}, err
+	}
+
+	// read again on every refresh, so changes to the role or the organization reach the user with the next token
+	claims, err := s.userClaims(ctx, user.ID, user.Username, user.Role.Name, familyID)
+	if err != nil {
+		return Tokens{}, err
+	}
+
+	accessToken, expireIn, err := s.tokenManager.Generate(claims)
 	if err != nil {
 		return Tokens{}, err
 	}
 
-	return s.setRefreshToken(ctx, user.ID, user.Username, user.Role.Name)
+	return Tokens{
+		AccessToken:  accessToken,
+		RefreshToken: newRefreshToken,
+		ExpireIn:     expireIn,
+	}, nil
 }
 
 func (s *AuthService) Verify(ctx context.Context, userID int, hash string) error {
 	return nil
 }
 
-func (s *AuthService) setRefreshToken(ctx context.Context, userID int, userName string, userRole string) (Tokens, error) {
+// checkNotBanned returns ErrUserBanned for a banned user.
+func (s *AuthService) checkNotBanned(ctx context.Context, userID int) error {
+	banned, err := s.repo.IsUserBanned(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if banned {
+		return domain.ErrUserBanned
+	}
+	return nil
+}
+
+// setRefreshToken issues an access token and a refresh token starting a new session.
+// Every sign-in ends here, so a banned user never gets tokens.
+func (s *AuthService) setRefreshToken(ctx context.Context, userID int, userName string, userRole string, client ClientInfo) (Tokens, error) {
+	if err := s.checkNotBanned(ctx, userID); err != nil {
+		return Tokens{}, err
+	}
+
+	familyID, err := s.tokenManager.GenerateToken(16)
+	if err != nil {
+		return Tokens{}, err
+	}
 
-	accessToken, expireIn, err := s.tokenManager.Generate(userID, userName, userRole)
+	claims, err := s.userClaims(ctx, userID, userName, userRole, familyID)
+	if err != nil {
+		return Tokens{}, err
+	}
+
+	accessToken, expireIn, err := s.tokenManager.Generate(claims)
 	if err != nil {
 		return Tokens{}, err
 	}
@@ -161,9 +763,10 @@ func (s *AuthService) setRefreshToken(ctx context.Context, userID int, userName
 	}
 
 	err = s.repo.SetRefreshToken(ctx, userID, domain.RefreshToken{
-		RefreshToken: refreshToken,
-		ExpiresAt:    expireAt,
-	})
+		TokenHash: s.tokenHasher.Hash(refreshToken),
+		ExpiresAt: expireAt,
+		FamilyID:  familyID,
+	}, newSession(client))
 
 	return Tokens{
 		AccessToken:  accessToken,
@@ -172,6 +775,42 @@ func (s *AuthService) setRefreshToken(ctx context.Context, userID int, userName
 	}, err
 }
 
+// userClaims collects the claims of an access token: the permissions of the role
+// and the organization of the user with the role in it, if the user is in one.
+func (s *AuthService) userClaims(ctx context.Context, userID int, userName string, role string, sessionID string) (auth.UserClaims, error) {
+	permissions, err := s.roles.RolePermissions(ctx, role)
+	if err != nil {
+		return auth.UserClaims{}, err
+	}
+
+	claims := auth.UserClaims{
+		UserID:      userID,
+		UserName:    userName,
+		Role:        role,
+		Permissions: permissions,
+		SessionID:   sessionID,
+	}
+
+	// other services trust the organization of the token, so anyone creating an organization
+	// for any domain must not get one until an admin verifies it
+	member, err := s.organizations.Membership(ctx, userID)
+	switch {
+	case err == nil:
+		if member.OrganizationVerified {
+			claims.OrganizationID = member.OrganizationID
+			claims.OrganizationRole = member.Role
+		}
+	case !errors.Is(err, domain.ErrNotOrganizationMember):
+		return auth.UserClaims{}, err
+	}
+
+	if claims.StudentUntil, err = s.students.StudentUntil(ctx, userID); err != nil {
+		return auth.UserClaims{}, err
+	}
+
+	return claims, nil
+}
+
 func (s *AuthService) GetFullUserInfo(ctx context.Context, userID int) (domain.User, error) {
 	res, err := s.repo.GetFullUserInfo(ctx, userID)
 	if err != nil {
@@ -0,0 +1,177 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"github.com/shamank/edutour-backend/auth-service/internal/domain"
+	"testing"
+	"time"
+)
+
+const testTwoFactorCode = "123456"
+
+func newTestAuthService(t *testing.T, repo *memAuthRepo, twoFactor TwoFactor, cfg AuthConfig) *AuthService {
+	t.Helper()
+
+	return NewAuthService(repo, newTestLogger(), nil, newTestHasher(t), &sequentialTokens{}, &queuedEmails{}, noTransaction{},
+		newTestRenderer(t), nil, &revokedUsers{}, twoFactor, nil, nil, nil, nil, cfg)
+}
+
+func testTwoFactorConfig() TwoFactorConfig {
+	return TwoFactorConfig{
+		ChallengeTTL:  time.Minute,
+		MaxAttempts:   3,
+		Lockout:       SignInLockoutConfig{MaxFailures: 5, Window: time.Hour},
+		RequiredRoles: []string{domain.AdminRoleName, domain.UniversityRoleName},
+	}
+}
+
+func TestSignInTwoFactorLockout(t *testing.T) {
+	user := domain.User{ID: 1, Username: "ivan", Role: domain.UserRole{Name: domain.UserRoleName}}
+	repo := newMemAuthRepo(user)
+	s := newTestAuthService(t, repo, &fixedTwoFactor{enabled: map[int]bool{user.ID: true}, code: testTwoFactorCode},
+		AuthConfig{TwoFactor: testTwoFactorConfig()})
+
+	challenge := func() string {
+		t.Helper()
+
+		res, err := s.completeSignIn(context.Background(), user, ClientInfo{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if res.ChallengeToken == "" || res.EnrolmentRequired {
+			t.Fatalf("unexpected sign in result %+v", res)
+		}
+		return res.ChallengeToken
+	}
+
+	signIn := func(challengeToken string, code string, wantErr error) {
+		t.Helper()
+
+		if _, err := s.SignInTwoFactor(context.Background(), challengeToken, code, ClientInfo{}); !errors.Is(err, wantErr) {
+			t.Fatalf("err = %v, want %v", err, wantErr)
+		}
+	}
+
+	first := challenge()
+	for i := 0; i < 3; i++ {
+		signIn(first, "000000", domain.ErrInvalidTwoFactorCode)
+	}
+	signIn(first, testTwoFactorCode, domain.ErrInvalidToken)
+
+	// a new challenge doesn't give the guesses back
+	second := challenge()
+	for i := 0; i < 2; i++ {
+		signIn(second, "000000", domain.ErrInvalidTwoFactorCode)
+	}
+	signIn(second, testTwoFactorCode, domain.ErrSignInLocked)
+	signIn(challenge(), testTwoFactorCode, domain.ErrSignInLocked)
+}
+
+func TestCompleteSignInTwoFactorEnrolment(t *testing.T) {
+	tests := []struct {
+		name              string
+		role              string
+		twoFactorEnabled  bool
+		wantEnrolment     bool
+		wantChallengeType int
+	}{
+		{name: "admin without 2FA", role: domain.AdminRoleName, wantEnrolment: true, wantChallengeType: domain.TokenTypeTwoFactorEnrolment},
+		{name: "university without 2FA", role: domain.UniversityRoleName, wantEnrolment: true, wantChallengeType: domain.TokenTypeTwoFactorEnrolment},
+		{name: "admin with 2FA", role: domain.AdminRoleName, twoFactorEnabled: true, wantChallengeType: domain.TokenTypeTwoFactorChallenge},
+		{name: "user with 2FA", role: domain.UserRoleName, twoFactorEnabled: true, wantChallengeType: domain.TokenTypeTwoFactorChallenge},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			user := domain.User{ID: 1, Username: "ivan", Role: domain.UserRole{Name: tt.role}}
+			repo := newMemAuthRepo(user)
+			s := newTestAuthService(t, repo, &fixedTwoFactor{enabled: map[int]bool{user.ID: tt.twoFactorEnabled}, code: testTwoFactorCode},
+				AuthConfig{TwoFactor: testTwoFactorConfig()})
+
+			res, err := s.completeSignIn(context.Background(), user, ClientInfo{})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if res.Tokens.AccessToken != "" || res.ChallengeToken == "" || res.EnrolmentRequired != tt.wantEnrolment {
+				t.Fatalf("unexpected sign in result %+v", res)
+			}
+			if len(repo.tokens) != 1 || repo.tokens[0].tokenType != tt.wantChallengeType {
+				t.Fatalf("challenge tokens = %+v, want one of type %d", repo.tokens, tt.wantChallengeType)
+			}
+		})
+	}
+}
+
+func TestConfirmTwoFactorOnSignIn(t *testing.T) {
+	admin := domain.User{ID: 1, Username: "admin", Role: domain.UserRole{Name: domain.AdminRoleName}}
+	repo := newMemAuthRepo(admin)
+	s := newTestAuthService(t, repo, &fixedTwoFactor{enabled: map[int]bool{}, code: testTwoFactorCode},
+		AuthConfig{TwoFactor: testTwoFactorConfig()})
+
+	res, err := s.completeSignIn(context.Background(), admin, ClientInfo{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the enrolment token isn't a challenge for the second step
+	if _, err := s.SignInTwoFactor(context.Background(), res.ChallengeToken, testTwoFactorCode, ClientInfo{}); !errors.Is(err, domain.ErrInvalidToken) {
+		t.Fatalf("err = %v, want %v", err, domain.ErrInvalidToken)
+	}
+
+	// wrong codes count towards the lockout like those of the second step
+	for i := 0; i < 3; i++ {
+		if _, _, err := s.ConfirmTwoFactorOnSignIn(context.Background(), res.ChallengeToken, "000000", ClientInfo{}); !errors.Is(err, domain.ErrInvalidTwoFactorCode) {
+			t.Fatalf("err = %v, want %v", err, domain.ErrInvalidTwoFactorCode)
+		}
+	}
+
+	failures, err := repo.CountUserTokens(context.Background(), admin.ID, domain.TokenTypeTwoFactorFailure)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if failures != 3 {
+		t.Fatalf("failures = %d, want 3", failures)
+	}
+}
+
+func TestResetPassword(t *testing.T) {
+	confirmed := domain.User{ID: 1, Username: "ivan", Email: "ivan@example.com", IsConfirm: true, Locale: "ru"}
+	unconfirmed := domain.User{ID: 2, Username: "petr", Email: "petr@example.com", Locale: "ru"}
+
+	repo := newMemAuthRepo(confirmed, unconfirmed)
+	s := newTestAuthService(t, repo, &fixedTwoFactor{}, AuthConfig{
+		FrontendURL:                 "https://edutour.test",
+		VerificationCode:            VerificationCodeConfig{Length: 6, TTL: time.Minute, MaxAttempts: 5},
+		PasswordResetResendInterval: time.Minute,
+	})
+	outbox := s.outbox.(*queuedEmails)
+
+	// none of the requests is reported, only the first to a confirmed user sends an email
+	tests := []struct {
+		name      string
+		email     string
+		wantSends int
+	}{
+		{name: "unknown email", email: "nobody@example.com", wantSends: 0},
+		{name: "unconfirmed user", email: unconfirmed.Email, wantSends: 0},
+		{name: "confirmed user", email: confirmed.Email, wantSends: 1},
+		{name: "again too soon", email: confirmed.Email, wantSends: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := s.ResetPassword(context.Background(), tt.email); err != nil {
+				t.Fatalf("err = %v, want nil", err)
+			}
+			if len(outbox.emails) != tt.wantSends {
+				t.Fatalf("sent %d emails, want %d", len(outbox.emails), tt.wantSends)
+			}
+		})
+	}
+
+	if to := outbox.emails[0].To; len(to) != 1 || to[0] != confirmed.Email {
+		t.Fatalf("email sent to %v, want %s", to, confirmed.Email)
+	}
+}
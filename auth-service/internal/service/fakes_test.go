@@ -0,0 +1,327 @@
+package service
+
+import (
+	"context"
+	"github.com/shamank/edutour-backend/auth-service/internal/domain"
+	"github.com/shamank/edutour-backend/auth-service/internal/repository"
+	"github.com/shamank/edutour-backend/auth-service/internal/templates"
+	"github.com/shamank/edutour-backend/auth-service/pkg/auth"
+	"github.com/shamank/edutour-backend/auth-service/pkg/hash"
+	"io"
+	"log/slog"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// memAuthRepo is an in-memory repository.Authorization shared by the service tests.
+// Only the methods the tests reach are implemented, the others panic.
+type memAuthRepo struct {
+	repository.Authorization
+	users  map[int]domain.User
+	tokens []memUserToken
+}
+
+type memUserToken struct {
+	userID      int
+	tokenType   int
+	tokenHash   string
+	blacklisted bool
+	attempts    int
+	createdAt   time.Time
+	expireAt    time.Time
+}
+
+func newMemAuthRepo(users ...domain.User) *memAuthRepo {
+	r := &memAuthRepo{users: make(map[int]domain.User)}
+	for _, user := range users {
+		r.users[user.ID] = user
+	}
+	return r
+}
+
+func (r *memAuthRepo) GetFullUserInfo(ctx context.Context, userID int) (domain.User, error) {
+	user, ok := r.users[userID]
+	if !ok {
+		return domain.User{}, domain.ErrUserNotFound
+	}
+	return user, nil
+}
+
+func (r *memAuthRepo) GetByCredentials(ctx context.Context, email string) (domain.User, error) {
+	for _, user := range r.users {
+		if user.Email == email {
+			return user, nil
+		}
+	}
+	return domain.User{}, domain.ErrUserNotFound
+}
+
+func (r *memAuthRepo) GetByPhone(ctx context.Context, phone string) (domain.User, error) {
+	for _, user := range r.users {
+		if user.Phone == phone {
+			return user, nil
+		}
+	}
+	return domain.User{}, domain.ErrUserNotFound
+}
+
+func (r *memAuthRepo) SetTokenResetPassword(ctx context.Context, email string, tokenHash string, expireAt int64) (int, error) {
+	user, err := r.GetByCredentials(ctx, email)
+	if err != nil || !user.IsConfirm {
+		return 0, domain.ErrUserNotFound
+	}
+	return user.ID, r.CreateUserToken(ctx, user.ID, domain.TokenTypePasswordReset, tokenHash, expireAt)
+}
+
+func (r *memAuthRepo) CreateUserToken(ctx context.Context, userID int, tokenType int, tokenHash string, expireAt int64) error {
+	r.tokens = append(r.tokens, memUserToken{
+		userID:    userID,
+		tokenType: tokenType,
+		tokenHash: tokenHash,
+		createdAt: time.Now(),
+		expireAt:  time.Unix(expireAt, 0),
+	})
+	return nil
+}
+
+func (r *memAuthRepo) RevokeUserTokensOfType(ctx context.Context, userID int, tokenType int) error {
+	for i, token := range r.tokens {
+		if token.userID == userID && token.tokenType == tokenType {
+			r.tokens[i].blacklisted = true
+		}
+	}
+	return nil
+}
+
+func (r *memAuthRepo) HasRecentUserToken(ctx context.Context, userID int, tokenType int, period time.Duration) (bool, error) {
+	for _, token := range r.tokens {
+		if token.userID == userID && token.tokenType == tokenType && time.Since(token.createdAt) < period {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (r *memAuthRepo) GetUserByToken(ctx context.Context, tokenType int, tokenHash string, maxAttempts int) (domain.User, error) {
+	for _, token := range r.tokens {
+		if token.tokenType == tokenType && token.tokenHash == tokenHash && !token.blacklisted &&
+			token.expireAt.After(time.Now()) && token.attempts < maxAttempts {
+			return r.users[token.userID], nil
+		}
+	}
+	return domain.User{}, domain.ErrInvalidToken
+}
+
+func (r *memAuthRepo) GetUserCode(ctx context.Context, userID int, tokenType int) (domain.UserCode, error) {
+	for i := len(r.tokens) - 1; i >= 0; i-- {
+		token := r.tokens[i]
+		if token.userID == userID && token.tokenType == tokenType && !token.blacklisted && token.expireAt.After(time.Now()) {
+			return domain.UserCode{UserID: userID, TokenHash: token.tokenHash, Attempts: token.attempts}, nil
+		}
+	}
+	return domain.UserCode{}, domain.ErrInvalidVerificationCode
+}
+
+func (r *memAuthRepo) AddUserTokenAttempt(ctx context.Context, tokenType int, tokenHash string) error {
+	for i, token := range r.tokens {
+		if token.tokenType == tokenType && token.tokenHash == tokenHash {
+			r.tokens[i].attempts++
+		}
+	}
+	return nil
+}
+
+func (r *memAuthRepo) RevokeUserToken(ctx context.Context, tokenType int, tokenHash string) error {
+	for i, token := range r.tokens {
+		if token.tokenType == tokenType && token.tokenHash == tokenHash && !token.blacklisted {
+			r.tokens[i].blacklisted = true
+			return nil
+		}
+	}
+	return domain.ErrInvalidToken
+}
+
+func (r *memAuthRepo) CountUserTokens(ctx context.Context, userID int, tokenType int) (int, error) {
+	count := 0
+	for _, token := range r.tokens {
+		if token.userID == userID && token.tokenType == tokenType && !token.blacklisted && token.expireAt.After(time.Now()) {
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (r *memAuthRepo) IsUserBanned(ctx context.Context, userID int) (bool, error) {
+	return !r.users[userID].BannedAt.IsZero(), nil
+}
+
+// useToken blacklists an unused, unexpired token of the user, as repositories do in the same
+// transaction as the change the token confirms. It reports whether there was such a token.
+func (r *memAuthRepo) useToken(userID int, tokenType int, tokenHash string) bool {
+	for i, token := range r.tokens {
+		if token.userID == userID && token.tokenType == tokenType && token.tokenHash == tokenHash &&
+			!token.blacklisted && token.expireAt.After(time.Now()) {
+			r.tokens[i].blacklisted = true
+			return true
+		}
+	}
+	return false
+}
+
+// memRoleRepo is an in-memory repository.Roles. userRoles maps user ids to role ids.
+type memRoleRepo struct {
+	repository.Roles
+	roles     map[int]domain.Role
+	userRoles map[int]int
+}
+
+func (r *memRoleRepo) GetRole(ctx context.Context, roleID int) (domain.Role, error) {
+	role, ok := r.roles[roleID]
+	if !ok {
+		return domain.Role{}, domain.ErrRoleNotFound
+	}
+	return role, nil
+}
+
+func (r *memRoleRepo) CreateRole(ctx context.Context, role domain.Role) (int, error) {
+	role.ID = len(r.roles) + 1
+	for _, existing := range r.roles {
+		if existing.Name == role.Name {
+			return 0, domain.ErrRoleAlreadyExists
+		}
+		role.ID = max(role.ID, existing.ID+1)
+	}
+	r.roles[role.ID] = role
+	return role.ID, nil
+}
+
+func (r *memRoleRepo) UpdateRole(ctx context.Context, role domain.Role) error {
+	existing, ok := r.roles[role.ID]
+	if !ok {
+		return domain.ErrRoleNotFound
+	}
+	existing.Description = role.Description
+	existing.Permissions = role.Permissions
+	r.roles[role.ID] = existing
+	return nil
+}
+
+func (r *memRoleRepo) GetUserRole(ctx context.Context, userID int) (domain.Role, error) {
+	roleID, ok := r.userRoles[userID]
+	if !ok {
+		return domain.Role{}, domain.ErrUserNotFound
+	}
+	return r.GetRole(ctx, roleID)
+}
+
+// noTransaction is a repository.Transactor that runs the function as is.
+type noTransaction struct{}
+
+func (noTransaction) WithinTransaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	return fn(ctx)
+}
+
+// queuedEmails is a repository.Outbox that keeps the queued emails.
+type queuedEmails struct {
+	repository.Outbox
+	emails []domain.OutboxEmail
+	dead   []int
+}
+
+func (o *queuedEmails) EnqueueEmail(ctx context.Context, email domain.OutboxEmail) error {
+	email.ID = len(o.emails) + 1
+	o.emails = append(o.emails, email)
+	return nil
+}
+
+func (o *queuedEmails) ClaimEmails(ctx context.Context, limit int, lease time.Duration) ([]domain.OutboxEmail, error) {
+	return append([]domain.OutboxEmail(nil), o.emails...), nil
+}
+
+func (o *queuedEmails) MarkEmailFailed(ctx context.Context, id int, lastError string, retryIn time.Duration, dead bool) error {
+	if dead {
+		o.dead = append(o.dead, id)
+	}
+	return nil
+}
+
+// sequentialTokens is an auth.TokenManager that generates token-1, token-2 and so on.
+type sequentialTokens struct {
+	auth.TokenManager
+	generated int
+}
+
+func (m *sequentialTokens) GenerateToken(byteSize int) (string, error) {
+	m.generated++
+	return "token-" + strconv.Itoa(m.generated), nil
+}
+
+// fixedTwoFactor is a TwoFactor that accepts only code from the users in enabled.
+type fixedTwoFactor struct {
+	TwoFactor
+	enabled map[int]bool
+	code    string
+}
+
+func (f *fixedTwoFactor) IsEnabled(ctx context.Context, userID int) (bool, error) {
+	return f.enabled[userID], nil
+}
+
+func (f *fixedTwoFactor) VerifyCode(ctx context.Context, userID int, code string) error {
+	if !f.enabled[userID] || code != f.code {
+		return domain.ErrInvalidTwoFactorCode
+	}
+	return nil
+}
+
+func (f *fixedTwoFactor) ConfirmTOTP(ctx context.Context, userID int, code string) ([]string, error) {
+	if code != f.code {
+		return nil, domain.ErrInvalidTwoFactorCode
+	}
+	f.enabled[userID] = true
+	return []string{"recovery"}, nil
+}
+
+// revokedUsers is a Revocations that records whose tokens were revoked.
+type revokedUsers struct {
+	Revocations
+	users []int
+	roles []int
+}
+
+func (r *revokedUsers) RevokeUserTokens(ctx context.Context, userID int) error {
+	r.users = append(r.users, userID)
+	return nil
+}
+
+func (r *revokedUsers) RevokeRoleTokens(ctx context.Context, roleID int) error {
+	r.roles = append(r.roles, roleID)
+	return nil
+}
+
+func newTestLogger() *slog.Logger {
+	return slog.New(slog.NewTextHandler(io.Discard, nil))
+}
+
+func newTestHasher(t *testing.T) hash.TokenHasher {
+	t.Helper()
+
+	tokenHasher, err := hash.NewHMACHasher("0123456789abcdef0123456789abcdef")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return tokenHasher
+}
+
+func newTestRenderer(t *testing.T) *templates.Renderer {
+	t.Helper()
+
+	renderer, err := templates.NewRenderer()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return renderer
+}
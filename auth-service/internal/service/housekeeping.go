@@ -0,0 +1,146 @@
+package service
+
+import (
+	"context"
+	"github.com/shamank/edutour-backend/auth-service/internal/repository"
+	"log/slog"
+	"time"
+)
+
+const (
+	JobPurgeExpiredTokens       = "purge-expired-tokens"
+	JobPurgeUnconfirmedAccounts = "purge-unconfirmed-accounts"
+	JobPurgeSentEmails          = "purge-sent-emails"
+)
+
+var defaultJobSchedules = map[string]string{
+	JobPurgeExpiredTokens:       "@every 1h",
+	JobPurgeUnconfirmedAccounts: "@every 1h",
+	JobPurgeSentEmails:          "@every 24h",
+}
+
+// HousekeepingService deletes rows that are of no use anymore.
+type HousekeepingService struct {
+	repo           repository.Housekeeping
+	authRepo       repository.Authorization
+	logger         *slog.Logger
+	unconfirmedTTL time.Duration
+	sentRetention  time.Duration
+	deadRetention  time.Duration
+}
+
+func NewHousekeepingService(repo repository.Housekeeping, authRepo repository.Authorization, logger *slog.Logger, unconfirmedTTL time.Duration, sentRetention time.Duration, deadRetention time.Duration) *HousekeepingService {
+	return &HousekeepingService{
+		repo:           repo,
+		authRepo:       authRepo,
+		logger:         logger,
+		unconfirmedTTL: unconfirmedTTL,
+		sentRetention:  sentRetention,
+		deadRetention:  deadRetention,
+	}
+}
+
+// PurgeExpiredTokens deletes expired one-off tokens, refresh tokens with the sessions they ended,
+// access token revocations and passkey ceremonies.
+func (s *HousekeepingService) PurgeExpiredTokens(ctx context.Context) error {
+	const op = "Service.HousekeepingService.PurgeExpiredTokens"
+	logger := s.logger.With(slog.String("op", op))
+
+	// sessions go after refresh tokens, because a session ends with its last token
+	steps := []struct {
+		table  string
+		delete func(ctx context.Context) (int64, error)
+	}{
+		{"user_tokens", s.repo.DeleteExpiredUserTokens},
+		{"refresh_tokens", s.repo.DeleteExpiredRefreshTokens},
+		{"sessions", s.repo.DeleteEndedSessions},
+		{"revoked_access_tokens", s.repo.DeleteExpiredRevokedAccessTokens},
+		{"webauthn_sessions", s.repo.DeleteExpiredWebAuthnSessions},
+	}
+
+	for _, step := range steps {
+		deleted, err := step.delete(ctx)
+		if err != nil {
+			return err
+		}
+		if deleted > 0 {
+			logger.Info("expired rows deleted", slog.String("table", step.table), slog.Int64("count", deleted))
+		}
+	}
+
+	return nil
+}
+
+// PurgeUnconfirmedAccounts deletes accounts that have stayed unconfirmed for longer than unconfirmedTTL.
+func (s *HousekeepingService) PurgeUnconfirmedAccounts(ctx context.Context) error {
+	const op = "Service.HousekeepingService.PurgeUnconfirmedAccounts"
+	logger := s.logger.With(slog.String("op", op))
+
+	users, err := s.authRepo.DeleteUnconfirmedUsers(ctx, s.unconfirmedTTL)
+	if err != nil {
+		return err
+	}
+
+	for _, user := range users {
+		logger.Info("deleted unconfirmed account",
+			slog.Int("user_id", user.ID),
+			slog.String("username", user.Username),
+			slog.Time("created_at", user.CreatedAt))
+	}
+
+	if len(users) > 0 {
+		logger.Info("unconfirmed accounts purged", slog.Int("count", len(users)))
+	}
+
+	return nil
+}
+
+// PurgeSentEmails deletes delivered emails older than sentRetention. Dead ones are kept
+// for inspection and retries for deadRetention.
+func (s *HousekeepingService) PurgeSentEmails(ctx context.Context) error {
+	const op = "Service.HousekeepingService.PurgeSentEmails"
+	logger := s.logger.With(slog.String("op", op))
+
+	deleted, err := s.repo.DeleteSentEmails(ctx, s.sentRetention)
+	if err != nil {
+		return err
+	}
+
+	if deleted > 0 {
+		logger.Info("sent emails deleted", slog.Int64("count", deleted))
+	}
+
+	deleted, err = s.repo.DeleteDeadEmails(ctx, s.deadRetention)
+	if err != nil {
+		return err
+	}
+
+	if deleted > 0 {
+		logger.Info("dead emails deleted", slog.Int64("count", deleted))
+	}
+
+	return nil
+}
+
+// RegisterHousekeepingJobs adds the housekeeping jobs to the scheduler.
+// schedules overrides the default schedules by job name.
+func RegisterHousekeepingJobs(scheduler Scheduler, housekeeping Housekeeping, schedules map[string]string) error {
+	jobs := map[string]JobFunc{
+		JobPurgeExpiredTokens:       housekeeping.PurgeExpiredTokens,
+		JobPurgeUnconfirmedAccounts: housekeeping.PurgeUnconfirmedAccounts,
+		JobPurgeSentEmails:          housekeeping.PurgeSentEmails,
+	}
+
+	for name, run := range jobs {
+		spec, ok := schedules[name]
+		if !ok {
+			spec = defaultJobSchedules[name]
+		}
+
+		if err := scheduler.Register(name, spec, run); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,462 @@
+package service
+
+import (
+	"context"
+	"github.com/shamank/edutour-backend/auth-service/internal/domain"
+	"github.com/shamank/edutour-backend/auth-service/internal/repository"
+	"github.com/shamank/edutour-backend/auth-service/internal/templates"
+	"github.com/shamank/edutour-backend/auth-service/pkg/auth"
+	"github.com/shamank/edutour-backend/auth-service/pkg/hash"
+	"log/slog"
+	"strings"
+	"time"
+)
+
+type OrganizationInput struct {
+	Name string
+	// Domain is the domain of the organization's email addresses; it can't be changed later
+	Domain string
+}
+
+type InvitationInput struct {
+	Email string
+	Role  string
+}
+
+// OrganizationConfig holds tunables of organizations.
+type OrganizationConfig struct {
+	// FrontendURL is the base of invitation links.
+	FrontendURL string
+	// InvitationTTL is how long an invitation can be answered.
+	InvitationTTL time.Duration
+}
+
+// OrganizationService manages organizations, their members and invitations.
+// Access tokens carry the organization and the role of the user in it once the organization
+// is verified, so every change of a membership revokes the access tokens of the member,
+// who gets up-to-date ones on refresh.
+type OrganizationService struct {
+	repo         repository.Organizations
+	users        repository.Authorization
+	outbox       repository.Outbox
+	transactor   repository.Transactor
+	templates    EmailTemplates
+	tokenManager auth.TokenManager
+	tokenHasher  hash.TokenHasher
+	revocations  Revocations
+	logger       *slog.Logger
+	cfg          OrganizationConfig
+}
+
+func NewOrganizationService(repo repository.Organizations, users repository.Authorization, outbox repository.Outbox, transactor repository.Transactor, templates EmailTemplates, tokenManager auth.TokenManager, tokenHasher hash.TokenHasher, revocations Revocations, logger *slog.Logger, cfg OrganizationConfig) *OrganizationService {
+	return &OrganizationService{
+		repo:         repo,
+		users:        users,
+		outbox:       outbox,
+		transactor:   transactor,
+		templates:    templates,
+		tokenManager: tokenManager,
+		tokenHasher:  tokenHasher,
+		revocations:  revocations,
+		logger:       logger,
+		cfg:          cfg,
+	}
+}
+
+// CreateOrganization creates an unverified organization owned by the user. Until an admin verifies it,
+// the domain isn't reserved and the organization isn't in access tokens.
+func (s *OrganizationService) CreateOrganization(ctx context.Context, userID int, input OrganizationInput) (domain.Organization, error) {
+	const op = "Service.OrganizationService.CreateOrganization"
+	logger := s.logger.With(slog.String("op", op))
+
+	orgID, err := s.repo.CreateOrganization(ctx, domain.Organization{
+		Name:   strings.TrimSpace(input.Name),
+		Domain: strings.TrimPrefix(strings.ToLower(strings.TrimSpace(input.Domain)), "@"),
+	}, userID)
+	if err != nil {
+		return domain.Organization{}, err
+	}
+
+	logger.Info("organization created", slog.Int("user_id", userID), slog.Int("organization_id", orgID))
+
+	if err := s.revocations.RevokeUserTokens(ctx, userID); err != nil {
+		return domain.Organization{}, err
+	}
+
+	return s.repo.GetOrganization(ctx, orgID)
+}
+
+// GetOrganization returns the organization to one of its members.
+func (s *OrganizationService) GetOrganization(ctx context.Context, userID int, orgID int) (domain.Organization, error) {
+	if _, err := s.member(ctx, userID, orgID); err != nil {
+		return domain.Organization{}, err
+	}
+
+	return s.repo.GetOrganization(ctx, orgID)
+}
+
+// UpdateOrganization renames the organization, which only its owners may do.
+func (s *OrganizationService) UpdateOrganization(ctx context.Context, userID int, orgID int, name string) (domain.Organization, error) {
+	member, err := s.member(ctx, userID, orgID)
+	if err != nil {
+		return domain.Organization{}, err
+	}
+
+	if !member.IsOwner() {
+		return domain.Organization{}, domain.ErrOrganizationForbidden
+	}
+
+	if err := s.repo.UpdateOrganization(ctx, domain.Organization{
+		ID:   orgID,
+		Name: strings.TrimSpace(name),
+	}); err != nil {
+		return domain.Organization{}, err
+	}
+
+	return s.repo.GetOrganization(ctx, orgID)
+}
+
+// DeleteOrganization deletes the organization, which only its owners may do.
+func (s *OrganizationService) DeleteOrganization(ctx context.Context, userID int, orgID int) error {
+	const op = "Service.OrganizationService.DeleteOrganization"
+	logger := s.logger.With(slog.String("op", op))
+
+	var members []domain.OrganizationMember
+
+	err := s.transactor.WithinTransaction(ctx, func(ctx context.Context) error {
+		if err := s.repo.LockOrganization(ctx, orgID); err != nil {
+			return err
+		}
+
+		member, err := s.member(ctx, userID, orgID)
+		if err != nil {
+			return err
+		}
+
+		if !member.IsOwner() {
+			return domain.ErrOrganizationForbidden
+		}
+
+		if members, err = s.repo.GetMembers(ctx, orgID); err != nil {
+			return err
+		}
+
+		return s.repo.DeleteOrganization(ctx, orgID)
+	})
+	if err != nil {
+		return err
+	}
+
+	logger.Info("organization deleted", slog.Int("user_id", userID), slog.Int("organization_id", orgID))
+
+	for _, m := range members {
+		if err := s.revocations.RevokeUserTokens(ctx, m.UserID); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Membership returns the organization membership of the user, ErrNotOrganizationMember if there is none.
+func (s *OrganizationService) Membership(ctx context.Context, userID int) (domain.OrganizationMember, error) {
+	return s.repo.GetMembership(ctx, userID)
+}
+
+func (s *OrganizationService) GetMembers(ctx context.Context, userID int, orgID int) ([]domain.OrganizationMember, error) {
+	if _, err := s.member(ctx, userID, orgID); err != nil {
+		return nil, err
+	}
+
+	return s.repo.GetMembers(ctx, orgID)
+}
+
+// ChangeMemberRole sets the organization role of a member, which only owners may do.
+// The last owner can't be demoted.
+func (s *OrganizationService) ChangeMemberRole(ctx context.Context, userID int, orgID int, memberID int, role string) error {
+	const op = "Service.OrganizationService.ChangeMemberRole"
+	logger := s.logger.With(slog.String("op", op))
+
+	if !domain.IsOrganizationRole(role) {
+		return domain.ErrInvalidOrganizationRole
+	}
+
+	err := s.transactor.WithinTransaction(ctx, func(ctx context.Context) error {
+		if err := s.repo.LockOrganization(ctx, orgID); err != nil {
+			return err
+		}
+
+		actor, err := s.member(ctx, userID, orgID)
+		if err != nil {
+			return err
+		}
+
+		if !actor.IsOwner() {
+			return domain.ErrOrganizationForbidden
+		}
+
+		target, err := s.member(ctx, memberID, orgID)
+		if err != nil {
+			return err
+		}
+
+		if target.IsOwner() && role != domain.OrganizationRoleOwner {
+			if err := s.checkNotLastOwner(ctx, orgID); err != nil {
+				return err
+			}
+		}
+
+		return s.repo.SetMemberRole(ctx, orgID, memberID, role)
+	})
+	if err != nil {
+		return err
+	}
+
+	logger.Info("organization member role changed", slog.Int("user_id", userID), slog.Int("organization_id", orgID),
+		slog.Int("member_id", memberID), slog.String("role", role))
+
+	return s.revocations.RevokeUserTokens(ctx, memberID)
+}
+
+// RemoveMember removes a member from the organization. Owners may remove anyone,
+// any member may leave. The last owner can't leave, the organization is deleted instead.
+func (s *OrganizationService) RemoveMember(ctx context.Context, userID int, orgID int, memberID int) error {
+	const op = "Service.OrganizationService.RemoveMember"
+	logger := s.logger.With(slog.String("op", op))
+
+	err := s.transactor.WithinTransaction(ctx, func(ctx context.Context) error {
+		if err := s.repo.LockOrganization(ctx, orgID); err != nil {
+			return err
+		}
+
+		actor, err := s.member(ctx, userID, orgID)
+		if err != nil {
+			return err
+		}
+
+		if !actor.IsOwner() && userID != memberID {
+			return domain.ErrOrganizationForbidden
+		}
+
+		target, err := s.member(ctx, memberID, orgID)
+		if err != nil {
+			return err
+		}
+
+		if target.IsOwner() {
+			if err := s.checkNotLastOwner(ctx, orgID); err != nil {
+				return err
+			}
+		}
+
+		return s.repo.RemoveMember(ctx, orgID, memberID)
+	})
+	if err != nil {
+		return err
+	}
+
+	logger.Info("organization member removed", slog.Int("user_id", userID), slog.Int("organization_id", orgID),
+		slog.Int("member_id", memberID))
+
+	return s.revocations.RevokeUserTokens(ctx, memberID)
+}
+
+// Invite emails an invitation to join the organization. Owners and managers may invite,
+// only owners may invite owners. Inviting an email again replaces its pending invitation.
+func (s *OrganizationService) Invite(ctx context.Context, userID int, orgID int, input InvitationInput) (domain.OrganizationInvitation, error) {
+	const op = "Service.OrganizationService.Invite"
+	logger := s.logger.With(slog.String("op", op))
+
+	if !domain.IsOrganizationRole(input.Role) {
+		return domain.OrganizationInvitation{}, domain.ErrInvalidOrganizationRole
+	}
+
+	inviter, err := s.member(ctx, userID, orgID)
+	if err != nil {
+		return domain.OrganizationInvitation{}, err
+	}
+
+	if !inviter.CanInvite() || (input.Role == domain.OrganizationRoleOwner && !inviter.IsOwner()) {
+		return domain.OrganizationInvitation{}, domain.ErrOrganizationForbidden
+	}
+
+	org, err := s.repo.GetOrganization(ctx, orgID)
+	if err != nil {
+		return domain.OrganizationInvitation{}, err
+	}
+
+	token, err := s.tokenManager.GenerateToken(32)
+	if err != nil {
+		return domain.OrganizationInvitation{}, err
+	}
+
+	invitation := domain.OrganizationInvitation{
+		OrganizationID:   orgID,
+		OrganizationName: org.Name,
+		Email:            strings.ToLower(strings.TrimSpace(input.Email)),
+		Role:             input.Role,
+		TokenHash:        s.tokenHasher.Hash(token),
+		InvitedBy:        userID,
+		Status:           domain.InvitationStatusPending,
+		ExpiresAt:        time.Now().Add(s.cfg.InvitationTTL),
+		CreatedAt:        time.Now(),
+	}
+
+	err = s.transactor.WithinTransaction(ctx, func(ctx context.Context) error {
+		id, err := s.repo.CreateInvitation(ctx, invitation)
+		if err != nil {
+			return err
+		}
+		invitation.ID = id
+
+		// the invitee may have no account yet, so the email is in the inviter's language
+		email, err := s.templates.Render(inviter.Locale, templates.EmailOrganizationInvitation, templates.InvitationData{
+			Inviter:      inviter.Username,
+			Organization: org.Name,
+			Role:         input.Role,
+			Link:         frontendLink(s.cfg.FrontendURL, "organizations/invitations", token),
+			LinkTTL:      int(s.cfg.InvitationTTL.Hours() / 24),
+		})
+		if err != nil {
+			return err
+		}
+
+		return s.outbox.EnqueueEmail(ctx, domain.OutboxEmail{
+			To:       []string{invitation.Email},
+			Subject:  email.Subject,
+			Body:     email.Text,
+			HTMLBody: email.HTML,
+		})
+	})
+	if err != nil {
+		return domain.OrganizationInvitation{}, err
+	}
+
+	logger.Info("organization invitation sent", slog.Int("user_id", userID), slog.Int("organization_id", orgID),
+		slog.Int("invitation_id", invitation.ID))
+
+	return invitation, nil
+}
+
+// GetInvitations returns the pending invitations of the organization to its owners and managers.
+func (s *OrganizationService) GetInvitations(ctx context.Context, userID int, orgID int) ([]domain.OrganizationInvitation, error) {
+	member, err := s.member(ctx, userID, orgID)
+	if err != nil {
+		return nil, err
+	}
+
+	if !member.CanInvite() {
+		return nil, domain.ErrOrganizationForbidden
+	}
+
+	return s.repo.GetInvitations(ctx, orgID)
+}
+
+func (s *OrganizationService) RevokeInvitation(ctx context.Context, userID int, orgID int, invitationID int) error {
+	member, err := s.member(ctx, userID, orgID)
+	if err != nil {
+		return err
+	}
+
+	if !member.CanInvite() {
+		return domain.ErrOrganizationForbidden
+	}
+
+	return s.repo.RevokeInvitation(ctx, orgID, invitationID)
+}
+
+// AcceptInvitation makes the user a member of the organization the invitation is to.
+// The invitation must have been sent to the user's email.
+func (s *OrganizationService) AcceptInvitation(ctx context.Context, userID int, token string) (domain.OrganizationMember, error) {
+	const op = "Service.OrganizationService.AcceptInvitation"
+	logger := s.logger.With(slog.String("op", op))
+
+	var invitation domain.OrganizationInvitation
+
+	err := s.transactor.WithinTransaction(ctx, func(ctx context.Context) error {
+		var err error
+		if invitation, err = s.invitationFor(ctx, userID, token); err != nil {
+			return err
+		}
+
+		if err := s.repo.AddMember(ctx, domain.OrganizationMember{
+			OrganizationID: invitation.OrganizationID,
+			UserID:         userID,
+			Role:           invitation.Role,
+		}); err != nil {
+			return err
+		}
+
+		return s.repo.AnswerInvitation(ctx, invitation.ID, domain.InvitationStatusAccepted)
+	})
+	if err != nil {
+		return domain.OrganizationMember{}, err
+	}
+
+	logger.Info("organization invitation accepted", slog.Int("user_id", userID),
+		slog.Int("organization_id", invitation.OrganizationID), slog.Int("invitation_id", invitation.ID))
+
+	if err := s.revocations.RevokeUserTokens(ctx, userID); err != nil {
+		return domain.OrganizationMember{}, err
+	}
+
+	return s.repo.GetMembership(ctx, userID)
+}
+
+// DeclineInvitation declines an invitation sent to the user's email.
+func (s *OrganizationService) DeclineInvitation(ctx context.Context, userID int, token string) error {
+	return s.transactor.WithinTransaction(ctx, func(ctx context.Context) error {
+		invitation, err := s.invitationFor(ctx, userID, token)
+		if err != nil {
+			return err
+		}
+
+		return s.repo.AnswerInvitation(ctx, invitation.ID, domain.InvitationStatusDeclined)
+	})
+}
+
+// invitationFor returns the pending invitation with the token if it was sent to the user's email.
+func (s *OrganizationService) invitationFor(ctx context.Context, userID int, token string) (domain.OrganizationInvitation, error) {
+	user, err := s.users.GetFullUserInfo(ctx, userID)
+	if err != nil {
+		return domain.OrganizationInvitation{}, err
+	}
+
+	invitation, err := s.repo.GetInvitationByToken(ctx, s.tokenHasher.Hash(token))
+	if err != nil {
+		return domain.OrganizationInvitation{}, err
+	}
+
+	if !strings.EqualFold(invitation.Email, user.Email) {
+		return domain.OrganizationInvitation{}, domain.ErrInvitationEmailMismatch
+	}
+
+	return invitation, nil
+}
+
+// member returns the membership of the user in the organization, ErrNotOrganizationMember if the user isn't in it.
+func (s *OrganizationService) member(ctx context.Context, userID int, orgID int) (domain.OrganizationMember, error) {
+	member, err := s.repo.GetMembership(ctx, userID)
+	if err != nil {
+		return domain.OrganizationMember{}, err
+	}
+
+	if member.OrganizationID != orgID {
+		return domain.OrganizationMember{}, domain.ErrNotOrganizationMember
+	}
+
+	return member, nil
+}
+
+func (s *OrganizationService) checkNotLastOwner(ctx context.Context, orgID int) error {
+	owners, err := s.repo.CountOwners(ctx, orgID)
+	if err != nil {
+		return err
+	}
+
+	if owners <= 1 {
+		return domain.ErrLastOrganizationOwner
+	}
+
+	return nil
+}
@@ -0,0 +1,310 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"github.com/shamank/edutour-backend/auth-service/internal/domain"
+	"github.com/shamank/edutour-backend/auth-service/internal/repository"
+	"github.com/shamank/edutour-backend/auth-service/pkg/hash"
+	"testing"
+	"time"
+)
+
+const testOrganizationID = 1
+
+// memOrganizationRepo is an in-memory repository.Organizations with a single organization.
+type memOrganizationRepo struct {
+	repository.Organizations
+	members     map[int]domain.OrganizationMember
+	invitations []domain.OrganizationInvitation
+}
+
+func (r *memOrganizationRepo) GetOrganization(ctx context.Context, orgID int) (domain.Organization, error) {
+	if orgID != testOrganizationID {
+		return domain.Organization{}, domain.ErrOrganizationNotFound
+	}
+	return domain.Organization{ID: orgID, Name: "MSU", Domain: "msu.ru"}, nil
+}
+
+func (r *memOrganizationRepo) LockOrganization(ctx context.Context, orgID int) error {
+	return nil
+}
+
+func (r *memOrganizationRepo) GetMembership(ctx context.Context, userID int) (domain.OrganizationMember, error) {
+	member, ok := r.members[userID]
+	if !ok {
+		return domain.OrganizationMember{}, domain.ErrNotOrganizationMember
+	}
+	return member, nil
+}
+
+func (r *memOrganizationRepo) AddMember(ctx context.Context, member domain.OrganizationMember) error {
+	if _, ok := r.members[member.UserID]; ok {
+		return domain.ErrAlreadyOrganizationMember
+	}
+	r.members[member.UserID] = member
+	return nil
+}
+
+func (r *memOrganizationRepo) SetMemberRole(ctx context.Context, orgID int, userID int, role string) error {
+	member := r.members[userID]
+	member.Role = role
+	r.members[userID] = member
+	return nil
+}
+
+func (r *memOrganizationRepo) RemoveMember(ctx context.Context, orgID int, userID int) error {
+	delete(r.members, userID)
+	return nil
+}
+
+func (r *memOrganizationRepo) CountOwners(ctx context.Context, orgID int) (int, error) {
+	owners := 0
+	for _, member := range r.members {
+		if member.OrganizationID == orgID && member.IsOwner() {
+			owners++
+		}
+	}
+	return owners, nil
+}
+
+func (r *memOrganizationRepo) CreateInvitation(ctx context.Context, invitation domain.OrganizationInvitation) (int, error) {
+	invitation.ID = len(r.invitations) + 1
+	r.invitations = append(r.invitations, invitation)
+	return invitation.ID, nil
+}
+
+func (r *memOrganizationRepo) GetInvitationByToken(ctx context.Context, tokenHash string) (domain.OrganizationInvitation, error) {
+	for _, invitation := range r.invitations {
+		if invitation.TokenHash == tokenHash && invitation.Status == domain.InvitationStatusPending {
+			return invitation, nil
+		}
+	}
+	return domain.OrganizationInvitation{}, domain.ErrInvitationNotFound
+}
+
+func (r *memOrganizationRepo) AnswerInvitation(ctx context.Context, invitationID int, status string) error {
+	r.invitations[invitationID-1].Status = status
+	return nil
+}
+
+type organizationFixture struct {
+	service     *OrganizationService
+	repo        *memOrganizationRepo
+	outbox      *queuedEmails
+	revocations *revokedUsers
+	tokenHasher hash.TokenHasher
+}
+
+func newOrganizationFixture(t *testing.T, users []domain.User, members ...domain.OrganizationMember) organizationFixture {
+	t.Helper()
+
+	f := organizationFixture{
+		repo:        &memOrganizationRepo{members: make(map[int]domain.OrganizationMember)},
+		outbox:      &queuedEmails{},
+		revocations: &revokedUsers{},
+		tokenHasher: newTestHasher(t),
+	}
+	for _, member := range members {
+		f.repo.members[member.UserID] = member
+	}
+
+	f.service = NewOrganizationService(f.repo, newMemAuthRepo(users...), f.outbox, noTransaction{}, newTestRenderer(t),
+		&sequentialTokens{}, f.tokenHasher, f.revocations, newTestLogger(),
+		OrganizationConfig{FrontendURL: "https://edutour.test", InvitationTTL: 7 * 24 * time.Hour})
+
+	return f
+}
+
+func organizationMember(userID int, role string) domain.OrganizationMember {
+	return domain.OrganizationMember{OrganizationID: testOrganizationID, UserID: userID, Role: role}
+}
+
+func TestOrganizationLastOwner(t *testing.T) {
+	const (
+		ownerID   = 1
+		managerID = 2
+		coOwnerID = 3
+	)
+
+	changeRole := func(userID, memberID int, role string) func(s *OrganizationService) error {
+		return func(s *OrganizationService) error {
+			return s.ChangeMemberRole(context.Background(), userID, testOrganizationID, memberID, role)
+		}
+	}
+	remove := func(userID, memberID int) func(s *OrganizationService) error {
+		return func(s *OrganizationService) error {
+			return s.RemoveMember(context.Background(), userID, testOrganizationID, memberID)
+		}
+	}
+
+	tests := []struct {
+		name       string
+		coOwner    bool
+		action     func(s *OrganizationService) error
+		wantErr    error
+		wantOwners []int
+	}{
+		{name: "last owner demotes self", action: changeRole(ownerID, ownerID, domain.OrganizationRoleManager),
+			wantErr: domain.ErrLastOrganizationOwner, wantOwners: []int{ownerID}},
+		{name: "last owner leaves", action: remove(ownerID, ownerID),
+			wantErr: domain.ErrLastOrganizationOwner, wantOwners: []int{ownerID}},
+		{name: "owner demotes co-owner", coOwner: true, action: changeRole(ownerID, coOwnerID, domain.OrganizationRoleViewer),
+			wantOwners: []int{ownerID}},
+		{name: "co-owner leaves", coOwner: true, action: remove(coOwnerID, coOwnerID),
+			wantOwners: []int{ownerID}},
+		{name: "owner promotes manager", action: changeRole(ownerID, managerID, domain.OrganizationRoleOwner),
+			wantOwners: []int{ownerID, managerID}},
+		{name: "manager removes owner", action: remove(managerID, ownerID),
+			wantErr: domain.ErrOrganizationForbidden, wantOwners: []int{ownerID}},
+		{name: "manager demotes owner", action: changeRole(managerID, ownerID, domain.OrganizationRoleViewer),
+			wantErr: domain.ErrOrganizationForbidden, wantOwners: []int{ownerID}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			members := []domain.OrganizationMember{
+				organizationMember(ownerID, domain.OrganizationRoleOwner),
+				organizationMember(managerID, domain.OrganizationRoleManager),
+			}
+			if tt.coOwner {
+				members = append(members, organizationMember(coOwnerID, domain.OrganizationRoleOwner))
+			}
+			f := newOrganizationFixture(t, nil, members...)
+
+			err := tt.action(f.service)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("err = %v, want %v", err, tt.wantErr)
+			}
+
+			owners, _ := f.repo.CountOwners(context.Background(), testOrganizationID)
+			if owners != len(tt.wantOwners) {
+				t.Fatalf("owners = %d, want %d", owners, len(tt.wantOwners))
+			}
+			for _, userID := range tt.wantOwners {
+				if !f.repo.members[userID].IsOwner() {
+					t.Fatalf("user %d is not an owner", userID)
+				}
+			}
+
+			if (tt.wantErr == nil) != (len(f.revocations.users) == 1) {
+				t.Fatalf("revoked tokens of %v", f.revocations.users)
+			}
+		})
+	}
+}
+
+func TestOrganizationInvite(t *testing.T) {
+	const (
+		ownerID    = 1
+		managerID  = 2
+		viewerID   = 3
+		outsiderID = 4
+	)
+
+	tests := []struct {
+		name    string
+		userID  int
+		role    string
+		wantErr error
+	}{
+		{name: "owner invites owner", userID: ownerID, role: domain.OrganizationRoleOwner},
+		{name: "owner invites viewer", userID: ownerID, role: domain.OrganizationRoleViewer},
+		{name: "manager invites manager", userID: managerID, role: domain.OrganizationRoleManager},
+		{name: "manager invites owner", userID: managerID, role: domain.OrganizationRoleOwner, wantErr: domain.ErrOrganizationForbidden},
+		{name: "viewer invites viewer", userID: viewerID, role: domain.OrganizationRoleViewer, wantErr: domain.ErrOrganizationForbidden},
+		{name: "outsider invites viewer", userID: outsiderID, role: domain.OrganizationRoleViewer, wantErr: domain.ErrNotOrganizationMember},
+		{name: "unknown role", userID: ownerID, role: "admin", wantErr: domain.ErrInvalidOrganizationRole},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newOrganizationFixture(t, nil,
+				organizationMember(ownerID, domain.OrganizationRoleOwner),
+				organizationMember(managerID, domain.OrganizationRoleManager),
+				organizationMember(viewerID, domain.OrganizationRoleViewer),
+			)
+
+			invitation, err := f.service.Invite(context.Background(), tt.userID, testOrganizationID, InvitationInput{
+				Email: " Invitee@MSU.ru ",
+				Role:  tt.role,
+			})
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("err = %v, want %v", err, tt.wantErr)
+			}
+
+			if tt.wantErr != nil {
+				if len(f.repo.invitations) != 0 || len(f.outbox.emails) != 0 {
+					t.Fatalf("invitation was sent")
+				}
+				return
+			}
+
+			if invitation.Email != "invitee@msu.ru" || invitation.Role != tt.role {
+				t.Fatalf("invitation = %+v", invitation)
+			}
+			if len(f.outbox.emails) != 1 || f.outbox.emails[0].To[0] != "invitee@msu.ru" {
+				t.Fatalf("emails = %+v", f.outbox.emails)
+			}
+		})
+	}
+}
+
+func TestOrganizationAcceptInvitation(t *testing.T) {
+	const (
+		ownerID   = 1
+		inviteeID = 2
+		otherID   = 3
+	)
+
+	tests := []struct {
+		name    string
+		userID  int
+		token   string
+		wantErr error
+	}{
+		{name: "invitee accepts", userID: inviteeID, token: "invitation"},
+		{name: "another user accepts", userID: otherID, token: "invitation", wantErr: domain.ErrInvitationEmailMismatch},
+		{name: "unknown token", userID: inviteeID, token: "unknown", wantErr: domain.ErrInvitationNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newOrganizationFixture(t, []domain.User{
+				{ID: inviteeID, Email: "Invitee@msu.ru"},
+				{ID: otherID, Email: "other@msu.ru"},
+			}, organizationMember(ownerID, domain.OrganizationRoleOwner))
+
+			f.repo.invitations = []domain.OrganizationInvitation{{
+				ID:             1,
+				OrganizationID: testOrganizationID,
+				Email:          "invitee@msu.ru",
+				Role:           domain.OrganizationRoleManager,
+				TokenHash:      f.tokenHasher.Hash("invitation"),
+				Status:         domain.InvitationStatusPending,
+			}}
+
+			member, err := f.service.AcceptInvitation(context.Background(), tt.userID, tt.token)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("err = %v, want %v", err, tt.wantErr)
+			}
+
+			if tt.wantErr != nil {
+				if _, ok := f.repo.members[tt.userID]; ok {
+					t.Fatalf("user joined the organization")
+				}
+				if f.repo.invitations[0].Status != domain.InvitationStatusPending {
+					t.Fatalf("invitation status = %q, want pending", f.repo.invitations[0].Status)
+				}
+				return
+			}
+
+			if member.OrganizationID != testOrganizationID || member.Role != domain.OrganizationRoleManager {
+				t.Fatalf("member = %+v", member)
+			}
+			if f.repo.invitations[0].Status != domain.InvitationStatusAccepted {
+				t.Fatalf("invitation status = %q, want accepted", f.repo.invitations[0].Status)
+			}
+		})
+	}
+}
@@ -0,0 +1,208 @@
+package service
+
+import (
+	"context"
+	"github.com/shamank/edutour-backend/auth-service/internal/domain"
+	"github.com/shamank/edutour-backend/auth-service/internal/repository"
+	"github.com/shamank/edutour-backend/auth-service/pkg/email"
+	"github.com/shamank/edutour-backend/auth-service/pkg/encrypt"
+	"github.com/shamank/edutour-backend/auth-service/pkg/logger/sl"
+	"log/slog"
+	"time"
+)
+
+// deadEmailsLimit caps how many dead emails are listed at once.
+const deadEmailsLimit = 100
+
+// OutboxConfig configures delivery of queued emails.
+type OutboxConfig struct {
+	// PollInterval is how often the worker looks for due emails.
+	PollInterval time.Duration
+	BatchSize    int
+	// MaxAttempts is how many times an email is tried before it becomes dead.
+	MaxAttempts int
+	// BackoffBase is the pause after the first failure. It doubles with every next one up to BackoffMax.
+	BackoffBase time.Duration
+	BackoffMax  time.Duration
+	// Lease is how long a worker may take to deliver a claimed email before another worker takes it over.
+	Lease time.Duration
+	// SentRetention is how long delivered emails are kept.
+	SentRetention time.Duration
+	// DeadRetention is how long emails that ran out of attempts are kept.
+	DeadRetention time.Duration
+}
+
+// OutboxService delivers emails queued by other services. Any number of replicas can run it,
+// each email is claimed by one of them.
+type OutboxService struct {
+	repo   repository.Outbox
+	logger *slog.Logger
+	mailer email.Mailer
+	cfg    OutboxConfig
+}
+
+func NewOutboxService(repo repository.Outbox, logger *slog.Logger, mailer email.Mailer, cfg OutboxConfig) *OutboxService {
+	return &OutboxService{
+		repo:   repo,
+		logger: logger,
+		mailer: mailer,
+		cfg:    cfg,
+	}
+}
+
+// Run delivers due emails every PollInterval until ctx is cancelled.
+func (s *OutboxService) Run(ctx context.Context) {
+	const op = "Service.OutboxService.Run"
+	logger := s.logger.With(slog.String("op", op))
+
+	ticker := time.NewTicker(s.cfg.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		// a full batch means more emails may be due, so don't wait for the next tick
+		for {
+			delivered, err := s.deliver(ctx)
+			if err != nil {
+				logger.Error("failed to deliver emails", sl.Err(err))
+				break
+			}
+			if delivered < s.cfg.BatchSize || ctx.Err() != nil {
+				break
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// deliver sends one batch of due emails and returns how many were claimed.
+func (s *OutboxService) deliver(ctx context.Context) (int, error) {
+	const op = "Service.OutboxService.deliver"
+	logger := s.logger.With(slog.String("op", op))
+
+	emails, err := s.repo.ClaimEmails(ctx, s.cfg.BatchSize, s.cfg.Lease)
+	if err != nil {
+		return 0, err
+	}
+
+	for _, e := range emails {
+		sendErr := s.mailer.Send(ctx, email.Message{
+			To:      e.To,
+			Subject: e.Subject,
+			Text:    e.Body,
+			HTML:    e.HTMLBody,
+		})
+		if sendErr == nil {
+			if err := s.repo.MarkEmailSent(ctx, e.ID); err != nil {
+				return 0, err
+			}
+			continue
+		}
+
+		attempt := e.Attempts + 1
+		dead := attempt >= s.cfg.MaxAttempts
+		retryIn := s.backoff(attempt)
+
+		if dead {
+			logger.Error("email is dead, no attempts left",
+				slog.Int("email_id", e.ID), slog.Int("attempts", attempt), sl.Err(sendErr))
+		} else {
+			logger.Warn("failed to send email, will retry",
+				slog.Int("email_id", e.ID), slog.Int("attempt", attempt), slog.Duration("retry_in", retryIn), sl.Err(sendErr))
+		}
+
+		if err := s.repo.MarkEmailFailed(ctx, e.ID, sendErr.Error(), retryIn, dead); err != nil {
+			return 0, err
+		}
+	}
+
+	return len(emails), nil
+}
+
+// backoff returns the pause after the attempt-th failure.
+func (s *OutboxService) backoff(attempt int) time.Duration {
+	retryIn := s.cfg.BackoffBase
+	for i := 1; i < attempt && retryIn < s.cfg.BackoffMax; i++ {
+		retryIn *= 2
+	}
+
+	if retryIn > s.cfg.BackoffMax {
+		return s.cfg.BackoffMax
+	}
+	return retryIn
+}
+
+func (s *OutboxService) GetDeadEmails(ctx context.Context) ([]domain.OutboxEmail, error) {
+	return s.repo.GetEmails(ctx, domain.EmailStatusDead, deadEmailsLimit)
+}
+
+// RetryEmail queues a dead email again with a fresh set of attempts.
+func (s *OutboxService) RetryEmail(ctx context.Context, id int) error {
+	return s.repo.RetryEmail(ctx, id)
+}
+
+// encryptedOutbox keeps the bodies of queued emails encrypted: they carry confirmation links,
+// sign-in codes and the like. Bodies are decrypted only when the worker claims the emails.
+type encryptedOutbox struct {
+	repository.Outbox
+	encryptor encrypt.Encryptor
+	logger    *slog.Logger
+}
+
+func newEncryptedOutbox(outbox repository.Outbox, encryptor encrypt.Encryptor, logger *slog.Logger) *encryptedOutbox {
+	return &encryptedOutbox{
+		Outbox:    outbox,
+		encryptor: encryptor,
+		logger:    logger,
+	}
+}
+
+func (o *encryptedOutbox) EnqueueEmail(ctx context.Context, email domain.OutboxEmail) error {
+	var err error
+
+	if email.Body, err = o.encryptor.Encrypt(email.Body); err != nil {
+		return err
+	}
+	if email.HTMLBody, err = o.encryptor.Encrypt(email.HTMLBody); err != nil {
+		return err
+	}
+
+	return o.Outbox.EnqueueEmail(ctx, email)
+}
+
+// ClaimEmails returns the claimed emails with decrypted bodies. An email that can't be decrypted,
+// e.g. after the key was changed, is never going to be sent, so it is made dead right away.
+func (o *encryptedOutbox) ClaimEmails(ctx context.Context, limit int, lease time.Duration) ([]domain.OutboxEmail, error) {
+	const op = "Service.encryptedOutbox.ClaimEmails"
+	logger := o.logger.With(slog.String("op", op))
+
+	emails, err := o.Outbox.ClaimEmails(ctx, limit, lease)
+	if err != nil {
+		return nil, err
+	}
+
+	decrypted := emails[:0]
+	for _, e := range emails {
+		body, err := o.encryptor.Decrypt(e.Body)
+		if err == nil {
+			e.HTMLBody, err = o.encryptor.Decrypt(e.HTMLBody)
+		}
+		if err != nil {
+			logger.Error("cannot decrypt email", slog.Int("email_id", e.ID), sl.Err(err))
+
+			if err := o.Outbox.MarkEmailFailed(ctx, e.ID, "cannot decrypt email: "+err.Error(), 0, true); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		e.Body = body
+		decrypted = append(decrypted, e)
+	}
+
+	return decrypted, nil
+}
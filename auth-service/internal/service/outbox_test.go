@@ -0,0 +1,54 @@
+package service
+
+import (
+	"context"
+	"github.com/shamank/edutour-backend/auth-service/internal/domain"
+	"github.com/shamank/edutour-backend/auth-service/pkg/encrypt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEncryptedOutbox(t *testing.T) {
+	encryptor, err := encrypt.NewAESGCM("0123456789abcdef0123456789abcdef")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stored := &queuedEmails{}
+	outbox := newEncryptedOutbox(stored, encryptor, newTestLogger())
+
+	email := domain.OutboxEmail{
+		To:       []string{"ivan@example.com"},
+		Subject:  "Password reset",
+		Body:     "code 123456",
+		HTMLBody: "<p>code 123456</p>",
+	}
+
+	for i := 0; i < 2; i++ {
+		if err := outbox.EnqueueEmail(context.Background(), email); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, e := range stored.emails {
+		if strings.Contains(e.Body, "123456") || strings.Contains(e.HTMLBody, "123456") {
+			t.Fatalf("email stored in plaintext: %+v", e)
+		}
+	}
+
+	// the second email can't be decrypted anymore
+	stored.emails[1].Body = "garbage"
+
+	claimed, err := outbox.ClaimEmails(context.Background(), 10, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(claimed) != 1 || claimed[0].Body != email.Body || claimed[0].HTMLBody != email.HTMLBody {
+		t.Fatalf("claimed %+v, want the first email decrypted", claimed)
+	}
+	if len(stored.dead) != 1 || stored.dead[0] != stored.emails[1].ID {
+		t.Fatalf("dead emails = %v, want [%d]", stored.dead, stored.emails[1].ID)
+	}
+}
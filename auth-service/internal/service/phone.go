@@ -0,0 +1,128 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"github.com/shamank/edutour-backend/auth-service/internal/domain"
+)
+
+// SendPhoneVerification sends a code confirming the phone number to it. An empty phone
+// resends the code to the number waiting for confirmation, e.g. the one given at sign up.
+func (s *AuthService) SendPhoneVerification(ctx context.Context, userID int, phone string) error {
+	if phone != "" {
+		if err := s.repo.SetPendingPhone(ctx, userID, phone); err != nil {
+			return err
+		}
+	} else {
+		pendingPhone, err := s.repo.GetPendingPhone(ctx, userID)
+		if err != nil {
+			return err
+		}
+		if pendingPhone == "" {
+			return domain.ErrPhoneNotSet
+		}
+		phone = pendingPhone
+	}
+
+	if err := s.checkSMSResendInterval(ctx, userID, domain.TokenTypePhoneVerifyCode); err != nil {
+		return err
+	}
+
+	code, err := s.createVerificationCode(ctx, userID, domain.TokenTypePhoneVerifyCode)
+	if err != nil {
+		return err
+	}
+
+	return s.smsSender.Send(ctx, phone, "EduTour phone confirmation code: "+code)
+}
+
+func (s *AuthService) ConfirmPhone(ctx context.Context, userID int, code string) error {
+	codeHash, err := s.checkVerificationCode(ctx, userID, domain.TokenTypePhoneVerifyCode, code)
+	if err != nil {
+		return err
+	}
+
+	return s.repo.ConfirmPhone(ctx, userID, codeHash)
+}
+
+// SendPhoneSignInCode texts a sign-in code to a confirmed phone number, at most once per SMSResendInterval.
+// An unknown number is not reported, so the endpoint can't be used to find out who is registered.
+// Neither is a locked out user, who gets no code until the lockout ends.
+func (s *AuthService) SendPhoneSignInCode(ctx context.Context, phone string) error {
+	user, err := s.repo.GetByPhone(ctx, phone)
+	if err != nil {
+		if errors.Is(err, domain.ErrUserNotFound) {
+			return nil
+		}
+		return err
+	}
+
+	if err := s.checkSignInLockout(ctx, user.ID, domain.TokenTypePhoneSignInFailure, s.cfg.PhoneSignInLockout); err != nil {
+		if errors.Is(err, domain.ErrSignInLocked) {
+			return nil
+		}
+		return err
+	}
+
+	if err := s.checkSMSResendInterval(ctx, user.ID, domain.TokenTypePhoneSignInCode); err != nil {
+		return err
+	}
+
+	code, err := s.createVerificationCode(ctx, user.ID, domain.TokenTypePhoneSignInCode)
+	if err != nil {
+		return err
+	}
+
+	return s.smsSender.Send(ctx, phone, "EduTour sign in code: "+code)
+}
+
+// SignInPhone signs in with a code texted by SendPhoneSignInCode. The code replaces only
+// the password, so a user with two-factor authentication still gets a challenge.
+// Wrong codes add up over all codes, and PhoneSignInLockout.MaxFailures of them lock phone sign-in.
+func (s *AuthService) SignInPhone(ctx context.Context, phone string, code string, client ClientInfo) (SignInResult, error) {
+	user, err := s.repo.GetByPhone(ctx, phone)
+	if err != nil {
+		if errors.Is(err, domain.ErrUserNotFound) {
+			return SignInResult{}, domain.ErrInvalidVerificationCode
+		}
+		return SignInResult{}, err
+	}
+
+	if err := s.checkSignInLockout(ctx, user.ID, domain.TokenTypePhoneSignInFailure, s.cfg.PhoneSignInLockout); err != nil {
+		return SignInResult{}, err
+	}
+
+	codeHash, err := s.checkVerificationCode(ctx, user.ID, domain.TokenTypePhoneSignInCode, code)
+	if err != nil {
+		if errors.Is(err, domain.ErrInvalidVerificationCode) {
+			if err := s.addSignInFailure(ctx, user.ID, domain.TokenTypePhoneSignInFailure, s.cfg.PhoneSignInLockout); err != nil {
+				return SignInResult{}, err
+			}
+		}
+		return SignInResult{}, err
+	}
+
+	if err := s.repo.RevokeUserToken(ctx, domain.TokenTypePhoneSignInCode, codeHash); err != nil {
+		if errors.Is(err, domain.ErrInvalidToken) {
+			return SignInResult{}, domain.ErrInvalidVerificationCode
+		}
+		return SignInResult{}, err
+	}
+
+	return s.completeSignIn(ctx, user, client)
+}
+
+// checkSMSResendInterval returns ErrCodeResendTooSoon if a code of the type was texted
+// to the user less than SMSResendInterval ago. Every text costs money, and every new code
+// gives another MaxAttempts guesses.
+func (s *AuthService) checkSMSResendInterval(ctx context.Context, userID int, tokenType int) error {
+	sentRecently, err := s.repo.HasRecentUserToken(ctx, userID, tokenType, s.cfg.SMSResendInterval)
+	if err != nil {
+		return err
+	}
+	if sentRecently {
+		return domain.ErrCodeResendTooSoon
+	}
+
+	return nil
+}
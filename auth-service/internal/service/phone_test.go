@@ -0,0 +1,83 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"github.com/shamank/edutour-backend/auth-service/internal/domain"
+	"github.com/shamank/edutour-backend/auth-service/pkg/sms"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSignInPhoneLockout(t *testing.T) {
+	const phone = "+79991234567"
+
+	// with 2FA a correct code ends in a challenge, which is enough to tell it was accepted
+	user := domain.User{ID: 1, Username: "ivan", Phone: phone, Role: domain.UserRole{Name: domain.UserRoleName}}
+	repo := newMemAuthRepo(user)
+	s := newTestAuthService(t, repo, &fixedTwoFactor{enabled: map[int]bool{user.ID: true}}, AuthConfig{
+		TwoFactor:          testTwoFactorConfig(),
+		VerificationCode:   VerificationCodeConfig{Length: 6, TTL: time.Minute, MaxAttempts: 3},
+		PhoneSignInLockout: SignInLockoutConfig{MaxFailures: 5, Window: time.Hour},
+	})
+	sender := sms.NewMemorySender()
+	s.smsSender = sender
+
+	sendCode := func() string {
+		t.Helper()
+
+		sent := len(sender.Messages())
+		if err := s.SendPhoneSignInCode(context.Background(), phone); err != nil {
+			t.Fatal(err)
+		}
+		if len(sender.Messages()) == sent {
+			return ""
+		}
+
+		message, _ := sender.Last(phone)
+		return strings.TrimPrefix(message.Text, "EduTour sign in code: ")
+	}
+
+	signIn := func(code string, wantErr error) {
+		t.Helper()
+
+		res, err := s.SignInPhone(context.Background(), phone, code, ClientInfo{})
+		if !errors.Is(err, wantErr) {
+			t.Fatalf("err = %v, want %v", err, wantErr)
+		}
+		if err == nil && res.ChallengeToken == "" {
+			t.Fatalf("unexpected sign in result %+v", res)
+		}
+	}
+
+	first := sendCode()
+	for i := 0; i < 3; i++ {
+		signIn("wrong", domain.ErrInvalidVerificationCode)
+	}
+	signIn(first, domain.ErrVerificationCodeLocked)
+
+	// a new code doesn't give the guesses back
+	second := sendCode()
+	for i := 0; i < 2; i++ {
+		signIn("wrong", domain.ErrInvalidVerificationCode)
+	}
+	signIn(second, domain.ErrSignInLocked)
+
+	if code := sendCode(); code != "" {
+		t.Fatal("a code was texted to a locked out user")
+	}
+
+	// the failures leave the window
+	for i, token := range repo.tokens {
+		if token.tokenType == domain.TokenTypePhoneSignInFailure {
+			repo.tokens[i].expireAt = time.Now().Add(-time.Second)
+		}
+	}
+
+	third := sendCode()
+	if third == "" {
+		t.Fatal("no code texted after the lockout ended")
+	}
+	signIn(third, nil)
+}
@@ -0,0 +1,146 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"github.com/patrickmn/go-cache"
+	"github.com/shamank/edutour-backend/auth-service/internal/domain"
+	"github.com/shamank/edutour-backend/auth-service/internal/repository"
+	"github.com/shamank/edutour-backend/auth-service/pkg/auth"
+	"log/slog"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	revokedTokenCachePrefix = "revoked_jti:"
+	validAfterCachePrefix   = "valid_after:"
+)
+
+// RevocationService invalidates access tokens before they expire. Lookups are cached
+// for cacheTTL, so a revocation made by another replica is seen after at most that long.
+type RevocationService struct {
+	repo     repository.Revocations
+	logger   *slog.Logger
+	cache    *cache.Cache
+	cacheTTL time.Duration
+}
+
+func NewRevocationService(repo repository.Revocations, logger *slog.Logger, cache *cache.Cache, cacheTTL time.Duration) *RevocationService {
+	return &RevocationService{
+		repo:     repo,
+		logger:   logger,
+		cache:    cache,
+		cacheTTL: cacheTTL,
+	}
+}
+
+// RevokeAccessToken puts a single token on the revocation list.
+func (s *RevocationService) RevokeAccessToken(ctx context.Context, claims auth.UserClaims) error {
+	if err := s.repo.RevokeAccessToken(ctx, claims.TokenID, claims.UserID, claims.ExpiresAt); err != nil {
+		return err
+	}
+
+	// revoked is final, keep it until the token would expire anyway
+	s.cacheSet(revokedTokenCachePrefix+claims.TokenID, true, time.Until(claims.ExpiresAt))
+
+	return nil
+}
+
+// RevokeUserTokens invalidates every access token issued to the user so far.
+func (s *RevocationService) RevokeUserTokens(ctx context.Context, userID int) error {
+	validAfter := revocationWatermark()
+
+	if err := s.repo.SetTokensValidAfter(ctx, userID, validAfter); err != nil {
+		return err
+	}
+
+	s.cacheSet(validAfterCachePrefix+strconv.Itoa(userID), validAfter, s.cacheTTL)
+
+	return nil
+}
+
+// RevokeRoleTokens invalidates every access token issued so far to users with the role.
+func (s *RevocationService) RevokeRoleTokens(ctx context.Context, roleID int) error {
+	validAfter := revocationWatermark()
+
+	if err := s.repo.SetRoleTokensValidAfter(ctx, roleID, validAfter); err != nil {
+		return err
+	}
+
+	// which users have the role isn't known here, so all cached watermarks go
+	for key := range s.cache.Items() {
+		if strings.HasPrefix(key, validAfterCachePrefix) {
+			s.cache.Delete(key)
+		}
+	}
+
+	return nil
+}
+
+// IsRevoked reports whether the token was revoked by id, belongs to an ended session
+// or was issued before the user's watermark.
+func (s *RevocationService) IsRevoked(ctx context.Context, claims auth.UserClaims) (bool, error) {
+	validAfter, err := s.tokensValidAfter(ctx, claims.UserID)
+	if err != nil {
+		if errors.Is(err, domain.ErrUserNotFound) {
+			return true, nil
+		}
+		return false, err
+	}
+
+	// iat is truncated to auth.IssuedAtPrecision, so a token issued within the same
+	// millisecond as the revocation is rejected too rather than one issued before it let through
+	if !validAfter.IsZero() && !claims.IssuedAt.After(validAfter) {
+		return true, nil
+	}
+
+	key := revokedTokenCachePrefix + claims.TokenID
+	if revoked, ok := s.cache.Get(key); ok {
+		return revoked.(bool), nil
+	}
+
+	revoked, err := s.repo.IsAccessTokenRevoked(ctx, claims.TokenID, claims.SessionID)
+	if err != nil {
+		return false, err
+	}
+
+	ttl := s.cacheTTL
+	if revoked {
+		ttl = time.Until(claims.ExpiresAt)
+	}
+	s.cacheSet(key, revoked, ttl)
+
+	return revoked, nil
+}
+
+func (s *RevocationService) tokensValidAfter(ctx context.Context, userID int) (time.Time, error) {
+	key := validAfterCachePrefix + strconv.Itoa(userID)
+
+	if validAfter, ok := s.cache.Get(key); ok {
+		return validAfter.(time.Time), nil
+	}
+
+	validAfter, err := s.repo.GetTokensValidAfter(ctx, userID)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	s.cacheSet(key, validAfter, s.cacheTTL)
+
+	return validAfter, nil
+}
+
+// revocationWatermark is the time tokens issued until are revoked, at the precision of iat.
+func revocationWatermark() time.Time {
+	return time.Now().Truncate(auth.IssuedAtPrecision)
+}
+
+// cacheSet skips non-positive ttl, which go-cache would treat as default or no expiration.
+func (s *RevocationService) cacheSet(key string, value interface{}, ttl time.Duration) {
+	if ttl <= 0 {
+		return
+	}
+	s.cache.Set(key, value, ttl)
+}
@@ -0,0 +1,78 @@
+package service
+
+import (
+	"context"
+	"github.com/patrickmn/go-cache"
+	"github.com/shamank/edutour-backend/auth-service/internal/repository"
+	"github.com/shamank/edutour-backend/auth-service/pkg/auth"
+	"testing"
+	"time"
+)
+
+// memRevocationRepo is an in-memory repository.Revocations keeping only the watermarks.
+type memRevocationRepo struct {
+	repository.Revocations
+	validAfter map[int]time.Time
+}
+
+func (r *memRevocationRepo) SetTokensValidAfter(ctx context.Context, userID int, validAfter time.Time) error {
+	r.validAfter[userID] = validAfter
+	return nil
+}
+
+func (r *memRevocationRepo) GetTokensValidAfter(ctx context.Context, userID int) (time.Time, error) {
+	return r.validAfter[userID], nil
+}
+
+func (r *memRevocationRepo) IsAccessTokenRevoked(ctx context.Context, tokenID string, sessionID string) (bool, error) {
+	return false, nil
+}
+
+func TestRevokeUserTokens(t *testing.T) {
+	const userID = 1
+
+	// iat as Manager.Parse returns it
+	issuedAt := func() time.Time {
+		return time.Now().Truncate(auth.IssuedAtPrecision)
+	}
+
+	s := NewRevocationService(&memRevocationRepo{validAfter: make(map[int]time.Time)}, newTestLogger(),
+		cache.New(time.Minute, time.Minute), time.Minute)
+
+	before := issuedAt()
+	time.Sleep(2 * auth.IssuedAtPrecision)
+
+	if err := s.RevokeUserTokens(context.Background(), userID); err != nil {
+		t.Fatal(err)
+	}
+
+	time.Sleep(2 * auth.IssuedAtPrecision)
+	after := issuedAt()
+
+	tests := []struct {
+		name     string
+		issuedAt time.Time
+		want     bool
+	}{
+		{name: "issued before", issuedAt: before, want: true},
+		{name: "issued a second before", issuedAt: before.Add(-time.Second), want: true},
+		{name: "issued after", issuedAt: after, want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			revoked, err := s.IsRevoked(context.Background(), auth.UserClaims{
+				TokenID:   tt.name,
+				UserID:    userID,
+				IssuedAt:  tt.issuedAt,
+				ExpiresAt: tt.issuedAt.Add(time.Minute),
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if revoked != tt.want {
+				t.Fatalf("revoked = %v, want %v", revoked, tt.want)
+			}
+		})
+	}
+}
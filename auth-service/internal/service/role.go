@@ -0,0 +1,180 @@
+package service
+
+import (
+	"context"
+	"github.com/shamank/edutour-backend/auth-service/internal/domain"
+	"github.com/shamank/edutour-backend/auth-service/internal/repository"
+	"log/slog"
+	"regexp"
+	"slices"
+)
+
+var roleNameRegexp = regexp.MustCompile(`^[a-z][a-z0-9_-]{1,63}$`)
+
+// RoleInput defines a role. Name is ignored on update, as access tokens and the
+// data service refer to roles by name and id.
+type RoleInput struct {
+	Name        string
+	Description string
+	Permissions []string
+}
+
+// RoleService manages roles and resolves their permissions. Access tokens carry
+// the permissions of the role, so changing them revokes the access tokens of its users,
+// who then get tokens with the new permissions on refresh.
+type RoleService struct {
+	repo        repository.Roles
+	logger      *slog.Logger
+	revocations Revocations
+}
+
+func NewRoleService(repo repository.Roles, logger *slog.Logger, revocations Revocations) *RoleService {
+	return &RoleService{
+		repo:        repo,
+		logger:      logger,
+		revocations: revocations,
+	}
+}
+
+func (s *RoleService) GetRoles(ctx context.Context) ([]domain.Role, error) {
+	return s.repo.GetRoles(ctx)
+}
+
+func (s *RoleService) GetRole(ctx context.Context, roleID int) (domain.Role, error) {
+	return s.repo.GetRole(ctx, roleID)
+}
+
+// CreateRole creates a role that grants only permissions the admin has, so that managing roles
+// can't be turned into more rights by creating a stronger role and giving it to an accomplice.
+func (s *RoleService) CreateRole(ctx context.Context, adminID int, input RoleInput) (domain.Role, error) {
+	const op = "Service.RoleService.CreateRole"
+	logger := s.logger.With(slog.String("op", op))
+
+	if !roleNameRegexp.MatchString(input.Name) {
+		return domain.Role{}, domain.ErrInvalidRoleName
+	}
+
+	adminRole, err := s.repo.GetUserRole(ctx, adminID)
+	if err != nil {
+		return domain.Role{}, err
+	}
+
+	permissions := normalizePermissions(input.Permissions)
+
+	if !grantsOnly(permissions, adminRole.Permissions) {
+		return domain.Role{}, domain.ErrRoleAboveOwn
+	}
+
+	roleID, err := s.repo.CreateRole(ctx, domain.Role{
+		Name:        input.Name,
+		Description: input.Description,
+		Permissions: permissions,
+	})
+	if err != nil {
+		return domain.Role{}, err
+	}
+
+	logger.Info("role created", slog.Int("admin_id", adminID), slog.String("role", input.Name))
+
+	return s.repo.GetRole(ctx, roleID)
+}
+
+// UpdateRole replaces the description and the permissions of a role.
+// The admin role is left alone, so that there is always a role able to fix the others.
+// As with ChangeUserRole, both the current and the new permissions of the role may include
+// only permissions the admin has, and admins can't change their own role.
+func (s *RoleService) UpdateRole(ctx context.Context, adminID int, roleID int, input RoleInput) (domain.Role, error) {
+	const op = "Service.RoleService.UpdateRole"
+	logger := s.logger.With(slog.String("op", op))
+
+	role, err := s.repo.GetRole(ctx, roleID)
+	if err != nil {
+		return domain.Role{}, err
+	}
+
+	if role.Name == domain.AdminRoleName {
+		return domain.Role{}, domain.ErrRoleProtected
+	}
+
+	adminRole, err := s.repo.GetUserRole(ctx, adminID)
+	if err != nil {
+		return domain.Role{}, err
+	}
+
+	if adminRole.ID == role.ID {
+		return domain.Role{}, domain.ErrChangeOwnRole
+	}
+
+	permissions := normalizePermissions(input.Permissions)
+
+	if !grantsOnly(role.Permissions, adminRole.Permissions) || !grantsOnly(permissions, adminRole.Permissions) {
+		return domain.Role{}, domain.ErrRoleAboveOwn
+	}
+
+	if err := s.repo.UpdateRole(ctx, domain.Role{
+		ID:          roleID,
+		Description: input.Description,
+		Permissions: permissions,
+	}); err != nil {
+		return domain.Role{}, err
+	}
+
+	logger.Info("role updated", slog.Int("admin_id", adminID), slog.String("role", role.Name))
+
+	if !slices.Equal(normalizePermissions(role.Permissions), permissions) {
+		if err := s.revocations.RevokeRoleTokens(ctx, roleID); err != nil {
+			return domain.Role{}, err
+		}
+	}
+
+	return s.repo.GetRole(ctx, roleID)
+}
+
+// DeleteRole deletes a role that isn't built in and that no user has.
+func (s *RoleService) DeleteRole(ctx context.Context, adminID int, roleID int) error {
+	const op = "Service.RoleService.DeleteRole"
+	logger := s.logger.With(slog.String("op", op))
+
+	role, err := s.repo.GetRole(ctx, roleID)
+	if err != nil {
+		return err
+	}
+
+	if role.IsSystem {
+		return domain.ErrRoleProtected
+	}
+
+	if err := s.repo.DeleteRole(ctx, roleID); err != nil {
+		return err
+	}
+
+	logger.Info("role deleted", slog.Int("admin_id", adminID), slog.String("role", role.Name))
+
+	return nil
+}
+
+func (s *RoleService) GetPermissions(ctx context.Context) ([]domain.Permission, error) {
+	return s.repo.GetPermissions(ctx)
+}
+
+// RolePermissions returns the permissions of the role with the given name.
+func (s *RoleService) RolePermissions(ctx context.Context, role string) ([]string, error) {
+	return s.repo.GetRolePermissions(ctx, role)
+}
+
+// grantsOnly reports whether every one of permissions is among allowed.
+func grantsOnly(permissions []string, allowed []string) bool {
+	for _, permission := range permissions {
+		if !slices.Contains(allowed, permission) {
+			return false
+		}
+	}
+	return true
+}
+
+// normalizePermissions sorts and deduplicates permission names, so that sets of them can be compared.
+func normalizePermissions(permissions []string) []string {
+	res := slices.Clone(permissions)
+	slices.Sort(res)
+	return slices.Compact(res)
+}
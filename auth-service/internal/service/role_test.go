@@ -0,0 +1,141 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"github.com/shamank/edutour-backend/auth-service/internal/domain"
+	"testing"
+)
+
+const (
+	adminRoleID = iota + 1
+	managerRoleID
+	supportRoleID
+	moderatorRoleID
+)
+
+const (
+	adminUserID   = 10
+	managerUserID = 20
+)
+
+// newTestRoleService returns roles where the manager can manage roles and read users,
+// but can't update or ban them.
+func newTestRoleService() (*RoleService, *memRoleRepo) {
+	repo := &memRoleRepo{
+		roles: map[int]domain.Role{
+			adminRoleID: {ID: adminRoleID, Name: domain.AdminRoleName, Permissions: []string{
+				domain.PermissionRolesManageAny, domain.PermissionUsersBanAny, domain.PermissionUsersReadAny, domain.PermissionUsersUpdateAny,
+			}},
+			managerRoleID: {ID: managerRoleID, Name: "manager", Permissions: []string{
+				domain.PermissionRolesManageAny, domain.PermissionUsersReadAny,
+			}},
+			supportRoleID: {ID: supportRoleID, Name: "support", Permissions: []string{
+				domain.PermissionUsersReadAny,
+			}},
+			moderatorRoleID: {ID: moderatorRoleID, Name: "moderator", Permissions: []string{
+				domain.PermissionUsersBanAny, domain.PermissionUsersReadAny,
+			}},
+		},
+		userRoles: map[int]int{adminUserID: adminRoleID, managerUserID: managerRoleID},
+	}
+
+	return NewRoleService(repo, newTestLogger(), &revokedUsers{}), repo
+}
+
+func TestRoleServiceCreateRole(t *testing.T) {
+	tests := []struct {
+		name        string
+		adminID     int
+		permissions []string
+		wantErr     error
+	}{
+		{name: "permissions the manager has", adminID: managerUserID, permissions: []string{domain.PermissionUsersReadAny}},
+		{name: "permission the manager lacks", adminID: managerUserID, permissions: []string{domain.PermissionUsersReadAny, domain.PermissionUsersBanAny}, wantErr: domain.ErrRoleAboveOwn},
+		{name: "admin", adminID: adminUserID, permissions: []string{domain.PermissionUsersBanAny}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, repo := newTestRoleService()
+
+			_, err := s.CreateRole(context.Background(), tt.adminID, RoleInput{Name: "editor", Permissions: tt.permissions})
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("err = %v, want %v", err, tt.wantErr)
+			}
+
+			created := len(repo.roles) == 5
+			if created != (tt.wantErr == nil) {
+				t.Fatalf("role created = %v, want %v", created, tt.wantErr == nil)
+			}
+		})
+	}
+}
+
+func TestRoleServiceUpdateRole(t *testing.T) {
+	tests := []struct {
+		name        string
+		adminID     int
+		roleID      int
+		permissions []string
+		wantErr     error
+	}{
+		{
+			name:        "within the permissions of the manager",
+			adminID:     managerUserID,
+			roleID:      supportRoleID,
+			permissions: []string{domain.PermissionRolesManageAny, domain.PermissionUsersReadAny},
+		},
+		{
+			name:        "grant a permission the manager lacks",
+			adminID:     managerUserID,
+			roleID:      supportRoleID,
+			permissions: []string{domain.PermissionUsersReadAny, domain.PermissionUsersUpdateAny},
+			wantErr:     domain.ErrRoleAboveOwn,
+		},
+		{
+			name:        "weaken a role stronger than the manager",
+			adminID:     managerUserID,
+			roleID:      moderatorRoleID,
+			permissions: []string{domain.PermissionUsersReadAny},
+			wantErr:     domain.ErrRoleAboveOwn,
+		},
+		{
+			name:        "own role",
+			adminID:     managerUserID,
+			roleID:      managerRoleID,
+			permissions: []string{domain.PermissionRolesManageAny},
+			wantErr:     domain.ErrChangeOwnRole,
+		},
+		{
+			name:        "admin role",
+			adminID:     managerUserID,
+			roleID:      adminRoleID,
+			permissions: []string{domain.PermissionUsersReadAny},
+			wantErr:     domain.ErrRoleProtected,
+		},
+		{
+			name:        "admin changes a stronger role",
+			adminID:     adminUserID,
+			roleID:      moderatorRoleID,
+			permissions: []string{domain.PermissionUsersReadAny},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, repo := newTestRoleService()
+			before := repo.roles[tt.roleID].Permissions
+
+			_, err := s.UpdateRole(context.Background(), tt.adminID, tt.roleID, RoleInput{Permissions: tt.permissions})
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("err = %v, want %v", err, tt.wantErr)
+			}
+
+			after := repo.roles[tt.roleID].Permissions
+			if tt.wantErr != nil && len(after) != len(before) {
+				t.Fatalf("permissions changed to %v", after)
+			}
+		})
+	}
+}
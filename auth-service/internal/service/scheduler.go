@@ -0,0 +1,229 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/robfig/cron/v3"
+	"github.com/shamank/edutour-backend/auth-service/internal/domain"
+	"github.com/shamank/edutour-backend/auth-service/internal/repository"
+	"github.com/shamank/edutour-backend/auth-service/pkg/logger/sl"
+	"log/slog"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// JobFunc is the work of a scheduled job.
+type JobFunc func(ctx context.Context) error
+
+// JobStatus describes a registered job and its last run on any replica.
+type JobStatus struct {
+	Name     string
+	Schedule string
+	LastRun  domain.JobRun
+	// NextRunAt is when the job is due next
+	NextRunAt time.Time
+}
+
+type scheduledJob struct {
+	name     string
+	spec     string
+	schedule cron.Schedule
+	run      JobFunc
+
+	// next and running are guarded by SchedulerService.mu
+	next    time.Time
+	running bool
+}
+
+// SchedulerService runs registered jobs on their schedules. Every replica runs a scheduler,
+// but a Postgres advisory lock and the shared next run time make each job run on one replica only.
+type SchedulerService struct {
+	repo     repository.Jobs
+	logger   *slog.Logger
+	tick     time.Duration
+	instance string
+
+	mu   sync.Mutex
+	jobs []*scheduledJob
+	wg   sync.WaitGroup
+}
+
+func NewSchedulerService(repo repository.Jobs, logger *slog.Logger, tick time.Duration) *SchedulerService {
+	host, err := os.Hostname()
+	if err != nil {
+		host = "unknown"
+	}
+
+	return &SchedulerService{
+		repo:     repo,
+		logger:   logger,
+		tick:     tick,
+		instance: host + ":" + strconv.Itoa(os.Getpid()),
+	}
+}
+
+// Register adds a job. spec is a cron expression, e.g. "0 3 * * *", or an interval, e.g. "@every 1h".
+// A job that has never run on any replica runs on the first tick.
+func (s *SchedulerService) Register(name string, spec string, run JobFunc) error {
+	schedule, err := cron.ParseStandard(spec)
+	if err != nil {
+		return fmt.Errorf("job %s: invalid schedule %q: %w", name, spec, err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, job := range s.jobs {
+		if job.name == name {
+			return fmt.Errorf("job %s is already registered", name)
+		}
+	}
+
+	s.jobs = append(s.jobs, &scheduledJob{
+		name:     name,
+		spec:     spec,
+		schedule: schedule,
+		run:      run,
+		next:     time.Now(),
+	})
+
+	return nil
+}
+
+// Run starts due jobs every tick until ctx is cancelled, then waits for the running ones to return.
+func (s *SchedulerService) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.tick)
+	defer ticker.Stop()
+
+	for {
+		s.runDue(ctx, time.Now())
+
+		select {
+		case <-ctx.Done():
+			s.wg.Wait()
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Jobs returns the registered jobs with their last runs.
+func (s *SchedulerService) Jobs(ctx context.Context) ([]JobStatus, error) {
+	runs, err := s.repo.GetJobRuns(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	lastRuns := make(map[string]domain.JobRun, len(runs))
+	for _, run := range runs {
+		lastRuns[run.Name] = run
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	res := make([]JobStatus, 0, len(s.jobs))
+	for _, job := range s.jobs {
+		status := JobStatus{
+			Name:      job.name,
+			Schedule:  job.spec,
+			LastRun:   lastRuns[job.name],
+			NextRunAt: job.next,
+		}
+		if status.LastRun.NextRunAt.After(status.NextRunAt) {
+			status.NextRunAt = status.LastRun.NextRunAt
+		}
+		res = append(res, status)
+	}
+
+	return res, nil
+}
+
+func (s *SchedulerService) runDue(ctx context.Context, now time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, job := range s.jobs {
+		if job.running || job.next.After(now) {
+			continue
+		}
+		job.running = true
+
+		s.wg.Add(1)
+		go func(job *scheduledJob) {
+			defer s.wg.Done()
+
+			next := s.runJob(ctx, job)
+
+			s.mu.Lock()
+			job.running = false
+			if !next.IsZero() {
+				job.next = next
+			}
+			s.mu.Unlock()
+		}(job)
+	}
+}
+
+// runJob runs the job unless another replica holds it or has run it already.
+// It returns when the job is due next, or the zero time to retry on the next tick.
+func (s *SchedulerService) runJob(ctx context.Context, job *scheduledJob) time.Time {
+	const op = "Service.SchedulerService.runJob"
+	logger := s.logger.With(slog.String("op", op), slog.String("job", job.name))
+
+	release, acquired, err := s.repo.TryLockJob(ctx, job.name)
+	if err != nil {
+		logger.Error("failed to lock the job", sl.Err(err))
+		return time.Time{}
+	}
+	if !acquired {
+		logger.Debug("the job is running on another replica")
+		return time.Time{}
+	}
+	defer release()
+
+	lastRun, err := s.repo.GetJobRun(ctx, job.name)
+	if err != nil {
+		logger.Error("failed to get the last run", sl.Err(err))
+		return time.Time{}
+	}
+
+	startedAt := time.Now()
+	if lastRun.NextRunAt.After(startedAt) {
+		return lastRun.NextRunAt
+	}
+
+	logger.Info("job started")
+
+	err = job.run(ctx)
+
+	finishedAt := time.Now()
+	run := domain.JobRun{
+		Name:           job.name,
+		LastStartedAt:  startedAt,
+		LastFinishedAt: finishedAt,
+		LastStatus:     domain.JobStatusOK,
+		LastDuration:   finishedAt.Sub(startedAt),
+		NextRunAt:      job.schedule.Next(finishedAt),
+		RunBy:          s.instance,
+	}
+
+	if err != nil {
+		run.LastStatus = domain.JobStatusFailed
+		run.LastError = err.Error()
+		if !errors.Is(err, context.Canceled) {
+			logger.Error("job failed", sl.Err(err), slog.Duration("duration", run.LastDuration))
+		}
+	} else {
+		logger.Info("job finished", slog.Duration("duration", run.LastDuration))
+	}
+
+	if err := s.repo.SaveJobRun(ctx, run); err != nil {
+		logger.Error("failed to save the run", sl.Err(err))
+	}
+
+	return run.NextRunAt
+}
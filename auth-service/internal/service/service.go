@@ -2,12 +2,20 @@ package service
 
 import (
 	"context"
+	"github.com/go-webauthn/webauthn/protocol"
+	"github.com/go-webauthn/webauthn/webauthn"
 	"github.com/patrickmn/go-cache"
 	"github.com/shamank/edutour-backend/auth-service/internal/domain"
 	"github.com/shamank/edutour-backend/auth-service/internal/repository"
+	"github.com/shamank/edutour-backend/auth-service/internal/templates"
 	"github.com/shamank/edutour-backend/auth-service/pkg/auth"
 	"github.com/shamank/edutour-backend/auth-service/pkg/email"
+	"github.com/shamank/edutour-backend/auth-service/pkg/encrypt"
 	"github.com/shamank/edutour-backend/auth-service/pkg/hash"
+	"github.com/shamank/edutour-backend/auth-service/pkg/otp"
+	"github.com/shamank/edutour-backend/auth-service/pkg/sms"
+	"github.com/shamank/edutour-backend/auth-service/pkg/storage"
+	"io"
 	"log/slog"
 	"time"
 )
@@ -17,11 +25,19 @@ type UserSignUpInput struct {
 	Email    string
 	Phone    string
 	Password string
+	Locale   string
 }
 
 type UserSignInInput struct {
 	Login    string
 	Password string
+	Client   ClientInfo
+}
+
+// ClientInfo describes the device a request came from.
+type ClientInfo struct {
+	IP        string
+	UserAgent string
 }
 
 type Tokens struct {
@@ -30,6 +46,17 @@ type Tokens struct {
 	ExpireIn     time.Duration
 }
 
+// SignInResult holds either tokens or, when the user has two-factor authentication
+// enabled, a challenge token to be exchanged for tokens together with a code.
+// EnrolmentRequired means the user's role requires two-factor authentication and the user
+// has to enable it with the challenge token first.
+type SignInResult struct {
+	Tokens            Tokens
+	ChallengeToken    string
+	ChallengeExpireIn time.Duration
+	EnrolmentRequired bool
+}
+
 type UserProfile struct {
 	UserName   string
 	FirstName  string
@@ -37,20 +64,39 @@ type UserProfile struct {
 	MiddleName string
 	Avatar     string
 	Role       string
+	// StudentUntil is zero if the user isn't a student
+	StudentUntil time.Time
 }
 
 type Authorization interface {
 	SignUp(ctx context.Context, input UserSignUpInput) error
-	SignIn(ctx context.Context, input UserSignInInput) (Tokens, error)
+	SignIn(ctx context.Context, input UserSignInInput) (SignInResult, error)
+	SignInTwoFactor(ctx context.Context, challengeToken string, code string, client ClientInfo) (Tokens, error)
+	EnrollTwoFactorOnSignIn(ctx context.Context, challengeToken string) (otp.TOTPKey, error)
+	ConfirmTwoFactorOnSignIn(ctx context.Context, challengeToken string, code string, client ClientInfo) ([]string, Tokens, error)
+	SignInWebAuthn(ctx context.Context, sessionID string, response []byte, client ClientInfo) (Tokens, error)
+	SendMagicLink(ctx context.Context, email string) error
+	SignInMagicLink(ctx context.Context, token string, client ClientInfo) (SignInResult, error)
+
+	SendPhoneVerification(ctx context.Context, userID int, phone string) error
+	ConfirmPhone(ctx context.Context, userID int, code string) error
+	SendPhoneSignInCode(ctx context.Context, phone string) error
+	SignInPhone(ctx context.Context, phone string, code string, client ClientInfo) (SignInResult, error)
 	ConfirmUser(ctx context.Context, confirmToken string) error
+	ResendConfirmation(ctx context.Context, email string) error
+
+	ConfirmUserByCode(ctx context.Context, email string, code string) error
 
 	ResetPassword(ctx context.Context, email string) error
 	ConfirmResetPassword(ctx context.Context, token string, password string) error
+	ConfirmResetPasswordByCode(ctx context.Context, email string, code string, password string) error
 
-	RefreshToken(ctx context.Context, refreshToken string) (Tokens, error)
+	RefreshToken(ctx context.Context, refreshToken string, client ClientInfo) (Tokens, error)
+	Logout(ctx context.Context, refreshToken string, accessToken auth.UserClaims) error
+	LogoutAll(ctx context.Context, userID int) error
 	Verify(ctx context.Context, userID int, hash string) error
 
-	setRefreshToken(ctx context.Context, userID int, userName string, userRole string) (Tokens, error)
+	setRefreshToken(ctx context.Context, userID int, userName string, userRole string, client ClientInfo) (Tokens, error)
 	GetFullUserInfo(ctx context.Context, userID int) (domain.User, error)
 }
 
@@ -59,6 +105,11 @@ type UserProfileInput struct {
 	LastName   string
 	MiddleName string
 	Avatar     string
+	Locale     string
+}
+
+type EmailTemplates interface {
+	Render(locale string, name string, data any) (templates.Email, error)
 }
 
 //go:generate go run github.com/vektra/mockery/v2@v2.28.2 --name=Users
@@ -68,26 +119,238 @@ type Users interface {
 	ChangeUserPassword(ctx context.Context, userID int, oldPassword, newPassword string) error
 }
 
+type Admin interface {
+	GetUsers(ctx context.Context, input UserListInput) (UserList, error)
+	GetUser(ctx context.Context, userID int) (domain.User, error)
+	ChangeUserRole(ctx context.Context, adminID int, userID int, role string) error
+	ConfirmUser(ctx context.Context, adminID int, userID int) error
+	BanUser(ctx context.Context, adminID int, userID int, reason string) error
+	UnbanUser(ctx context.Context, adminID int, userID int) error
+	DeleteUser(ctx context.Context, adminID int, userID int) error
+}
+
+type Roles interface {
+	GetRoles(ctx context.Context) ([]domain.Role, error)
+	GetRole(ctx context.Context, roleID int) (domain.Role, error)
+	CreateRole(ctx context.Context, adminID int, input RoleInput) (domain.Role, error)
+	UpdateRole(ctx context.Context, adminID int, roleID int, input RoleInput) (domain.Role, error)
+	DeleteRole(ctx context.Context, adminID int, roleID int) error
+	GetPermissions(ctx context.Context) ([]domain.Permission, error)
+	RolePermissions(ctx context.Context, role string) ([]string, error)
+}
+
+type Organizations interface {
+	CreateOrganization(ctx context.Context, userID int, input OrganizationInput) (domain.Organization, error)
+	GetOrganization(ctx context.Context, userID int, orgID int) (domain.Organization, error)
+	UpdateOrganization(ctx context.Context, userID int, orgID int, name string) (domain.Organization, error)
+	DeleteOrganization(ctx context.Context, userID int, orgID int) error
+	Membership(ctx context.Context, userID int) (domain.OrganizationMember, error)
+
+	GetMembers(ctx context.Context, userID int, orgID int) ([]domain.OrganizationMember, error)
+	ChangeMemberRole(ctx context.Context, userID int, orgID int, memberID int, role string) error
+	RemoveMember(ctx context.Context, userID int, orgID int, memberID int) error
+
+	Invite(ctx context.Context, userID int, orgID int, input InvitationInput) (domain.OrganizationInvitation, error)
+	GetInvitations(ctx context.Context, userID int, orgID int) ([]domain.OrganizationInvitation, error)
+	RevokeInvitation(ctx context.Context, userID int, orgID int, invitationID int) error
+	AcceptInvitation(ctx context.Context, userID int, token string) (domain.OrganizationMember, error)
+	DeclineInvitation(ctx context.Context, userID int, token string) error
+}
+
+type Universities interface {
+	Apply(ctx context.Context, userID int, input ApplicationInput) (domain.UniversityApplication, error)
+	GetUserApplications(ctx context.Context, userID int) ([]domain.UniversityApplication, error)
+	GetUserApplication(ctx context.Context, userID int, applicationID int) (domain.UniversityApplication, error)
+	OpenUserDocument(ctx context.Context, userID int, applicationID int, documentID int) (domain.ApplicationDocument, io.ReadCloser, error)
+
+	GetApplications(ctx context.Context, status string) ([]domain.UniversityApplication, error)
+	GetApplication(ctx context.Context, applicationID int) (domain.UniversityApplication, error)
+	OpenDocument(ctx context.Context, applicationID int, documentID int) (domain.ApplicationDocument, io.ReadCloser, error)
+	Approve(ctx context.Context, adminID int, applicationID int, comment string) (domain.UniversityApplication, error)
+	Reject(ctx context.Context, adminID int, applicationID int, comment string) (domain.UniversityApplication, error)
+}
+
+type Students interface {
+	GetAcademicDomains(ctx context.Context) ([]domain.AcademicDomain, error)
+	CreateAcademicDomain(ctx context.Context, adminID int, input AcademicDomainInput) (domain.AcademicDomain, error)
+	UpdateAcademicDomain(ctx context.Context, adminID int, id int, universityName string) (domain.AcademicDomain, error)
+	DeleteAcademicDomain(ctx context.Context, adminID int, id int) error
+
+	GetStudentStatus(ctx context.Context, userID int) (domain.StudentStatus, error)
+	StudentUntil(ctx context.Context, userID int) (time.Time, error)
+	SendAcademicEmailConfirmation(ctx context.Context, userID int, email string) error
+	ConfirmAcademicEmail(ctx context.Context, userID int, token string) (domain.StudentStatus, error)
+	RemoveAcademicEmail(ctx context.Context, userID int) error
+}
+
+type Sessions interface {
+	GetUserSessions(ctx context.Context, userID int) ([]domain.Session, error)
+	RevokeSession(ctx context.Context, userID int, sessionID int, current auth.UserClaims) error
+}
+
+type Revocations interface {
+	RevokeAccessToken(ctx context.Context, claims auth.UserClaims) error
+	RevokeUserTokens(ctx context.Context, userID int) error
+	RevokeRoleTokens(ctx context.Context, roleID int) error
+	IsRevoked(ctx context.Context, claims auth.UserClaims) (bool, error)
+}
+
+type TwoFactor interface {
+	EnrollTOTP(ctx context.Context, userID int, account string) (otp.TOTPKey, error)
+	ConfirmTOTP(ctx context.Context, userID int, code string) ([]string, error)
+	DisableTOTP(ctx context.Context, userID int, code string) error
+	IsEnabled(ctx context.Context, userID int) (bool, error)
+	VerifyCode(ctx context.Context, userID int, code string) error
+}
+
+type WebAuthn interface {
+	BeginRegistration(ctx context.Context, userID int) (*protocol.CredentialCreation, string, error)
+	FinishRegistration(ctx context.Context, userID int, sessionID string, name string, response []byte) (domain.WebAuthnCredential, error)
+	BeginLogin(ctx context.Context) (*protocol.CredentialAssertion, string, error)
+	FinishLogin(ctx context.Context, sessionID string, response []byte) (domain.User, error)
+
+	GetCredentials(ctx context.Context, userID int) ([]domain.WebAuthnCredential, error)
+	DeleteCredential(ctx context.Context, userID int, id int) error
+}
+
+type Scheduler interface {
+	Register(name string, spec string, run JobFunc) error
+	Run(ctx context.Context)
+	Jobs(ctx context.Context) ([]JobStatus, error)
+}
+
+type Housekeeping interface {
+	PurgeExpiredTokens(ctx context.Context) error
+	PurgeUnconfirmedAccounts(ctx context.Context) error
+	PurgeSentEmails(ctx context.Context) error
+}
+
+type Outbox interface {
+	Run(ctx context.Context)
+	GetDeadEmails(ctx context.Context) ([]domain.OutboxEmail, error)
+	RetryEmail(ctx context.Context, id int) error
+}
+
 type Services struct {
 	repos         *repository.Repository
 	logger        *slog.Logger
 	Authorization Authorization
 	Users         Users
+	Admin         Admin
+	Roles         Roles
+	Organizations Organizations
+	Universities  Universities
+	Students      Students
+	Sessions      Sessions
+	Revocations   Revocations
+	TwoFactor     TwoFactor
+	WebAuthn      WebAuthn
+	Scheduler     Scheduler
+	Housekeeping  Housekeeping
+	Outbox        Outbox
+}
+
+// AuthConfig holds tunables of the authorization flows.
+type AuthConfig struct {
+	// RefreshTokenGracePeriod allows an already rotated refresh token to be exchanged
+	// again for a short time, so concurrent refreshes of one client don't trigger reuse detection.
+	RefreshTokenGracePeriod time.Duration
+	// RevocationCacheTTL is how long revocation lookups are cached in memory.
+	RevocationCacheTTL time.Duration
+	TwoFactor          TwoFactorConfig
+	VerificationCode   VerificationCodeConfig
+	// FrontendURL is the base of links in emails.
+	FrontendURL string
+	// MagicLinkTTL is how long an emailed sign-in link works.
+	MagicLinkTTL time.Duration
+	// WebAuthnSessionTTL is how long a passkey registration or login may take.
+	WebAuthnSessionTTL time.Duration
+	// ConfirmationResendInterval is how often a confirmation email may be requested again.
+	ConfirmationResendInterval time.Duration
+	// SMSResendInterval is how often a code may be texted to a user again.
+	SMSResendInterval time.Duration
+	// PasswordResetResendInterval is how often a password reset email may be requested again.
+	PasswordResetResendInterval time.Duration
+	// PhoneSignInLockout limits wrong phone sign-in codes of a user across codes.
+	PhoneSignInLockout SignInLockoutConfig
+	// UnconfirmedAccountTTL is how long an account may stay unconfirmed before it is deleted.
+	UnconfirmedAccountTTL time.Duration
+}
+
+// VerificationCodeConfig configures numeric codes sent by email as an alternative to links.
+type VerificationCodeConfig struct {
+	Length      int
+	TTL         time.Duration
+	MaxAttempts int
+}
+
+type TwoFactorConfig struct {
+	// Issuer is the name authenticator apps show next to the account.
+	Issuer string
+	// ChallengeTTL is how long the second sign-in step may take.
+	ChallengeTTL time.Duration
+	// MaxAttempts is how many wrong codes a challenge survives.
+	MaxAttempts int
+	// Lockout limits wrong codes of a user across challenges.
+	Lockout SignInLockoutConfig
+	// RequiredRoles have to enable two-factor authentication before they get tokens.
+	RequiredRoles []string
+}
+
+// SignInLockoutConfig locks a sign-in method of a user after MaxFailures wrong codes within Window.
+type SignInLockoutConfig struct {
+	MaxFailures int
+	Window      time.Duration
 }
 
 type Dependencies struct {
 	Cache        *cache.Cache
 	Hasher       hash.PasswordHasher
+	TokenHasher  hash.TokenHasher
 	TokenManager auth.TokenManager
-	EmailManager *email.EmailManager
+	Mailer       email.Mailer
+	Templates    EmailTemplates
+	SMSSender    sms.SMSSender
+	Encryptor    encrypt.Encryptor
+	WebAuthn     *webauthn.WebAuthn
+	AuthConfig   AuthConfig
+	// SchedulerTick is how often the scheduler looks for due jobs.
+	SchedulerTick      time.Duration
+	OutboxConfig       OutboxConfig
+	OrganizationConfig OrganizationConfig
+	// Storage keeps documents of university applications.
+	Storage          storage.BlobStorage
+	UniversityConfig UniversityConfig
+	StudentConfig    StudentConfig
 }
 
 func NewServices(repos *repository.Repository, logger *slog.Logger, dependencies Dependencies) *Services {
+	revocations := NewRevocationService(repos.Revocations, logger, dependencies.Cache, dependencies.AuthConfig.RevocationCacheTTL)
+	twoFactor := NewTwoFactorService(repos.TwoFactor, logger, dependencies.Encryptor, dependencies.TokenHasher, dependencies.AuthConfig.TwoFactor.Issuer)
+	webAuthn := NewWebAuthnService(repos.WebAuthn, logger, dependencies.WebAuthn, dependencies.TokenHasher, dependencies.AuthConfig.WebAuthnSessionTTL)
+	roles := NewRoleService(repos.Roles, logger, revocations)
+	// the key of TOTP secrets also keeps email bodies in the outbox table unreadable
+	outbox := newEncryptedOutbox(repos.Outbox, dependencies.Encryptor, logger)
+	organizations := NewOrganizationService(repos.Organizations, repos.Authorization, outbox, repos.Transactor, dependencies.Templates, dependencies.TokenManager, dependencies.TokenHasher, revocations, logger, dependencies.OrganizationConfig)
+	students := NewStudentService(repos.Students, repos.Authorization, outbox, repos.Transactor, dependencies.Templates, dependencies.TokenManager, dependencies.TokenHasher, revocations, logger, dependencies.StudentConfig)
+	authorization := NewAuthService(repos.Authorization, logger, dependencies.Hasher, dependencies.TokenHasher, dependencies.TokenManager, outbox, repos.Transactor, dependencies.Templates, dependencies.SMSSender, revocations, twoFactor, webAuthn, roles, organizations, students, dependencies.AuthConfig)
 
 	return &Services{
 		repos:         repos,
 		logger:        logger,
-		Authorization: NewAuthService(repos.Authorization, logger, dependencies.Hasher, dependencies.TokenManager, dependencies.EmailManager),
+		Authorization: authorization,
 		Users:         NewUserService(repos.Users, logger, dependencies.Hasher),
+		Admin:         NewAdminService(repos.Admin, logger, authorization, roles),
+		Roles:         roles,
+		Organizations: organizations,
+		Students:      students,
+		Universities:  NewUniversityService(repos.Universities, repos.Organizations, repos.Authorization, outbox, repos.Transactor, dependencies.Templates, dependencies.Storage, dependencies.TokenManager, revocations, logger, dependencies.UniversityConfig),
+		Sessions:      NewSessionService(repos.Sessions, logger, revocations),
+		Revocations:   revocations,
+		TwoFactor:     twoFactor,
+		WebAuthn:      webAuthn,
+		Scheduler:     NewSchedulerService(repos.Jobs, logger, dependencies.SchedulerTick),
+		Housekeeping:  NewHousekeepingService(repos.Housekeeping, repos.Authorization, logger, dependencies.AuthConfig.UnconfirmedAccountTTL, dependencies.OutboxConfig.SentRetention, dependencies.OutboxConfig.DeadRetention),
+		Outbox:        NewOutboxService(outbox, logger, dependencies.Mailer, dependencies.OutboxConfig),
 	}
 }
@@ -0,0 +1,79 @@
+package service
+
+import (
+	"context"
+	"github.com/mssola/useragent"
+	"github.com/shamank/edutour-backend/auth-service/internal/domain"
+	"github.com/shamank/edutour-backend/auth-service/internal/repository"
+	"github.com/shamank/edutour-backend/auth-service/pkg/auth"
+	"log/slog"
+	"strings"
+)
+
+const (
+	deviceDesktop = "desktop"
+	deviceMobile  = "mobile"
+	deviceBot     = "bot"
+)
+
+type SessionService struct {
+	repo        repository.Sessions
+	logger      *slog.Logger
+	revocations Revocations
+}
+
+func NewSessionService(repo repository.Sessions, logger *slog.Logger, revocations Revocations) *SessionService {
+	return &SessionService{
+		repo:        repo,
+		logger:      logger,
+		revocations: revocations,
+	}
+}
+
+func (s *SessionService) GetUserSessions(ctx context.Context, userID int) ([]domain.Session, error) {
+	return s.repo.GetUserSessions(ctx, userID)
+}
+
+// RevokeSession ends a session of the user. Access tokens of the session are rejected once revocation
+// lookups see it ended; current, the token of the request, is revoked right away if it belongs to the session.
+func (s *SessionService) RevokeSession(ctx context.Context, userID int, sessionID int, current auth.UserClaims) error {
+	familyID, err := s.repo.RevokeSession(ctx, userID, sessionID)
+	if err != nil {
+		return err
+	}
+
+	if familyID != current.SessionID {
+		return nil
+	}
+
+	return s.revocations.RevokeAccessToken(ctx, current)
+}
+
+// newSession describes a new session of the client, parsing its User-Agent.
+func newSession(client ClientInfo) domain.Session {
+	session := domain.Session{
+		IP:        client.IP,
+		UserAgent: client.UserAgent,
+	}
+
+	if client.UserAgent == "" {
+		return session
+	}
+
+	ua := useragent.New(client.UserAgent)
+
+	name, version := ua.Browser()
+	session.Browser = strings.TrimSpace(name + " " + version)
+	session.OS = ua.OS()
+
+	switch {
+	case ua.Bot():
+		session.Device = deviceBot
+	case ua.Mobile():
+		session.Device = deviceMobile
+	default:
+		session.Device = deviceDesktop
+	}
+
+	return session
+}
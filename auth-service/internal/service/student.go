@@ -0,0 +1,277 @@
+package service
+
+import (
+	"context"
+	"github.com/shamank/edutour-backend/auth-service/internal/domain"
+	"github.com/shamank/edutour-backend/auth-service/internal/repository"
+	"github.com/shamank/edutour-backend/auth-service/internal/templates"
+	"github.com/shamank/edutour-backend/auth-service/pkg/auth"
+	"github.com/shamank/edutour-backend/auth-service/pkg/hash"
+	"log/slog"
+	"strings"
+	"time"
+)
+
+type AcademicDomainInput struct {
+	// Domain is the domain of the university's email addresses; it can't be changed later
+	Domain         string
+	UniversityName string
+}
+
+// StudentConfig holds tunables of the student status.
+type StudentConfig struct {
+	// FrontendURL is the base of confirmation links.
+	FrontendURL string
+	// StatusTTL is how long the student status lasts after the academic email is confirmed.
+	StatusTTL time.Duration
+	// ConfirmationTTL is how long a confirmation link works.
+	ConfirmationTTL time.Duration
+	// ResendInterval is how often a confirmation email may be requested again.
+	ResendInterval time.Duration
+}
+
+// StudentService confirms academic emails of users. An academic email is one on a domain
+// of the allow-list that admins keep; confirming it makes the user a student for StatusTTL.
+// Access tokens carry the status, so its changes revoke the access tokens of the user.
+type StudentService struct {
+	repo         repository.Students
+	users        repository.Authorization
+	outbox       repository.Outbox
+	transactor   repository.Transactor
+	templates    EmailTemplates
+	tokenManager auth.TokenManager
+	tokenHasher  hash.TokenHasher
+	revocations  Revocations
+	logger       *slog.Logger
+	cfg          StudentConfig
+}
+
+func NewStudentService(repo repository.Students, users repository.Authorization, outbox repository.Outbox, transactor repository.Transactor, templates EmailTemplates, tokenManager auth.TokenManager, tokenHasher hash.TokenHasher, revocations Revocations, logger *slog.Logger, cfg StudentConfig) *StudentService {
+	return &StudentService{
+		repo:         repo,
+		users:        users,
+		outbox:       outbox,
+		transactor:   transactor,
+		templates:    templates,
+		tokenManager: tokenManager,
+		tokenHasher:  tokenHasher,
+		revocations:  revocations,
+		logger:       logger,
+		cfg:          cfg,
+	}
+}
+
+func (s *StudentService) GetAcademicDomains(ctx context.Context) ([]domain.AcademicDomain, error) {
+	return s.repo.GetAcademicDomains(ctx)
+}
+
+// CreateAcademicDomain adds a university domain to the allow-list.
+func (s *StudentService) CreateAcademicDomain(ctx context.Context, adminID int, input AcademicDomainInput) (domain.AcademicDomain, error) {
+	const op = "Service.StudentService.CreateAcademicDomain"
+	logger := s.logger.With(slog.String("op", op))
+
+	academicDomain := normalizeDomain(input.Domain)
+	if !strings.Contains(academicDomain, ".") {
+		return domain.AcademicDomain{}, domain.ErrAcademicDomainTooBroad
+	}
+
+	id, err := s.repo.CreateAcademicDomain(ctx, domain.AcademicDomain{
+		Domain:         academicDomain,
+		UniversityName: strings.TrimSpace(input.UniversityName),
+	})
+	if err != nil {
+		return domain.AcademicDomain{}, err
+	}
+
+	logger.Info("academic domain added", slog.Int("admin_id", adminID), slog.Int("domain_id", id))
+
+	return s.repo.GetAcademicDomain(ctx, id)
+}
+
+func (s *StudentService) UpdateAcademicDomain(ctx context.Context, adminID int, id int, universityName string) (domain.AcademicDomain, error) {
+	const op = "Service.StudentService.UpdateAcademicDomain"
+	logger := s.logger.With(slog.String("op", op))
+
+	if err := s.repo.UpdateAcademicDomain(ctx, domain.AcademicDomain{
+		ID:             id,
+		UniversityName: strings.TrimSpace(universityName),
+	}); err != nil {
+		return domain.AcademicDomain{}, err
+	}
+
+	logger.Info("academic domain updated", slog.Int("admin_id", adminID), slog.Int("domain_id", id))
+
+	return s.repo.GetAcademicDomain(ctx, id)
+}
+
+// DeleteAcademicDomain removes a domain from the allow-list. Students confirmed
+// on the domain keep their status until it expires.
+func (s *StudentService) DeleteAcademicDomain(ctx context.Context, adminID int, id int) error {
+	const op = "Service.StudentService.DeleteAcademicDomain"
+	logger := s.logger.With(slog.String("op", op))
+
+	if err := s.repo.DeleteAcademicDomain(ctx, id); err != nil {
+		return err
+	}
+
+	logger.Info("academic domain deleted", slog.Int("admin_id", adminID), slog.Int("domain_id", id))
+
+	return nil
+}
+
+func (s *StudentService) GetStudentStatus(ctx context.Context, userID int) (domain.StudentStatus, error) {
+	return s.repo.GetStudentStatus(ctx, userID)
+}
+
+// StudentUntil returns when the student status of the user ends, zero if the user isn't a student.
+func (s *StudentService) StudentUntil(ctx context.Context, userID int) (time.Time, error) {
+	status, err := s.repo.GetStudentStatus(ctx, userID)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	if !status.IsStudent(time.Now()) {
+		return time.Time{}, nil
+	}
+
+	return status.StudentUntil, nil
+}
+
+// SendAcademicEmailConfirmation emails a confirmation link to an academic email of the user.
+// The email must be on a domain of the allow-list. Until it is confirmed, the previous
+// academic email and the student status stay as they are.
+func (s *StudentService) SendAcademicEmailConfirmation(ctx context.Context, userID int, email string) error {
+	const op = "Service.StudentService.SendAcademicEmailConfirmation"
+	logger := s.logger.With(slog.String("op", op))
+
+	email = strings.ToLower(strings.TrimSpace(email))
+
+	academicDomain, err := s.repo.FindAcademicDomain(ctx, academicDomainCandidates(email))
+	if err != nil {
+		return err
+	}
+
+	sentRecently, err := s.users.HasRecentUserToken(ctx, userID, domain.TokenTypeAcademicEmailVerify, s.cfg.ResendInterval)
+	if err != nil {
+		return err
+	}
+	if sentRecently {
+		return domain.ErrConfirmationResendTooSoon
+	}
+
+	status, err := s.repo.GetStudentStatus(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	token, err := s.tokenManager.GenerateToken(32)
+	if err != nil {
+		return err
+	}
+
+	err = s.transactor.WithinTransaction(ctx, func(ctx context.Context) error {
+		if err := s.repo.SetPendingAcademicEmail(ctx, userID, email); err != nil {
+			return err
+		}
+
+		// a link sent earlier, possibly to another email, must not confirm this one
+		if err := s.users.RevokeUserTokensOfType(ctx, userID, domain.TokenTypeAcademicEmailVerify); err != nil {
+			return err
+		}
+
+		if err := s.users.CreateUserToken(ctx, userID, domain.TokenTypeAcademicEmailVerify, s.tokenHasher.Hash(token),
+			time.Now().Add(s.cfg.ConfirmationTTL).Unix()); err != nil {
+			return err
+		}
+
+		message, err := s.templates.Render(status.Locale, templates.EmailConfirmAcademicEmail, templates.AcademicEmailData{
+			Username:   status.Username,
+			University: academicDomain.UniversityName,
+			Link:       frontendLink(s.cfg.FrontendURL, "academic-email/confirm", token),
+			LinkTTL:    int(s.cfg.ConfirmationTTL.Hours()),
+		})
+		if err != nil {
+			return err
+		}
+
+		return s.outbox.EnqueueEmail(ctx, domain.OutboxEmail{
+			To:       []string{email},
+			Subject:  message.Subject,
+			Body:     message.Text,
+			HTMLBody: message.HTML,
+		})
+	})
+	if err != nil {
+		return err
+	}
+
+	logger.Info("academic email confirmation sent", slog.Int("user_id", userID), slog.Int("domain_id", academicDomain.ID))
+
+	return nil
+}
+
+// ConfirmAcademicEmail confirms the pending academic email of the user with the emailed token
+// and starts the student status. The domain must still be on the allow-list.
+func (s *StudentService) ConfirmAcademicEmail(ctx context.Context, userID int, token string) (domain.StudentStatus, error) {
+	const op = "Service.StudentService.ConfirmAcademicEmail"
+	logger := s.logger.With(slog.String("op", op))
+
+	status, err := s.repo.GetStudentStatus(ctx, userID)
+	if err != nil {
+		return domain.StudentStatus{}, err
+	}
+
+	if status.PendingAcademicEmail == "" {
+		return domain.StudentStatus{}, domain.ErrAcademicEmailNotSet
+	}
+
+	email := status.PendingAcademicEmail
+	if _, err := s.repo.FindAcademicDomain(ctx, academicDomainCandidates(email)); err != nil {
+		return domain.StudentStatus{}, err
+	}
+
+	if err := s.repo.ConfirmAcademicEmail(ctx, userID, s.tokenHasher.Hash(token), time.Now().Add(s.cfg.StatusTTL)); err != nil {
+		return domain.StudentStatus{}, err
+	}
+
+	logger.Info("academic email confirmed", slog.Int("user_id", userID))
+
+	if err := s.revocations.RevokeUserTokens(ctx, userID); err != nil {
+		return domain.StudentStatus{}, err
+	}
+
+	return s.repo.GetStudentStatus(ctx, userID)
+}
+
+// RemoveAcademicEmail removes the academic email of the user, ending the student status.
+func (s *StudentService) RemoveAcademicEmail(ctx context.Context, userID int) error {
+	const op = "Service.StudentService.RemoveAcademicEmail"
+	logger := s.logger.With(slog.String("op", op))
+
+	if err := s.repo.ClearAcademicEmail(ctx, userID); err != nil {
+		return err
+	}
+
+	logger.Info("academic email removed", slog.Int("user_id", userID))
+
+	return s.revocations.RevokeUserTokens(ctx, userID)
+}
+
+// academicDomainCandidates returns the domain of the email and every domain above it,
+// any of which may be on the allow-list: a.b.msu.ru, b.msu.ru and msu.ru. The top-level
+// domain alone is never a candidate, it would match every address under it.
+func academicDomainCandidates(email string) []string {
+	d := normalizeDomain(email[strings.LastIndex(email, "@")+1:])
+
+	var candidates []string
+	for i := strings.Index(d, "."); i != -1; i = strings.Index(d, ".") {
+		candidates = append(candidates, d)
+		d = d[i+1:]
+	}
+
+	return candidates
+}
+
+func normalizeDomain(d string) string {
+	return strings.Trim(strings.TrimPrefix(strings.ToLower(strings.TrimSpace(d)), "@"), ".")
+}
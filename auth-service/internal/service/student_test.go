@@ -0,0 +1,247 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"github.com/shamank/edutour-backend/auth-service/internal/domain"
+	"github.com/shamank/edutour-backend/auth-service/internal/repository"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// memStudentRepo is an in-memory repository.Students. Confirmation tokens are kept by auth.
+type memStudentRepo struct {
+	repository.Students
+	auth     *memAuthRepo
+	domains  []domain.AcademicDomain
+	statuses map[int]domain.StudentStatus
+}
+
+func (r *memStudentRepo) FindAcademicDomain(ctx context.Context, domains []string) (domain.AcademicDomain, error) {
+	var found domain.AcademicDomain
+	for _, d := range r.domains {
+		if slices.Contains(domains, d.Domain) && len(d.Domain) > len(found.Domain) {
+			found = d
+		}
+	}
+	if found.ID == 0 {
+		return domain.AcademicDomain{}, domain.ErrAcademicDomainNotAllowed
+	}
+	return found, nil
+}
+
+func (r *memStudentRepo) GetStudentStatus(ctx context.Context, userID int) (domain.StudentStatus, error) {
+	status, ok := r.statuses[userID]
+	if !ok {
+		return domain.StudentStatus{}, domain.ErrUserNotFound
+	}
+	return status, nil
+}
+
+func (r *memStudentRepo) SetPendingAcademicEmail(ctx context.Context, userID int, email string) error {
+	status := r.statuses[userID]
+	status.PendingAcademicEmail = email
+	r.statuses[userID] = status
+	return nil
+}
+
+func (r *memStudentRepo) ConfirmAcademicEmail(ctx context.Context, userID int, tokenHash string, studentUntil time.Time) error {
+	if !r.auth.useToken(userID, domain.TokenTypeAcademicEmailVerify, tokenHash) {
+		return domain.ErrInvalidToken
+	}
+
+	status := r.statuses[userID]
+	status.AcademicEmail, status.PendingAcademicEmail = status.PendingAcademicEmail, ""
+	status.StudentUntil = studentUntil
+	r.statuses[userID] = status
+	return nil
+}
+
+type studentFixture struct {
+	service     *StudentService
+	repo        *memStudentRepo
+	outbox      *queuedEmails
+	revocations *revokedUsers
+}
+
+func newStudentFixture(t *testing.T, statuses map[int]domain.StudentStatus) studentFixture {
+	t.Helper()
+
+	users := newMemAuthRepo()
+
+	f := studentFixture{
+		repo: &memStudentRepo{
+			auth: users,
+			domains: []domain.AcademicDomain{
+				{ID: 1, Domain: "msu.ru", UniversityName: "Moscow State University"},
+				{ID: 2, Domain: "cs.msu.ru", UniversityName: "MSU Faculty of Computer Science"},
+				{ID: 3, Domain: "spbu.ru", UniversityName: "Saint Petersburg State University"},
+				// added before top-level domains were rejected
+				{ID: 4, Domain: "ru", UniversityName: "Every Russian Address"},
+			},
+			statuses: statuses,
+		},
+		outbox:      &queuedEmails{},
+		revocations: &revokedUsers{},
+	}
+
+	f.service = NewStudentService(f.repo, users, f.outbox, noTransaction{}, newTestRenderer(t), &sequentialTokens{},
+		newTestHasher(t), f.revocations, newTestLogger(), StudentConfig{
+			FrontendURL:     "https://edutour.test",
+			StatusTTL:       365 * 24 * time.Hour,
+			ConfirmationTTL: 24 * time.Hour,
+		})
+
+	return f
+}
+
+func TestStudentAcademicDomainMatching(t *testing.T) {
+	const userID = 1
+
+	tests := []struct {
+		name           string
+		email          string
+		wantUniversity string
+		wantErr        error
+	}{
+		{name: "listed domain", email: "ivan@msu.ru", wantUniversity: "Moscow State University"},
+		{name: "subdomain", email: "ivan@student.msu.ru", wantUniversity: "Moscow State University"},
+		{name: "most specific domain", email: "ivan@lab.cs.msu.ru", wantUniversity: "MSU Faculty of Computer Science"},
+		{name: "upper case", email: " Ivan@SPbU.ru ", wantUniversity: "Saint Petersburg State University"},
+		{name: "domain ending the same", email: "ivan@notmsu.ru", wantErr: domain.ErrAcademicDomainNotAllowed},
+		{name: "listed domain inside another", email: "ivan@msu.ru.example.com", wantErr: domain.ErrAcademicDomainNotAllowed},
+		{name: "parent of listed domain", email: "ivan@ru", wantErr: domain.ErrAcademicDomainNotAllowed},
+		{name: "listed top-level domain", email: "ivan@mail.ru", wantErr: domain.ErrAcademicDomainNotAllowed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newStudentFixture(t, map[int]domain.StudentStatus{userID: {UserID: userID, Username: "ivan"}})
+
+			err := f.service.SendAcademicEmailConfirmation(context.Background(), userID, tt.email)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("err = %v, want %v", err, tt.wantErr)
+			}
+
+			if tt.wantErr != nil {
+				if f.repo.statuses[userID].PendingAcademicEmail != "" || len(f.outbox.emails) != 0 {
+					t.Fatalf("confirmation was sent")
+				}
+				return
+			}
+
+			want := strings.ToLower(strings.TrimSpace(tt.email))
+			if got := f.repo.statuses[userID].PendingAcademicEmail; got != want {
+				t.Fatalf("pending academic email = %q, want %q", got, want)
+			}
+			if len(f.outbox.emails) != 1 || !strings.Contains(f.outbox.emails[0].Body, tt.wantUniversity) {
+				t.Fatalf("emails = %+v, want one about %s", f.outbox.emails, tt.wantUniversity)
+			}
+		})
+	}
+}
+
+func TestStudentResendRevokesPreviousLink(t *testing.T) {
+	const userID = 1
+
+	tests := []struct {
+		name      string
+		token     string
+		wantErr   error
+		wantEmail string
+	}{
+		{name: "link of the first email", token: "token-1", wantErr: domain.ErrInvalidToken},
+		{name: "link of the second email", token: "token-2", wantEmail: "ivan@cs.msu.ru"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newStudentFixture(t, map[int]domain.StudentStatus{userID: {UserID: userID, Username: "ivan"}})
+
+			for _, email := range []string{"ivan@msu.ru", "ivan@cs.msu.ru"} {
+				if err := f.service.SendAcademicEmailConfirmation(context.Background(), userID, email); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			status, err := f.service.ConfirmAcademicEmail(context.Background(), userID, tt.token)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("err = %v, want %v", err, tt.wantErr)
+			}
+
+			if tt.wantErr != nil {
+				if f.repo.statuses[userID].AcademicEmail != "" || len(f.revocations.users) != 0 {
+					t.Fatalf("academic email was confirmed")
+				}
+				return
+			}
+
+			if status.AcademicEmail != tt.wantEmail || !status.IsStudent(time.Now()) {
+				t.Fatalf("status = %+v, want a student with %s", status, tt.wantEmail)
+			}
+			if len(f.revocations.users) != 1 {
+				t.Fatalf("access tokens weren't revoked")
+			}
+		})
+	}
+}
+
+func TestStudentUntil(t *testing.T) {
+	const userID = 1
+
+	now := time.Now()
+
+	tests := []struct {
+		name   string
+		status domain.StudentStatus
+		want   time.Time
+	}{
+		{
+			name:   "confirmed",
+			status: domain.StudentStatus{AcademicEmail: "ivan@msu.ru", StudentUntil: now.Add(time.Hour)},
+			want:   now.Add(time.Hour),
+		},
+		{
+			name:   "expired",
+			status: domain.StudentStatus{AcademicEmail: "ivan@msu.ru", StudentUntil: now.Add(-time.Hour)},
+		},
+		{
+			name:   "pending only",
+			status: domain.StudentStatus{PendingAcademicEmail: "ivan@msu.ru"},
+		},
+		{
+			name: "no academic email",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.status.UserID = userID
+			f := newStudentFixture(t, map[int]domain.StudentStatus{userID: tt.status})
+
+			got, err := f.service.StudentUntil(context.Background(), userID)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !got.Equal(tt.want) {
+				t.Fatalf("StudentUntil = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCreateAcademicDomainTopLevel(t *testing.T) {
+	for _, d := range []string{"ru", " .RU. ", "@com"} {
+		t.Run(d, func(t *testing.T) {
+			f := newStudentFixture(t, map[int]domain.StudentStatus{})
+
+			_, err := f.service.CreateAcademicDomain(context.Background(), 1, AcademicDomainInput{Domain: d, UniversityName: "Everyone"})
+			if !errors.Is(err, domain.ErrAcademicDomainTooBroad) {
+				t.Fatalf("err = %v, want %v", err, domain.ErrAcademicDomainTooBroad)
+			}
+		})
+	}
+}
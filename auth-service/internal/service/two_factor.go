@@ -0,0 +1,189 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"github.com/shamank/edutour-backend/auth-service/internal/domain"
+	"github.com/shamank/edutour-backend/auth-service/internal/repository"
+	"github.com/shamank/edutour-backend/auth-service/pkg/encrypt"
+	"github.com/shamank/edutour-backend/auth-service/pkg/hash"
+	"github.com/shamank/edutour-backend/auth-service/pkg/otp"
+	"log/slog"
+	"strings"
+	"time"
+)
+
+const (
+	recoveryCodesCount = 10
+	// recovery codes look like "k7m2p-x9qr4"
+	recoveryCodeHalfLen  = 5
+	recoveryCodeAlphabet = "abcdefghjkmnpqrstuvwxyz23456789"
+)
+
+type TwoFactorService struct {
+	repo        repository.TwoFactor
+	logger      *slog.Logger
+	encryptor   encrypt.Encryptor
+	tokenHasher hash.TokenHasher
+	issuer      string
+}
+
+func NewTwoFactorService(repo repository.TwoFactor, logger *slog.Logger, encryptor encrypt.Encryptor, tokenHasher hash.TokenHasher, issuer string) *TwoFactorService {
+	return &TwoFactorService{
+		repo:        repo,
+		logger:      logger,
+		encryptor:   encryptor,
+		tokenHasher: tokenHasher,
+		issuer:      issuer,
+	}
+}
+
+// EnrollTOTP generates a new secret for the user. It takes effect only after ConfirmTOTP.
+func (s *TwoFactorService) EnrollTOTP(ctx context.Context, userID int, account string) (otp.TOTPKey, error) {
+	key, err := otp.GenerateTOTP(s.issuer, account)
+	if err != nil {
+		return otp.TOTPKey{}, err
+	}
+
+	secret, err := s.encryptor.Encrypt(key.Secret)
+	if err != nil {
+		return otp.TOTPKey{}, err
+	}
+
+	if err := s.repo.SetPendingTOTP(ctx, userID, secret); err != nil {
+		return otp.TOTPKey{}, err
+	}
+
+	return key, nil
+}
+
+// ConfirmTOTP enables the pending secret once the user proves they can generate codes with it.
+// It returns recovery codes, which are shown to the user only this once.
+func (s *TwoFactorService) ConfirmTOTP(ctx context.Context, userID int, code string) ([]string, error) {
+	totp, err := s.repo.GetTOTP(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if totp.Enabled {
+		return nil, domain.ErrTwoFactorAlreadyEnabled
+	}
+
+	secret, err := s.encryptor.Decrypt(totp.Secret)
+	if err != nil {
+		return nil, err
+	}
+
+	step, ok := otp.ValidateTOTP(secret, code, time.Now())
+	if !ok {
+		return nil, domain.ErrInvalidTwoFactorCode
+	}
+
+	codes := make([]string, 0, recoveryCodesCount)
+	codeHashes := make([]string, 0, recoveryCodesCount)
+
+	for i := 0; i < recoveryCodesCount; i++ {
+		recoveryCode, err := generateRecoveryCode()
+		if err != nil {
+			return nil, err
+		}
+		codes = append(codes, recoveryCode)
+		codeHashes = append(codeHashes, s.tokenHasher.Hash(normalizeRecoveryCode(recoveryCode)))
+	}
+
+	if err := s.repo.EnableTOTP(ctx, userID, step, codeHashes); err != nil {
+		return nil, err
+	}
+
+	return codes, nil
+}
+
+// DisableTOTP turns two-factor authentication off. A valid code is required,
+// so a stolen access token alone is not enough.
+func (s *TwoFactorService) DisableTOTP(ctx context.Context, userID int, code string) error {
+	if err := s.VerifyCode(ctx, userID, code); err != nil {
+		return err
+	}
+
+	return s.repo.DisableTOTP(ctx, userID)
+}
+
+func (s *TwoFactorService) IsEnabled(ctx context.Context, userID int) (bool, error) {
+	totp, err := s.repo.GetTOTP(ctx, userID)
+	if err != nil {
+		if errors.Is(err, domain.ErrTwoFactorNotEnabled) {
+			return false, nil
+		}
+		return false, err
+	}
+
+	return totp.Enabled, nil
+}
+
+// VerifyCode accepts either a code from the authenticator app or an unused recovery code.
+// Each code is accepted only once.
+func (s *TwoFactorService) VerifyCode(ctx context.Context, userID int, code string) error {
+	totp, err := s.repo.GetTOTP(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if !totp.Enabled {
+		return domain.ErrTwoFactorNotEnabled
+	}
+
+	code = strings.TrimSpace(code)
+
+	if len(normalizeRecoveryCode(code)) == 2*recoveryCodeHalfLen {
+		ok, err := s.repo.UseRecoveryCode(ctx, userID, s.tokenHasher.Hash(normalizeRecoveryCode(code)))
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return domain.ErrInvalidTwoFactorCode
+		}
+		return nil
+	}
+
+	secret, err := s.encryptor.Decrypt(totp.Secret)
+	if err != nil {
+		return err
+	}
+
+	step, ok := otp.ValidateTOTP(secret, code, time.Now())
+	if !ok {
+		return domain.ErrInvalidTwoFactorCode
+	}
+
+	ok, err = s.repo.UpdateTOTPStep(ctx, userID, step)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return domain.ErrInvalidTwoFactorCode
+	}
+
+	return nil
+}
+
+func generateRecoveryCode() (string, error) {
+	bytes := make([]byte, 2*recoveryCodeHalfLen)
+	if _, err := rand.Read(bytes); err != nil {
+		return "", err
+	}
+
+	var sb strings.Builder
+	for i, b := range bytes {
+		if i == recoveryCodeHalfLen {
+			sb.WriteByte('-')
+		}
+		// the alphabet is short enough for the modulo bias not to matter
+		sb.WriteByte(recoveryCodeAlphabet[int(b)%len(recoveryCodeAlphabet)])
+	}
+
+	return sb.String(), nil
+}
+
+// normalizeRecoveryCode lets users type a recovery code without the dash or in upper case.
+func normalizeRecoveryCode(code string) string {
+	return strings.ToLower(strings.NewReplacer("-", "", " ", "").Replace(code))
+}
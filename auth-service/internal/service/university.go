@@ -0,0 +1,438 @@
+package service
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"github.com/shamank/edutour-backend/auth-service/internal/domain"
+	"github.com/shamank/edutour-backend/auth-service/internal/repository"
+	"github.com/shamank/edutour-backend/auth-service/internal/templates"
+	"github.com/shamank/edutour-backend/auth-service/pkg/auth"
+	"github.com/shamank/edutour-backend/auth-service/pkg/logger/sl"
+	"github.com/shamank/edutour-backend/auth-service/pkg/storage"
+	"io"
+	"log/slog"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// documentContentTypes are the accepted types of supporting documents, sniffed from their content.
+var documentContentTypes = map[string]bool{
+	"application/pdf": true,
+	"image/jpeg":      true,
+	"image/png":       true,
+}
+
+type ApplicationInput struct {
+	UniversityName string
+	Position       string
+	Message        string
+	Documents      []DocumentInput
+}
+
+// DocumentInput is an uploaded file. Size is what the client declared, the content is checked against the limit anyway.
+type DocumentInput struct {
+	FileName string
+	Size     int64
+	Content  io.Reader
+}
+
+// UniversityConfig holds tunables of university applications.
+type UniversityConfig struct {
+	// FrontendURL is the base of links in emails.
+	FrontendURL string
+	// MaxDocuments is how many documents an application may have.
+	MaxDocuments int
+	// MaxDocumentSize is the size limit of a document in bytes.
+	MaxDocumentSize int64
+}
+
+// UniversityService handles applications of users to become university representatives.
+// Documents are put into blob storage before the application is saved and deleted again
+// if saving fails. Every change of status emails the applicant.
+type UniversityService struct {
+	repo          repository.Universities
+	organizations repository.Organizations
+	users         repository.Authorization
+	outbox        repository.Outbox
+	transactor    repository.Transactor
+	templates     EmailTemplates
+	storage       storage.BlobStorage
+	tokenManager  auth.TokenManager
+	revocations   Revocations
+	logger        *slog.Logger
+	cfg           UniversityConfig
+}
+
+func NewUniversityService(repo repository.Universities, organizations repository.Organizations, users repository.Authorization, outbox repository.Outbox, transactor repository.Transactor, templates EmailTemplates, storage storage.BlobStorage, tokenManager auth.TokenManager, revocations Revocations, logger *slog.Logger, cfg UniversityConfig) *UniversityService {
+	return &UniversityService{
+		repo:          repo,
+		organizations: organizations,
+		users:         users,
+		outbox:        outbox,
+		transactor:    transactor,
+		templates:     templates,
+		storage:       storage,
+		tokenManager:  tokenManager,
+		revocations:   revocations,
+		logger:        logger,
+		cfg:           cfg,
+	}
+}
+
+// Apply submits an application with its documents. A user has one pending application at most.
+// If the user owns an unverified organization, it is attached to the application and verified on approval.
+func (s *UniversityService) Apply(ctx context.Context, userID int, input ApplicationInput) (domain.UniversityApplication, error) {
+	const op = "Service.UniversityService.Apply"
+	logger := s.logger.With(slog.String("op", op))
+
+	if len(input.Documents) == 0 {
+		return domain.UniversityApplication{}, domain.ErrDocumentsRequired
+	}
+	if len(input.Documents) > s.cfg.MaxDocuments {
+		return domain.UniversityApplication{}, domain.ErrTooManyDocuments
+	}
+	for _, document := range input.Documents {
+		if document.Size <= 0 || document.Size > s.cfg.MaxDocumentSize {
+			return domain.UniversityApplication{}, domain.ErrInvalidDocument
+		}
+	}
+
+	user, err := s.users.GetFullUserInfo(ctx, userID)
+	if err != nil {
+		return domain.UniversityApplication{}, err
+	}
+
+	if user.Role.Name == domain.UniversityRoleName {
+		return domain.UniversityApplication{}, domain.ErrAlreadyUniversity
+	}
+	// approval replaces the role, so users with any other role would lose their permissions
+	if user.Role.Name != domain.UserRoleName {
+		return domain.UniversityApplication{}, domain.ErrApplicantRole
+	}
+
+	application := domain.UniversityApplication{
+		UserID:         userID,
+		UniversityName: strings.TrimSpace(input.UniversityName),
+		Position:       strings.TrimSpace(input.Position),
+		Message:        strings.TrimSpace(input.Message),
+	}
+
+	membership, err := s.organizations.GetMembership(ctx, userID)
+	switch {
+	case errors.Is(err, domain.ErrNotOrganizationMember):
+	case err != nil:
+		return domain.UniversityApplication{}, err
+	case membership.IsOwner():
+		org, err := s.organizations.GetOrganization(ctx, membership.OrganizationID)
+		if err != nil {
+			return domain.UniversityApplication{}, err
+		}
+		if !org.IsVerified() {
+			application.OrganizationID = org.ID
+		}
+	}
+
+	documents := make([]domain.ApplicationDocument, 0, len(input.Documents))
+
+	// the uploaded documents are deleted unless the application is saved
+	saved := false
+	defer func() {
+		if !saved {
+			s.deleteDocuments(documents)
+		}
+	}()
+
+	for _, document := range input.Documents {
+		d, err := s.putDocument(ctx, userID, document)
+		if err != nil {
+			return domain.UniversityApplication{}, err
+		}
+		documents = append(documents, d)
+	}
+
+	err = s.transactor.WithinTransaction(ctx, func(ctx context.Context) error {
+		id, err := s.repo.CreateApplication(ctx, application)
+		if err != nil {
+			return err
+		}
+
+		for _, d := range documents {
+			d.ApplicationID = id
+			if _, err := s.repo.AddDocument(ctx, d); err != nil {
+				return err
+			}
+		}
+
+		if application, err = s.repo.GetApplication(ctx, id); err != nil {
+			return err
+		}
+
+		return s.enqueueEmail(ctx, templates.EmailApplicationSubmitted, application)
+	})
+	if err != nil {
+		return domain.UniversityApplication{}, err
+	}
+	saved = true
+
+	logger.Info("university application submitted", slog.Int("user_id", userID), slog.Int("application_id", application.ID))
+
+	return application, nil
+}
+
+// GetUserApplications returns the applications of the user, newest first.
+func (s *UniversityService) GetUserApplications(ctx context.Context, userID int) ([]domain.UniversityApplication, error) {
+	return s.repo.GetApplications(ctx, userID, "")
+}
+
+// GetUserApplication returns an application of the user, ErrApplicationNotFound if it is someone else's.
+func (s *UniversityService) GetUserApplication(ctx context.Context, userID int, applicationID int) (domain.UniversityApplication, error) {
+	application, err := s.repo.GetApplication(ctx, applicationID)
+	if err != nil {
+		return domain.UniversityApplication{}, err
+	}
+
+	if application.UserID != userID {
+		return domain.UniversityApplication{}, domain.ErrApplicationNotFound
+	}
+
+	return application, nil
+}
+
+// OpenUserDocument opens a document of an application of the user. The caller closes the reader.
+func (s *UniversityService) OpenUserDocument(ctx context.Context, userID int, applicationID int, documentID int) (domain.ApplicationDocument, io.ReadCloser, error) {
+	if _, err := s.GetUserApplication(ctx, userID, applicationID); err != nil {
+		return domain.ApplicationDocument{}, nil, err
+	}
+
+	return s.OpenDocument(ctx, applicationID, documentID)
+}
+
+// GetApplications returns the applications with status, or all of them if status is empty, newest first.
+func (s *UniversityService) GetApplications(ctx context.Context, status string) ([]domain.UniversityApplication, error) {
+	return s.repo.GetApplications(ctx, 0, status)
+}
+
+func (s *UniversityService) GetApplication(ctx context.Context, applicationID int) (domain.UniversityApplication, error) {
+	return s.repo.GetApplication(ctx, applicationID)
+}
+
+// OpenDocument opens a document of an application. The caller closes the reader.
+func (s *UniversityService) OpenDocument(ctx context.Context, applicationID int, documentID int) (domain.ApplicationDocument, io.ReadCloser, error) {
+	const op = "Service.UniversityService.OpenDocument"
+	logger := s.logger.With(slog.String("op", op))
+
+	document, err := s.repo.GetDocument(ctx, applicationID, documentID)
+	if err != nil {
+		return domain.ApplicationDocument{}, nil, err
+	}
+
+	r, err := s.storage.Get(ctx, document.StorageKey)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			logger.Error("document is missing in storage", slog.Int("document_id", documentID))
+			return domain.ApplicationDocument{}, nil, domain.ErrDocumentNotFound
+		}
+		return domain.ApplicationDocument{}, nil, err
+	}
+
+	return document, r, nil
+}
+
+// Approve approves a pending application: the applicant gets the university role
+// and the attached organization becomes verified.
+func (s *UniversityService) Approve(ctx context.Context, adminID int, applicationID int, comment string) (domain.UniversityApplication, error) {
+	const op = "Service.UniversityService.Approve"
+	logger := s.logger.With(slog.String("op", op))
+
+	application, err := s.review(ctx, adminID, applicationID, func(ctx context.Context) error {
+		return s.repo.ApproveApplication(ctx, applicationID, adminID, strings.TrimSpace(comment))
+	})
+	if err != nil {
+		return domain.UniversityApplication{}, err
+	}
+
+	logger.Info("university application approved", slog.Int("admin_id", adminID), slog.Int("application_id", applicationID))
+
+	// the access token carries the role, the applicant gets the new one on refresh
+	if err := s.revocations.RevokeUserTokens(ctx, application.UserID); err != nil {
+		return domain.UniversityApplication{}, err
+	}
+
+	// and the organization, which only appears in tokens once it is verified
+	if application.OrganizationID != 0 {
+		members, err := s.organizations.GetMembers(ctx, application.OrganizationID)
+		if err != nil {
+			return domain.UniversityApplication{}, err
+		}
+
+		for _, member := range members {
+			if member.UserID == application.UserID {
+				continue
+			}
+			if err := s.revocations.RevokeUserTokens(ctx, member.UserID); err != nil {
+				return domain.UniversityApplication{}, err
+			}
+		}
+	}
+
+	return application, nil
+}
+
+// Reject rejects a pending application, the comment tells the applicant why.
+func (s *UniversityService) Reject(ctx context.Context, adminID int, applicationID int, comment string) (domain.UniversityApplication, error) {
+	const op = "Service.UniversityService.Reject"
+	logger := s.logger.With(slog.String("op", op))
+
+	application, err := s.review(ctx, adminID, applicationID, func(ctx context.Context) error {
+		return s.repo.RejectApplication(ctx, applicationID, adminID, strings.TrimSpace(comment))
+	})
+	if err != nil {
+		return domain.UniversityApplication{}, err
+	}
+
+	logger.Info("university application rejected", slog.Int("admin_id", adminID), slog.Int("application_id", applicationID))
+
+	return application, nil
+}
+
+// review applies the decision to a pending application and emails the applicant about it
+// in one transaction, then returns the reviewed application.
+func (s *UniversityService) review(ctx context.Context, adminID int, applicationID int, decide func(ctx context.Context) error) (domain.UniversityApplication, error) {
+	var application domain.UniversityApplication
+
+	err := s.transactor.WithinTransaction(ctx, func(ctx context.Context) error {
+		var err error
+		if application, err = s.repo.GetApplication(ctx, applicationID); err != nil {
+			return err
+		}
+
+		if application.UserID == adminID {
+			return domain.ErrReviewOwnApplication
+		}
+
+		if !application.IsPending() {
+			return domain.ErrApplicationReviewed
+		}
+
+		if err := decide(ctx); err != nil {
+			return err
+		}
+
+		if application, err = s.repo.GetApplication(ctx, applicationID); err != nil {
+			return err
+		}
+
+		name := templates.EmailApplicationRejected
+		if application.Status == domain.ApplicationStatusApproved {
+			name = templates.EmailApplicationApproved
+		}
+
+		return s.enqueueEmail(ctx, name, application)
+	})
+	if err != nil {
+		return domain.UniversityApplication{}, err
+	}
+
+	return application, nil
+}
+
+func (s *UniversityService) enqueueEmail(ctx context.Context, name string, application domain.UniversityApplication) error {
+	email, err := s.templates.Render(application.Locale, name, templates.ApplicationData{
+		Username:   application.Username,
+		University: application.UniversityName,
+		Comment:    application.ReviewComment,
+		Link:       frontendLink(s.cfg.FrontendURL, "university-applications", strconv.Itoa(application.ID)),
+	})
+	if err != nil {
+		return err
+	}
+
+	return s.outbox.EnqueueEmail(ctx, domain.OutboxEmail{
+		To:       []string{application.Email},
+		Subject:  email.Subject,
+		Body:     email.Text,
+		HTMLBody: email.HTML,
+	})
+}
+
+// putDocument checks the type and the size of the document by its content while putting it into storage.
+func (s *UniversityService) putDocument(ctx context.Context, userID int, input DocumentInput) (domain.ApplicationDocument, error) {
+	content := bufio.NewReaderSize(io.LimitReader(input.Content, s.cfg.MaxDocumentSize+1), 512)
+
+	// Peek returns less than 512 bytes with an error for short files, which is fine for sniffing
+	head, _ := content.Peek(512)
+
+	contentType := http.DetectContentType(head)
+	if !documentContentTypes[contentType] {
+		return domain.ApplicationDocument{}, domain.ErrInvalidDocument
+	}
+
+	name, err := s.tokenManager.GenerateToken(16)
+	if err != nil {
+		return domain.ApplicationDocument{}, err
+	}
+
+	document := domain.ApplicationDocument{
+		FileName:    documentFileName(input.FileName),
+		ContentType: contentType,
+		StorageKey:  "university-applications/" + strconv.Itoa(userID) + "/" + name,
+		CreatedAt:   time.Now(),
+	}
+
+	counter := &countingReader{r: content}
+
+	if err := s.storage.Put(ctx, document.StorageKey, counter); err != nil {
+		return domain.ApplicationDocument{}, err
+	}
+
+	document.Size = counter.n
+
+	if document.Size > s.cfg.MaxDocumentSize {
+		s.deleteDocuments([]domain.ApplicationDocument{document})
+		return domain.ApplicationDocument{}, domain.ErrInvalidDocument
+	}
+
+	return document, nil
+}
+
+// deleteDocuments deletes documents of an application that wasn't saved. It runs after the request
+// may have been cancelled, so it doesn't use the request context.
+func (s *UniversityService) deleteDocuments(documents []domain.ApplicationDocument) {
+	const op = "Service.UniversityService.deleteDocuments"
+	logger := s.logger.With(slog.String("op", op))
+
+	for _, d := range documents {
+		if err := s.storage.Delete(context.Background(), d.StorageKey); err != nil {
+			logger.Error("error occurred when deleting a document", slog.String("key", d.StorageKey), sl.Err(err))
+		}
+	}
+}
+
+// documentFileName keeps only the base name of an uploaded file, which is shown to reviewers.
+func documentFileName(name string) string {
+	name = filepath.Base(strings.ReplaceAll(name, "\\", "/"))
+	if name == "." || name == "/" {
+		return "document"
+	}
+
+	if runes := []rune(name); len(runes) > 255 {
+		name = string(runes[len(runes)-255:])
+	}
+
+	return name
+}
+
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
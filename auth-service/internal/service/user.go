@@ -6,6 +6,7 @@ import (
 	"github.com/shamank/edutour-backend/auth-service/internal/repository"
 	"github.com/shamank/edutour-backend/auth-service/pkg/hash"
 	"log/slog"
+	"time"
 )
 
 type UserService struct {
@@ -29,13 +30,19 @@ func (s *UserService) GetUserProfile(ctx context.Context, userName string) (User
 		return UserProfile{}, err
 	}
 
-	return UserProfile{
+	profile := UserProfile{
 		FirstName:  res.FirstName,
 		LastName:   res.LastName,
 		MiddleName: res.MiddleName,
 		Avatar:     res.Avatar,
 		Role:       res.Role.Name,
-	}, nil
+	}
+
+	if res.StudentUntil.After(time.Now()) {
+		profile.StudentUntil = res.StudentUntil
+	}
+
+	return profile, nil
 }
 
 func (s *UserService) UpdateUserProfile(ctx context.Context, userName string, user UserProfileInput) error {
@@ -45,6 +52,7 @@ func (s *UserService) UpdateUserProfile(ctx context.Context, userName string, us
 		LastName:   user.LastName,
 		MiddleName: user.MiddleName,
 		Avatar:     user.Avatar,
+		Locale:     user.Locale,
 	})
 	if err != nil {
 		return err
@@ -55,16 +63,25 @@ func (s *UserService) UpdateUserProfile(ctx context.Context, userName string, us
 func (s *UserService) ChangeUserPassword(ctx context.Context, userID int, oldPassword, newPassword string) error {
 
 	// TODO: добавить логгер & обработку ошибок
-	oldPasswordHash, err := s.hasher.Hash(oldPassword)
+	oldPasswordHash, err := s.repo.GetPasswordHash(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	ok, _, err := s.hasher.Verify(oldPassword, oldPasswordHash)
 	if err != nil {
 		return err
 	}
+	if !ok {
+		return domain.ErrInvalidPassword
+	}
+
 	newPasswordHash, err := s.hasher.Hash(newPassword)
 	if err != nil {
 		return err
 	}
 
-	err = s.repo.ChangeUserPassword(ctx, userID, oldPasswordHash, newPasswordHash)
+	err = s.repo.ChangeUserPassword(ctx, userID, newPasswordHash)
 	if err != nil {
 		return err
 	}
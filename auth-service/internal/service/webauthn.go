@@ -0,0 +1,313 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/go-webauthn/webauthn/protocol"
+	"github.com/go-webauthn/webauthn/webauthn"
+	"github.com/shamank/edutour-backend/auth-service/internal/domain"
+	"github.com/shamank/edutour-backend/auth-service/internal/repository"
+	"github.com/shamank/edutour-backend/auth-service/pkg/hash"
+	"log/slog"
+	"strconv"
+	"time"
+)
+
+type WebAuthnService struct {
+	repo        repository.WebAuthn
+	logger      *slog.Logger
+	webAuthn    *webauthn.WebAuthn
+	tokenHasher hash.TokenHasher
+	sessionTTL  time.Duration
+}
+
+func NewWebAuthnService(repo repository.WebAuthn, logger *slog.Logger, webAuthn *webauthn.WebAuthn, tokenHasher hash.TokenHasher, sessionTTL time.Duration) *WebAuthnService {
+	return &WebAuthnService{
+		repo:        repo,
+		logger:      logger,
+		webAuthn:    webAuthn,
+		tokenHasher: tokenHasher,
+		sessionTTL:  sessionTTL,
+	}
+}
+
+// webAuthnUser adapts a user and their credentials to webauthn.User.
+type webAuthnUser struct {
+	user        domain.User
+	credentials []domain.WebAuthnCredential
+}
+
+// WebAuthnID is the user handle authenticators store with a passkey.
+// It is the user id, which identifies the user without revealing anything about them.
+func (u *webAuthnUser) WebAuthnID() []byte {
+	return []byte(strconv.Itoa(u.user.ID))
+}
+
+func (u *webAuthnUser) WebAuthnName() string {
+	return u.user.Username
+}
+
+func (u *webAuthnUser) WebAuthnDisplayName() string {
+	return u.user.Username
+}
+
+func (u *webAuthnUser) WebAuthnIcon() string {
+	return ""
+}
+
+func (u *webAuthnUser) WebAuthnCredentials() []webauthn.Credential {
+	credentials := make([]webauthn.Credential, 0, len(u.credentials))
+	for _, c := range u.credentials {
+		transports := make([]protocol.AuthenticatorTransport, 0, len(c.Transports))
+		for _, t := range c.Transports {
+			transports = append(transports, protocol.AuthenticatorTransport(t))
+		}
+
+		credentials = append(credentials, webauthn.Credential{
+			ID:              c.CredentialID,
+			PublicKey:       c.PublicKey,
+			AttestationType: c.AttestationType,
+			Transport:       transports,
+			Flags: webauthn.CredentialFlags{
+				BackupEligible: c.BackupEligible,
+				BackupState:    c.BackupState,
+			},
+			Authenticator: webauthn.Authenticator{
+				AAGUID:    c.AAGUID,
+				SignCount: c.SignCount,
+			},
+		})
+	}
+
+	return credentials
+}
+
+func (s *WebAuthnService) loadUser(ctx context.Context, userID int) (*webAuthnUser, error) {
+	user, err := s.repo.GetUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	credentials, err := s.repo.GetCredentials(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &webAuthnUser{user: user, credentials: credentials}, nil
+}
+
+// BeginRegistration starts adding a passkey to the user's account. It returns options for
+// navigator.credentials.create() and the id of the ceremony to pass to FinishRegistration.
+func (s *WebAuthnService) BeginRegistration(ctx context.Context, userID int) (*protocol.CredentialCreation, string, error) {
+	user, err := s.loadUser(ctx, userID)
+	if err != nil {
+		return nil, "", err
+	}
+
+	exclusions := make([]protocol.CredentialDescriptor, 0, len(user.credentials))
+	for _, c := range user.WebAuthnCredentials() {
+		exclusions = append(exclusions, c.Descriptor())
+	}
+
+	creation, session, err := s.webAuthn.BeginRegistration(user,
+		webauthn.WithExclusions(exclusions),
+		webauthn.WithResidentKeyRequirement(protocol.ResidentKeyRequirementRequired),
+	)
+	if err != nil {
+		return nil, "", err
+	}
+
+	sessionID, err := s.saveSession(ctx, domain.WebAuthnCeremonyRegistration, userID, session)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return creation, sessionID, nil
+}
+
+// FinishRegistration verifies the authenticator's response and stores the new credential.
+func (s *WebAuthnService) FinishRegistration(ctx context.Context, userID int, sessionID string, name string, response []byte) (domain.WebAuthnCredential, error) {
+	session, err := s.takeSession(ctx, domain.WebAuthnCeremonyRegistration, sessionID)
+	if err != nil {
+		return domain.WebAuthnCredential{}, err
+	}
+
+	if session.UserID != userID {
+		return domain.WebAuthnCredential{}, domain.ErrWebAuthnSessionNotFound
+	}
+
+	var sessionData webauthn.SessionData
+	if err := json.Unmarshal(session.Data, &sessionData); err != nil {
+		return domain.WebAuthnCredential{}, err
+	}
+
+	parsed, err := protocol.ParseCredentialCreationResponseBody(bytes.NewReader(response))
+	if err != nil {
+		return domain.WebAuthnCredential{}, verificationError(err)
+	}
+
+	user, err := s.loadUser(ctx, userID)
+	if err != nil {
+		return domain.WebAuthnCredential{}, err
+	}
+
+	credential, err := s.webAuthn.CreateCredential(user, sessionData, parsed)
+	if err != nil {
+		return domain.WebAuthnCredential{}, verificationError(err)
+	}
+
+	transports := make([]string, 0, len(credential.Transport))
+	for _, t := range credential.Transport {
+		transports = append(transports, string(t))
+	}
+
+	res := domain.WebAuthnCredential{
+		UserID:          userID,
+		CredentialID:    credential.ID,
+		PublicKey:       credential.PublicKey,
+		AttestationType: credential.AttestationType,
+		AAGUID:          credential.Authenticator.AAGUID,
+		SignCount:       credential.Authenticator.SignCount,
+		Transports:      transports,
+		BackupEligible:  credential.Flags.BackupEligible,
+		BackupState:     credential.Flags.BackupState,
+		Name:            name,
+		CreatedAt:       time.Now(),
+		LastUsedAt:      time.Now(),
+	}
+
+	res.ID, err = s.repo.CreateCredential(ctx, res)
+	if err != nil {
+		return domain.WebAuthnCredential{}, err
+	}
+
+	return res, nil
+}
+
+// BeginLogin starts a passkey login. The user is not known in advance: the authenticator
+// lets them pick one of their passkeys and tells us whose it is.
+func (s *WebAuthnService) BeginLogin(ctx context.Context) (*protocol.CredentialAssertion, string, error) {
+	assertion, session, err := s.webAuthn.BeginDiscoverableLogin(
+		webauthn.WithUserVerification(protocol.VerificationRequired),
+	)
+	if err != nil {
+		return nil, "", err
+	}
+
+	sessionID, err := s.saveSession(ctx, domain.WebAuthnCeremonyLogin, 0, session)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return assertion, sessionID, nil
+}
+
+// FinishLogin verifies the assertion and returns the user it proves.
+func (s *WebAuthnService) FinishLogin(ctx context.Context, sessionID string, response []byte) (domain.User, error) {
+	const op = "Service.WebAuthnService.FinishLogin"
+	logger := s.logger.With(slog.String("op", op))
+
+	session, err := s.takeSession(ctx, domain.WebAuthnCeremonyLogin, sessionID)
+	if err != nil {
+		return domain.User{}, err
+	}
+
+	var sessionData webauthn.SessionData
+	if err := json.Unmarshal(session.Data, &sessionData); err != nil {
+		return domain.User{}, err
+	}
+
+	parsed, err := protocol.ParseCredentialRequestResponseBody(bytes.NewReader(response))
+	if err != nil {
+		return domain.User{}, verificationError(err)
+	}
+
+	var user *webAuthnUser
+
+	credential, err := s.webAuthn.ValidateDiscoverableLogin(func(rawID, userHandle []byte) (webauthn.User, error) {
+		userID, err := strconv.Atoi(string(userHandle))
+		if err != nil {
+			return nil, err
+		}
+
+		user, err = s.loadUser(ctx, userID)
+		if err != nil {
+			return nil, err
+		}
+
+		return user, nil
+	}, sessionData, parsed)
+	if err != nil {
+		return domain.User{}, verificationError(err)
+	}
+
+	// the signature counter went backwards, so the private key probably exists in two places
+	if credential.Authenticator.CloneWarning {
+		logger.Warn("webauthn sign count did not increase, possibly cloned authenticator",
+			slog.Int("user_id", user.user.ID))
+		return domain.User{}, domain.ErrWebAuthnVerificationFailed
+	}
+
+	err = s.repo.UpdateCredentialUsage(ctx, credential.ID, credential.Authenticator.SignCount, credential.Flags.BackupState)
+	if err != nil {
+		return domain.User{}, err
+	}
+
+	return user.user, nil
+}
+
+func (s *WebAuthnService) GetCredentials(ctx context.Context, userID int) ([]domain.WebAuthnCredential, error) {
+	return s.repo.GetCredentials(ctx, userID)
+}
+
+func (s *WebAuthnService) DeleteCredential(ctx context.Context, userID int, id int) error {
+	return s.repo.DeleteCredential(ctx, userID, id)
+}
+
+// saveSession stores the ceremony state and returns the id the client finishes the ceremony with.
+// Only a hash of the id is stored.
+func (s *WebAuthnService) saveSession(ctx context.Context, ceremony string, userID int, session *webauthn.SessionData) (string, error) {
+	data, err := json.Marshal(session)
+	if err != nil {
+		return "", err
+	}
+
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	sessionID := base64.RawURLEncoding.EncodeToString(buf)
+
+	err = s.repo.CreateSession(ctx, domain.WebAuthnSession{
+		SessionHash: s.tokenHasher.Hash(sessionID),
+		Ceremony:    ceremony,
+		UserID:      userID,
+		Data:        data,
+		ExpiresAt:   time.Now().Add(s.sessionTTL).Unix(),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return sessionID, nil
+}
+
+func (s *WebAuthnService) takeSession(ctx context.Context, ceremony string, sessionID string) (domain.WebAuthnSession, error) {
+	return s.repo.TakeSession(ctx, s.tokenHasher.Hash(sessionID), ceremony)
+}
+
+// verificationError wraps a rejected response in ErrWebAuthnVerificationFailed,
+// so callers can tell it from an internal error.
+func verificationError(err error) error {
+	var protocolErr *protocol.Error
+	if errors.As(err, &protocolErr) {
+		return fmt.Errorf("%w: %s", domain.ErrWebAuthnVerificationFailed, protocolErr.Details)
+	}
+
+	return fmt.Errorf("%w: %s", domain.ErrWebAuthnVerificationFailed, err.Error())
+}
@@ -0,0 +1,438 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"github.com/go-webauthn/webauthn/protocol"
+	"github.com/go-webauthn/webauthn/protocol/webauthncbor"
+	"github.com/go-webauthn/webauthn/protocol/webauthncose"
+	"github.com/go-webauthn/webauthn/webauthn"
+	"github.com/shamank/edutour-backend/auth-service/internal/domain"
+	"github.com/shamank/edutour-backend/auth-service/pkg/hash"
+	"io"
+	"log/slog"
+	"strconv"
+	"testing"
+	"time"
+)
+
+const (
+	testRPID   = "edutour.test"
+	testOrigin = "https://edutour.test"
+)
+
+// softAuthenticator is a passkey authenticator implemented in software.
+// It produces "none" attestations and ES256 assertions.
+type softAuthenticator struct {
+	credentialID []byte
+	key          *ecdsa.PrivateKey
+	userHandle   []byte
+	signCount    uint32
+	origin       string
+}
+
+func newSoftAuthenticator(t *testing.T) *softAuthenticator {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	credentialID := make([]byte, 16)
+	if _, err := rand.Read(credentialID); err != nil {
+		t.Fatal(err)
+	}
+
+	return &softAuthenticator{
+		credentialID: credentialID,
+		key:          key,
+		origin:       testOrigin,
+	}
+}
+
+func (a *softAuthenticator) authData(withCredential bool) []byte {
+	rpIDHash := sha256.Sum256([]byte(testRPID))
+
+	flags := protocol.FlagUserPresent | protocol.FlagUserVerified
+	if withCredential {
+		flags |= protocol.FlagAttestedCredentialData
+	}
+
+	var buf bytes.Buffer
+	buf.Write(rpIDHash[:])
+	buf.WriteByte(byte(flags))
+	_ = binary.Write(&buf, binary.BigEndian, a.signCount)
+
+	if withCredential {
+		buf.Write(make([]byte, 16)) // AAGUID
+		_ = binary.Write(&buf, binary.BigEndian, uint16(len(a.credentialID)))
+		buf.Write(a.credentialID)
+
+		publicKey, _ := webauthncbor.Marshal(webauthncose.EC2PublicKeyData{
+			PublicKeyData: webauthncose.PublicKeyData{
+				KeyType:   int64(webauthncose.EllipticKey),
+				Algorithm: int64(webauthncose.AlgES256),
+			},
+			Curve:  1, // P-256
+			XCoord: a.key.X.FillBytes(make([]byte, 32)),
+			YCoord: a.key.Y.FillBytes(make([]byte, 32)),
+		})
+		buf.Write(publicKey)
+	}
+
+	return buf.Bytes()
+}
+
+func (a *softAuthenticator) clientData(t *testing.T, ceremony string, challenge protocol.URLEncodedBase64) []byte {
+	t.Helper()
+
+	clientData, err := json.Marshal(map[string]string{
+		"type":      ceremony,
+		"challenge": base64.RawURLEncoding.EncodeToString(challenge),
+		"origin":    a.origin,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return clientData
+}
+
+// create answers navigator.credentials.create().
+func (a *softAuthenticator) create(t *testing.T, options *protocol.CredentialCreation) []byte {
+	t.Helper()
+
+	a.userHandle = []byte(options.Response.User.ID.(protocol.URLEncodedBase64))
+
+	attestationObject, err := webauthncbor.Marshal(map[string]interface{}{
+		"fmt":      "none",
+		"attStmt":  map[string]interface{}{},
+		"authData": a.authData(true),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return a.marshal(t, map[string]string{
+		"clientDataJSON":    encode(a.clientData(t, "webauthn.create", options.Response.Challenge)),
+		"attestationObject": encode(attestationObject),
+	})
+}
+
+// get answers navigator.credentials.get().
+func (a *softAuthenticator) get(t *testing.T, options *protocol.CredentialAssertion) []byte {
+	t.Helper()
+
+	authData := a.authData(false)
+	clientData := a.clientData(t, "webauthn.get", options.Response.Challenge)
+	clientDataHash := sha256.Sum256(clientData)
+
+	digest := sha256.Sum256(append(authData, clientDataHash[:]...))
+	signature, err := ecdsa.SignASN1(rand.Reader, a.key, digest[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return a.marshal(t, map[string]string{
+		"clientDataJSON":    encode(clientData),
+		"authenticatorData": encode(authData),
+		"signature":         encode(signature),
+		"userHandle":        encode(a.userHandle),
+	})
+}
+
+func (a *softAuthenticator) marshal(t *testing.T, response map[string]string) []byte {
+	t.Helper()
+
+	res, err := json.Marshal(map[string]interface{}{
+		"id":       encode(a.credentialID),
+		"rawId":    encode(a.credentialID),
+		"type":     "public-key",
+		"response": response,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return res
+}
+
+func encode(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// memWebAuthnRepo is an in-memory repository.WebAuthn.
+type memWebAuthnRepo struct {
+	users       map[int]domain.User
+	sessions    map[string]domain.WebAuthnSession
+	credentials []domain.WebAuthnCredential
+}
+
+func newMemWebAuthnRepo(users ...domain.User) *memWebAuthnRepo {
+	r := &memWebAuthnRepo{
+		users:    make(map[int]domain.User),
+		sessions: make(map[string]domain.WebAuthnSession),
+	}
+	for _, u := range users {
+		r.users[u.ID] = u
+	}
+	return r
+}
+
+func (r *memWebAuthnRepo) CreateSession(ctx context.Context, session domain.WebAuthnSession) error {
+	r.sessions[session.SessionHash] = session
+	return nil
+}
+
+func (r *memWebAuthnRepo) TakeSession(ctx context.Context, sessionHash string, ceremony string) (domain.WebAuthnSession, error) {
+	session, ok := r.sessions[sessionHash]
+	if !ok || session.Ceremony != ceremony || session.ExpiresAt <= time.Now().Unix() {
+		return domain.WebAuthnSession{}, domain.ErrWebAuthnSessionNotFound
+	}
+	delete(r.sessions, sessionHash)
+	return session, nil
+}
+
+func (r *memWebAuthnRepo) GetUser(ctx context.Context, userID int) (domain.User, error) {
+	user, ok := r.users[userID]
+	if !ok {
+		return domain.User{}, domain.ErrUserNotFound
+	}
+	return user, nil
+}
+
+func (r *memWebAuthnRepo) GetCredentials(ctx context.Context, userID int) ([]domain.WebAuthnCredential, error) {
+	res := make([]domain.WebAuthnCredential, 0)
+	for _, c := range r.credentials {
+		if c.UserID == userID {
+			res = append(res, c)
+		}
+	}
+	return res, nil
+}
+
+func (r *memWebAuthnRepo) CreateCredential(ctx context.Context, credential domain.WebAuthnCredential) (int, error) {
+	for _, c := range r.credentials {
+		if bytes.Equal(c.CredentialID, credential.CredentialID) {
+			return 0, domain.ErrWebAuthnCredentialExists
+		}
+	}
+	credential.ID = len(r.credentials) + 1
+	r.credentials = append(r.credentials, credential)
+	return credential.ID, nil
+}
+
+func (r *memWebAuthnRepo) UpdateCredentialUsage(ctx context.Context, credentialID []byte, signCount uint32, backupState bool) error {
+	for i, c := range r.credentials {
+		if bytes.Equal(c.CredentialID, credentialID) {
+			r.credentials[i].SignCount = signCount
+			r.credentials[i].BackupState = backupState
+		}
+	}
+	return nil
+}
+
+func (r *memWebAuthnRepo) DeleteCredential(ctx context.Context, userID int, id int) error {
+	for i, c := range r.credentials {
+		if c.ID == id && c.UserID == userID {
+			r.credentials = append(r.credentials[:i], r.credentials[i+1:]...)
+			return nil
+		}
+	}
+	return domain.ErrWebAuthnCredentialNotFound
+}
+
+func newTestWebAuthnService(t *testing.T, repo *memWebAuthnRepo) *WebAuthnService {
+	t.Helper()
+
+	w, err := webauthn.New(&webauthn.Config{
+		RPID:          testRPID,
+		RPDisplayName: "EduTour",
+		RPOrigins:     []string{testOrigin},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tokenHasher, err := hash.NewHMACHasher("0123456789abcdef0123456789abcdef")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	return NewWebAuthnService(repo, logger, w, tokenHasher, time.Minute)
+}
+
+var testUser = domain.User{ID: 42, Username: "student", Email: "student@edutour.test"}
+
+// register runs the registration ceremony for testUser with the authenticator.
+func register(t *testing.T, s *WebAuthnService, a *softAuthenticator) {
+	t.Helper()
+
+	ctx := context.Background()
+
+	options, sessionID, err := s.BeginRegistration(ctx, testUser.ID)
+	if err != nil {
+		t.Fatalf("BeginRegistration: %v", err)
+	}
+
+	if _, err := s.FinishRegistration(ctx, testUser.ID, sessionID, "laptop", a.create(t, options)); err != nil {
+		t.Fatalf("FinishRegistration: %v", err)
+	}
+}
+
+func TestWebAuthnRegisterAndLogin(t *testing.T) {
+	repo := newMemWebAuthnRepo(testUser)
+	s := newTestWebAuthnService(t, repo)
+	a := newSoftAuthenticator(t)
+	ctx := context.Background()
+
+	register(t, s, a)
+
+	if len(repo.credentials) != 1 || repo.credentials[0].Name != "laptop" {
+		t.Fatalf("credential is not stored: %+v", repo.credentials)
+	}
+	if string(a.userHandle) != strconv.Itoa(testUser.ID) {
+		t.Fatalf("user handle = %q, want user id", a.userHandle)
+	}
+
+	for i := 0; i < 2; i++ {
+		a.signCount++
+
+		options, sessionID, err := s.BeginLogin(ctx)
+		if err != nil {
+			t.Fatalf("BeginLogin: %v", err)
+		}
+
+		user, err := s.FinishLogin(ctx, sessionID, a.get(t, options))
+		if err != nil {
+			t.Fatalf("FinishLogin: %v", err)
+		}
+		if user.ID != testUser.ID {
+			t.Fatalf("FinishLogin returned user %d, want %d", user.ID, testUser.ID)
+		}
+		if repo.credentials[0].SignCount != a.signCount {
+			t.Fatalf("sign count = %d, want %d", repo.credentials[0].SignCount, a.signCount)
+		}
+	}
+}
+
+func TestWebAuthnRegisterTwice(t *testing.T) {
+	repo := newMemWebAuthnRepo(testUser)
+	s := newTestWebAuthnService(t, repo)
+	a := newSoftAuthenticator(t)
+	ctx := context.Background()
+
+	register(t, s, a)
+
+	options, sessionID, err := s.BeginRegistration(ctx, testUser.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(options.Response.CredentialExcludeList) != 1 {
+		t.Fatalf("registered credential is not excluded")
+	}
+
+	_, err = s.FinishRegistration(ctx, testUser.ID, sessionID, "laptop", a.create(t, options))
+	if !errors.Is(err, domain.ErrWebAuthnCredentialExists) {
+		t.Fatalf("err = %v, want %v", err, domain.ErrWebAuthnCredentialExists)
+	}
+}
+
+func TestWebAuthnSessionIsSingleUse(t *testing.T) {
+	repo := newMemWebAuthnRepo(testUser)
+	s := newTestWebAuthnService(t, repo)
+	a := newSoftAuthenticator(t)
+	ctx := context.Background()
+
+	register(t, s, a)
+
+	options, sessionID, err := s.BeginLogin(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	a.signCount++
+	if _, err := s.FinishLogin(ctx, sessionID, a.get(t, options)); err != nil {
+		t.Fatal(err)
+	}
+
+	a.signCount++
+	_, err = s.FinishLogin(ctx, sessionID, a.get(t, options))
+	if !errors.Is(err, domain.ErrWebAuthnSessionNotFound) {
+		t.Fatalf("err = %v, want %v", err, domain.ErrWebAuthnSessionNotFound)
+	}
+}
+
+func TestWebAuthnRegistrationSessionOfAnotherUser(t *testing.T) {
+	other := domain.User{ID: 7, Username: "other"}
+	repo := newMemWebAuthnRepo(testUser, other)
+	s := newTestWebAuthnService(t, repo)
+	a := newSoftAuthenticator(t)
+	ctx := context.Background()
+
+	options, sessionID, err := s.BeginRegistration(ctx, testUser.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = s.FinishRegistration(ctx, other.ID, sessionID, "", a.create(t, options))
+	if !errors.Is(err, domain.ErrWebAuthnSessionNotFound) {
+		t.Fatalf("err = %v, want %v", err, domain.ErrWebAuthnSessionNotFound)
+	}
+}
+
+func TestWebAuthnLoginRejected(t *testing.T) {
+	tests := []struct {
+		name   string
+		tamper func(a *softAuthenticator)
+	}{
+		{
+			name:   "wrong origin",
+			tamper: func(a *softAuthenticator) { a.origin = "https://evil.test" },
+		},
+		{
+			name: "unknown key",
+			tamper: func(a *softAuthenticator) {
+				a.key, _ = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+			},
+		},
+		{
+			name:   "sign count went backwards",
+			tamper: func(a *softAuthenticator) { a.signCount = 1 },
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := newMemWebAuthnRepo(testUser)
+			s := newTestWebAuthnService(t, repo)
+			a := newSoftAuthenticator(t)
+			ctx := context.Background()
+
+			a.signCount = 10
+			register(t, s, a)
+			tt.tamper(a)
+
+			options, sessionID, err := s.BeginLogin(ctx)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			_, err = s.FinishLogin(ctx, sessionID, a.get(t, options))
+			if !errors.Is(err, domain.ErrWebAuthnVerificationFailed) {
+				t.Fatalf("err = %v, want %v", err, domain.ErrWebAuthnVerificationFailed)
+			}
+		})
+	}
+}
@@ -0,0 +1,154 @@
+// Package templates renders localized emails from the templates embedded in the binary.
+//
+// Every email is a file email/<locale>/<name>.tmpl that defines three templates:
+// "subject", "text" for the plain text part and "content" for the HTML part,
+// which is wrapped into email/layout.html.tmpl. email/<locale>/common.tmpl holds what all emails share.
+package templates
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	"github.com/shamank/edutour-backend/auth-service/internal/domain"
+	htmltemplate "html/template"
+	"io/fs"
+	"strings"
+	texttemplate "text/template"
+)
+
+const (
+	EmailConfirm       = "confirm_email"
+	EmailResetPassword = "reset_password"
+	EmailMagicLink     = "magic_link"
+
+	EmailConfirmAcademicEmail = "confirm_academic_email"
+
+	EmailOrganizationInvitation = "organization_invitation"
+
+	EmailApplicationSubmitted = "university_application_submitted"
+	EmailApplicationApproved  = "university_application_approved"
+	EmailApplicationRejected  = "university_application_rejected"
+)
+
+//go:embed email
+var files embed.FS
+
+// Email is a rendered email.
+type Email struct {
+	Subject string
+	Text    string
+	HTML    string
+}
+
+type emailTemplate struct {
+	text *texttemplate.Template
+	html *htmltemplate.Template
+}
+
+type Renderer struct {
+	// emails are keyed by locale and name
+	emails map[string]map[string]emailTemplate
+}
+
+// NewRenderer parses all email templates, so a broken one is found at startup.
+func NewRenderer() (*Renderer, error) {
+	r := &Renderer{emails: make(map[string]map[string]emailTemplate)}
+
+	for _, locale := range domain.Locales {
+		names, err := fs.Glob(files, "email/"+locale+"/*.tmpl")
+		if err != nil {
+			return nil, err
+		}
+
+		r.emails[locale] = make(map[string]emailTemplate)
+
+		for _, path := range names {
+			name := strings.TrimSuffix(path[strings.LastIndex(path, "/")+1:], ".tmpl")
+			if name == "common" {
+				continue
+			}
+
+			common := "email/" + locale + "/common.tmpl"
+
+			text, err := texttemplate.ParseFS(files, common, path)
+			if err != nil {
+				return nil, fmt.Errorf("email %s/%s: %w", locale, name, err)
+			}
+
+			html, err := htmltemplate.ParseFS(files, "email/layout.html.tmpl", common, path)
+			if err != nil {
+				return nil, fmt.Errorf("email %s/%s: %w", locale, name, err)
+			}
+
+			r.emails[locale][name] = emailTemplate{text: text, html: html}
+		}
+	}
+
+	return r, nil
+}
+
+// Render renders the email in locale, falling back to domain.DefaultLocale if locale isn't supported.
+func (r *Renderer) Render(locale string, name string, data any) (Email, error) {
+	t, ok := r.emails[domain.NormalizeLocale(locale)][name]
+	if !ok {
+		return Email{}, fmt.Errorf("email template %s doesn't exists", name)
+	}
+
+	var subject, text, html bytes.Buffer
+
+	if err := t.text.ExecuteTemplate(&subject, "subject", data); err != nil {
+		return Email{}, err
+	}
+	if err := t.text.ExecuteTemplate(&text, "text", data); err != nil {
+		return Email{}, err
+	}
+	if err := t.html.ExecuteTemplate(&html, "layout", data); err != nil {
+		return Email{}, err
+	}
+
+	return Email{
+		Subject: strings.TrimSpace(subject.String()),
+		Text:    strings.TrimSpace(text.String()) + "\n",
+		HTML:    html.String(),
+	}, nil
+}
+
+// LinkData is the data of emails with a link and, optionally, a numeric code.
+type LinkData struct {
+	Username string
+	Link     string
+	Code     string
+	// LinkTTL and CodeTTL are in minutes
+	LinkTTL int
+	CodeTTL int
+}
+
+// InvitationData is the data of an invitation to join an organization.
+type InvitationData struct {
+	// Username is empty, the invitation is addressed to an email that may have no account yet
+	Username     string
+	Inviter      string
+	Organization string
+	Role         string
+	Link         string
+	// LinkTTL is in days
+	LinkTTL int
+}
+
+// ApplicationData is the data of emails about a university application.
+type ApplicationData struct {
+	Username   string
+	University string
+	// Comment is the reviewer's comment, it may be empty for an approved application
+	Comment string
+	Link    string
+}
+
+// AcademicEmailData is the data of the confirmation of an academic email.
+type AcademicEmailData struct {
+	Username   string
+	University string
+	Link       string
+	// LinkTTL is in hours
+	LinkTTL int
+}
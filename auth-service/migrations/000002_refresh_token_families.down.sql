@@ -0,0 +1,10 @@
+DROP TABLE SECURITY_EVENTS;
+
+DROP INDEX IF EXISTS refresh_tokens_family_idx;
+DROP INDEX IF EXISTS refresh_tokens_token_idx;
+
+ALTER TABLE REFRESH_TOKENS
+    DROP COLUMN rotated_at,
+    DROP COLUMN created_at,
+    DROP COLUMN parent_id,
+    DROP COLUMN family_id;
@@ -0,0 +1,26 @@
+ALTER TABLE REFRESH_TOKENS
+    ADD COLUMN family_id  varchar(64),
+    ADD COLUMN parent_id  int REFERENCES REFRESH_TOKENS (id) ON DELETE SET NULL,
+    ADD COLUMN created_at TIMESTAMP default CURRENT_TIMESTAMP not null,
+    ADD COLUMN rotated_at TIMESTAMP;
+
+-- каждый существующий токен становится отдельной семьёй
+UPDATE REFRESH_TOKENS
+SET family_id = 'legacy-' || id;
+
+ALTER TABLE REFRESH_TOKENS
+    ALTER COLUMN family_id SET NOT NULL;
+
+CREATE UNIQUE INDEX refresh_tokens_token_idx ON REFRESH_TOKENS (refresh_token);
+CREATE INDEX refresh_tokens_family_idx ON REFRESH_TOKENS (family_id);
+
+CREATE TABLE SECURITY_EVENTS
+(
+    id          serial                              not null unique,
+    user_id     int                                 not null,
+    event_type  varchar(64)                         not null,
+    description text                                not null default '',
+    created_at  TIMESTAMP default CURRENT_TIMESTAMP not null,
+
+    FOREIGN KEY (user_id) REFERENCES USERS (id) ON DELETE CASCADE
+);
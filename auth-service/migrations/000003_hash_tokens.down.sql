@@ -0,0 +1,5 @@
+ALTER TABLE USER_TOKENS
+    RENAME COLUMN token_hash TO token_value;
+
+ALTER TABLE REFRESH_TOKENS
+    RENAME COLUMN token_hash TO refresh_token;
@@ -0,0 +1,14 @@
+-- токены больше не хранятся в открытом виде, в колонках лежит HMAC-SHA256 от токена.
+-- Существующие токены захешировать нельзя (ключ есть только у сервиса), поэтому они удаляются:
+-- пользователям нужно войти заново, ссылки подтверждения и сброса пароля нужно запросить повторно.
+DELETE
+FROM REFRESH_TOKENS;
+
+DELETE
+FROM USER_TOKENS;
+
+ALTER TABLE REFRESH_TOKENS
+    RENAME COLUMN refresh_token TO token_hash;
+
+ALTER TABLE USER_TOKENS
+    RENAME COLUMN token_value TO token_hash;
@@ -0,0 +1,4 @@
+ALTER TABLE REFRESH_TOKENS
+    DROP CONSTRAINT IF EXISTS refresh_tokens_family_id_fkey;
+
+DROP TABLE SESSIONS;
@@ -0,0 +1,25 @@
+CREATE TABLE SESSIONS
+(
+    id           serial                              not null unique,
+    user_id      int                                 not null,
+    family_id    varchar(64)                         not null unique,
+
+    ip           varchar(64)                         not null default '',
+    user_agent   text                                not null default '',
+    browser      varchar(255)                        not null default '',
+    os           varchar(255)                        not null default '',
+    device       varchar(32)                         not null default '',
+
+    created_at   TIMESTAMP default CURRENT_TIMESTAMP not null,
+    last_used_at TIMESTAMP default CURRENT_TIMESTAMP not null,
+
+    FOREIGN KEY (user_id) REFERENCES USERS (id) ON DELETE CASCADE
+);
+
+INSERT INTO SESSIONS (user_id, family_id, created_at, last_used_at)
+SELECT user_id, family_id, min(created_at), max(created_at)
+FROM REFRESH_TOKENS
+GROUP BY user_id, family_id;
+
+ALTER TABLE REFRESH_TOKENS
+    ADD FOREIGN KEY (family_id) REFERENCES SESSIONS (family_id) ON DELETE CASCADE;
@@ -0,0 +1,4 @@
+ALTER TABLE USERS
+    DROP COLUMN tokens_valid_after;
+
+DROP TABLE REVOKED_ACCESS_TOKENS;
@@ -0,0 +1,13 @@
+CREATE TABLE REVOKED_ACCESS_TOKENS
+(
+    jti        varchar(64)                         not null primary key,
+    user_id    int                                 not null,
+    expire_at  TIMESTAMP                           not null,
+    created_at TIMESTAMP default CURRENT_TIMESTAMP not null,
+
+    FOREIGN KEY (user_id) REFERENCES USERS (id) ON DELETE CASCADE
+);
+
+-- access-токены, выпущенные раньше этого момента, недействительны
+ALTER TABLE USERS
+    ADD COLUMN tokens_valid_after TIMESTAMP;
@@ -0,0 +1,14 @@
+DROP TABLE RECOVERY_CODES;
+
+DROP TABLE USER_TOTP;
+
+ALTER TABLE USER_TOKENS
+    DROP COLUMN attempts;
+
+DELETE
+FROM USER_TOKENS
+WHERE token_type = 3;
+
+DELETE
+FROM TOKEN_TYPES
+WHERE id = 3;
@@ -0,0 +1,30 @@
+INSERT INTO TOKEN_TYPES
+VALUES (3, 'TWO_FACTOR_CHALLENGE');
+
+ALTER TABLE USER_TOKENS
+    ADD COLUMN attempts int default 0 not null;
+
+CREATE TABLE USER_TOTP
+(
+    user_id        int                                 not null unique,
+    secret         text                                not null,
+    enabled        bool      default false             not null,
+    last_used_step bigint    default 0                 not null,
+    created_at     TIMESTAMP default CURRENT_TIMESTAMP not null,
+    enabled_at     TIMESTAMP,
+
+    FOREIGN KEY (user_id) REFERENCES USERS (id) ON DELETE CASCADE
+);
+
+CREATE TABLE RECOVERY_CODES
+(
+    id         serial                              not null unique,
+    user_id    int                                 not null,
+    code_hash  varchar(64)                         not null,
+    created_at TIMESTAMP default CURRENT_TIMESTAMP not null,
+    used_at    TIMESTAMP,
+
+    FOREIGN KEY (user_id) REFERENCES USERS (id) ON DELETE CASCADE
+);
+
+CREATE INDEX recovery_codes_user_idx ON RECOVERY_CODES (user_id);
@@ -0,0 +1,3 @@
+DROP TABLE WEBAUTHN_SESSIONS;
+
+DROP TABLE WEBAUTHN_CREDENTIALS;
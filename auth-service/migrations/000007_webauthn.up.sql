@@ -0,0 +1,36 @@
+CREATE TABLE WEBAUTHN_CREDENTIALS
+(
+    id               serial                              not null unique,
+    user_id          int                                 not null,
+    credential_id    bytea                               not null unique,
+    public_key       bytea                               not null,
+    attestation_type varchar(32)                         not null default '',
+    aaguid           bytea,
+    sign_count       bigint                              not null default 0,
+    transports       text[]                              not null default '{}',
+    backup_eligible  bool                                not null default false,
+    backup_state     bool                                not null default false,
+    name             varchar(255)                        not null default '',
+
+    created_at       TIMESTAMP default CURRENT_TIMESTAMP not null,
+    last_used_at     TIMESTAMP default CURRENT_TIMESTAMP not null,
+
+    FOREIGN KEY (user_id) REFERENCES USERS (id) ON DELETE CASCADE
+);
+
+CREATE INDEX webauthn_credentials_user_idx ON WEBAUTHN_CREDENTIALS (user_id);
+
+-- состояние церемонии между запросами begin и finish
+CREATE TABLE WEBAUTHN_SESSIONS
+(
+    id           serial                              not null unique,
+    session_hash varchar(64)                         not null unique,
+    ceremony     varchar(16)                         not null,
+    -- NULL для входа по passkey: пользователь становится известен только из ответа аутентификатора
+    user_id      int,
+    data         jsonb                               not null,
+    expire_at    TIMESTAMP                           not null,
+    created_at   TIMESTAMP default CURRENT_TIMESTAMP not null,
+
+    FOREIGN KEY (user_id) REFERENCES USERS (id) ON DELETE CASCADE
+);
@@ -0,0 +1,7 @@
+DELETE
+FROM USER_TOKENS
+WHERE token_type = 4;
+
+DELETE
+FROM TOKEN_TYPES
+WHERE id = 4;
@@ -0,0 +1,2 @@
+INSERT INTO TOKEN_TYPES
+VALUES (4, 'MAGIC_LINK');
@@ -0,0 +1,7 @@
+DELETE
+FROM USER_TOKENS
+WHERE token_type IN (5, 6);
+
+DELETE
+FROM TOKEN_TYPES
+WHERE id IN (5, 6);
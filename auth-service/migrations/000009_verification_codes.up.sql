@@ -0,0 +1,3 @@
+INSERT INTO TOKEN_TYPES
+VALUES (5, 'EMAIL_VERIFY_CODE'),
+       (6, 'PASSWORD_RESET_CODE');
@@ -0,0 +1,10 @@
+DELETE
+FROM USER_TOKENS
+WHERE token_type IN (7, 8);
+
+DELETE
+FROM TOKEN_TYPES
+WHERE id IN (7, 8);
+
+ALTER TABLE USERS
+    DROP COLUMN pending_phone;
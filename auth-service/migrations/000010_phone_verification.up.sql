@@ -0,0 +1,7 @@
+-- USERS.phone хранит только подтвержденный номер, новый номер ждет подтверждения здесь
+ALTER TABLE USERS
+    ADD COLUMN pending_phone varchar(32);
+
+INSERT INTO TOKEN_TYPES
+VALUES (7, 'PHONE_VERIFY_CODE'),
+       (8, 'PHONE_SIGN_IN_CODE');
@@ -0,0 +1,5 @@
+DROP INDEX refresh_tokens_expire_at_idx;
+
+DROP INDEX user_tokens_expire_at_idx;
+
+DROP TABLE SCHEDULED_JOBS;
@@ -0,0 +1,16 @@
+-- состояние фоновых задач, общее для всех реплик
+CREATE TABLE SCHEDULED_JOBS
+(
+    name             varchar(64)  not null primary key,
+    last_started_at  TIMESTAMPTZ,
+    last_finished_at TIMESTAMPTZ,
+    last_status      varchar(16)  not null default '',
+    last_error       text         not null default '',
+    last_duration_ms bigint       not null default 0,
+    -- задача, которую уже выполнила другая реплика, не запускается раньше этого момента
+    next_run_at      TIMESTAMPTZ,
+    run_by           varchar(255) not null default ''
+);
+
+CREATE INDEX user_tokens_expire_at_idx ON USER_TOKENS (expire_at);
+CREATE INDEX refresh_tokens_expire_at_idx ON REFRESH_TOKENS (expire_at);
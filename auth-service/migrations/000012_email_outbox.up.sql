@@ -0,0 +1,21 @@
+-- письма записываются в одной транзакции с пользователем или токеном и отправляются воркером
+CREATE TABLE EMAIL_OUTBOX
+(
+    id              serial                                  not null unique,
+    recipients      text[]                                  not null,
+    subject         varchar(255)                            not null,
+    body            text                                    not null,
+
+    -- pending, sent или dead, если попытки закончились
+    status          varchar(16) default 'pending'           not null,
+    attempts        int         default 0                   not null,
+    last_error      text        default ''                  not null,
+    next_attempt_at TIMESTAMPTZ default CURRENT_TIMESTAMP   not null,
+    -- воркер, взявший письмо, отправляет его до этого момента, потом его могут взять снова
+    locked_until    TIMESTAMPTZ,
+
+    created_at      TIMESTAMPTZ default CURRENT_TIMESTAMP   not null,
+    sent_at         TIMESTAMPTZ
+);
+
+CREATE INDEX email_outbox_pending_idx ON EMAIL_OUTBOX (next_attempt_at) WHERE status = 'pending';
@@ -0,0 +1,5 @@
+ALTER TABLE EMAIL_OUTBOX
+    DROP COLUMN html_body;
+
+ALTER TABLE USERS
+    DROP COLUMN locale;
@@ -0,0 +1,6 @@
+-- язык писем пользователю
+ALTER TABLE USERS
+    ADD COLUMN locale varchar(8) default 'ru' not null;
+
+ALTER TABLE EMAIL_OUTBOX
+    ADD COLUMN html_body text default '' not null;
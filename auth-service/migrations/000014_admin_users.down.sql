@@ -0,0 +1,7 @@
+DROP INDEX users_created_at_idx;
+DROP INDEX users_search_vector_idx;
+
+ALTER TABLE USERS
+    DROP COLUMN search_vector,
+    DROP COLUMN ban_reason,
+    DROP COLUMN banned_at;
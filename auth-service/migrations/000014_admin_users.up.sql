@@ -0,0 +1,16 @@
+-- блокировка пользователей администратором
+ALTER TABLE USERS
+    ADD COLUMN banned_at  TIMESTAMPTZ,
+    ADD COLUMN ban_reason varchar(255) default '' not null;
+
+-- полнотекстовый поиск пользователей в админке
+ALTER TABLE USERS
+    ADD COLUMN search_vector tsvector GENERATED ALWAYS AS (
+        to_tsvector('simple',
+                    username || ' ' || email || ' ' || replace(email, '@', ' ') || ' ' ||
+                    COALESCE(first_name, '') || ' ' || COALESCE(last_name, '') || ' ' ||
+                    COALESCE(middle_name, ''))
+        ) STORED;
+
+CREATE INDEX users_search_vector_idx ON USERS USING GIN (search_vector);
+CREATE INDEX users_created_at_idx ON USERS (created_at);
@@ -0,0 +1,19 @@
+DROP TABLE ROLE_PERMISSIONS;
+DROP TABLE PERMISSIONS;
+
+-- пользователи добавленных ролей возвращаются к роли user
+UPDATE USERS
+SET role_id = 1
+WHERE role_id IN (SELECT id FROM ROLES WHERE NOT is_system);
+
+DELETE
+FROM ROLES
+WHERE NOT is_system;
+
+ALTER TABLE ROLES
+    DROP COLUMN created_at,
+    DROP COLUMN is_system,
+    DROP COLUMN description,
+    ALTER COLUMN name DROP NOT NULL;
+
+ALTER TABLE ROLES RENAME TO ROLE_TYPES;
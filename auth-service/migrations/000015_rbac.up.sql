@@ -0,0 +1,54 @@
+-- роли становятся настраиваемыми: описание и признак встроенной роли
+ALTER TABLE ROLE_TYPES RENAME TO ROLES;
+
+ALTER TABLE ROLES
+    ALTER COLUMN name SET NOT NULL,
+    ADD COLUMN description varchar(255) default ''                not null,
+    ADD COLUMN is_system   bool         default false             not null,
+    ADD COLUMN created_at  TIMESTAMPTZ  default CURRENT_TIMESTAMP not null;
+
+UPDATE ROLES
+SET is_system = true
+WHERE name IN ('user', 'admin', 'university');
+
+UPDATE ROLES SET description = 'Зарегистрированный пользователь' WHERE name = 'user';
+UPDATE ROLES SET description = 'Администратор' WHERE name = 'admin';
+UPDATE ROLES SET description = 'Представитель университета' WHERE name = 'university';
+
+-- встроенные роли вставлены с явными id, последовательность нужно сдвинуть
+SELECT setval(pg_get_serial_sequence('roles', 'id'), (SELECT max(id) FROM ROLES));
+
+-- права проверяются кодом, поэтому их список задаётся миграциями
+CREATE TABLE PERMISSIONS
+(
+    id          serial       not null unique,
+    name        varchar(128) not null unique,
+    description varchar(255) default '' not null
+);
+
+CREATE TABLE ROLE_PERMISSIONS
+(
+    role_id       int not null,
+    permission_id int not null,
+
+    PRIMARY KEY (role_id, permission_id),
+    FOREIGN KEY (role_id) REFERENCES ROLES (id) ON DELETE CASCADE,
+    FOREIGN KEY (permission_id) REFERENCES PERMISSIONS (id) ON DELETE CASCADE
+);
+
+INSERT INTO PERMISSIONS (name, description)
+VALUES ('users:read:any', 'Просмотр любых пользователей'),
+       ('users:update:any', 'Изменение профиля, роли и подтверждение любых пользователей'),
+       ('users:ban:any', 'Блокировка и разблокировка пользователей'),
+       ('users:delete:any', 'Удаление пользователей'),
+       ('roles:read:any', 'Просмотр ролей и прав'),
+       ('roles:manage:any', 'Создание, изменение и удаление ролей'),
+       ('jobs:read:any', 'Просмотр фоновых задач'),
+       ('emails:manage:any', 'Просмотр и повторная отправка недоставленных писем');
+
+-- администратор получает все права
+INSERT INTO ROLE_PERMISSIONS (role_id, permission_id)
+SELECT r.id, p.id
+FROM ROLES r
+         CROSS JOIN PERMISSIONS p
+WHERE r.name = 'admin';
@@ -0,0 +1,3 @@
+DROP TABLE ORGANIZATION_INVITATIONS;
+DROP TABLE ORGANIZATION_MEMBERS;
+DROP TABLE ORGANIZATIONS;
@@ -0,0 +1,55 @@
+-- организации (университеты) с сотрудниками и приглашениями
+CREATE TABLE ORGANIZATIONS
+(
+    id          serial                                  not null unique,
+    name        varchar(255)                            not null,
+    domain      varchar(255)                            not null unique,
+    verified_at TIMESTAMPTZ,
+
+    created_at  TIMESTAMPTZ default CURRENT_TIMESTAMP not null,
+    updated_at  TIMESTAMPTZ default CURRENT_TIMESTAMP not null
+);
+
+CREATE TRIGGER update_timestamp_trigger
+    BEFORE UPDATE
+    ON ORGANIZATIONS
+    FOR EACH ROW
+    EXECUTE PROCEDURE update_timestamp();
+
+-- пользователь состоит не более чем в одной организации: она передаётся в токене
+CREATE TABLE ORGANIZATION_MEMBERS
+(
+    organization_id int                                     not null,
+    user_id         int                                     not null unique,
+    role            varchar(16)                             not null,
+    created_at      TIMESTAMPTZ default CURRENT_TIMESTAMP not null,
+
+    PRIMARY KEY (organization_id, user_id),
+    CHECK (role IN ('owner', 'manager', 'viewer')),
+    FOREIGN KEY (organization_id) REFERENCES ORGANIZATIONS (id) ON DELETE CASCADE,
+    FOREIGN KEY (user_id) REFERENCES USERS (id) ON DELETE CASCADE
+);
+
+CREATE TABLE ORGANIZATION_INVITATIONS
+(
+    id              serial                                  not null unique,
+    organization_id int                                     not null,
+    email           varchar(255)                            not null,
+    role            varchar(16)                             not null,
+    token_hash      varchar(255)                            not null unique,
+    invited_by      int,
+    status          varchar(16) default 'pending'         not null,
+    expire_at       TIMESTAMPTZ                             not null,
+    created_at      TIMESTAMPTZ default CURRENT_TIMESTAMP not null,
+    responded_at    TIMESTAMPTZ,
+
+    CHECK (role IN ('owner', 'manager', 'viewer')),
+    CHECK (status IN ('pending', 'accepted', 'declined', 'revoked')),
+    FOREIGN KEY (organization_id) REFERENCES ORGANIZATIONS (id) ON DELETE CASCADE,
+    FOREIGN KEY (invited_by) REFERENCES USERS (id) ON DELETE SET NULL
+);
+
+-- одно действующее приглашение на адрес в организацию
+CREATE UNIQUE INDEX organization_invitations_pending_idx
+    ON ORGANIZATION_INVITATIONS (organization_id, lower(email))
+    WHERE status = 'pending';
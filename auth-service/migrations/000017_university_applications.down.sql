@@ -0,0 +1,6 @@
+DELETE
+FROM PERMISSIONS
+WHERE name = 'universities:review:any';
+
+DROP TABLE APPLICATION_DOCUMENTS;
+DROP TABLE UNIVERSITY_APPLICATIONS;
@@ -0,0 +1,52 @@
+-- заявки пользователей на статус представителя университета
+CREATE TABLE UNIVERSITY_APPLICATIONS
+(
+    id              serial                                  not null unique,
+    user_id         int                                     not null,
+    -- организация пользователя на момент подачи, подтверждается вместе с заявкой
+    organization_id int,
+    university_name varchar(255)                            not null,
+    position        varchar(255)                            not null,
+    message         text        default ''                 not null,
+    status          varchar(16) default 'pending'         not null,
+    reviewer_id     int,
+    review_comment  text        default ''                 not null,
+    reviewed_at     TIMESTAMPTZ,
+    created_at      TIMESTAMPTZ default CURRENT_TIMESTAMP not null,
+
+    CHECK (status IN ('pending', 'approved', 'rejected')),
+    FOREIGN KEY (user_id) REFERENCES USERS (id) ON DELETE CASCADE,
+    FOREIGN KEY (organization_id) REFERENCES ORGANIZATIONS (id) ON DELETE SET NULL,
+    FOREIGN KEY (reviewer_id) REFERENCES USERS (id) ON DELETE SET NULL
+);
+
+-- у пользователя не больше одной заявки на рассмотрении
+CREATE UNIQUE INDEX university_applications_pending_idx
+    ON UNIVERSITY_APPLICATIONS (user_id)
+    WHERE status = 'pending';
+
+CREATE INDEX university_applications_status_idx ON UNIVERSITY_APPLICATIONS (status, id);
+
+-- сами файлы лежат в хранилище, в таблице только их описание
+CREATE TABLE APPLICATION_DOCUMENTS
+(
+    id             serial                                  not null unique,
+    application_id int                                     not null,
+    file_name      varchar(255)                            not null,
+    content_type   varchar(128)                            not null,
+    size           bigint                                  not null,
+    storage_key    varchar(512)                            not null unique,
+    created_at     TIMESTAMPTZ default CURRENT_TIMESTAMP not null,
+
+    FOREIGN KEY (application_id) REFERENCES UNIVERSITY_APPLICATIONS (id) ON DELETE CASCADE
+);
+
+INSERT INTO PERMISSIONS (name, description)
+VALUES ('universities:review:any', 'Рассмотрение заявок представителей университетов');
+
+INSERT INTO ROLE_PERMISSIONS (role_id, permission_id)
+SELECT r.id, p.id
+FROM ROLES r
+         CROSS JOIN PERMISSIONS p
+WHERE r.name = 'admin'
+  AND p.name = 'universities:review:any';
@@ -0,0 +1,18 @@
+DELETE
+FROM PERMISSIONS
+WHERE name = 'academic_domains:manage:any';
+
+DELETE
+FROM USER_TOKENS
+WHERE token_type = 9;
+
+DELETE
+FROM TOKEN_TYPES
+WHERE id = 9;
+
+ALTER TABLE USERS
+    DROP COLUMN student_until,
+    DROP COLUMN pending_academic_email,
+    DROP COLUMN academic_email;
+
+DROP TABLE ACADEMIC_DOMAINS;
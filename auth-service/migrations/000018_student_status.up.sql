@@ -0,0 +1,27 @@
+-- домены почты университетов: адрес на таком домене или его поддомене подтверждает статус студента
+CREATE TABLE ACADEMIC_DOMAINS
+(
+    id              serial                                  not null unique,
+    domain          varchar(255)                            not null unique,
+    university_name varchar(255)                            not null,
+    created_at      TIMESTAMPTZ default CURRENT_TIMESTAMP not null
+);
+
+-- academic_email хранит только подтвержденный адрес, новый адрес ждет подтверждения в pending_academic_email
+ALTER TABLE USERS
+    ADD COLUMN academic_email         varchar(255) unique,
+    ADD COLUMN pending_academic_email varchar(255),
+    ADD COLUMN student_until          TIMESTAMPTZ;
+
+INSERT INTO TOKEN_TYPES
+VALUES (9, 'ACADEMIC_EMAIL_VERIFY');
+
+INSERT INTO PERMISSIONS (name, description)
+VALUES ('academic_domains:manage:any', 'Управление списком доменов почты университетов');
+
+INSERT INTO ROLE_PERMISSIONS (role_id, permission_id)
+SELECT r.id, p.id
+FROM ROLES r
+         CROSS JOIN PERMISSIONS p
+WHERE r.name = 'admin'
+  AND p.name = 'academic_domains:manage:any';
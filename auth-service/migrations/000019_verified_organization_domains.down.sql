@@ -0,0 +1,10 @@
+DROP INDEX organizations_verified_domain_idx;
+
+-- непроверенные дубли верифицированного домена не переживут отката
+DELETE
+FROM ORGANIZATIONS o
+WHERE o.verified_at IS NULL
+  AND EXISTS(SELECT 1 FROM ORGANIZATIONS v WHERE v.domain = o.domain AND v.id <> o.id AND (v.verified_at IS NOT NULL OR v.id < o.id));
+
+ALTER TABLE ORGANIZATIONS
+    ADD CONSTRAINT organizations_domain_key UNIQUE (domain);
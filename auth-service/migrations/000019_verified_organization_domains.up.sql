@@ -0,0 +1,7 @@
+-- домен закрепляется за организацией только после проверки, иначе его мог бы занять кто угодно
+ALTER TABLE ORGANIZATIONS
+    DROP CONSTRAINT organizations_domain_key;
+
+CREATE UNIQUE INDEX organizations_verified_domain_idx
+    ON ORGANIZATIONS (domain)
+    WHERE verified_at IS NOT NULL;
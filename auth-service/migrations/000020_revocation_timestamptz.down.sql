@@ -0,0 +1,6 @@
+ALTER TABLE REVOKED_ACCESS_TOKENS
+    ALTER COLUMN expire_at TYPE TIMESTAMP,
+    ALTER COLUMN created_at TYPE TIMESTAMP;
+
+ALTER TABLE USERS
+    ALTER COLUMN tokens_valid_after TYPE TIMESTAMP;
@@ -0,0 +1,7 @@
+-- отметки отзыва хранятся с часовым поясом: TIMESTAMP без него сдвигался на смещение сессии БД
+ALTER TABLE USERS
+    ALTER COLUMN tokens_valid_after TYPE TIMESTAMPTZ;
+
+ALTER TABLE REVOKED_ACCESS_TOKENS
+    ALTER COLUMN expire_at TYPE TIMESTAMPTZ,
+    ALTER COLUMN created_at TYPE TIMESTAMPTZ;
@@ -0,0 +1,7 @@
+DELETE
+FROM USER_TOKENS
+WHERE token_type IN (10, 11);
+
+DELETE
+FROM TOKEN_TYPES
+WHERE id IN (10, 11);
@@ -0,0 +1,6 @@
+-- неверные коды второго фактора считаются по пользователю, а не по вызову: каждая ошибка хранится
+-- токеном, который истекает по окончании окна блокировки.
+-- администраторы и представители вузов без 2FA получают после входа токен для подключения 2FA.
+INSERT INTO TOKEN_TYPES
+VALUES (10, 'TWO_FACTOR_ENROLMENT'),
+       (11, 'TWO_FACTOR_FAILURE');
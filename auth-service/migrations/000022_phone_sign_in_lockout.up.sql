@@ -0,0 +1,4 @@
+-- неверные коды входа по телефону считаются по пользователю за все отправленные коды,
+-- каждая ошибка хранится токеном, который истекает по окончании окна блокировки
+INSERT INTO TOKEN_TYPES
+VALUES (12, 'PHONE_SIGN_IN_FAILURE');
@@ -0,0 +1,3 @@
+-- зашифрованные письма без ключа не отправить
+DELETE
+FROM EMAIL_OUTBOX;
@@ -0,0 +1,4 @@
+-- тексты писем хранятся зашифрованными, в них ссылки подтверждения, сброса пароля и коды.
+-- Существующие письма зашифровать нельзя (ключ есть только у сервиса), поэтому они удаляются.
+DELETE
+FROM EMAIL_OUTBOX;
@@ -6,79 +6,221 @@ import (
 	"errors"
 	"fmt"
 	"github.com/golang-jwt/jwt/v5"
+	"strconv"
 	"time"
 )
 
-type userClaims struct {
+var (
+	ErrTokenMalformed        = errors.New("token is malformed")
+	ErrTokenExpired          = errors.New("token is expired")
+	ErrTokenNotValidYet      = errors.New("token is not valid yet")
+	ErrTokenInvalidIssuer    = errors.New("token has invalid issuer")
+	ErrTokenInvalidAudience  = errors.New("token has invalid audience")
+	ErrTokenInvalidSignature = errors.New("token signature is invalid")
+)
+
+// IssuedAtPrecision is the precision of iat and the other times in access tokens. Whole seconds
+// wouldn't tell a token issued right before a revocation from one issued right after it.
+const IssuedAtPrecision = time.Millisecond
+
+func init() {
+	jwt.TimePrecision = IssuedAtPrecision
+}
+
+// UserClaims are the claims of an access token. TokenID, IssuedAt and ExpiresAt
+// are set by the Manager and ignored by Generate.
+type UserClaims struct {
+	TokenID  string
 	UserID   int
 	UserName string
 	Role     string
-	ExpireAt int64
+	// Permissions are the effective permissions of Role when the token was issued.
+	Permissions []string
+	// OrganizationID and OrganizationRole are the verified organization of the user and the role in it,
+	// zero if the user is in none.
+	OrganizationID   int
+	OrganizationRole string
+	// StudentUntil is when the student status of the user ends, zero if the user isn't a student.
+	StudentUntil time.Time
+	SessionID    string
+	IssuedAt     time.Time
+	ExpiresAt    time.Time
+}
+
+// accessClaims is the wire format of an access token: registered claims plus user data.
+type accessClaims struct {
+	jwt.RegisteredClaims
+	UserName         string   `json:"user_name"`
+	Role             string   `json:"user_role"`
+	Permissions      []string `json:"perms,omitempty"`
+	OrganizationID   int      `json:"org_id,omitempty"`
+	OrganizationRole string   `json:"org_role,omitempty"`
+	// StudentUntil is a unix time like exp, absent for users who aren't students
+	StudentUntil *jwt.NumericDate `json:"student_until,omitempty"`
+	SessionID    string           `json:"sid,omitempty"`
 }
 
 type TokenManager interface {
-	Generate(userID int, userName string, role string) (string, time.Duration, error)
-	Parse(token string) (userClaims, error)
+	Generate(claims UserClaims) (string, time.Duration, error)
+	Parse(token string) (UserClaims, error)
 	GenerateToken(byteSize int) (string, error)
 	GenerateRefreshToken() (string, int64, error)
+	JWKS() JWKS
+}
+
+// ManagerConfig holds token lifetimes and the values tokens are issued and validated with.
+type ManagerConfig struct {
+	AccessTokenTTL  time.Duration
+	RefreshTokenTTL time.Duration
+	Issuer          string
+	Audience        string
+	// Leeway is the clock skew tolerated when checking exp, nbf and iat.
+	Leeway time.Duration
 }
 
 type Manager struct {
-	signedKey       string
-	accessTokenTTL  time.Duration
-	refreshTokenTTL time.Duration
+	keys   *KeySet
+	cfg    ManagerConfig
+	parser *jwt.Parser
 }
 
-func NewManager(signedKey string, accessTokenTTL time.Duration, refreshTokenTTL time.Duration) (*Manager, error) {
-	if signedKey == "" {
-		return nil, errors.New("empty signed key")
+func NewManager(keys *KeySet, cfg ManagerConfig) (*Manager, error) {
+	if keys == nil {
+		return nil, errors.New("empty key set")
+	}
+	if cfg.Issuer == "" || cfg.Audience == "" {
+		return nil, errors.New("empty issuer or audience")
 	}
 
 	return &Manager{
-		signedKey:       signedKey,
-		accessTokenTTL:  accessTokenTTL,
-		refreshTokenTTL: refreshTokenTTL,
+		keys: keys,
+		cfg:  cfg,
+		parser: jwt.NewParser(
+			jwt.WithValidMethods([]string{jwt.SigningMethodEdDSA.Alg(), jwt.SigningMethodRS256.Alg()}),
+			jwt.WithIssuer(cfg.Issuer),
+			jwt.WithAudience(cfg.Audience),
+			jwt.WithLeeway(cfg.Leeway),
+			jwt.WithIssuedAt(),
+		),
 	}, nil
 }
 
-func (m *Manager) Generate(userID int, userName string, role string) (string, time.Duration, error) {
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
-		"user_id":   userID,
-		"user_role": role,
-		"user_name": userName,
-		"expire_at": time.Now().Add(m.accessTokenTTL).Unix(),
+func (m *Manager) Generate(claims UserClaims) (string, time.Duration, error) {
+	key := m.keys.Active()
+
+	tokenID, err := m.GenerateToken(16)
+	if err != nil {
+		return "", 0, err
+	}
+
+	now := time.Now()
+
+	token := jwt.NewWithClaims(key.Method, accessClaims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        tokenID,
+			Issuer:    m.cfg.Issuer,
+			Audience:  jwt.ClaimStrings{m.cfg.Audience},
+			Subject:   strconv.Itoa(claims.UserID),
+			IssuedAt:  jwt.NewNumericDate(now),
+			NotBefore: jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(m.cfg.AccessTokenTTL)),
+		},
+		UserName:         claims.UserName,
+		Role:             claims.Role,
+		Permissions:      claims.Permissions,
+		OrganizationID:   claims.OrganizationID,
+		OrganizationRole: claims.OrganizationRole,
+		StudentUntil:     numericDateOrNil(claims.StudentUntil),
+		SessionID:        claims.SessionID,
 	})
-	tokenString, err := token.SignedString([]byte(m.signedKey))
+	token.Header["kid"] = key.ID
+
+	tokenString, err := token.SignedString(key.PrivateKey)
 
 	if err != nil {
 		return "", 0, fmt.Errorf("error with sign token: %s", err.Error())
 	}
 
-	return tokenString, m.accessTokenTTL, nil
+	return tokenString, m.cfg.AccessTokenTTL, nil
 }
 
-func (m *Manager) Parse(tokenString string) (userClaims, error) {
-	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (i interface{}, err error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+// Parse verifies the signature and registered claims of an access token.
+// Validation failures are reported as one of the ErrToken* errors.
+func (m *Manager) Parse(tokenString string) (UserClaims, error) {
+	var claims accessClaims
+
+	_, err := m.parser.ParseWithClaims(tokenString, &claims, func(token *jwt.Token) (i interface{}, err error) {
+		kid, ok := token.Header["kid"].(string)
+		if !ok {
+			return nil, errors.New("token has no key id")
+		}
+
+		key, ok := m.keys.Lookup(kid)
+		if !ok {
+			return nil, fmt.Errorf("unknown key id: %s", kid)
+		}
+
+		if token.Method.Alg() != key.Method.Alg() {
 			return nil, fmt.Errorf("Unexpected signing method: %v", token.Header["alg"])
 		}
 
-		return []byte(m.signedKey), nil
+		return key.PublicKey, nil
 	})
 	if err != nil {
-		return userClaims{}, err
+		return UserClaims{}, mapParseError(err)
 	}
 
-	if claims, ok := token.Claims.(jwt.MapClaims); ok && token.Valid {
-		return userClaims{
-			UserID:   int(claims["user_id"].(float64)),
-			UserName: claims["user_name"].(string),
-			Role:     claims["user_role"].(string),
-			ExpireAt: int64(claims["expire_at"].(float64)),
-		}, nil
+	if claims.ID == "" || claims.ExpiresAt == nil || claims.IssuedAt == nil {
+		return UserClaims{}, ErrTokenMalformed
 	}
-	return userClaims{}, fmt.Errorf("cannot get claims from token")
 
+	userID, err := strconv.Atoi(claims.Subject)
+	if err != nil {
+		return UserClaims{}, ErrTokenMalformed
+	}
+
+	var studentUntil time.Time
+	if claims.StudentUntil != nil {
+		studentUntil = claims.StudentUntil.Time
+	}
+
+	return UserClaims{
+		TokenID:          claims.ID,
+		UserID:           userID,
+		UserName:         claims.UserName,
+		Role:             claims.Role,
+		Permissions:      claims.Permissions,
+		OrganizationID:   claims.OrganizationID,
+		OrganizationRole: claims.OrganizationRole,
+		StudentUntil:     studentUntil,
+		SessionID:        claims.SessionID,
+		IssuedAt:         claims.IssuedAt.Time,
+		ExpiresAt:        claims.ExpiresAt.Time,
+	}, nil
+}
+
+func numericDateOrNil(t time.Time) *jwt.NumericDate {
+	if t.IsZero() {
+		return nil
+	}
+	return jwt.NewNumericDate(t)
+}
+
+func mapParseError(err error) error {
+	switch {
+	case errors.Is(err, jwt.ErrTokenExpired):
+		return ErrTokenExpired
+	case errors.Is(err, jwt.ErrTokenNotValidYet), errors.Is(err, jwt.ErrTokenUsedBeforeIssued):
+		return ErrTokenNotValidYet
+	case errors.Is(err, jwt.ErrTokenInvalidIssuer):
+		return ErrTokenInvalidIssuer
+	case errors.Is(err, jwt.ErrTokenInvalidAudience):
+		return ErrTokenInvalidAudience
+	case errors.Is(err, jwt.ErrTokenSignatureInvalid), errors.Is(err, jwt.ErrTokenUnverifiable):
+		return ErrTokenInvalidSignature
+	default:
+		return ErrTokenMalformed
+	}
 }
 
 func (m *Manager) GenerateToken(byteSize int) (string, error) {
@@ -98,6 +240,11 @@ func (m *Manager) GenerateRefreshToken() (string, int64, error) {
 	if err != nil {
 		return "", 0, err
 	}
-	expireAt := time.Now().Add(m.refreshTokenTTL).Unix()
+	expireAt := time.Now().Add(m.cfg.RefreshTokenTTL).Unix()
 	return token, expireAt, nil
 }
+
+// JWKS returns public keys tokens can be verified with.
+func (m *Manager) JWKS() JWKS {
+	return m.keys.JWKS()
+}
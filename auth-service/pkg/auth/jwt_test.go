@@ -0,0 +1,329 @@
+package auth
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"errors"
+	"github.com/golang-jwt/jwt/v5"
+	"strings"
+	"testing"
+	"time"
+)
+
+const (
+	testIssuer   = "https://auth.edutour.test"
+	testAudience = "edutour"
+)
+
+func newTestEd25519Key(t *testing.T, id string) *SigningKey {
+	t.Helper()
+
+	_, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	key, err := NewSigningKey(id, privateKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return key
+}
+
+func newTestRSAKey(t *testing.T, id string) *SigningKey {
+	t.Helper()
+
+	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	key, err := NewSigningKey(id, privateKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return key
+}
+
+func newTestManager(t *testing.T, activeID string, keys ...*SigningKey) *Manager {
+	t.Helper()
+
+	keySet, err := NewKeySetFromKeys(activeID, keys...)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m, err := NewManager(keySet, ManagerConfig{
+		AccessTokenTTL: 15 * time.Minute,
+		Issuer:         testIssuer,
+		Audience:       testAudience,
+		Leeway:         30 * time.Second,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return m
+}
+
+// validClaims are claims Manager.Parse accepts, tests break one of them.
+func validClaims() accessClaims {
+	now := time.Now()
+
+	return accessClaims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        "token-id",
+			Issuer:    testIssuer,
+			Audience:  jwt.ClaimStrings{testAudience},
+			Subject:   "42",
+			IssuedAt:  jwt.NewNumericDate(now),
+			NotBefore: jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(time.Minute)),
+		},
+		UserName: "ivan",
+		Role:     "user",
+	}
+}
+
+func signTestToken(t *testing.T, method jwt.SigningMethod, kid string, key any, claims jwt.Claims) string {
+	t.Helper()
+
+	token := jwt.NewWithClaims(method, claims)
+	if kid != "" {
+		token.Header["kid"] = kid
+	}
+
+	s, err := token.SignedString(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return s
+}
+
+func TestManagerRoundTrip(t *testing.T) {
+	for _, key := range []*SigningKey{newTestEd25519Key(t, "ed"), newTestRSAKey(t, "rsa")} {
+		t.Run(key.Method.Alg(), func(t *testing.T) {
+			m := newTestManager(t, key.ID, key)
+
+			studentUntil := time.Now().Add(24 * time.Hour).Truncate(time.Second)
+
+			token, ttl, err := m.Generate(UserClaims{
+				UserID:           42,
+				UserName:         "ivan",
+				Role:             "university",
+				Permissions:      []string{"universities:manage:own"},
+				OrganizationID:   7,
+				OrganizationRole: "owner",
+				StudentUntil:     studentUntil,
+				SessionID:        "family",
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if ttl != 15*time.Minute {
+				t.Fatalf("ttl = %v, want 15m", ttl)
+			}
+
+			claims, err := m.Parse(token)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if claims.TokenID == "" || claims.UserID != 42 || claims.UserName != "ivan" || claims.Role != "university" ||
+				len(claims.Permissions) != 1 || claims.OrganizationID != 7 || claims.OrganizationRole != "owner" ||
+				!claims.StudentUntil.Equal(studentUntil) || claims.SessionID != "family" {
+				t.Fatalf("unexpected claims %+v", claims)
+			}
+		})
+	}
+}
+
+func TestManagerParseRejects(t *testing.T) {
+	edKey := newTestEd25519Key(t, "ed")
+	rsaKey := newTestRSAKey(t, "rsa")
+	m := newTestManager(t, edKey.ID, edKey, rsaKey)
+
+	_, otherPrivateKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	with := func(change func(c *accessClaims)) accessClaims {
+		c := validClaims()
+		change(&c)
+		return c
+	}
+
+	tests := []struct {
+		name  string
+		token string
+		want  error
+	}{
+		{
+			name:  "garbage",
+			token: "not.a.token",
+			want:  ErrTokenMalformed,
+		},
+		{
+			name:  "alg none",
+			token: signTestToken(t, jwt.SigningMethodNone, edKey.ID, jwt.UnsafeAllowNoneSignatureType, validClaims()),
+			want:  ErrTokenInvalidSignature,
+		},
+		{
+			name:  "HMAC with a key id",
+			token: signTestToken(t, jwt.SigningMethodHS256, edKey.ID, []byte("secret"), validClaims()),
+			want:  ErrTokenInvalidSignature,
+		},
+		{
+			name:  "alg of another key type",
+			token: signTestToken(t, jwt.SigningMethodRS256, edKey.ID, rsaKey.PrivateKey, validClaims()),
+			want:  ErrTokenInvalidSignature,
+		},
+		{
+			name:  "unknown key id",
+			token: signTestToken(t, jwt.SigningMethodEdDSA, "unknown", edKey.PrivateKey, validClaims()),
+			want:  ErrTokenInvalidSignature,
+		},
+		{
+			name:  "no key id",
+			token: signTestToken(t, jwt.SigningMethodEdDSA, "", edKey.PrivateKey, validClaims()),
+			want:  ErrTokenInvalidSignature,
+		},
+		{
+			name:  "signed with another key",
+			token: signTestToken(t, jwt.SigningMethodEdDSA, edKey.ID, otherPrivateKey, validClaims()),
+			want:  ErrTokenInvalidSignature,
+		},
+		{
+			name: "wrong issuer",
+			token: signTestToken(t, jwt.SigningMethodEdDSA, edKey.ID, edKey.PrivateKey, with(func(c *accessClaims) {
+				c.Issuer = "https://evil.test"
+			})),
+			want: ErrTokenInvalidIssuer,
+		},
+		{
+			name: "wrong audience",
+			token: signTestToken(t, jwt.SigningMethodEdDSA, edKey.ID, edKey.PrivateKey, with(func(c *accessClaims) {
+				c.Audience = jwt.ClaimStrings{"another-service"}
+			})),
+			want: ErrTokenInvalidAudience,
+		},
+		{
+			name: "expired beyond leeway",
+			token: signTestToken(t, jwt.SigningMethodEdDSA, edKey.ID, edKey.PrivateKey, with(func(c *accessClaims) {
+				c.IssuedAt = jwt.NewNumericDate(time.Now().Add(-time.Hour))
+				c.NotBefore = c.IssuedAt
+				c.ExpiresAt = jwt.NewNumericDate(time.Now().Add(-time.Minute))
+			})),
+			want: ErrTokenExpired,
+		},
+		{
+			name: "not valid yet beyond leeway",
+			token: signTestToken(t, jwt.SigningMethodEdDSA, edKey.ID, edKey.PrivateKey, with(func(c *accessClaims) {
+				c.NotBefore = jwt.NewNumericDate(time.Now().Add(time.Minute))
+			})),
+			want: ErrTokenNotValidYet,
+		},
+		{
+			name: "issued in the future",
+			token: signTestToken(t, jwt.SigningMethodEdDSA, edKey.ID, edKey.PrivateKey, with(func(c *accessClaims) {
+				c.IssuedAt = jwt.NewNumericDate(time.Now().Add(time.Minute))
+				c.NotBefore = nil
+			})),
+			want: ErrTokenNotValidYet,
+		},
+		{
+			name: "no token id",
+			token: signTestToken(t, jwt.SigningMethodEdDSA, edKey.ID, edKey.PrivateKey, with(func(c *accessClaims) {
+				c.ID = ""
+			})),
+			want: ErrTokenMalformed,
+		},
+		{
+			name: "no expiration",
+			token: signTestToken(t, jwt.SigningMethodEdDSA, edKey.ID, edKey.PrivateKey, with(func(c *accessClaims) {
+				c.ExpiresAt = nil
+			})),
+			want: ErrTokenMalformed,
+		},
+		{
+			name: "subject isn't a user id",
+			token: signTestToken(t, jwt.SigningMethodEdDSA, edKey.ID, edKey.PrivateKey, with(func(c *accessClaims) {
+				c.Subject = "ivan"
+			})),
+			want: ErrTokenMalformed,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := m.Parse(tt.token); !errors.Is(err, tt.want) {
+				t.Fatalf("err = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestManagerParseLeeway(t *testing.T) {
+	key := newTestEd25519Key(t, "ed")
+	m := newTestManager(t, key.ID, key)
+
+	claims := validClaims()
+	claims.IssuedAt = jwt.NewNumericDate(time.Now().Add(10 * time.Second))
+	claims.NotBefore = claims.IssuedAt
+	claims.ExpiresAt = jwt.NewNumericDate(time.Now().Add(-10 * time.Second))
+
+	if _, err := m.Parse(signTestToken(t, jwt.SigningMethodEdDSA, key.ID, key.PrivateKey, claims)); err != nil {
+		t.Fatalf("token within leeway rejected: %v", err)
+	}
+}
+
+func TestManagerParseTamperedPayload(t *testing.T) {
+	key := newTestEd25519Key(t, "ed")
+	m := newTestManager(t, key.ID, key)
+
+	token, _, err := m.Generate(UserClaims{UserID: 42, Role: "user"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	parts := strings.Split(token, ".")
+	forged := signTestToken(t, jwt.SigningMethodEdDSA, key.ID, key.PrivateKey, validClaims())
+	parts[1] = strings.Split(forged, ".")[1]
+
+	if _, err := m.Parse(strings.Join(parts, ".")); !errors.Is(err, ErrTokenInvalidSignature) {
+		t.Fatalf("err = %v, want %v", err, ErrTokenInvalidSignature)
+	}
+}
+
+func TestNewManager(t *testing.T) {
+	key := newTestEd25519Key(t, "ed")
+	keySet, err := NewKeySetFromKeys(key.ID, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		keys    *KeySet
+		cfg     ManagerConfig
+		wantErr bool
+	}{
+		{name: "valid", keys: keySet, cfg: ManagerConfig{Issuer: testIssuer, Audience: testAudience}},
+		{name: "no keys", cfg: ManagerConfig{Issuer: testIssuer, Audience: testAudience}, wantErr: true},
+		{name: "no issuer", keys: keySet, cfg: ManagerConfig{Audience: testAudience}, wantErr: true},
+		{name: "no audience", keys: keySet, cfg: ManagerConfig{Issuer: testIssuer}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewManager(tt.keys, tt.cfg); (err != nil) != tt.wantErr {
+				t.Fatalf("got error %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,226 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// SigningKey is a key pair used to sign and verify access tokens.
+// Retired keys have no private part and are only used for verification.
+type SigningKey struct {
+	ID         string
+	Method     jwt.SigningMethod
+	PrivateKey crypto.Signer
+	PublicKey  crypto.PublicKey
+}
+
+// KeySet holds the active signing key and every key tokens may still be verified with.
+type KeySet struct {
+	active *SigningKey
+	keys   map[string]*SigningKey
+	order  []string
+}
+
+// KeyFile points to a PEM-encoded key on disk. Either path may be empty:
+// a private key is required for the active key, a public key is enough for retired ones.
+type KeyFile struct {
+	ID             string
+	PrivateKeyPath string
+	PublicKeyPath  string
+}
+
+// NewKeySet loads keys from files. activeID selects the key new tokens are signed with.
+func NewKeySet(activeID string, files []KeyFile) (*KeySet, error) {
+	keys := make([]*SigningKey, 0, len(files))
+
+	for _, f := range files {
+		key, err := loadKey(f)
+		if err != nil {
+			return nil, fmt.Errorf("load key %q: %w", f.ID, err)
+		}
+		keys = append(keys, key)
+	}
+
+	return NewKeySetFromKeys(activeID, keys...)
+}
+
+// NewKeySetFromKeys builds a KeySet from already parsed keys.
+func NewKeySetFromKeys(activeID string, keys ...*SigningKey) (*KeySet, error) {
+	ks := &KeySet{
+		keys: make(map[string]*SigningKey, len(keys)),
+	}
+
+	for _, key := range keys {
+		if key.ID == "" {
+			return nil, errors.New("key id is empty")
+		}
+		if _, ok := ks.keys[key.ID]; ok {
+			return nil, fmt.Errorf("duplicate key id %q", key.ID)
+		}
+		ks.keys[key.ID] = key
+		ks.order = append(ks.order, key.ID)
+	}
+
+	active, ok := ks.keys[activeID]
+	if !ok {
+		return nil, fmt.Errorf("active key %q is not configured", activeID)
+	}
+	if active.PrivateKey == nil {
+		return nil, fmt.Errorf("active key %q has no private key", activeID)
+	}
+	ks.active = active
+
+	return ks, nil
+}
+
+// NewSigningKey wraps an Ed25519 or RSA private key.
+func NewSigningKey(id string, privateKey crypto.Signer) (*SigningKey, error) {
+	method, err := signingMethodFor(privateKey.Public())
+	if err != nil {
+		return nil, err
+	}
+
+	return &SigningKey{
+		ID:         id,
+		Method:     method,
+		PrivateKey: privateKey,
+		PublicKey:  privateKey.Public(),
+	}, nil
+}
+
+// Active returns the key new tokens are signed with.
+func (ks *KeySet) Active() *SigningKey {
+	return ks.active
+}
+
+// Lookup returns the key with the given id, active or retired.
+func (ks *KeySet) Lookup(id string) (*SigningKey, bool) {
+	key, ok := ks.keys[id]
+	return key, ok
+}
+
+func loadKey(f KeyFile) (*SigningKey, error) {
+	if f.PrivateKeyPath != "" {
+		data, err := os.ReadFile(f.PrivateKeyPath)
+		if err != nil {
+			return nil, err
+		}
+
+		var privateKey crypto.PrivateKey
+		if privateKey, err = jwt.ParseEdPrivateKeyFromPEM(data); err != nil {
+			if privateKey, err = jwt.ParseRSAPrivateKeyFromPEM(data); err != nil {
+				return nil, errors.New("private key is neither Ed25519 nor RSA")
+			}
+		}
+
+		signer, ok := privateKey.(crypto.Signer)
+		if !ok {
+			return nil, fmt.Errorf("unsupported private key type %T", privateKey)
+		}
+
+		return NewSigningKey(f.ID, signer)
+	}
+
+	if f.PublicKeyPath == "" {
+		return nil, errors.New("neither private nor public key path is set")
+	}
+
+	data, err := os.ReadFile(f.PublicKeyPath)
+	if err != nil {
+		return nil, err
+	}
+
+	var publicKey crypto.PublicKey
+	if publicKey, err = jwt.ParseEdPublicKeyFromPEM(data); err != nil {
+		if publicKey, err = jwt.ParseRSAPublicKeyFromPEM(data); err != nil {
+			return nil, errors.New("public key is neither Ed25519 nor RSA")
+		}
+	}
+
+	method, err := signingMethodFor(publicKey)
+	if err != nil {
+		return nil, err
+	}
+
+	return &SigningKey{
+		ID:        f.ID,
+		Method:    method,
+		PublicKey: publicKey,
+	}, nil
+}
+
+func signingMethodFor(publicKey crypto.PublicKey) (jwt.SigningMethod, error) {
+	switch publicKey.(type) {
+	case ed25519.PublicKey:
+		return jwt.SigningMethodEdDSA, nil
+	case *rsa.PublicKey:
+		return jwt.SigningMethodRS256, nil
+	default:
+		return nil, fmt.Errorf("unsupported key type %T", publicKey)
+	}
+}
+
+// JWK is a public key in JSON Web Key format (RFC 7517).
+type JWK struct {
+	KeyType   string `json:"kty"`
+	KeyID     string `json:"kid"`
+	Use       string `json:"use"`
+	Algorithm string `json:"alg"`
+
+	// RSA
+	N string `json:"n,omitempty"`
+	E string `json:"e,omitempty"`
+
+	// OKP
+	Curve string `json:"crv,omitempty"`
+	X     string `json:"x,omitempty"`
+}
+
+// JWKS is a JSON Web Key Set.
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// JWKS returns public parts of all keys, active key first.
+func (ks *KeySet) JWKS() JWKS {
+	set := JWKS{Keys: make([]JWK, 0, len(ks.keys))}
+
+	set.Keys = append(set.Keys, ks.active.jwk())
+	for _, id := range ks.order {
+		if id == ks.active.ID {
+			continue
+		}
+		set.Keys = append(set.Keys, ks.keys[id].jwk())
+	}
+
+	return set
+}
+
+func (k *SigningKey) jwk() JWK {
+	jwk := JWK{
+		KeyID:     k.ID,
+		Use:       "sig",
+		Algorithm: k.Method.Alg(),
+	}
+
+	switch pub := k.PublicKey.(type) {
+	case ed25519.PublicKey:
+		jwk.KeyType = "OKP"
+		jwk.Curve = "Ed25519"
+		jwk.X = base64.RawURLEncoding.EncodeToString(pub)
+	case *rsa.PublicKey:
+		jwk.KeyType = "RSA"
+		jwk.N = base64.RawURLEncoding.EncodeToString(pub.N.Bytes())
+		jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes())
+	}
+
+	return jwk
+}
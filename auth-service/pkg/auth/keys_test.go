@@ -0,0 +1,213 @@
+package auth
+
+import (
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// retired returns the key without its private part, as it is configured once it no longer signs.
+func retired(key *SigningKey) *SigningKey {
+	return &SigningKey{ID: key.ID, Method: key.Method, PublicKey: key.PublicKey}
+}
+
+func TestKeyRotation(t *testing.T) {
+	oldKey := newTestEd25519Key(t, "2024-01")
+	newKey := newTestRSAKey(t, "2024-06")
+
+	before := newTestManager(t, oldKey.ID, oldKey)
+	oldToken, _, err := before.Generate(UserClaims{UserID: 42, Role: "user"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	during := newTestManager(t, newKey.ID, newKey, retired(oldKey))
+	newToken, _, err := during.Generate(UserClaims{UserID: 42, Role: "user"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	after := newTestManager(t, newKey.ID, newKey)
+
+	tests := []struct {
+		name    string
+		manager *Manager
+		token   string
+		wantErr error
+	}{
+		{name: "old token before rotation", manager: before, token: oldToken},
+		{name: "old token during overlap", manager: during, token: oldToken},
+		{name: "new token during overlap", manager: during, token: newToken},
+		{name: "new token before rotation", manager: before, token: newToken, wantErr: ErrTokenInvalidSignature},
+		{name: "old token after retirement", manager: after, token: oldToken, wantErr: ErrTokenInvalidSignature},
+		{name: "new token after retirement", manager: after, token: newToken},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := tt.manager.Parse(tt.token); !errors.Is(err, tt.wantErr) {
+				t.Fatalf("err = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestNewKeySetFromKeys(t *testing.T) {
+	active := newTestEd25519Key(t, "active")
+	old := newTestEd25519Key(t, "old")
+
+	tests := []struct {
+		name     string
+		activeID string
+		keys     []*SigningKey
+		wantErr  bool
+	}{
+		{name: "active and retired", activeID: "active", keys: []*SigningKey{active, retired(old)}},
+		{name: "unknown active key", activeID: "missing", keys: []*SigningKey{active}, wantErr: true},
+		{name: "active key without private key", activeID: "old", keys: []*SigningKey{active, retired(old)}, wantErr: true},
+		{name: "duplicate key id", activeID: "active", keys: []*SigningKey{active, active}, wantErr: true},
+		{name: "empty key id", activeID: "active", keys: []*SigningKey{active, {Method: old.Method, PublicKey: old.PublicKey}}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewKeySetFromKeys(tt.activeID, tt.keys...); (err != nil) != tt.wantErr {
+				t.Fatalf("got error %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestKeySetJWKS(t *testing.T) {
+	oldKey := newTestRSAKey(t, "old")
+	activeKey := newTestEd25519Key(t, "active")
+	otherKey := newTestEd25519Key(t, "other")
+
+	keySet, err := NewKeySetFromKeys(activeKey.ID, retired(oldKey), activeKey, retired(otherKey))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	jwks := keySet.JWKS()
+
+	var ids []string
+	for _, jwk := range jwks.Keys {
+		ids = append(ids, jwk.KeyID)
+	}
+	if len(ids) != 3 || ids[0] != "active" || ids[1] != "old" || ids[2] != "other" {
+		t.Fatalf("key ids = %v, want the active key first", ids)
+	}
+
+	ed := jwks.Keys[0]
+	x, err := base64.RawURLEncoding.DecodeString(ed.X)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ed.KeyType != "OKP" || ed.Curve != "Ed25519" || ed.Algorithm != "EdDSA" || ed.Use != "sig" ||
+		!ed25519.PublicKey(x).Equal(activeKey.PublicKey) {
+		t.Fatalf("unexpected Ed25519 key %+v", ed)
+	}
+
+	rsaJWK := jwks.Keys[1]
+	n, err := base64.RawURLEncoding.DecodeString(rsaJWK.N)
+	if err != nil {
+		t.Fatal(err)
+	}
+	e, err := base64.RawURLEncoding.DecodeString(rsaJWK.E)
+	if err != nil {
+		t.Fatal(err)
+	}
+	publicKey := &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}
+	if rsaJWK.KeyType != "RSA" || rsaJWK.Algorithm != "RS256" || !publicKey.Equal(oldKey.PublicKey) {
+		t.Fatalf("unexpected RSA key %+v", rsaJWK)
+	}
+}
+
+func TestNewKeySetFromFiles(t *testing.T) {
+	dir := t.TempDir()
+
+	writePEM := func(name string, blockType string, der []byte) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der}), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	activeKey := newTestEd25519Key(t, "active")
+	oldKey := newTestRSAKey(t, "old")
+
+	privateDER, err := x509.MarshalPKCS8PrivateKey(activeKey.PrivateKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	publicDER, err := x509.MarshalPKIXPublicKey(oldKey.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	activePath := writePEM("active.pem", "PRIVATE KEY", privateDER)
+	oldPath := writePEM("old.pub.pem", "PUBLIC KEY", publicDER)
+	garbagePath := writePEM("garbage.pem", "PRIVATE KEY", []byte("garbage"))
+
+	tests := []struct {
+		name    string
+		files   []KeyFile
+		wantErr bool
+	}{
+		{
+			name:  "private active key and public retired key",
+			files: []KeyFile{{ID: "active", PrivateKeyPath: activePath}, {ID: "old", PublicKeyPath: oldPath}},
+		},
+		{
+			name:    "active key with public key only",
+			files:   []KeyFile{{ID: "active", PublicKeyPath: oldPath}},
+			wantErr: true,
+		},
+		{
+			name:    "unparsable key",
+			files:   []KeyFile{{ID: "active", PrivateKeyPath: garbagePath}},
+			wantErr: true,
+		},
+		{
+			name:    "missing file",
+			files:   []KeyFile{{ID: "active", PrivateKeyPath: filepath.Join(dir, "missing.pem")}},
+			wantErr: true,
+		},
+		{
+			name:    "no paths",
+			files:   []KeyFile{{ID: "active"}},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			keySet, err := NewKeySet("active", tt.files)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("got error %v, want error %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+
+			// a token the old key signed before it was retired still verifies
+			oldToken := signTestToken(t, oldKey.Method, oldKey.ID, oldKey.PrivateKey, validClaims())
+
+			m, err := NewManager(keySet, ManagerConfig{Issuer: testIssuer, Audience: testAudience})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := m.Parse(oldToken); err != nil {
+				t.Fatalf("token of the retired key rejected: %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,225 @@
+package email
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// dkimHeaders are the headers signed when present. From is required by RFC 6376.
+var dkimHeaders = []string{"From", "To", "Cc", "Reply-To", "Subject", "Date", "Message-ID", "MIME-Version", "Content-Type"}
+
+// DKIMSigner signs messages with DKIM (RFC 6376) using rsa-sha256 and relaxed/relaxed canonicalization.
+// The public key must be published in the TXT record <selector>._domainkey.<domain>.
+type DKIMSigner struct {
+	domain   string
+	selector string
+	key      *rsa.PrivateKey
+	now      func() time.Time
+}
+
+func NewDKIMSigner(domain string, selector string, key *rsa.PrivateKey) (*DKIMSigner, error) {
+	if domain == "" || selector == "" {
+		return nil, errors.New("dkim domain and selector are required")
+	}
+	if key == nil {
+		return nil, errors.New("dkim private key is required")
+	}
+
+	return &DKIMSigner{
+		domain:   domain,
+		selector: selector,
+		key:      key,
+		now:      time.Now,
+	}, nil
+}
+
+// LoadDKIMKey reads an RSA private key in a PKCS #1 or PKCS #8 PEM file.
+func LoadDKIMKey(path string) (*rsa.PrivateKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return ParseDKIMKey(data)
+}
+
+// ParseDKIMKey parses an RSA private key in the PKCS #1 or PKCS #8 PEM format.
+func ParseDKIMKey(data []byte) (*rsa.PrivateKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("dkim private key is not PEM encoded")
+	}
+
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("parse dkim private key: %w", err)
+	}
+
+	key, ok := parsed.(*rsa.PrivateKey)
+	if !ok {
+		return nil, errors.New("dkim private key is not an RSA key")
+	}
+
+	return key, nil
+}
+
+// Sign returns the message with a DKIM-Signature header prepended.
+// Bare LF line endings are turned into CRLF first, as they would be on the wire.
+func (s *DKIMSigner) Sign(raw []byte) ([]byte, error) {
+	raw = toCRLF(raw)
+
+	header, body, ok := bytes.Cut(raw, []byte("\r\n\r\n"))
+	if !ok {
+		header, body = bytes.TrimSuffix(raw, []byte("\r\n")), nil
+	}
+
+	fields := splitHeaderFields(header)
+
+	var (
+		signedNames []string
+		signedInput bytes.Buffer
+	)
+	for _, name := range dkimHeaders {
+		field, ok := lastHeaderField(fields, name)
+		if !ok {
+			continue
+		}
+		signedNames = append(signedNames, strings.ToLower(name))
+		signedInput.WriteString(relaxedHeader(field))
+		signedInput.WriteString("\r\n")
+	}
+
+	if len(signedNames) == 0 || signedNames[0] != "from" {
+		return nil, errors.New("dkim: message has no From header")
+	}
+
+	bodyHash := sha256.Sum256(relaxedBody(body))
+
+	signature := "DKIM-Signature: v=1; a=rsa-sha256; c=relaxed/relaxed;\r\n" +
+		" d=" + s.domain + "; s=" + s.selector + "; t=" + strconv.FormatInt(s.now().Unix(), 10) + ";\r\n" +
+		" h=" + strings.Join(signedNames, ":") + ";\r\n" +
+		" bh=" + base64.StdEncoding.EncodeToString(bodyHash[:]) + ";\r\n" +
+		" b="
+
+	// the signature header itself is signed with an empty b= and without the trailing CRLF
+	signedInput.WriteString(relaxedHeader(signature))
+
+	digest := sha256.Sum256(signedInput.Bytes())
+	sig, err := rsa.SignPKCS1v15(rand.Reader, s.key, crypto.SHA256, digest[:])
+	if err != nil {
+		return nil, err
+	}
+
+	var signed bytes.Buffer
+	signed.WriteString(signature)
+	signed.WriteString(foldBase64(base64.StdEncoding.EncodeToString(sig)))
+	signed.WriteString("\r\n")
+	signed.Write(raw)
+
+	return signed.Bytes(), nil
+}
+
+// toCRLF replaces bare LF line endings with CRLF.
+func toCRLF(raw []byte) []byte {
+	if !bytes.Contains(raw, []byte("\n")) {
+		return raw
+	}
+
+	normalized := bytes.ReplaceAll(raw, []byte("\r\n"), []byte("\n"))
+	return bytes.ReplaceAll(normalized, []byte("\n"), []byte("\r\n"))
+}
+
+// splitHeaderFields splits a header block into fields, keeping the folded lines of a field together.
+func splitHeaderFields(header []byte) []string {
+	var fields []string
+
+	for _, line := range strings.Split(string(header), "\r\n") {
+		if len(fields) > 0 && (strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t")) {
+			fields[len(fields)-1] += "\r\n" + line
+			continue
+		}
+		fields = append(fields, line)
+	}
+
+	return fields
+}
+
+// lastHeaderField returns the bottom-most field with the name, which is the one a verifier checks first.
+func lastHeaderField(fields []string, name string) (string, bool) {
+	for i := len(fields) - 1; i >= 0; i-- {
+		fieldName, _, ok := strings.Cut(fields[i], ":")
+		if ok && strings.EqualFold(strings.TrimRight(fieldName, " \t"), name) {
+			return fields[i], true
+		}
+	}
+
+	return "", false
+}
+
+// relaxedHeader canonicalizes a header field the "relaxed" way (RFC 6376 section 3.4.2).
+func relaxedHeader(field string) string {
+	name, value, _ := strings.Cut(field, ":")
+
+	value = strings.ReplaceAll(value, "\r\n", "")
+	value = strings.Join(strings.FieldsFunc(value, isWSP), " ")
+
+	return strings.ToLower(strings.TrimRight(name, " \t")) + ":" + value
+}
+
+// relaxedBody canonicalizes a body the "relaxed" way (RFC 6376 section 3.4.4).
+func relaxedBody(body []byte) []byte {
+	lines := strings.Split(string(body), "\r\n")
+
+	for i, line := range lines {
+		line = strings.TrimRight(line, " \t")
+		lines[i] = strings.Join(strings.FieldsFunc(line, isWSP), " ")
+		if strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t") {
+			lines[i] = " " + lines[i]
+		}
+	}
+
+	for len(lines) > 0 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+
+	if len(lines) == 0 {
+		return nil
+	}
+
+	return []byte(strings.Join(lines, "\r\n") + "\r\n")
+}
+
+func isWSP(r rune) bool {
+	return r == ' ' || r == '\t'
+}
+
+// foldBase64 splits a long base64 value into continuation lines.
+func foldBase64(value string) string {
+	const width = 72
+
+	var b strings.Builder
+	for len(value) > width {
+		b.WriteString(value[:width])
+		b.WriteString("\r\n ")
+		value = value[width:]
+	}
+	b.WriteString(value)
+
+	return b.String()
+}
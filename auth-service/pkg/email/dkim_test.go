@@ -0,0 +1,309 @@
+package email
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"github.com/emersion/go-msgauth/dkim"
+	"net/mail"
+	"strings"
+	"testing"
+	"time"
+)
+
+const (
+	testDKIMDomain   = "edutour.test"
+	testDKIMSelector = "mail"
+)
+
+func newTestDKIMSigner(t *testing.T) (*DKIMSigner, *rsa.PrivateKey) {
+	t.Helper()
+
+	key, err := LoadDKIMKey("testdata/dkim.pem")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	signer, err := NewDKIMSigner(testDKIMDomain, testDKIMSelector, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer.now = func() time.Time { return time.Unix(1700000000, 0) }
+
+	return signer, key
+}
+
+// verifyDKIM checks the signatures with an independent implementation,
+// resolving the DNS record from the public key.
+func verifyDKIM(t *testing.T, signed []byte, key *rsa.PublicKey) []*dkim.Verification {
+	t.Helper()
+
+	der, err := x509.MarshalPKIXPublicKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	record := "v=DKIM1; k=rsa; p=" + base64.StdEncoding.EncodeToString(der)
+
+	verifications, err := dkim.VerifyWithOptions(bytes.NewReader(signed), &dkim.VerifyOptions{
+		LookupTXT: func(domain string) ([]string, error) {
+			if domain != testDKIMSelector+"._domainkey."+testDKIMDomain {
+				return nil, errors.New("unknown domain " + domain)
+			}
+			return []string{record}, nil
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return verifications
+}
+
+func TestDKIMSignerSign(t *testing.T) {
+	tests := []struct {
+		name string
+		raw  func(t *testing.T) []byte
+	}{
+		{
+			name: "plain text",
+			raw: func(t *testing.T) []byte {
+				return mustBytes(t, Message{
+					From:    "EduTour <no-reply@edutour.test>",
+					To:      []string{"student@university.test"},
+					Subject: "Confirm your email",
+					Text:    "Follow the link: https://edutour.test/confirm?token=abc",
+				})
+			},
+		},
+		{
+			name: "multipart with non-ASCII headers",
+			raw: func(t *testing.T) []byte {
+				return mustBytes(t, Message{
+					From:    "ЭдуТур <no-reply@edutour.test>",
+					To:      []string{"Иван <ivan@university.test>", "anna@university.test"},
+					Subject: "Подтверждение почты",
+					Text:    "Перейдите по ссылке, чтобы подтвердить почту.\n\n" + strings.Repeat("длинная строка ", 20),
+					HTML:    "<p>Перейдите по <a href=\"https://edutour.test/confirm?token=abc\">ссылке</a></p>",
+				})
+			},
+		},
+		{
+			name: "bare LF, folded header and extra whitespace",
+			raw: func(t *testing.T) []byte {
+				return []byte("From: no-reply@edutour.test\n" +
+					"To: student@university.test,\n\tother@university.test\n" +
+					"Subject:   Spaces   and\ttabs  \n" +
+					"\n" +
+					"line with trailing spaces   \n" +
+					"  indented\tline\n" +
+					"\n\n\n")
+			},
+		},
+		{
+			name: "empty body",
+			raw: func(t *testing.T) []byte {
+				return []byte("From: no-reply@edutour.test\r\nSubject: Empty\r\n\r\n")
+			},
+		},
+	}
+
+	signer, key := newTestDKIMSigner(t)
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			signed, err := signer.Sign(tt.raw(t))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			verifications := verifyDKIM(t, signed, &key.PublicKey)
+			if len(verifications) != 1 {
+				t.Fatalf("got %d signatures, want 1", len(verifications))
+			}
+
+			v := verifications[0]
+			if v.Err != nil {
+				t.Fatalf("signature does not verify: %v", v.Err)
+			}
+			if v.Domain != testDKIMDomain {
+				t.Errorf("domain = %q, want %q", v.Domain, testDKIMDomain)
+			}
+			if v.HeaderKeys[0] != "from" {
+				t.Errorf("signed headers = %v, want From first", v.HeaderKeys)
+			}
+		})
+	}
+}
+
+func TestDKIMSignerSignTags(t *testing.T) {
+	signer, _ := newTestDKIMSigner(t)
+
+	signed, err := signer.Sign(mustBytes(t, Message{
+		From:    "no-reply@edutour.test",
+		To:      []string{"student@university.test"},
+		Subject: "Reset password",
+		Text:    "Your code is 123456",
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	msg, err := mail.ReadMessage(bytes.NewReader(signed))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tags := make(map[string]string)
+	for _, tag := range strings.Split(msg.Header.Get("DKIM-Signature"), ";") {
+		name, value, _ := strings.Cut(tag, "=")
+		tags[strings.TrimSpace(name)] = strings.Join(strings.Fields(value), "")
+	}
+
+	want := map[string]string{
+		"v": "1",
+		"a": "rsa-sha256",
+		"c": "relaxed/relaxed",
+		"d": testDKIMDomain,
+		"s": testDKIMSelector,
+		"t": "1700000000",
+		"h": "from:to:subject:date:message-id:mime-version:content-type",
+	}
+	for name, value := range want {
+		if tags[name] != value {
+			t.Errorf("%s= is %q, want %q", name, tags[name], value)
+		}
+	}
+
+	for _, line := range strings.Split(string(signed), "\r\n") {
+		if len(line) > 78 {
+			t.Errorf("line is longer than 78 characters: %q", line)
+		}
+	}
+}
+
+func TestDKIMSignerSignTampered(t *testing.T) {
+	signer, key := newTestDKIMSigner(t)
+
+	signed, err := signer.Sign(mustBytes(t, Message{
+		From:    "no-reply@edutour.test",
+		To:      []string{"student@university.test"},
+		Subject: "Confirm your email",
+		Text:    "https://edutour.test/confirm?token=abc",
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		old, new string
+	}{
+		{"body", "token=3Dabc", "token=3Devil"},
+		{"signed header", "Subject: Confirm your email", "Subject: Confirm your account"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if !bytes.Contains(signed, []byte(tt.old)) {
+				t.Fatalf("message does not contain %q", tt.old)
+			}
+			tampered := bytes.Replace(signed, []byte(tt.old), []byte(tt.new), 1)
+
+			verifications := verifyDKIM(t, tampered, &key.PublicKey)
+			if len(verifications) != 1 || verifications[0].Err == nil {
+				t.Fatal("tampered message verifies")
+			}
+		})
+	}
+
+	t.Run("other key", func(t *testing.T) {
+		otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		verifications := verifyDKIM(t, signed, &otherKey.PublicKey)
+		if len(verifications) != 1 || verifications[0].Err == nil {
+			t.Fatal("message verifies with another key")
+		}
+	})
+}
+
+func TestDKIMSignerSignWithoutFrom(t *testing.T) {
+	signer, _ := newTestDKIMSigner(t)
+
+	if _, err := signer.Sign([]byte("To: student@university.test\r\nSubject: Hi\r\n\r\nbody\r\n")); err == nil {
+		t.Fatal("message without From is signed")
+	}
+}
+
+// The examples are from RFC 6376 section 3.4.5.
+func TestRelaxedCanonicalization(t *testing.T) {
+	if got, want := relaxedHeader("A: X"), "a:X"; got != want {
+		t.Errorf("relaxedHeader = %q, want %q", got, want)
+	}
+	if got, want := relaxedHeader("B : Y\t\r\n\tZ  "), "b:Y Z"; got != want {
+		t.Errorf("relaxedHeader = %q, want %q", got, want)
+	}
+
+	body := []byte(" C \r\nD \t E\r\n\r\n\r\n")
+	if got, want := string(relaxedBody(body)), " C\r\nD E\r\n"; got != want {
+		t.Errorf("relaxedBody = %q, want %q", got, want)
+	}
+
+	if got := relaxedBody([]byte("\r\n\r\n")); len(got) != 0 {
+		t.Errorf("relaxedBody of an empty body = %q, want empty", got)
+	}
+}
+
+func TestParseDKIMKey(t *testing.T) {
+	key, err := LoadDKIMKey("testdata/dkim.pem")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pkcs8, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	parsed, err := ParseDKIMKey(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: pkcs8}))
+	if err != nil {
+		t.Fatalf("PKCS #8 key: %v", err)
+	}
+	if !parsed.Equal(key) {
+		t.Error("PKCS #8 key differs from the PKCS #1 one")
+	}
+
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecDER, err := x509.MarshalPKCS8PrivateKey(ecKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := ParseDKIMKey(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: ecDER})); err == nil {
+		t.Error("ECDSA key is accepted")
+	}
+	if _, err := ParseDKIMKey([]byte("not a key")); err == nil {
+		t.Error("garbage is accepted")
+	}
+}
+
+func mustBytes(t *testing.T, msg Message) []byte {
+	t.Helper()
+
+	raw, err := msg.Bytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return raw
+}
@@ -1,58 +1,29 @@
 package email
 
 import (
-	"strconv"
-
-	//"gopkg.in/gomail.v2"
-	"net/smtp"
-	"strings"
+	"context"
+	"net/mail"
 )
 
-type SMTPServer struct {
-	Host     string
-	Port     int
-	User     string
-	Password string
+// Mailer delivers email messages. A message without a sender is sent from the mailer's default one.
+type Mailer interface {
+	Send(ctx context.Context, msg Message) error
 }
 
-func NewSMTPServer(Host string, Port int, User string, Password string) *SMTPServer {
-	return &SMTPServer{
-		Host:     Host,
-		Port:     Port,
-		User:     User,
-		Password: Password,
+// prepare sets the default sender and renders the message.
+func prepare(msg *Message, from string) ([]byte, error) {
+	if msg.From == "" {
+		msg.From = from
 	}
-}
 
-type EmailManager struct {
-	SMTP        *SMTPServer
-	SourceEmail string
+	return msg.Bytes()
 }
 
-func NewEmailManager(SMTP *SMTPServer, source string) *EmailManager {
-
-	return &EmailManager{
-		SMTP:        SMTP,
-		SourceEmail: source,
+// addressOf strips the display name, e.g. "EduTour <no-reply@example.com>" becomes "no-reply@example.com".
+func addressOf(addr string) (string, error) {
+	parsed, err := mail.ParseAddress(addr)
+	if err != nil {
+		return "", err
 	}
-}
-
-type SMTPManager interface {
-	SendMail(to string, subject string, content string) error
-}
-
-func (m *EmailManager) SendMail(to []string, subject string, content string) error {
-
-	auth := smtp.PlainAuth("", m.SMTP.User, m.SMTP.Password, m.SMTP.Host)
-
-	msg := []byte(
-		"From: " + m.SMTP.User + "\r\n" +
-			"To: " + strings.Join(to, ", ") + "\r\n" +
-			"Subject: " + subject + "\r\n" +
-			"\r\n" +
-			content + "\r\n")
-
-	err := smtp.SendMail(m.SMTP.Host+":"+strconv.Itoa(m.SMTP.Port), auth, m.SourceEmail, to, msg)
-
-	return err
+	return parsed.Address, nil
 }
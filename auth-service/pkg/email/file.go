@@ -0,0 +1,61 @@
+package email
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"time"
+)
+
+// FileMailer drops messages into a maildir instead of sending them. For local development:
+// the directory opens in any mail client that reads maildirs, and every file in new/ is a plain .eml message.
+type FileMailer struct {
+	dir      string
+	from     string
+	hostname string
+	seq      atomic.Uint64
+}
+
+func NewFileMailer(dir string, from string) (*FileMailer, error) {
+	for _, sub := range []string{"tmp", "new", "cur"} {
+		if err := os.MkdirAll(filepath.Join(dir, sub), 0o755); err != nil {
+			return nil, err
+		}
+	}
+
+	hostname, err := os.Hostname()
+	if err != nil {
+		hostname = "localhost"
+	}
+
+	return &FileMailer{
+		dir:      dir,
+		from:     from,
+		hostname: hostname,
+	}, nil
+}
+
+// Dir returns the maildir the messages are dropped into.
+func (m *FileMailer) Dir() string {
+	return m.dir
+}
+
+// Send writes the message to tmp/ and then moves it to new/, so readers never see a partial file.
+func (m *FileMailer) Send(ctx context.Context, msg Message) error {
+	raw, err := prepare(&msg, m.from)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	name := fmt.Sprintf("%d.%d_%d_%d.%s.eml", now.Unix(), now.UnixNano(), os.Getpid(), m.seq.Add(1), m.hostname)
+
+	tmpPath := filepath.Join(m.dir, "tmp", name)
+	if err := os.WriteFile(tmpPath, raw, 0o644); err != nil {
+		return err
+	}
+
+	return os.Rename(tmpPath, filepath.Join(m.dir, "new", name))
+}
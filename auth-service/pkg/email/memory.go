@@ -0,0 +1,111 @@
+package email
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// StoredMessage is a message kept by MemoryMailer.
+type StoredMessage struct {
+	ID      int
+	Message Message
+	// Raw is the MIME source the message would be sent as
+	Raw    []byte
+	SentAt time.Time
+}
+
+// MemoryMailer keeps messages in memory, so tests can assert on what was sent
+// and the development mailbox can show it.
+type MemoryMailer struct {
+	from     string
+	mu       sync.Mutex
+	lastID   int
+	messages []StoredMessage
+}
+
+func NewMemoryMailer(from string) *MemoryMailer {
+	return &MemoryMailer{from: from}
+}
+
+// Send keeps the message if it renders, so a message a real mailer would reject fails here too.
+func (m *MemoryMailer) Send(ctx context.Context, msg Message) error {
+	raw, err := prepare(&msg, m.from)
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.lastID++
+	m.messages = append(m.messages, StoredMessage{
+		ID:      m.lastID,
+		Message: msg,
+		Raw:     raw,
+		SentAt:  time.Now(),
+	})
+	return nil
+}
+
+// Messages returns the messages sent so far, oldest first.
+func (m *MemoryMailer) Messages() []Message {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	messages := make([]Message, 0, len(m.messages))
+	for _, stored := range m.messages {
+		messages = append(messages, stored.Message)
+	}
+	return messages
+}
+
+// Stored returns the messages sent so far with their sources, newest first.
+func (m *MemoryMailer) Stored() []StoredMessage {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	stored := make([]StoredMessage, 0, len(m.messages))
+	for i := len(m.messages) - 1; i >= 0; i-- {
+		stored = append(stored, m.messages[i])
+	}
+	return stored
+}
+
+// Get returns the message with the id.
+func (m *MemoryMailer) Get(id int) (StoredMessage, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, stored := range m.messages {
+		if stored.ID == id {
+			return stored, true
+		}
+	}
+
+	return StoredMessage{}, false
+}
+
+// Last returns the latest message sent to the address.
+func (m *MemoryMailer) Last(to string) (Message, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for i := len(m.messages) - 1; i >= 0; i-- {
+		for _, addr := range m.messages[i].Message.To {
+			if addr == to {
+				return m.messages[i].Message, true
+			}
+		}
+	}
+
+	return Message{}, false
+}
+
+// Reset forgets the messages sent so far.
+func (m *MemoryMailer) Reset() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.messages = nil
+}
@@ -0,0 +1,131 @@
+package email
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"net/textproto"
+	"strings"
+	"time"
+)
+
+// Message is an email with a plain text body and, optionally, an HTML alternative of it.
+type Message struct {
+	From    string
+	To      []string
+	Subject string
+	Text    string
+	HTML    string
+}
+
+// Bytes renders the message in the RFC 5322 format. Non-ASCII headers are encoded, and the bodies
+// are sent as quoted-printable UTF-8, in a multipart/alternative when there is an HTML version.
+func (m Message) Bytes() ([]byte, error) {
+	if len(m.To) == 0 {
+		return nil, errors.New("email has no recipients")
+	}
+
+	messageID, err := newMessageID(m.From)
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+
+	writeHeader(&buf, "From", formatAddress(m.From))
+	to := make([]string, 0, len(m.To))
+	for _, addr := range m.To {
+		to = append(to, formatAddress(addr))
+	}
+	writeHeader(&buf, "To", strings.Join(to, ", "))
+	writeHeader(&buf, "Subject", mime.QEncoding.Encode("utf-8", m.Subject))
+	writeHeader(&buf, "Date", time.Now().Format(time.RFC1123Z))
+	writeHeader(&buf, "Message-ID", messageID)
+	writeHeader(&buf, "MIME-Version", "1.0")
+
+	if m.HTML == "" {
+		writeHeader(&buf, "Content-Type", "text/plain; charset=utf-8")
+		writeHeader(&buf, "Content-Transfer-Encoding", "quoted-printable")
+		buf.WriteString("\r\n")
+
+		if err := writeQuotedPrintable(&buf, m.Text); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	}
+
+	mw := multipart.NewWriter(&buf)
+
+	writeHeader(&buf, "Content-Type", mime.FormatMediaType("multipart/alternative", map[string]string{"boundary": mw.Boundary()}))
+	buf.WriteString("\r\n")
+
+	// the preferred version goes last
+	for _, part := range []struct {
+		contentType string
+		body        string
+	}{
+		{"text/plain; charset=utf-8", m.Text},
+		{"text/html; charset=utf-8", m.HTML},
+	} {
+		w, err := mw.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {part.contentType},
+			"Content-Transfer-Encoding": {"quoted-printable"},
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		if err := writeQuotedPrintable(w, part.body); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := mw.Close(); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+func writeHeader(buf *bytes.Buffer, key string, value string) {
+	buf.WriteString(key + ": " + value + "\r\n")
+}
+
+func writeQuotedPrintable(w interface{ Write([]byte) (int, error) }, body string) error {
+	qp := quotedprintable.NewWriter(w)
+	if _, err := qp.Write([]byte(body)); err != nil {
+		return err
+	}
+	return qp.Close()
+}
+
+// formatAddress encodes the display name of an address like "Иван <ivan@example.com>".
+func formatAddress(addr string) string {
+	parsed, err := mail.ParseAddress(addr)
+	if err != nil {
+		return addr
+	}
+	return parsed.String()
+}
+
+// newMessageID returns a unique Message-ID in the domain of the sender.
+func newMessageID(from string) (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+
+	domain := "localhost"
+	if parsed, err := mail.ParseAddress(from); err == nil {
+		if i := strings.LastIndex(parsed.Address, "@"); i >= 0 {
+			domain = parsed.Address[i+1:]
+		}
+	}
+
+	return "<" + hex.EncodeToString(buf) + "@" + domain + ">", nil
+}
@@ -0,0 +1,146 @@
+package email
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/smtp"
+	"strconv"
+	"time"
+)
+
+const (
+	// TLSModeStartTLS upgrades a plain connection with STARTTLS, usually on port 587.
+	TLSModeStartTLS = "starttls"
+	// TLSModeImplicit speaks TLS from the start, usually on port 465.
+	TLSModeImplicit = "tls"
+	// TLSModeNone sends everything in the clear. For local test servers only.
+	TLSModeNone = "none"
+)
+
+type SMTPConfig struct {
+	Host     string
+	Port     int
+	User     string
+	Password string
+	// From is the default sender
+	From    string
+	TLSMode string
+	// Timeout limits a whole delivery, unless the context has an earlier deadline
+	Timeout time.Duration
+	// DKIM signs outgoing messages, nil sends them unsigned
+	DKIM *DKIMSigner
+}
+
+// SMTPMailer sends messages through an SMTP server, opening a connection for every message.
+type SMTPMailer struct {
+	cfg SMTPConfig
+}
+
+func NewSMTPMailer(cfg SMTPConfig) (*SMTPMailer, error) {
+	switch cfg.TLSMode {
+	case TLSModeStartTLS, TLSModeImplicit, TLSModeNone:
+	default:
+		return nil, fmt.Errorf("unknown smtp tls mode %q", cfg.TLSMode)
+	}
+
+	return &SMTPMailer{cfg: cfg}, nil
+}
+
+func (m *SMTPMailer) Send(ctx context.Context, msg Message) error {
+	raw, err := prepare(&msg, m.cfg.From)
+	if err != nil {
+		return err
+	}
+
+	if m.cfg.DKIM != nil {
+		if raw, err = m.cfg.DKIM.Sign(raw); err != nil {
+			return err
+		}
+	}
+
+	from, err := addressOf(msg.From)
+	if err != nil {
+		return err
+	}
+
+	if m.cfg.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, m.cfg.Timeout)
+		defer cancel()
+	}
+
+	conn, err := m.dial(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if deadline, ok := ctx.Deadline(); ok {
+		if err := conn.SetDeadline(deadline); err != nil {
+			return err
+		}
+	}
+
+	c, err := smtp.NewClient(conn, m.cfg.Host)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+
+	if m.cfg.TLSMode == TLSModeStartTLS {
+		if ok, _ := c.Extension("STARTTLS"); !ok {
+			return errors.New("smtp server doesn't support STARTTLS")
+		}
+		if err := c.StartTLS(&tls.Config{ServerName: m.cfg.Host}); err != nil {
+			return err
+		}
+	}
+
+	if m.cfg.User != "" {
+		if err := c.Auth(smtp.PlainAuth("", m.cfg.User, m.cfg.Password, m.cfg.Host)); err != nil {
+			return err
+		}
+	}
+
+	if err := c.Mail(from); err != nil {
+		return err
+	}
+
+	for _, to := range msg.To {
+		addr, err := addressOf(to)
+		if err != nil {
+			return err
+		}
+		if err := c.Rcpt(addr); err != nil {
+			return err
+		}
+	}
+
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(raw); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+
+	return c.Quit()
+}
+
+func (m *SMTPMailer) dial(ctx context.Context) (net.Conn, error) {
+	addr := net.JoinHostPort(m.cfg.Host, strconv.Itoa(m.cfg.Port))
+
+	if m.cfg.TLSMode == TLSModeImplicit {
+		dialer := &tls.Dialer{Config: &tls.Config{ServerName: m.cfg.Host}}
+		return dialer.DialContext(ctx, "tcp", addr)
+	}
+
+	var dialer net.Dialer
+	return dialer.DialContext(ctx, "tcp", addr)
+}
@@ -0,0 +1,72 @@
+package encrypt
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+)
+
+// Encryptor encrypts small secrets that have to be read back, e.g. TOTP seeds.
+type Encryptor interface {
+	Encrypt(plaintext string) (string, error)
+	Decrypt(ciphertext string) (string, error)
+}
+
+// AESGCM encrypts with AES-256-GCM. The key is derived from a passphrase with SHA-256.
+type AESGCM struct {
+	aead cipher.AEAD
+}
+
+func NewAESGCM(passphrase string) (*AESGCM, error) {
+	if len(passphrase) < 32 {
+		return nil, errors.New("encryption key must be at least 32 bytes")
+	}
+
+	key := sha256.Sum256([]byte(passphrase))
+
+	block, err := aes.NewCipher(key[:])
+	if err != nil {
+		return nil, err
+	}
+
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+
+	return &AESGCM{aead: aead}, nil
+}
+
+// Encrypt returns base64 of nonce followed by the sealed plaintext.
+func (e *AESGCM) Encrypt(plaintext string) (string, error) {
+	nonce := make([]byte, e.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+
+	sealed := e.aead.Seal(nonce, nonce, []byte(plaintext), nil)
+
+	return base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+func (e *AESGCM) Decrypt(ciphertext string) (string, error) {
+	data, err := base64.StdEncoding.DecodeString(ciphertext)
+	if err != nil {
+		return "", err
+	}
+
+	nonceSize := e.aead.NonceSize()
+	if len(data) < nonceSize {
+		return "", errors.New("ciphertext is too short")
+	}
+
+	plaintext, err := e.aead.Open(nil, data[:nonceSize], data[nonceSize:], nil)
+	if err != nil {
+		return "", err
+	}
+
+	return string(plaintext), nil
+}
@@ -0,0 +1,150 @@
+package hash
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+)
+
+const argon2idPrefix = "$argon2id$"
+
+// Limits of the parameters accepted from stored hashes. Zero time or threads make argon2 panic,
+// and a tampered hash must not make a sign-in allocate gigabytes.
+const (
+	argon2MaxTime      = 64
+	argon2MaxMemoryKiB = 4 << 20
+	argon2MinSaltLen   = 8
+	argon2MinKeyLen    = 16
+	argon2MaxKeyLen    = 128
+)
+
+var ErrInvalidHash = errors.New("invalid password hash format")
+
+// Argon2Params tunes the cost of Argon2id hashing.
+type Argon2Params struct {
+	Time      uint32
+	MemoryKiB uint32
+	Threads   uint8
+	SaltLen   uint32
+	KeyLen    uint32
+}
+
+// Argon2Hasher hashes passwords with Argon2id and a random per-password salt.
+// Hashes are stored in PHC string format:
+//
+//	$argon2id$v=19$m=65536,t=3,p=2$<salt>$<key>
+//
+// Hashes in any other format are passed to the legacy hasher, if set.
+type Argon2Hasher struct {
+	params Argon2Params
+	legacy PasswordHasher
+}
+
+func NewArgon2Hasher(params Argon2Params, legacy PasswordHasher) *Argon2Hasher {
+	if params.SaltLen == 0 {
+		params.SaltLen = 16
+	}
+	if params.KeyLen == 0 {
+		params.KeyLen = 32
+	}
+
+	return &Argon2Hasher{
+		params: params,
+		legacy: legacy,
+	}
+}
+
+// Hash creates Argon2id hash of given password.
+func (h *Argon2Hasher) Hash(password string) (string, error) {
+	salt := make([]byte, h.params.SaltLen)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+
+	key := argon2.IDKey([]byte(password), salt, h.params.Time, h.params.MemoryKiB, h.params.Threads, h.params.KeyLen)
+
+	return fmt.Sprintf("%sv=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2idPrefix, argon2.Version,
+		h.params.MemoryKiB, h.params.Time, h.params.Threads,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key)), nil
+}
+
+// Verify checks password against encodedHash. Hashes made with other parameters
+// or by the legacy hasher are reported as needing rehash.
+func (h *Argon2Hasher) Verify(password string, encodedHash string) (bool, bool, error) {
+	if !strings.HasPrefix(encodedHash, argon2idPrefix) {
+		if h.legacy == nil {
+			return false, false, ErrInvalidHash
+		}
+		ok, _, err := h.legacy.Verify(password, encodedHash)
+		return ok, ok, err
+	}
+
+	params, salt, key, err := decodeArgon2Hash(encodedHash)
+	if err != nil {
+		return false, false, err
+	}
+
+	otherKey := argon2.IDKey([]byte(password), salt, params.Time, params.MemoryKiB, params.Threads, params.KeyLen)
+
+	if subtle.ConstantTimeCompare(key, otherKey) != 1 {
+		return false, false, nil
+	}
+
+	needsRehash := params.Time != h.params.Time ||
+		params.MemoryKiB != h.params.MemoryKiB ||
+		params.Threads != h.params.Threads ||
+		params.SaltLen != h.params.SaltLen ||
+		params.KeyLen != h.params.KeyLen
+
+	return true, needsRehash, nil
+}
+
+func decodeArgon2Hash(encodedHash string) (Argon2Params, []byte, []byte, error) {
+	// "", "argon2id", "v=19", "m=...,t=...,p=...", salt, key
+	parts := strings.Split(encodedHash, "$")
+	if len(parts) != 6 {
+		return Argon2Params{}, nil, nil, ErrInvalidHash
+	}
+
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil {
+		return Argon2Params{}, nil, nil, ErrInvalidHash
+	}
+	if version != argon2.Version {
+		return Argon2Params{}, nil, nil, fmt.Errorf("unsupported argon2 version: %d", version)
+	}
+
+	var params Argon2Params
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &params.MemoryKiB, &params.Time, &params.Threads); err != nil {
+		return Argon2Params{}, nil, nil, ErrInvalidHash
+	}
+
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return Argon2Params{}, nil, nil, ErrInvalidHash
+	}
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil {
+		return Argon2Params{}, nil, nil, ErrInvalidHash
+	}
+
+	params.SaltLen = uint32(len(salt))
+	params.KeyLen = uint32(len(key))
+
+	if params.Time == 0 || params.Time > argon2MaxTime ||
+		params.Threads == 0 ||
+		params.MemoryKiB < 8*uint32(params.Threads) || params.MemoryKiB > argon2MaxMemoryKiB ||
+		params.SaltLen < argon2MinSaltLen ||
+		params.KeyLen < argon2MinKeyLen || params.KeyLen > argon2MaxKeyLen {
+		return Argon2Params{}, nil, nil, ErrInvalidHash
+	}
+
+	return params, salt, key, nil
+}
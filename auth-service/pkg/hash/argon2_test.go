@@ -0,0 +1,128 @@
+package hash
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+var testArgon2Params = Argon2Params{Time: 1, MemoryKiB: 64, Threads: 1}
+
+func TestArgon2RoundTrip(t *testing.T) {
+	h := NewArgon2Hasher(testArgon2Params, nil)
+
+	encoded, err := h.Hash("correct horse")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(encoded, "$argon2id$v=19$m=64,t=1,p=1$") {
+		t.Fatalf("unexpected hash format %q", encoded)
+	}
+
+	other, err := h.Hash("correct horse")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if other == encoded {
+		t.Fatal("two hashes of a password are equal, the salt isn't random")
+	}
+
+	tests := []struct {
+		name            string
+		hasher          *Argon2Hasher
+		password        string
+		wantOK          bool
+		wantNeedsRehash bool
+	}{
+		{name: "right password", hasher: h, password: "correct horse", wantOK: true},
+		{name: "wrong password", hasher: h, password: "battery staple"},
+		{
+			name:            "stronger parameters",
+			hasher:          NewArgon2Hasher(Argon2Params{Time: 2, MemoryKiB: 64, Threads: 1}, nil),
+			password:        "correct horse",
+			wantOK:          true,
+			wantNeedsRehash: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ok, needsRehash, err := tt.hasher.Verify(tt.password, encoded)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if ok != tt.wantOK || needsRehash != tt.wantNeedsRehash {
+				t.Fatalf("Verify = %v, %v, want %v, %v", ok, needsRehash, tt.wantOK, tt.wantNeedsRehash)
+			}
+		})
+	}
+}
+
+func TestArgon2LegacyHash(t *testing.T) {
+	legacy := NewSHA256Hasher("salt")
+
+	encoded, err := legacy.Hash("correct horse")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name            string
+		legacy          PasswordHasher
+		password        string
+		wantOK          bool
+		wantNeedsRehash bool
+		wantErr         error
+	}{
+		{name: "right password", legacy: legacy, password: "correct horse", wantOK: true, wantNeedsRehash: true},
+		{name: "wrong password", legacy: legacy, password: "battery staple"},
+		{name: "no legacy hasher", password: "correct horse", wantErr: ErrInvalidHash},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ok, needsRehash, err := NewArgon2Hasher(testArgon2Params, tt.legacy).Verify(tt.password, encoded)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("err = %v, want %v", err, tt.wantErr)
+			}
+			if ok != tt.wantOK || needsRehash != tt.wantNeedsRehash {
+				t.Fatalf("Verify = %v, %v, want %v, %v", ok, needsRehash, tt.wantOK, tt.wantNeedsRehash)
+			}
+		})
+	}
+}
+
+func TestArgon2InvalidHash(t *testing.T) {
+	const (
+		salt = "c2FsdHNhbHRzYWx0c2FsdA"
+		key  = "a2V5a2V5a2V5a2V5a2V5a2V5a2V5a2V5a2V5a2V5a2U"
+	)
+
+	tests := []struct {
+		name string
+		hash string
+	}{
+		{name: "missing part", hash: "$argon2id$v=19$m=64,t=1,p=1$" + salt},
+		{name: "zero threads", hash: "$argon2id$v=19$m=64,t=1,p=0$" + salt + "$" + key},
+		{name: "zero time", hash: "$argon2id$v=19$m=64,t=0,p=1$" + salt + "$" + key},
+		{name: "too little memory", hash: "$argon2id$v=19$m=4,t=1,p=1$" + salt + "$" + key},
+		{name: "too much memory", hash: "$argon2id$v=19$m=1073741824,t=1,p=1$" + salt + "$" + key},
+		{name: "too many passes", hash: "$argon2id$v=19$m=64,t=1000,p=1$" + salt + "$" + key},
+		{name: "threads out of range", hash: "$argon2id$v=19$m=64,t=1,p=256$" + salt + "$" + key},
+		{name: "short salt", hash: "$argon2id$v=19$m=64,t=1,p=1$c2FsdA$" + key},
+		{name: "short key", hash: "$argon2id$v=19$m=64,t=1,p=1$" + salt + "$a2V5"},
+		{name: "bad base64", hash: "$argon2id$v=19$m=64,t=1,p=1$!!!$" + key},
+		{name: "other version", hash: "$argon2id$v=16$m=64,t=1,p=1$" + salt + "$" + key},
+	}
+
+	h := NewArgon2Hasher(testArgon2Params, nil)
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ok, _, err := h.Verify("correct horse", tt.hash)
+			if err == nil || ok {
+				t.Fatalf("Verify = %v, %v, want an error", ok, err)
+			}
+		})
+	}
+}
@@ -2,17 +2,25 @@ package hash
 
 import (
 	"crypto/sha256"
+	"crypto/subtle"
 	"encoding/hex"
 	"fmt"
 )
 
 // PasswordHasher provides hashing logic to securely store passwords.
 type PasswordHasher interface {
-	Hash(str string) (string, error)
-	SimpleHash(str string) (string, error)
+	// Hash creates a self-describing hash of the given password.
+	Hash(password string) (string, error)
+	// Verify checks password against encodedHash. needsRehash reports that
+	// the hash was produced by an outdated algorithm or parameters and
+	// should be replaced with a fresh Hash of the same password.
+	Verify(password string, encodedHash string) (ok bool, needsRehash bool, err error)
 }
 
-// SHA256Hasher uses SHA1 to hash passwords with provided salt.
+// SHA256Hasher uses SHA256 to hash passwords with provided salt.
+//
+// Deprecated: the salt is global and is not mixed into the digest. It is kept
+// only to verify hashes created before the switch to Argon2Hasher.
 type SHA256Hasher struct {
 	salt string
 }
@@ -32,6 +40,20 @@ func (h *SHA256Hasher) Hash(str string) (string, error) {
 	return fmt.Sprintf("%x", hash.Sum([]byte(h.salt))), nil
 }
 
+// Verify compares password with a hash created by Hash. SHA256 hashes always need rehash.
+func (h *SHA256Hasher) Verify(password string, encodedHash string) (bool, bool, error) {
+	hash, err := h.Hash(password)
+	if err != nil {
+		return false, false, err
+	}
+
+	if subtle.ConstantTimeCompare([]byte(hash), []byte(encodedHash)) != 1 {
+		return false, false, nil
+	}
+
+	return true, true, nil
+}
+
 func (h *SHA256Hasher) SimpleHash(str string) (string, error) {
 	// Создание нового хэш-объекта SHA-256
 	hash := sha256.New()
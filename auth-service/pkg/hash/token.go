@@ -0,0 +1,36 @@
+package hash
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+)
+
+// TokenHasher hashes high-entropy bearer tokens (refresh, confirmation, reset)
+// before they are stored or looked up.
+type TokenHasher interface {
+	Hash(token string) string
+}
+
+// HMACHasher uses HMAC-SHA256 with a server-side key, so a leaked table
+// can't be checked against guessed tokens without the key.
+type HMACHasher struct {
+	key []byte
+}
+
+func NewHMACHasher(key string) (*HMACHasher, error) {
+	if len(key) < 32 {
+		return nil, errors.New("token hash key must be at least 32 bytes")
+	}
+
+	return &HMACHasher{key: []byte(key)}, nil
+}
+
+// Hash returns hex-encoded HMAC-SHA256 of token.
+func (h *HMACHasher) Hash(token string) string {
+	mac := hmac.New(sha256.New, h.key)
+	mac.Write([]byte(token))
+
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,79 @@
+package otp
+
+import (
+	"bytes"
+	"crypto/subtle"
+	"image/png"
+	"time"
+
+	"github.com/pquerna/otp"
+	"github.com/pquerna/otp/totp"
+)
+
+const (
+	period = 30
+	// skew is the number of periods before and after now a code is accepted for
+	skew = 1
+
+	qrSize = 256
+)
+
+// TOTPKey is a freshly generated TOTP secret with what the user needs to enrol it.
+type TOTPKey struct {
+	Secret string
+	URI    string
+	QRCode []byte // PNG
+}
+
+// GenerateTOTP creates a new secret for the account.
+func GenerateTOTP(issuer string, account string) (TOTPKey, error) {
+	key, err := totp.Generate(totp.GenerateOpts{
+		Issuer:      issuer,
+		AccountName: account,
+		Period:      period,
+	})
+	if err != nil {
+		return TOTPKey{}, err
+	}
+
+	img, err := key.Image(qrSize, qrSize)
+	if err != nil {
+		return TOTPKey{}, err
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return TOTPKey{}, err
+	}
+
+	return TOTPKey{
+		Secret: key.Secret(),
+		URI:    key.URL(),
+		QRCode: buf.Bytes(),
+	}, nil
+}
+
+// ValidateTOTP checks code against secret at time t. On success it returns the
+// time step the code belongs to, so callers can reject a code that was already used.
+func ValidateTOTP(secret string, code string, t time.Time) (int64, bool) {
+	opts := totp.ValidateOpts{
+		Period:    period,
+		Digits:    otp.DigitsSix,
+		Algorithm: otp.AlgorithmSHA1,
+	}
+
+	step := t.Unix() / period
+
+	for i := int64(-skew); i <= skew; i++ {
+		expected, err := totp.GenerateCodeCustom(secret, time.Unix((step+i)*period, 0), opts)
+		if err != nil {
+			return 0, false
+		}
+
+		if subtle.ConstantTimeCompare([]byte(expected), []byte(code)) == 1 {
+			return step + i, true
+		}
+	}
+
+	return 0, false
+}
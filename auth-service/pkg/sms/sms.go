@@ -0,0 +1,73 @@
+package sms
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+)
+
+// SMSSender delivers text messages to phone numbers in E.164 format.
+type SMSSender interface {
+	Send(ctx context.Context, phone string, text string) error
+}
+
+// LogSender writes messages to the log instead of sending them. For development only:
+// the log then contains one-time passwords.
+type LogSender struct {
+	logger *slog.Logger
+}
+
+func NewLogSender(logger *slog.Logger) *LogSender {
+	return &LogSender{logger: logger}
+}
+
+func (s *LogSender) Send(ctx context.Context, phone string, text string) error {
+	s.logger.Info("sms", slog.String("phone", phone), slog.String("text", text))
+	return nil
+}
+
+// Message is a text message kept by MemorySender.
+type Message struct {
+	Phone string
+	Text  string
+}
+
+// MemorySender keeps messages in memory, so tests can read the codes that were sent.
+type MemorySender struct {
+	mu       sync.Mutex
+	messages []Message
+}
+
+func NewMemorySender() *MemorySender {
+	return &MemorySender{}
+}
+
+func (s *MemorySender) Send(ctx context.Context, phone string, text string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.messages = append(s.messages, Message{Phone: phone, Text: text})
+	return nil
+}
+
+// Messages returns the messages sent so far, oldest first.
+func (s *MemorySender) Messages() []Message {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]Message(nil), s.messages...)
+}
+
+// Last returns the latest message sent to phone.
+func (s *MemorySender) Last(phone string) (Message, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i := len(s.messages) - 1; i >= 0; i-- {
+		if s.messages[i].Phone == phone {
+			return s.messages[i], true
+		}
+	}
+
+	return Message{}, false
+}
@@ -0,0 +1,95 @@
+package sms
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const smsRuSendURL = "https://sms.ru/sms/send"
+
+type SMSRuConfig struct {
+	APIID string
+	// From is the approved sender name, the default sender of the account is used if it is empty
+	From    string
+	Timeout time.Duration
+}
+
+// SMSRuSender sends messages through the sms.ru HTTP API.
+type SMSRuSender struct {
+	cfg     SMSRuConfig
+	client  *http.Client
+	sendURL string
+}
+
+func NewSMSRuSender(cfg SMSRuConfig) (*SMSRuSender, error) {
+	if cfg.APIID == "" {
+		return nil, fmt.Errorf("sms.ru api id is not set")
+	}
+
+	return &SMSRuSender{
+		cfg:     cfg,
+		client:  &http.Client{Timeout: cfg.Timeout},
+		sendURL: smsRuSendURL,
+	}, nil
+}
+
+// smsRuResponse is the part of the sms.ru answer we check: the request as a whole and every message.
+type smsRuResponse struct {
+	Status     string `json:"status"`
+	StatusCode int    `json:"status_code"`
+	StatusText string `json:"status_text"`
+	SMS        map[string]struct {
+		Status     string `json:"status"`
+		StatusCode int    `json:"status_code"`
+		StatusText string `json:"status_text"`
+	} `json:"sms"`
+}
+
+func (s *SMSRuSender) Send(ctx context.Context, phone string, text string) error {
+	form := url.Values{}
+	form.Set("api_id", s.cfg.APIID)
+	form.Set("to", strings.TrimPrefix(phone, "+"))
+	form.Set("msg", text)
+	form.Set("json", "1")
+	if s.cfg.From != "" {
+		form.Set("from", s.cfg.From)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.sendURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("sms.ru responded with %s", resp.Status)
+	}
+
+	var res smsRuResponse
+	if err := json.NewDecoder(resp.Body).Decode(&res); err != nil {
+		return fmt.Errorf("cannot decode sms.ru response: %w", err)
+	}
+
+	if res.Status != "OK" {
+		return fmt.Errorf("sms.ru rejected the request: %d %s", res.StatusCode, res.StatusText)
+	}
+
+	for _, message := range res.SMS {
+		if message.Status != "OK" {
+			return fmt.Errorf("sms.ru rejected the message: %d %s", message.StatusCode, message.StatusText)
+		}
+	}
+
+	return nil
+}
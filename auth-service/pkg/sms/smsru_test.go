@@ -0,0 +1,62 @@
+package sms
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestSMSRuSender(t *testing.T) {
+	tests := []struct {
+		name     string
+		response string
+		wantErr  bool
+	}{
+		{
+			name:     "sent",
+			response: `{"status":"OK","status_code":100,"sms":{"79991234567":{"status":"OK","status_code":100,"sms_id":"1"}}}`,
+		},
+		{
+			name:     "request rejected",
+			response: `{"status":"ERROR","status_code":200,"status_text":"wrong api_id"}`,
+			wantErr:  true,
+		},
+		{
+			name:     "message rejected",
+			response: `{"status":"OK","status_code":100,"sms":{"79991234567":{"status":"ERROR","status_code":207,"status_text":"no route"}}}`,
+			wantErr:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got map[string]string
+
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if err := r.ParseForm(); err != nil {
+					t.Error(err)
+				}
+				got = map[string]string{"api_id": r.PostForm.Get("api_id"), "to": r.PostForm.Get("to"), "msg": r.PostForm.Get("msg")}
+				w.Write([]byte(tt.response))
+			}))
+			defer server.Close()
+
+			s, err := NewSMSRuSender(SMSRuConfig{APIID: "key", Timeout: time.Second})
+			if err != nil {
+				t.Fatal(err)
+			}
+			s.sendURL = server.URL
+
+			err = s.Send(context.Background(), "+79991234567", "code: 123456")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("got error %v, want error %v", err, tt.wantErr)
+			}
+
+			if got["api_id"] != "key" || got["to"] != "79991234567" || got["msg"] != "code: 123456" {
+				t.Fatalf("unexpected request %v", got)
+			}
+		})
+	}
+}
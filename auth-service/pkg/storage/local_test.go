@@ -0,0 +1,61 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestLocalStorage(t *testing.T) {
+	ctx := context.Background()
+
+	s, err := NewLocalStorage(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.Put(ctx, "applications/1/scan.pdf", strings.NewReader("document")); err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := s.Get(ctx, "applications/1/scan.pdf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := io.ReadAll(r)
+	r.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "document" {
+		t.Fatalf("got %q, want %q", data, "document")
+	}
+
+	if err := s.Delete(ctx, "applications/1/scan.pdf"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Get(ctx, "applications/1/scan.pdf"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("got %v after delete, want ErrNotFound", err)
+	}
+	if err := s.Delete(ctx, "applications/1/scan.pdf"); err != nil {
+		t.Fatalf("deleting a missing object: %v", err)
+	}
+}
+
+func TestValidateKey(t *testing.T) {
+	valid := []string{"scan.pdf", "applications/1/scan.pdf", "a/b_c-d.e"}
+	for _, key := range valid {
+		if err := ValidateKey(key); err != nil {
+			t.Errorf("ValidateKey(%q) = %v, want nil", key, err)
+		}
+	}
+
+	invalid := []string{"", "/etc/passwd", "../secret", "a/../../b", "a//b", "a/", ".hidden", "a/./b", `a\b`, "a b"}
+	for _, key := range invalid {
+		if err := ValidateKey(key); !errors.Is(err, ErrInvalidKey) {
+			t.Errorf("ValidateKey(%q) = %v, want ErrInvalidKey", key, err)
+		}
+	}
+}
@@ -0,0 +1,45 @@
+// Package storage keeps binary objects, e.g. uploaded documents, under string keys.
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"regexp"
+	"strings"
+)
+
+var (
+	ErrNotFound   = errors.New("object doesn't exists")
+	ErrInvalidKey = errors.New("object key is invalid")
+)
+
+// keySegmentRegexp is what a key may have between slashes, so keys are also safe file paths.
+var keySegmentRegexp = regexp.MustCompile(`^[A-Za-z0-9_-][A-Za-z0-9._-]*$`)
+
+// BlobStorage keeps objects under keys made of slash-separated segments of latin letters,
+// digits, '.', '_' and '-', e.g. applications/12/scan.pdf.
+type BlobStorage interface {
+	// Put stores the object, replacing one with the same key.
+	Put(ctx context.Context, key string, r io.Reader) error
+	// Get opens the object for reading, ErrNotFound if there is none.
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	// Delete deletes the object. Deleting a missing object is not an error.
+	Delete(ctx context.Context, key string) error
+}
+
+// ValidateKey returns ErrInvalidKey unless key is made of valid segments.
+func ValidateKey(key string) error {
+	if key == "" {
+		return ErrInvalidKey
+	}
+
+	for _, segment := range strings.Split(key, "/") {
+		// a segment starting with '.' would allow "." and ".."
+		if !keySegmentRegexp.MatchString(segment) {
+			return ErrInvalidKey
+		}
+	}
+
+	return nil
+}